@@ -0,0 +1,198 @@
+package acme
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	challengeHTTP01 = "http-01"
+	challengeDNS01  = "dns-01"
+
+	validationTimeout = 30 * time.Second
+	// Limit of the http-01 response read
+	maxKeyAuthorizationSize = 1024
+	// Limit of the http-01 redirects followed
+	maxRedirects = 10
+)
+
+// The key authorization proving the client controls the account key (RFC 8555 section 8.1)
+func keyAuthorization(token string, key *jose.JSONWebKey) string {
+	return token + "." + encode(thumbprint(key))
+}
+
+// Validate the challenge and record the result in the order.
+// It's run in the background after the client responds to the challenge.
+func (s *Server) validate(ctx context.Context, orderName string, authzIndex int, challengeType, keyAuth string) {
+	log := s.Log.WithValues("order", orderName, "challenge", challengeType)
+	ctx, cancel := context.WithTimeout(ctx, validationTimeout)
+	defer cancel()
+
+	var err error
+	order := new(api.AcmeOrder)
+	if err = s.Reader.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: orderName}, order); err != nil {
+		log.Error(err, "Cannot get order")
+		return
+	}
+	authz := order.Status.Authorizations[authzIndex]
+	switch challengeType {
+	case challengeHTTP01:
+		err = s.validateHTTP01(ctx, authz.Identifier.Value, keyAuth)
+	case challengeDNS01:
+		err = s.validateDNS01(ctx, authz.Identifier.Value, keyAuth)
+	default:
+		err = fmt.Errorf("Unsupported challenge type %s", challengeType)
+	}
+	if err != nil {
+		log.Info("Challenge validation failed", "identifier", authz.Identifier.Value, "reason", err.Error())
+	} else {
+		log.Info("Challenge validated", "identifier", authz.Identifier.Value)
+	}
+
+	// The order may be updated by other challenges at the same time
+	updateErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		order := new(api.AcmeOrder)
+		if err := s.Reader.Get(context.Background(), client.ObjectKey{Namespace: s.Namespace, Name: orderName}, order); err != nil {
+			return err
+		}
+		setChallengeResult(order, authzIndex, challengeType, err)
+		return s.Client.Status().Update(context.Background(), order)
+	})
+	if updateErr != nil {
+		log.Error(updateErr, "Cannot update order")
+	}
+}
+
+// Set the challenge status and propagate it to the authorization and the order.
+func setChallengeResult(order *api.AcmeOrder, authzIndex int, challengeType string, err error) {
+	authz := &order.Status.Authorizations[authzIndex]
+	for i := range authz.Challenges {
+		challenge := &authz.Challenges[i]
+		if challenge.Type != challengeType || challenge.Status != api.AcmeStatusProcessing {
+			continue
+		}
+		if err != nil {
+			challenge.Status = api.AcmeStatusInvalid
+			challenge.Error = err.Error()
+			authz.Status = api.AcmeStatusInvalid
+		} else {
+			now := metav1.Now()
+			challenge.Status = api.AcmeStatusValid
+			challenge.Validated = &now
+			authz.Status = api.AcmeStatusValid
+		}
+	}
+	if order.Status.Status != api.AcmeStatusPending {
+		return
+	}
+	ready := true
+	for _, a := range order.Status.Authorizations {
+		switch a.Status {
+		case api.AcmeStatusInvalid:
+			order.Status.Status = api.AcmeStatusInvalid
+			order.Status.Error = fmt.Sprintf("Authorization of %s failed", a.Identifier.Value)
+			return
+		case api.AcmeStatusValid:
+		default:
+			ready = false
+		}
+	}
+	if ready {
+		order.Status.Status = api.AcmeStatusReady
+	}
+}
+
+// Get the key authorization from 'http://<domain>/.well-known/acme-challenge/<token>' (RFC 8555 section 8.3)
+func (s *Server) validateHTTP01(ctx context.Context, domain, keyAuth string) error {
+	token := strings.SplitN(keyAuth, ".", 2)[0]
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Cannot get %s: %s", url, err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response of %s has status %s", url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, resp.Body, maxKeyAuthorizationSize))
+	if err != nil {
+		return fmt.Errorf("Cannot read response of %s: %s", url, err.Error())
+	}
+	if strings.TrimSpace(string(body)) != keyAuth {
+		return fmt.Errorf("Response of %s doesn't match the key authorization", url)
+	}
+	return nil
+}
+
+// Redirects of the http-01 validation may lead only to domain names on the default
+// HTTP and HTTPS ports (RFC 8555 section 8.3). IP addresses of internal services
+// can't be reached this way.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("Stopped after %d redirects", len(via))
+	}
+	if net.ParseIP(req.URL.Hostname()) != nil {
+		return fmt.Errorf("Redirect to IP address %s not allowed", req.URL.Hostname())
+	}
+	port := req.URL.Port()
+	switch req.URL.Scheme {
+	case "http":
+		if port == "" || port == "80" {
+			return nil
+		}
+	case "https":
+		if port == "" || port == "443" {
+			return nil
+		}
+	}
+	return fmt.Errorf("Redirect to %s not allowed", req.URL.Redacted())
+}
+
+// Look up the key authorization digest in '_acme-challenge.<domain>' TXT records (RFC 8555 section 8.4)
+func (s *Server) validateDNS01(ctx context.Context, domain, keyAuth string) error {
+	digest := sha256.Sum256([]byte(keyAuth))
+	expected := encode(digest[:])
+	name := "_acme-challenge." + domain
+	records, err := s.resolver().LookupTXT(ctx, name)
+	if err != nil {
+		return fmt.Errorf("Cannot look up TXT records of %s: %s", name, err.Error())
+	}
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+	return fmt.Errorf("No TXT record of %s matches the key authorization", name)
+}
+
+// The configured DNS server or the system resolver
+func (s *Server) resolver() *net.Resolver {
+	if s.DNSResolver == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, s.DNSResolver)
+		},
+	}
+}
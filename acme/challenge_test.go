@@ -0,0 +1,57 @@
+package acme
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckRedirect(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		via     int
+		wantErr bool
+	}{
+		{name: "http", url: "http://www.example.com/.well-known/acme-challenge/token"},
+		{name: "http on port 80", url: "http://www.example.com:80/token"},
+		{name: "https", url: "https://www.example.com/token"},
+		{name: "https on port 443", url: "https://www.example.com:443/token"},
+		{name: "other port", url: "http://www.example.com:8080/token", wantErr: true},
+		{name: "https on port 80", url: "https://www.example.com:80/token", wantErr: true},
+		{name: "IPv4 address", url: "http://169.254.169.254/latest/meta-data", wantErr: true},
+		{name: "IPv6 address", url: "http://[::1]/token", wantErr: true},
+		{name: "other scheme", url: "ftp://www.example.com/token", wantErr: true},
+		{name: "too many redirects", url: "http://www.example.com/token", via: maxRedirects, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			via := make([]*http.Request, tt.via)
+			if err := checkRedirect(req, via); (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateHTTP01Redirect(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/internal" {
+			t.Errorf("redirect to IP address followed")
+		}
+		http.Redirect(w, r, "http://"+r.Host+"/internal", http.StatusFound)
+	}))
+	defer srv.Close()
+	s := &Server{httpClient: &http.Client{CheckRedirect: checkRedirect}}
+
+	err := s.validateHTTP01(context.Background(), strings.TrimPrefix(srv.URL, "http://"), "token.thumbprint")
+	if err == nil || !strings.Contains(err.Error(), "Redirect to IP address") {
+		t.Errorf("expected redirect refused, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package acme
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Check the finalize CSR requests exactly the order's identifiers.
+// Returns the CSR in PEM encoding.
+func checkCSR(der []byte, identifiers []api.AcmeIdentifier) ([]byte, error) {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse CSR: %s", err.Error())
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("Invalid CSR signature: %s", err.Error())
+	}
+	if len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return nil, fmt.Errorf("CSR may contain DNS names only")
+	}
+
+	requested := map[string]bool{}
+	for _, name := range csr.DNSNames {
+		requested[strings.ToLower(name)] = true
+	}
+	if cn := csr.Subject.CommonName; cn != "" {
+		requested[strings.ToLower(cn)] = true
+	}
+	ordered := map[string]bool{}
+	for _, id := range identifiers {
+		ordered[id.Value] = true
+	}
+	if missing, extra := difference(ordered, requested), difference(requested, ordered); len(missing) > 0 || len(extra) > 0 {
+		return nil, fmt.Errorf("CSR names don't match the order (missing: %s, not ordered: %s)",
+			strings.Join(missing, ", "), strings.Join(extra, ", "))
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), nil
+}
+
+// Names in a but not in b
+func difference(a, b map[string]bool) []string {
+	var names []string
+	for name := range a {
+		if !b[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fully qualified domain name without the wildcard prefix.
+// IP addresses are valid subdomains too, they are not accepted as DNS identifiers.
+func validDomain(domain string) bool {
+	return strings.Contains(domain, ".") && len(validation.IsDNS1123Subdomain(domain)) == 0 && net.ParseIP(domain) == nil
+}
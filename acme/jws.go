@@ -0,0 +1,112 @@
+package acme
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+// Signature algorithms allowed for ACME requests. MAC algorithms and 'none' are not (RFC 8555 section 6.2).
+var allowedAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+	string(jose.EdDSA): true,
+}
+
+// JWS of an ACME request in the flattened JSON serialization with the members
+// of the protected header used by ACME (RFC 8555 section 6.2)
+type jwsMessage struct {
+	jws   *jose.JSONWebSignature
+	Alg   string
+	Nonce string
+	URL   string
+	Kid   string
+	JWK   *jose.JSONWebKey
+}
+
+func parseJWS(body []byte) (*jwsMessage, error) {
+	// Only the flattened JSON serialization without unprotected header is allowed
+	var members struct {
+		Header     json.RawMessage `json:"header"`
+		Signatures json.RawMessage `json:"signatures"`
+	}
+	if err := json.Unmarshal(body, &members); err != nil {
+		return nil, fmt.Errorf("JWS must use the flattened JSON serialization")
+	}
+	if members.Signatures != nil {
+		return nil, fmt.Errorf("JWS must use the flattened JSON serialization")
+	}
+	if members.Header != nil {
+		return nil, fmt.Errorf("JWS must not have unprotected header")
+	}
+	jws, err := jose.ParseSigned(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse JWS: %s", err.Error())
+	}
+	signature := jws.Signatures[0]
+	header := signature.Protected
+	url, _ := header.ExtraHeaders["url"].(string)
+	return &jwsMessage{
+		jws:   jws,
+		Alg:   header.Algorithm,
+		Nonce: header.Nonce,
+		URL:   url,
+		Kid:   header.KeyID,
+		JWK:   header.JSONWebKey,
+	}, nil
+}
+
+// Verify the signature with the key and return the payload
+func (m *jwsMessage) verify(key *jose.JSONWebKey) ([]byte, error) {
+	if !allowedAlgorithms[m.Alg] {
+		return nil, fmt.Errorf("Algorithm %s not supported", m.Alg)
+	}
+	payload, err := m.jws.Verify(key)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid JWS signature")
+	}
+	return payload, nil
+}
+
+// Check the key is acceptable as an account key
+func checkJWK(key *jose.JSONWebKey) error {
+	if !key.Valid() || !key.IsPublic() {
+		return fmt.Errorf("Account key must be a valid public key")
+	}
+	switch k := key.Key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+	}
+	return nil
+}
+
+// The JWK thumbprint (RFC 7638) used in key authorizations
+func thumbprint(key *jose.JSONWebKey) []byte {
+	// Doesn't fail for the public keys accepted by checkJWK
+	sum, _ := key.Thumbprint(crypto.SHA256)
+	return sum
+}
+
+func parseJWK(data string) (*jose.JSONWebKey, error) {
+	key := new(jose.JSONWebKey)
+	if err := json.Unmarshal([]byte(data), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(data)
+}
@@ -0,0 +1,167 @@
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func signJWS(t *testing.T, alg jose.SignatureAlgorithm, key interface{}, opts *jose.SignerOptions, payload string) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := signer.Sign([]byte(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jws.FullSerialize()
+}
+
+func TestParseJWS(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := &jose.JSONWebKey{Key: ecKey.Public()}
+	acmeHeaders := func() *jose.SignerOptions {
+		return (&jose.SignerOptions{}).WithHeader("url", "https://acme.example.com/acme/new-order").WithHeader("nonce", "abc")
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		key       *jose.JSONWebKey
+		parseErr  bool
+		verifyErr bool
+		payload   string
+	}{
+		{
+			name:    "kid",
+			body:    signJWS(t, jose.ES256, ecKey, acmeHeaders().WithHeader("kid", "https://acme.example.com/acme/account/a"), `{}`),
+			key:     jwk,
+			payload: `{}`,
+		},
+		{
+			name:    "embedded jwk",
+			body:    signJWS(t, jose.ES256, ecKey, &jose.SignerOptions{EmbedJWK: true, ExtraHeaders: acmeHeaders().ExtraHeaders}, `{"contact":[]}`),
+			key:     jwk,
+			payload: `{"contact":[]}`,
+		},
+		{
+			name:    "POST-as-GET",
+			body:    signJWS(t, jose.ES256, ecKey, acmeHeaders(), ``),
+			key:     jwk,
+			payload: ``,
+		},
+		{
+			name:      "other key",
+			body:      signJWS(t, jose.ES256, otherKey, acmeHeaders(), `{}`),
+			key:       jwk,
+			verifyErr: true,
+		},
+		{
+			name:      "MAC",
+			body:      signJWS(t, jose.HS256, []byte("0123456789abcdef0123456789abcdef"), acmeHeaders(), `{}`),
+			key:       &jose.JSONWebKey{Key: []byte("0123456789abcdef0123456789abcdef")},
+			verifyErr: true,
+		},
+		{
+			name:     "compact serialization",
+			body:     "eyJhbGciOiJFUzI1NiJ9.e30.c2ln",
+			parseErr: true,
+		},
+		{
+			name:     "unprotected header",
+			body:     `{"protected":"eyJhbGciOiJFUzI1NiJ9","header":{"kid":"a"},"payload":"e30","signature":"c2ln"}`,
+			parseErr: true,
+		},
+		{
+			name:     "general serialization",
+			body:     `{"payload":"e30","signatures":[{"protected":"eyJhbGciOiJFUzI1NiJ9","signature":"c2ln"}]}`,
+			parseErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := parseJWS([]byte(tt.body))
+			if (err != nil) != tt.parseErr {
+				t.Fatalf("unexpected parse result: %v", err)
+			}
+			if err != nil {
+				return
+			}
+			if msg.URL != "https://acme.example.com/acme/new-order" || msg.Nonce != "abc" {
+				t.Errorf("unexpected header url %q nonce %q", msg.URL, msg.Nonce)
+			}
+			payload, err := msg.verify(tt.key)
+			if (err != nil) != tt.verifyErr {
+				t.Fatalf("unexpected verify result: %v", err)
+			}
+			if string(payload) != tt.payload {
+				t.Errorf("expected payload %q, got %q", tt.payload, payload)
+			}
+		})
+	}
+}
+
+func TestCheckJWK(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  interface{}
+		ok   bool
+	}{
+		{"EC", ecKey.Public(), true},
+		{"RSA", rsaKey.Public(), true},
+		{"small RSA", smallKey.Public(), false},
+		{"private", ecKey, false},
+		{"symmetric", []byte("secret"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkJWK(&jose.JSONWebKey{Key: tt.key}); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestAccountKeyRoundTrip(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk := &jose.JSONWebKey{Key: ecKey.Public()}
+	data, err := jwk.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parseJWK(string(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accountName(parsed) != accountName(jwk) {
+		t.Errorf("account name changed: %s, %s", accountName(jwk), accountName(parsed))
+	}
+}
@@ -0,0 +1,142 @@
+package acme
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	nonceLifetime = 10 * time.Minute
+	// Used nonces kept in an account. Requests over it get badNonce until some expire.
+	maxAccountNonces = 1000
+
+	// Secret in the ACME namespace with the key nonces are authenticated with
+	nonceKeySecret = "acme-nonce-key"
+	nonceKeyField  = "key"
+	nonceKeySize   = 32
+
+	nonceRandomSize = 16
+	nonceMACSize    = 16
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;create
+
+// Nonces issued to ACME clients. Each one can be used once.
+// A nonce holds its expiry time and is authenticated with a key shared by all replicas,
+// so any replica accepts nonces issued by the others. Used nonces are remembered in the
+// status of the AcmeAccount that signed the request until they expire, so a request
+// replayed against another replica is rejected too.
+type nonceStore struct {
+	key []byte
+}
+
+func newNonceStore(key []byte) *nonceStore {
+	return &nonceStore{key: key}
+}
+
+// Get the nonce key from its Secret, creating it if it doesn't exist
+func loadNonceKey(ctx context.Context, c client.Client, r client.Reader, namespace string) ([]byte, error) {
+	key := client.ObjectKey{Namespace: namespace, Name: nonceKeySecret}
+	secret := new(corev1.Secret)
+	err := r.Get(ctx, key, secret)
+	if apierrors.IsNotFound(err) {
+		b := make([]byte, nonceKeySize)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: nonceKeySecret},
+			Data:       map[string][]byte{nonceKeyField: b},
+		}
+		err = c.Create(ctx, secret)
+		if apierrors.IsAlreadyExists(err) {
+			// Created by another replica
+			err = r.Get(ctx, key, secret)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Cannot get nonce key: %s", err.Error())
+	}
+	if len(secret.Data[nonceKeyField]) < nonceKeySize {
+		return nil, fmt.Errorf("Secret %s has no valid nonce key", key)
+	}
+	return secret.Data[nonceKeyField], nil
+}
+
+// Nonce is base64url(expiry | random | MAC(expiry | random))
+func (s *nonceStore) issue() (string, error) {
+	b := make([]byte, 8+nonceRandomSize, 8+nonceRandomSize+nonceMACSize)
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(nonceLifetime).Unix()))
+	if _, err := rand.Read(b[8:]); err != nil {
+		return "", err
+	}
+	return encode(append(b, s.mac(b)...)), nil
+}
+
+// Returns false if the nonce wasn't issued or expired.
+func (s *nonceStore) valid(nonce string, now time.Time) bool {
+	b, err := decode(nonce)
+	if err != nil || len(b) != 8+nonceRandomSize+nonceMACSize {
+		return false
+	}
+	data := b[:8+nonceRandomSize]
+	if !hmac.Equal(b[len(data):], s.mac(data)) {
+		return false
+	}
+	expires := time.Unix(int64(binary.BigEndian.Uint64(b)), 0)
+	return now.Before(expires)
+}
+
+// Record the valid nonce as used by the account. Returns false if the account already used it.
+// The expired nonces of the account are dropped. Conflicting updates (e.g. by other replicas)
+// are re-tried with the account read again.
+func (s *nonceStore) use(ctx context.Context, c client.Client, r client.Reader, account *api.AcmeAccount, nonce string) (bool, error) {
+	ok := false
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		now := time.Now()
+		var nonces []string
+		for _, n := range account.Status.UsedNonces {
+			if n == nonce {
+				ok = false
+				return nil
+			}
+			if s.valid(n, now) {
+				nonces = append(nonces, n)
+			}
+		}
+		if len(nonces) >= maxAccountNonces {
+			// Reject rather than forget used nonces, the client will get badNonce and re-try.
+			ok = false
+			return nil
+		}
+		account.Status.UsedNonces = append(nonces, nonce)
+		err := c.Status().Update(ctx, account)
+		if apierrors.IsConflict(err) {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(account), account); err != nil {
+				return err
+			}
+		}
+		ok = err == nil
+		return err
+	})
+	return ok, err
+}
+
+func (s *nonceStore) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(data)
+	return h.Sum(nil)[:nonceMACSize]
+}
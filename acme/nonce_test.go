@@ -0,0 +1,131 @@
+package acme
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func newTestClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := api.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func TestNonces(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	store := newNonceStore(key)
+	// Another replica
+	other := newNonceStore(key)
+
+	nonce, err := store.issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := make([]byte, 8+nonceRandomSize)
+	binary.BigEndian.PutUint64(expired, uint64(time.Now().Add(-time.Minute).Unix()))
+	forged, err := newNonceStore([]byte("another key")).issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		store *nonceStore
+		nonce string
+		ok    bool
+	}{
+		{"issued", store, nonce, true},
+		{"issued by another replica", other, nonce, true},
+		{"expired", store, encode(append(expired, store.mac(expired)...)), false},
+		{"other key", store, forged, false},
+		{"truncated", store, nonce[:20], false},
+		{"not base64url", store, "?" + nonce[1:], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.store.valid(tt.nonce, time.Now()); got != tt.ok {
+				t.Errorf("expected %t, got %t", tt.ok, got)
+			}
+		})
+	}
+	if store.valid(nonce, time.Now().Add(nonceLifetime+time.Second)) {
+		t.Errorf("expected nonce to expire")
+	}
+}
+
+func TestUseNonce(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	store := newNonceStore(key)
+	ctx := context.Background()
+
+	expired := make([]byte, 8+nonceRandomSize)
+	binary.BigEndian.PutUint64(expired, uint64(time.Now().Add(-time.Minute).Unix()))
+	expiredNonce := encode(append(expired, store.mac(expired)...))
+	account := &api.AcmeAccount{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "acme", Name: "account-1"},
+		Status:     api.AcmeAccountStatus{Status: api.AcmeStatusValid, UsedNonces: []string{expiredNonce}},
+	}
+	c := newTestClient(t, account)
+
+	nonce, err := store.issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both replicas read the account before the nonce is used
+	first, second := new(api.AcmeAccount), new(api.AcmeAccount)
+	for _, a := range []*api.AcmeAccount{first, second} {
+		if err := c.Get(ctx, client.ObjectKeyFromObject(account), a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, err := store.use(ctx, c, c, first, nonce); err != nil || !ok {
+		t.Fatalf("expected nonce used, got %t, %v", ok, err)
+	}
+	// Replayed against another replica with a stale account
+	if ok, err := newNonceStore(key).use(ctx, c, c, second, nonce); err != nil || ok {
+		t.Errorf("expected replayed nonce rejected, got %t, %v", ok, err)
+	}
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(account), account); err != nil {
+		t.Fatal(err)
+	}
+	if len(account.Status.UsedNonces) != 1 || account.Status.UsedNonces[0] != nonce {
+		t.Errorf("expected only the used nonce kept, got %v", account.Status.UsedNonces)
+	}
+	if account.Status.Status != api.AcmeStatusValid {
+		t.Errorf("account status changed to %s", account.Status.Status)
+	}
+}
+
+func TestUseNonceLimit(t *testing.T) {
+	store := newNonceStore([]byte("0123456789abcdef0123456789abcdef"))
+	account := &api.AcmeAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "acme", Name: "account-1"}}
+	for i := 0; i < maxAccountNonces; i++ {
+		nonce, err := store.issue()
+		if err != nil {
+			t.Fatal(err)
+		}
+		account.Status.UsedNonces = append(account.Status.UsedNonces, nonce)
+	}
+	c := newTestClient(t, account)
+
+	nonce, err := store.issue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.use(context.Background(), c, c, account, nonce); err != nil || ok {
+		t.Errorf("expected nonce rejected over the limit, got %t, %v", ok, err)
+	}
+}
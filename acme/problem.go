@@ -0,0 +1,47 @@
+package acme
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const errorNamespace = "urn:ietf:params:acme:error:"
+
+// Error returned to ACME clients as problem document (RFC 7807)
+type problem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status"`
+}
+
+func (p *problem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+func newProblem(status int, errorType, format string, args ...interface{}) *problem {
+	return &problem{Type: errorNamespace + errorType, Detail: fmt.Sprintf(format, args...), Status: status}
+}
+
+func malformed(format string, args ...interface{}) *problem {
+	return newProblem(http.StatusBadRequest, "malformed", format, args...)
+}
+
+func unauthorized(format string, args ...interface{}) *problem {
+	return newProblem(http.StatusForbidden, "unauthorized", format, args...)
+}
+
+func notFound(format string, args ...interface{}) *problem {
+	return newProblem(http.StatusNotFound, "malformed", format, args...)
+}
+
+func serverInternal(err error) *problem {
+	return newProblem(http.StatusInternalServerError, "serverInternal", "%s", err.Error())
+}
+
+func badNonce() *problem {
+	return newProblem(http.StatusBadRequest, "badNonce", "Invalid or used nonce")
+}
+
+func rateLimited(format string, args ...interface{}) *problem {
+	return newProblem(http.StatusTooManyRequests, "rateLimited", format, args...)
+}
@@ -0,0 +1,918 @@
+// Package acme implements an ACME (RFC 8555) server for hosts that can't use certsrv.
+// Accounts and orders are stored as AcmeAccount and AcmeOrder resources. Finalized
+// orders create AdcsRequests which are sent to ADCS by the AdcsRequest controller.
+package acme
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	jose "gopkg.in/square/go-jose.v2"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	basePath = "/acme/"
+
+	// Label of AcmeOrders with the name of their AcmeAccount
+	accountLabel = "adcs.certmanager.csf.nokia.com/acme-account"
+
+	orderLifetime = 24 * time.Hour
+	// Suggested interval of polling orders waiting for ADCS
+	processingRetryAfter = 10 * time.Second
+	maxRequestSize       = 64 * 1024
+	shutdownTimeout      = 10 * time.Second
+)
+
+// +kubebuilder:rbac:groups=adcs.certmanager.csf.nokia.com,resources=acmeaccounts,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=adcs.certmanager.csf.nokia.com,resources=acmeaccounts/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=adcs.certmanager.csf.nokia.com,resources=acmeorders,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=adcs.certmanager.csf.nokia.com,resources=acmeorders/status,verbs=get;update;patch
+
+// Server is the ACME server. It implements manager.Runnable.
+type Server struct {
+	// Writes AcmeAccounts, AcmeOrders and AdcsRequests
+	Client client.Client
+	// Reads them bypassing the cache so that just created objects are found
+	Reader client.Reader
+	Log    logr.Logger
+
+	// Address to listen on e.g. ':8443'
+	Addr string
+	// TLS certificate and key files. Plain HTTP is served if not set
+	// (e.g. behind a TLS terminating proxy).
+	CertFile string
+	KeyFile  string
+	// URL the server is reachable at e.g. 'https://acme.example.com'.
+	// If not set it's taken from the requests.
+	ExternalURL string
+	// Namespace of AcmeAccounts, AcmeOrders and their AdcsRequests
+	Namespace string
+	// Issuer the certificates are requested from
+	IssuerRef cmmeta.ObjectReference
+	// DNS server (host:port) used to validate dns-01 challenges.
+	// The system resolver is used if not set.
+	DNSResolver string
+	// Maximum number of AcmeAccounts, of AcmeOrders and of AcmeOrders of a single
+	// account (zero means not limited). Orders count until the OrderSweeper removes them.
+	MaxAccounts         int
+	MaxOrders           int
+	MaxOrdersPerAccount int
+
+	nonces     *nonceStore
+	httpClient *http.Client
+	// Context of the validations running in the background
+	ctx context.Context
+}
+
+// Request with verified signature
+type request struct {
+	payload []byte
+	key     *jose.JSONWebKey
+	nonce   string
+	// Not set for new-account requests
+	account *api.AcmeAccount
+}
+
+type directoryObject struct {
+	NewNonce   string `json:"newNonce"`
+	NewAccount string `json:"newAccount"`
+	NewOrder   string `json:"newOrder"`
+}
+
+type accountObject struct {
+	Status  api.AcmeStatus `json:"status"`
+	Contact []string       `json:"contact,omitempty"`
+	Orders  string         `json:"orders"`
+}
+
+type orderObject struct {
+	Status         api.AcmeStatus       `json:"status"`
+	Expires        string               `json:"expires,omitempty"`
+	Identifiers    []api.AcmeIdentifier `json:"identifiers"`
+	Authorizations []string             `json:"authorizations"`
+	Finalize       string               `json:"finalize"`
+	Certificate    string               `json:"certificate,omitempty"`
+	Error          *problem             `json:"error,omitempty"`
+}
+
+type authorizationObject struct {
+	Identifier api.AcmeIdentifier `json:"identifier"`
+	Status     api.AcmeStatus     `json:"status"`
+	Expires    string             `json:"expires,omitempty"`
+	Challenges []challengeObject  `json:"challenges"`
+	Wildcard   bool               `json:"wildcard,omitempty"`
+}
+
+type challengeObject struct {
+	Type      string         `json:"type"`
+	URL       string         `json:"url"`
+	Token     string         `json:"token"`
+	Status    api.AcmeStatus `json:"status"`
+	Validated string         `json:"validated,omitempty"`
+	Error     *problem       `json:"error,omitempty"`
+}
+
+// Start implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	key, err := loadNonceKey(ctx, s.Client, s.Reader, s.Namespace)
+	if err != nil {
+		return err
+	}
+	s.nonces = newNonceStore(key)
+	s.httpClient = &http.Client{Timeout: validationTimeout, CheckRedirect: checkRedirect}
+	s.ctx = ctx
+
+	server := &http.Server{Addr: s.Addr, Handler: s}
+	errs := make(chan error, 1)
+	go func() {
+		s.Log.Info("Starting ACME server", "addr", s.Addr)
+		if s.CertFile != "" {
+			errs <- server.ListenAndServeTLS(s.CertFile, s.KeyFile)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errs:
+		return err
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// The server runs in all replicas. They share the nonce key.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, basePath) {
+		s.writeProblem(w, r, notFound("%s not found", r.URL.Path))
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, basePath), "/")
+	switch parts[0] {
+	case "directory":
+		s.writeJSON(w, r, http.StatusOK, directoryObject{
+			NewNonce:   s.url(r, "new-nonce"),
+			NewAccount: s.url(r, "new-account"),
+			NewOrder:   s.url(r, "new-order"),
+		})
+		return
+	case "new-nonce":
+		s.newNonce(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		s.writeProblem(w, r, newProblem(http.StatusMethodNotAllowed, "malformed", "Method %s not allowed", r.Method))
+		return
+	}
+
+	var p *problem
+	switch {
+	case parts[0] == "new-account" && len(parts) == 1:
+		p = s.newAccount(w, r)
+	case parts[0] == "account" && len(parts) == 2:
+		p = s.account(w, r, parts[1])
+	case parts[0] == "orders" && len(parts) == 2:
+		p = s.orders(w, r, parts[1])
+	case parts[0] == "new-order" && len(parts) == 1:
+		p = s.newOrder(w, r)
+	case parts[0] == "order" && len(parts) == 2:
+		p = s.order(w, r, parts[1])
+	case parts[0] == "authz" && len(parts) == 3:
+		p = s.authorization(w, r, parts[1], parts[2])
+	case parts[0] == "challenge" && len(parts) == 4:
+		p = s.challenge(w, r, parts[1], parts[2], parts[3])
+	case parts[0] == "finalize" && len(parts) == 2:
+		p = s.finalize(w, r, parts[1])
+	case parts[0] == "certificate" && len(parts) == 2:
+		p = s.certificate(w, r, parts[1])
+	default:
+		p = notFound("%s not found", r.URL.Path)
+	}
+	if p != nil {
+		if p.Status >= http.StatusInternalServerError {
+			s.Log.Error(p, "ACME request failed", "path", r.URL.Path)
+		}
+		s.writeProblem(w, r, p)
+	}
+}
+
+func (s *Server) newNonce(w http.ResponseWriter, r *http.Request) {
+	if err := s.addNonce(w); err != nil {
+		s.writeProblem(w, r, serverInternal(err))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	s.addIndexLink(w, r)
+	if r.Method == http.MethodGet {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *Server) newAccount(w http.ResponseWriter, r *http.Request) *problem {
+	req, p := s.verify(r, true)
+	if p != nil {
+		return p
+	}
+	var payload struct {
+		Contact            []string `json:"contact"`
+		OnlyReturnExisting bool     `json:"onlyReturnExisting"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return malformed("Cannot parse new-account request: %s", err.Error())
+	}
+
+	name := accountName(req.key)
+	account := new(api.AcmeAccount)
+	err := s.Reader.Get(r.Context(), client.ObjectKey{Namespace: s.Namespace, Name: name}, account)
+	if err == nil {
+		if p := s.useNonce(r.Context(), account, req.nonce); p != nil {
+			return p
+		}
+		w.Header().Set("Location", s.url(r, "account/"+name))
+		s.writeJSON(w, r, http.StatusOK, s.accountObject(r, account))
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return serverInternal(err)
+	}
+	if payload.OnlyReturnExisting {
+		return newProblem(http.StatusBadRequest, "accountDoesNotExist", "Account doesn't exist")
+	}
+	if p := checkContact(payload.Contact); p != nil {
+		return p
+	}
+	if p := s.checkLimit(r.Context(), new(api.AcmeAccountList), s.MaxAccounts, "accounts"); p != nil {
+		return p
+	}
+
+	key, err := req.key.MarshalJSON()
+	if err != nil {
+		return serverInternal(err)
+	}
+	account = &api.AcmeAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: s.Namespace},
+		Spec: api.AcmeAccountSpec{
+			Key:     string(key),
+			Contact: payload.Contact,
+		},
+	}
+	if err := s.Client.Create(r.Context(), account); err != nil {
+		return serverInternal(err)
+	}
+	account.Status.Status = api.AcmeStatusValid
+	if p := s.useNonce(r.Context(), account, req.nonce); p != nil {
+		return p
+	}
+	s.Log.Info("ACME account created", "account", name)
+	w.Header().Set("Location", s.url(r, "account/"+name))
+	s.writeJSON(w, r, http.StatusCreated, s.accountObject(r, account))
+	return nil
+}
+
+// Get or update (contact, deactivation) the account
+func (s *Server) account(w http.ResponseWriter, r *http.Request, name string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	if req.account.Name != name {
+		return unauthorized("Request not signed by the account's key")
+	}
+	account := req.account
+	if len(req.payload) > 0 {
+		var payload struct {
+			Contact []string       `json:"contact"`
+			Status  api.AcmeStatus `json:"status"`
+		}
+		if err := json.Unmarshal(req.payload, &payload); err != nil {
+			return malformed("Cannot parse account update: %s", err.Error())
+		}
+		if payload.Contact != nil {
+			if p := checkContact(payload.Contact); p != nil {
+				return p
+			}
+			account.Spec.Contact = payload.Contact
+			status := account.Status
+			if err := s.Client.Update(r.Context(), account); err != nil {
+				return serverInternal(err)
+			}
+			account.Status = status
+		}
+		if payload.Status == api.AcmeStatusDeactivated {
+			account.Status.Status = api.AcmeStatusDeactivated
+			if err := s.Client.Status().Update(r.Context(), account); err != nil {
+				return serverInternal(err)
+			}
+			s.Log.Info("ACME account deactivated", "account", name)
+		} else if payload.Status != "" {
+			return malformed("Account status can only be changed to %s", api.AcmeStatusDeactivated)
+		}
+	}
+	s.writeJSON(w, r, http.StatusOK, s.accountObject(r, account))
+	return nil
+}
+
+// List the account's orders
+func (s *Server) orders(w http.ResponseWriter, r *http.Request, name string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	if req.account.Name != name {
+		return unauthorized("Request not signed by the account's key")
+	}
+	list := new(api.AcmeOrderList)
+	if err := s.Reader.List(r.Context(), list, client.InNamespace(s.Namespace), client.MatchingLabels{accountLabel: name}); err != nil {
+		return serverInternal(err)
+	}
+	orders := []string{}
+	for _, order := range list.Items {
+		orders = append(orders, s.url(r, "order/"+order.Name))
+	}
+	s.writeJSON(w, r, http.StatusOK, map[string][]string{"orders": orders})
+	return nil
+}
+
+func (s *Server) newOrder(w http.ResponseWriter, r *http.Request) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	var payload struct {
+		Identifiers []api.AcmeIdentifier `json:"identifiers"`
+		NotBefore   string               `json:"notBefore"`
+		NotAfter    string               `json:"notAfter"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return malformed("Cannot parse new-order request: %s", err.Error())
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return malformed("notBefore and notAfter are not supported. The validity is set by the certificate template")
+	}
+	if len(payload.Identifiers) == 0 {
+		return malformed("No identifiers in the order")
+	}
+
+	var identifiers []api.AcmeIdentifier
+	var authorizations []api.AcmeAuthorization
+	seen := map[string]bool{}
+	for _, id := range payload.Identifiers {
+		if id.Type != "dns" {
+			return newProblem(http.StatusBadRequest, "unsupportedIdentifier", "Identifier type %s is not supported", id.Type)
+		}
+		value := strings.ToLower(strings.TrimSuffix(id.Value, "."))
+		if seen[value] {
+			continue
+		}
+		seen[value] = true
+		domain := strings.TrimPrefix(value, "*.")
+		if !validDomain(domain) {
+			return newProblem(http.StatusBadRequest, "rejectedIdentifier", "Invalid DNS identifier %s", id.Value)
+		}
+		identifiers = append(identifiers, api.AcmeIdentifier{Type: id.Type, Value: value})
+		authz, err := newAuthorization(domain, domain != value)
+		if err != nil {
+			return serverInternal(err)
+		}
+		authorizations = append(authorizations, authz)
+	}
+
+	if p := s.checkLimit(r.Context(), new(api.AcmeOrderList), s.MaxOrders, "orders"); p != nil {
+		return p
+	}
+	if p := s.checkLimit(r.Context(), new(api.AcmeOrderList), s.MaxOrdersPerAccount, "orders of the account",
+		client.MatchingLabels{accountLabel: req.account.Name}); p != nil {
+		return p
+	}
+
+	name, err := randomName("order-")
+	if err != nil {
+		return serverInternal(err)
+	}
+	order := &api.AcmeOrder{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: s.Namespace,
+			Labels:    map[string]string{accountLabel: req.account.Name},
+		},
+		Spec: api.AcmeOrderSpec{
+			Account:     req.account.Name,
+			Identifiers: identifiers,
+			IssuerRef:   s.IssuerRef,
+		},
+	}
+	if err := s.Client.Create(r.Context(), order); err != nil {
+		return serverInternal(err)
+	}
+	expires := metav1.NewTime(time.Now().Add(orderLifetime))
+	order.Status = api.AcmeOrderStatus{
+		Status:         api.AcmeStatusPending,
+		Expires:        &expires,
+		Authorizations: authorizations,
+	}
+	if err := s.Client.Status().Update(r.Context(), order); err != nil {
+		return serverInternal(err)
+	}
+	s.Log.Info("ACME order created", "order", name, "account", req.account.Name)
+	w.Header().Set("Location", s.url(r, "order/"+name))
+	s.writeJSON(w, r, http.StatusCreated, s.orderObject(r, order))
+	return nil
+}
+
+func (s *Server) order(w http.ResponseWriter, r *http.Request, name string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	order, p := s.getOrder(r.Context(), req, name)
+	if p != nil {
+		return p
+	}
+	if order.Status.Status == api.AcmeStatusProcessing {
+		w.Header().Set("Retry-After", strconv.Itoa(int(processingRetryAfter.Seconds())))
+	}
+	s.writeJSON(w, r, http.StatusOK, s.orderObject(r, order))
+	return nil
+}
+
+func (s *Server) authorization(w http.ResponseWriter, r *http.Request, orderName, index string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	order, p := s.getOrder(r.Context(), req, orderName)
+	if p != nil {
+		return p
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(order.Status.Authorizations) {
+		return notFound("Authorization %s/%s not found", orderName, index)
+	}
+	s.writeJSON(w, r, http.StatusOK, s.authorizationObject(r, order, i))
+	return nil
+}
+
+// Get the challenge. A non-empty payload starts the validation.
+func (s *Server) challenge(w http.ResponseWriter, r *http.Request, orderName, index, challengeType string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	order, p := s.getOrder(r.Context(), req, orderName)
+	if p != nil {
+		return p
+	}
+	i, err := strconv.Atoi(index)
+	if err != nil || i < 0 || i >= len(order.Status.Authorizations) {
+		return notFound("Authorization %s/%s not found", orderName, index)
+	}
+	authz := &order.Status.Authorizations[i]
+	var challenge *api.AcmeChallenge
+	for j := range authz.Challenges {
+		if authz.Challenges[j].Type == challengeType {
+			challenge = &authz.Challenges[j]
+		}
+	}
+	if challenge == nil {
+		return notFound("Challenge %s/%s/%s not found", orderName, index, challengeType)
+	}
+
+	if len(req.payload) > 0 && challenge.Status == api.AcmeStatusPending {
+		if order.Status.Status != api.AcmeStatusPending || authz.Status != api.AcmeStatusPending {
+			return malformed("Authorization is not pending")
+		}
+		challenge.Status = api.AcmeStatusProcessing
+		if err := s.Client.Status().Update(r.Context(), order); err != nil {
+			return serverInternal(err)
+		}
+		go s.validate(s.ctx, order.Name, i, challenge.Type, keyAuthorization(challenge.Token, req.key))
+	}
+	w.Header().Add("Link", fmt.Sprintf("<%s>;rel=\"up\"", s.url(r, fmt.Sprintf("authz/%s/%d", orderName, i))))
+	s.writeJSON(w, r, http.StatusOK, s.challengeObject(r, order, i, *challenge))
+	return nil
+}
+
+// Submit the CSR in an AdcsRequest
+func (s *Server) finalize(w http.ResponseWriter, r *http.Request, name string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	order, p := s.getOrder(r.Context(), req, name)
+	if p != nil {
+		return p
+	}
+	if order.Status.Status != api.AcmeStatusReady {
+		return newProblem(http.StatusForbidden, "orderNotReady", "Order is %s", order.Status.Status)
+	}
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := json.Unmarshal(req.payload, &payload); err != nil {
+		return malformed("Cannot parse finalize request: %s", err.Error())
+	}
+	der, err := decode(payload.CSR)
+	if err != nil {
+		return malformed("Cannot decode CSR: %s", err.Error())
+	}
+	csrPEM, err := checkCSR(der, order.Spec.Identifiers)
+	if err != nil {
+		return newProblem(http.StatusBadRequest, "badCSR", "%s", err.Error())
+	}
+
+	order.Spec.CSRPEM = csrPEM
+	status := order.Status
+	if err := s.Client.Update(r.Context(), order); err != nil {
+		return serverInternal(err)
+	}
+	order.Status = status
+	ar := &api.AdcsRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            order.Name,
+			Namespace:       order.Namespace,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(order, api.GroupVersion.WithKind("AcmeOrder"))},
+		},
+		Spec: api.AdcsRequestSpec{
+			CSRPEM:    csrPEM,
+			IssuerRef: order.Spec.IssuerRef,
+		},
+	}
+	if err := s.Client.Create(r.Context(), ar); err != nil && !apierrors.IsAlreadyExists(err) {
+		return serverInternal(err)
+	}
+	order.Status.Status = api.AcmeStatusProcessing
+	if err := s.Client.Status().Update(r.Context(), order); err != nil {
+		return serverInternal(err)
+	}
+	s.Log.Info("ACME order finalized", "order", order.Name)
+	w.Header().Set("Location", s.url(r, "order/"+order.Name))
+	w.Header().Set("Retry-After", strconv.Itoa(int(processingRetryAfter.Seconds())))
+	s.writeJSON(w, r, http.StatusOK, s.orderObject(r, order))
+	return nil
+}
+
+// Download the certificate chain
+func (s *Server) certificate(w http.ResponseWriter, r *http.Request, name string) *problem {
+	req, p := s.verify(r, false)
+	if p != nil {
+		return p
+	}
+	order, p := s.getOrder(r.Context(), req, name)
+	if p != nil {
+		return p
+	}
+	if order.Status.Status != api.AcmeStatusValid {
+		return notFound("Certificate of order %s not issued", name)
+	}
+	ar := new(api.AdcsRequest)
+	if err := s.Reader.Get(r.Context(), client.ObjectKey{Namespace: order.Namespace, Name: order.Name}, ar); err != nil {
+		return serverInternal(err)
+	}
+	if len(ar.Status.Certificate) == 0 {
+		return notFound("Certificate of order %s not found", name)
+	}
+	chain, err := issuers.CertificateChain(ar.Status.Certificate, ar.Status.CACertificate)
+	if err != nil {
+		return serverInternal(err)
+	}
+	if err := s.addNonce(w); err != nil {
+		return serverInternal(err)
+	}
+	s.addIndexLink(w, r)
+	w.Header().Set("Content-Type", "application/pem-certificate-chain")
+	w.WriteHeader(http.StatusOK)
+	w.Write(chain)
+	return nil
+}
+
+// Verify the JWS of the request. New accounts are signed with the JWK,
+// other requests with the key of the account in 'kid'.
+func (s *Server) verify(r *http.Request, newAccount bool) (*request, *problem) {
+	if ct := r.Header.Get("Content-Type"); ct != "application/jose+json" {
+		return nil, newProblem(http.StatusUnsupportedMediaType, "malformed", "Content type %s not supported", ct)
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestSize))
+	if err != nil {
+		return nil, malformed("Cannot read request: %s", err.Error())
+	}
+	msg, err := parseJWS(body)
+	if err != nil {
+		return nil, malformed("%s", err.Error())
+	}
+	if msg.URL != s.url(r, strings.TrimPrefix(r.URL.Path, basePath)) {
+		return nil, unauthorized("URL in JWS header doesn't match the request URL")
+	}
+	if !s.nonces.valid(msg.Nonce, time.Now()) {
+		return nil, badNonce()
+	}
+
+	req := new(request)
+	if newAccount {
+		if msg.JWK == nil || msg.Kid != "" {
+			return nil, malformed("Request must be signed with the JWK")
+		}
+		if err := checkJWK(msg.JWK); err != nil {
+			return nil, newProblem(http.StatusBadRequest, "badPublicKey", "%s", err.Error())
+		}
+		req.key = msg.JWK
+	} else {
+		if msg.JWK != nil || msg.Kid == "" {
+			return nil, malformed("Request must be signed with the account key ID")
+		}
+		accountURL := s.url(r, "account/")
+		if !strings.HasPrefix(msg.Kid, accountURL) {
+			return nil, newProblem(http.StatusBadRequest, "accountDoesNotExist", "Account %s doesn't exist", msg.Kid)
+		}
+		account := new(api.AcmeAccount)
+		err := s.Reader.Get(r.Context(), client.ObjectKey{Namespace: s.Namespace, Name: strings.TrimPrefix(msg.Kid, accountURL)}, account)
+		if apierrors.IsNotFound(err) {
+			return nil, newProblem(http.StatusBadRequest, "accountDoesNotExist", "Account %s doesn't exist", msg.Kid)
+		}
+		if err != nil {
+			return nil, serverInternal(err)
+		}
+		if account.Status.Status == api.AcmeStatusDeactivated {
+			return nil, unauthorized("Account is deactivated")
+		}
+		if req.key, err = parseJWK(account.Spec.Key); err != nil {
+			return nil, serverInternal(fmt.Errorf("Cannot parse key of account %s: %s", account.Name, err.Error()))
+		}
+		req.account = account
+	}
+	if req.payload, err = msg.verify(req.key); err != nil {
+		return nil, malformed("%s", err.Error())
+	}
+	// The nonce of new-account requests is recorded in the account found or created
+	req.nonce = msg.Nonce
+	if req.account != nil {
+		if p := s.useNonce(r.Context(), req.account, req.nonce); p != nil {
+			return nil, p
+		}
+	}
+	return req, nil
+}
+
+// Record the nonce of the signed request in the account
+func (s *Server) useNonce(ctx context.Context, account *api.AcmeAccount, nonce string) *problem {
+	ok, err := s.nonces.use(ctx, s.Client, s.Reader, account, nonce)
+	if err != nil {
+		return serverInternal(err)
+	}
+	if !ok {
+		return badNonce()
+	}
+	return nil
+}
+
+// Refuse to create more objects of the list's type if there are already 'limit' of them
+func (s *Server) checkLimit(ctx context.Context, list client.ObjectList, limit int, what string, opts ...client.ListOption) *problem {
+	if limit <= 0 {
+		return nil
+	}
+	if err := s.Client.List(ctx, list, append(opts, client.InNamespace(s.Namespace))...); err != nil {
+		return serverInternal(err)
+	}
+	if meta.LenList(list) >= limit {
+		return rateLimited("Limit of %d %s reached", limit, what)
+	}
+	return nil
+}
+
+// Get the account's order with up to date status
+func (s *Server) getOrder(ctx context.Context, req *request, name string) (*api.AcmeOrder, *problem) {
+	order := new(api.AcmeOrder)
+	err := s.Reader.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: name}, order)
+	if apierrors.IsNotFound(err) {
+		return nil, notFound("Order %s not found", name)
+	}
+	if err != nil {
+		return nil, serverInternal(err)
+	}
+	if order.Spec.Account != req.account.Name {
+		return nil, unauthorized("Order %s belongs to another account", name)
+	}
+	if err := s.refresh(ctx, order); err != nil {
+		return nil, serverInternal(err)
+	}
+	return order, nil
+}
+
+// Bring the order status up to date. Pending orders expire and processing ones
+// follow the state of their AdcsRequest.
+func (s *Server) refresh(ctx context.Context, order *api.AcmeOrder) error {
+	status := order.Status.DeepCopy()
+	switch order.Status.Status {
+	case api.AcmeStatusPending, api.AcmeStatusReady:
+		if order.Status.Expires != nil && time.Now().After(order.Status.Expires.Time) {
+			order.Status.Status = api.AcmeStatusInvalid
+			order.Status.Error = "Order expired"
+		}
+	case api.AcmeStatusProcessing:
+		ar := new(api.AdcsRequest)
+		err := s.Reader.Get(ctx, client.ObjectKey{Namespace: order.Namespace, Name: order.Name}, ar)
+		if apierrors.IsNotFound(err) {
+			order.Status.Status = api.AcmeStatusInvalid
+			order.Status.Error = "AdcsRequest of the order was deleted"
+			break
+		}
+		if err != nil {
+			return err
+		}
+		order.Status.RequestID = ar.Status.Id
+		switch ar.Status.State {
+		case api.Ready:
+			order.Status.Status = api.AcmeStatusValid
+		case api.Errored, api.Rejected:
+			order.Status.Status = api.AcmeStatusInvalid
+			order.Status.Error = fmt.Sprintf("ADCS request %s: %s", ar.Status.State, ar.Status.Reason)
+		}
+	}
+	if equality.Semantic.DeepEqual(status, &order.Status) {
+		return nil
+	}
+	return s.Client.Status().Update(ctx, order)
+}
+
+func (s *Server) accountObject(r *http.Request, account *api.AcmeAccount) accountObject {
+	return accountObject{
+		Status:  account.Status.Status,
+		Contact: account.Spec.Contact,
+		Orders:  s.url(r, "orders/"+account.Name),
+	}
+}
+
+func (s *Server) orderObject(r *http.Request, order *api.AcmeOrder) orderObject {
+	o := orderObject{
+		Status:      order.Status.Status,
+		Identifiers: order.Spec.Identifiers,
+		Finalize:    s.url(r, "finalize/"+order.Name),
+	}
+	if order.Status.Expires != nil {
+		o.Expires = order.Status.Expires.UTC().Format(time.RFC3339)
+	}
+	for i := range order.Status.Authorizations {
+		o.Authorizations = append(o.Authorizations, s.url(r, fmt.Sprintf("authz/%s/%d", order.Name, i)))
+	}
+	switch order.Status.Status {
+	case api.AcmeStatusValid:
+		o.Certificate = s.url(r, "certificate/"+order.Name)
+	case api.AcmeStatusInvalid:
+		o.Error = &problem{Type: errorNamespace + "serverInternal", Detail: order.Status.Error, Status: http.StatusForbidden}
+	}
+	return o
+}
+
+func (s *Server) authorizationObject(r *http.Request, order *api.AcmeOrder, i int) authorizationObject {
+	authz := order.Status.Authorizations[i]
+	a := authorizationObject{
+		Identifier: authz.Identifier,
+		Status:     authz.Status,
+		Wildcard:   authz.Wildcard,
+		Challenges: []challengeObject{},
+	}
+	if order.Status.Expires != nil {
+		a.Expires = order.Status.Expires.UTC().Format(time.RFC3339)
+	}
+	for _, challenge := range authz.Challenges {
+		a.Challenges = append(a.Challenges, s.challengeObject(r, order, i, challenge))
+	}
+	return a
+}
+
+func (s *Server) challengeObject(r *http.Request, order *api.AcmeOrder, i int, challenge api.AcmeChallenge) challengeObject {
+	c := challengeObject{
+		Type:   challenge.Type,
+		URL:    s.url(r, fmt.Sprintf("challenge/%s/%d/%s", order.Name, i, challenge.Type)),
+		Token:  challenge.Token,
+		Status: challenge.Status,
+	}
+	if challenge.Validated != nil {
+		c.Validated = challenge.Validated.UTC().Format(time.RFC3339)
+	}
+	if challenge.Error != "" {
+		c.Error = &problem{Type: errorNamespace + "incorrectResponse", Detail: challenge.Error, Status: http.StatusForbidden}
+	}
+	return c
+}
+
+// Authorization of the domain with http-01 and dns-01 challenges.
+// Wildcards can be validated only with dns-01.
+func newAuthorization(domain string, wildcard bool) (api.AcmeAuthorization, error) {
+	authz := api.AcmeAuthorization{
+		Identifier: api.AcmeIdentifier{Type: "dns", Value: domain},
+		Status:     api.AcmeStatusPending,
+		Wildcard:   wildcard,
+	}
+	types := []string{challengeHTTP01, challengeDNS01}
+	if wildcard {
+		types = []string{challengeDNS01}
+	}
+	for _, t := range types {
+		token := make([]byte, 32)
+		if _, err := rand.Read(token); err != nil {
+			return authz, err
+		}
+		authz.Challenges = append(authz.Challenges, api.AcmeChallenge{
+			Type:   t,
+			Token:  encode(token),
+			Status: api.AcmeStatusPending,
+		})
+	}
+	return authz, nil
+}
+
+func checkContact(contact []string) *problem {
+	for _, c := range contact {
+		if !strings.HasPrefix(c, "mailto:") {
+			return newProblem(http.StatusBadRequest, "unsupportedContact", "Contact %s is not supported. Only 'mailto:' is", c)
+		}
+	}
+	return nil
+}
+
+// The account name is derived from the key so that the account can be found by the key
+func accountName(key *jose.JSONWebKey) string {
+	return "account-" + hex.EncodeToString(thumbprint(key)[:16])
+}
+
+func randomName(prefix string) (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// Absolute URL of the ACME resource
+func (s *Server) url(r *http.Request, resource string) string {
+	base := strings.TrimSuffix(s.ExternalURL, "/")
+	if base == "" {
+		scheme := "https"
+		if r.TLS == nil {
+			scheme = "http"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + basePath + resource
+}
+
+func (s *Server) addNonce(w http.ResponseWriter) error {
+	nonce, err := s.nonces.issue()
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Replay-Nonce", nonce)
+	return nil
+}
+
+func (s *Server) addIndexLink(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Link", fmt.Sprintf("<%s>;rel=\"index\"", s.url(r, "directory")))
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, body interface{}) {
+	s.write(w, r, status, "application/json", body)
+}
+
+func (s *Server) writeProblem(w http.ResponseWriter, r *http.Request, p *problem) {
+	s.write(w, r, p.Status, "application/problem+json", p)
+}
+
+func (s *Server) write(w http.ResponseWriter, r *http.Request, status int, contentType string, body interface{}) {
+	if err := s.addNonce(w); err != nil {
+		s.Log.Error(err, "Cannot issue nonce")
+	}
+	s.addIndexLink(w, r)
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		s.Log.Error(err, "Cannot write ACME response")
+	}
+}
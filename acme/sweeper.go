@@ -0,0 +1,66 @@
+package acme
+
+import (
+	"context"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// OrderSweeper periodically removes expired AcmeOrders (with their AdcsRequests).
+// Processing orders are kept and brought up to date, so the ones completed while
+// the client wasn't polling are removed by a later run.
+type OrderSweeper struct {
+	Server   *Server
+	Interval time.Duration
+}
+
+// Start implements manager.Runnable
+func (s *OrderSweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.sweep(ctx, time.Now())
+		}
+	}
+}
+
+func (s *OrderSweeper) sweep(ctx context.Context, now time.Time) {
+	log := s.Server.Log
+	list := new(api.AcmeOrderList)
+	if err := s.Server.Reader.List(ctx, list, client.InNamespace(s.Server.Namespace)); err != nil {
+		log.Error(err, "Cannot list AcmeOrders")
+		return
+	}
+
+	removed := 0
+	for i := range list.Items {
+		order := &list.Items[i]
+		if order.Status.Status == api.AcmeStatusProcessing {
+			if err := s.Server.refresh(ctx, order); err != nil {
+				log.Error(err, "Cannot refresh AcmeOrder", "order", order.Name)
+			}
+			continue
+		}
+		// Orders whose status was never set expire too
+		expires := order.CreationTimestamp.Add(orderLifetime)
+		if order.Status.Expires != nil {
+			expires = order.Status.Expires.Time
+		}
+		if now.Before(expires) {
+			continue
+		}
+		if err := s.Server.Client.Delete(ctx, order); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Cannot delete expired AcmeOrder", "order", order.Name)
+			continue
+		}
+		removed++
+	}
+	log.Info("AcmeOrders checked", "total", len(list.Items), "removed", removed)
+}
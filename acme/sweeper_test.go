@@ -0,0 +1,80 @@
+package acme
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func TestSweep(t *testing.T) {
+	now := time.Now()
+	order := func(name string, status api.AcmeStatus, expires time.Time) *api.AcmeOrder {
+		return &api.AcmeOrder{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "acme", Name: name},
+			Status:     api.AcmeOrderStatus{Status: status, Expires: &metav1.Time{Time: expires}},
+		}
+	}
+	c := newTestClient(t,
+		order("expired", api.AcmeStatusValid, now.Add(-time.Minute)),
+		order("valid", api.AcmeStatusValid, now.Add(time.Hour)),
+		// Its AdcsRequest doesn't exist, it becomes invalid and is removed by a later run
+		order("processing", api.AcmeStatusProcessing, now.Add(-time.Minute)),
+	)
+	s := &OrderSweeper{Server: &Server{Client: c, Reader: c, Namespace: "acme", Log: logr.Discard()}}
+
+	s.sweep(context.Background(), now)
+	for name, exists := range map[string]bool{"expired": false, "valid": true, "processing": true} {
+		err := c.Get(context.Background(), client.ObjectKey{Namespace: "acme", Name: name}, new(api.AcmeOrder))
+		if exists && err != nil || !exists && !apierrors.IsNotFound(err) {
+			t.Errorf("order %s: expected exists %t, got %v", name, exists, err)
+		}
+	}
+
+	s.sweep(context.Background(), now)
+	err := c.Get(context.Background(), client.ObjectKey{Namespace: "acme", Name: "processing"}, new(api.AcmeOrder))
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected completed order removed, got %v", err)
+	}
+}
+
+func TestCheckLimit(t *testing.T) {
+	c := newTestClient(t,
+		&api.AcmeOrder{ObjectMeta: metav1.ObjectMeta{Namespace: "acme", Name: "order-1",
+			Labels: map[string]string{accountLabel: "account-1"}}},
+		&api.AcmeOrder{ObjectMeta: metav1.ObjectMeta{Namespace: "acme", Name: "order-2",
+			Labels: map[string]string{accountLabel: "account-2"}}},
+		&api.AcmeOrder{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "order-3"}},
+	)
+	s := &Server{Client: c, Reader: c, Namespace: "acme"}
+
+	tests := []struct {
+		name  string
+		limit int
+		opts  []client.ListOption
+		ok    bool
+	}{
+		{"not limited", 0, nil, true},
+		{"under the limit", 3, nil, true},
+		{"limit reached", 2, nil, false},
+		{"account under the limit", 2, []client.ListOption{client.MatchingLabels{accountLabel: "account-1"}}, true},
+		{"account limit reached", 1, []client.ListOption{client.MatchingLabels{accountLabel: "account-1"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := s.checkLimit(context.Background(), new(api.AcmeOrderList), tt.limit, "orders", tt.opts...)
+			if tt.ok && p != nil {
+				t.Errorf("unexpected problem: %s", p.Detail)
+			}
+			if !tt.ok && (p == nil || p.Type != errorNamespace+"rateLimited") {
+				t.Errorf("expected rateLimited, got %v", p)
+			}
+		})
+	}
+}
@@ -1,5 +1,10 @@
 package adcs
 
+import (
+	"errors"
+	"fmt"
+)
+
 type AdcsResponseStatus int
 
 const (
@@ -10,14 +15,28 @@ const (
 	Rejected AdcsResponseStatus = 4
 )
 
+// Returned by DenyRequest when the certsrv doesn't provide a way to deny requests.
+var ErrDenyNotSupported = errors.New("Denying requests is not supported by ADCS Certsrv")
+
+// Returned when certsrv responds with HTTP error status e.g. 401 for invalid credentials.
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("ADCS Certsrv response status %s", e.Status)
+}
+
 type AdcsCertsrv interface {
 	// Request new certificate.
 	// Returns (cert status, certificate or description, id, error)
 	// If cert status is 'Unknown' the state of the certificate info couldn't be obtained from  certsrv. Check for error.
-	// If cert status is 'Ready' the cert is returned immediately in 'certificate'.
+	// If cert status is 'Ready' the cert is returned immediately in 'certificate'. The 'id' is empty if certsrv didn't report it.
 	// If cert status is 'Pending' the cert can be obtained later with getExistingCertificate using the 'id' (see 'description' for more details)
 	// If cert status is 'Error' see 'description' for details.
-	RequestCertificate(csr string, template string) (AdcsResponseStatus, string, string, error)
+	// The 'attributes' are sent as additional request attributes (CertAttrib) next to the certificate template.
+	RequestCertificate(csr string, template string, attributes map[string]string) (AdcsResponseStatus, string, string, error)
 
 	// Get previously requested certicate from Certserv
 	// Returns (cert status, certificate or description, id, error)
@@ -27,6 +46,10 @@ type AdcsCertsrv interface {
 	// If cert status is 'Error' see 'description' for details.
 	GetExistingCertificate(id string) (AdcsResponseStatus, string, string, error)
 
+	// Deny pending request so it's removed from the CA's pending queue and can't be issued anymore.
+	// Returns ErrDenyNotSupported if certsrv doesn't allow that.
+	DenyRequest(id string) error
+
 	// Get the certsrv' CA cert
 	// Returns ( certificate, error)
 	GetCaCertificate() (string, error)
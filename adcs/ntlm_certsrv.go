@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"github.com/Azure/go-ntlmssp"
 	"github.com/golang/glog"
@@ -11,6 +12,7 @@ import (
 	"net/http"
 	neturl "net/url"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -27,11 +29,19 @@ const (
 	certnew_p7b = "certnew.p7b"
 	certcarc    = "certcarc.asp"
 	certfnsh    = "certfnsh.asp"
+	certdeny    = "certdeny.asp"
 
 	ct_pkix   = "application/pkix-cert"
 	ct_pkcs7  = "application/x-pkcs7-certificates"
 	ct_html   = "text/html"
 	ct_urlenc = "application/x-www-form-urlencoded"
+
+	// Version of the certdeny.asp page shipped in config/certsrv. The page
+	// reports its version in the X-Certdeny-Version response header.
+	certdenyVersion       = "2"
+	certdenyVersionHeader = "X-Certdeny-Version"
+	// Required by the page so that it can't be called with cross-site form posts
+	certdenyRequestHeader = "X-Certdeny-Request"
 )
 
 func NewNtlmCertsrv(url string, username string, password string, caCertPool *x509.CertPool, verify bool) (AdcsCertsrv, error) {
@@ -143,7 +153,7 @@ func (s *NtlmCertsrv) GetExistingCertificate(id string) (AdcsResponseStatus, str
 					disp = found[0]
 				}
 				err = fmt.Errorf("Disposition message unknown: %s", disp)
-				glog.Errorf(err.Error())
+				glog.Errorf("%s", err.Error())
 			}
 
 			lastStatusMessage := ""
@@ -166,11 +176,11 @@ func (s *NtlmCertsrv) GetExistingCertificate(id string) (AdcsResponseStatus, str
 			return Ready, string(cert), id, nil
 		default:
 			err = fmt.Errorf("Unexpected content type %s:", ct)
-			glog.Errorf(err.Error())
+			glog.Errorf("%s", err.Error())
 			return certStatus, "", id, err
 		}
 	}
-	return certStatus, "", id, fmt.Errorf("ADCS Certsrv response status %s. Error: %s", res.Status, err.Error())
+	return certStatus, "", id, &StatusError{StatusCode: res.StatusCode, Status: res.Status}
 
 }
 
@@ -181,14 +191,14 @@ func (s *NtlmCertsrv) GetExistingCertificate(id string) (AdcsResponseStatus, str
  * - ADCS Request ID (if known)
  * - Error
  */
-func (s *NtlmCertsrv) RequestCertificate(csr string, template string) (AdcsResponseStatus, string, string, error) {
+func (s *NtlmCertsrv) RequestCertificate(csr string, template string, attributes map[string]string) (AdcsResponseStatus, string, string, error) {
 	var certStatus AdcsResponseStatus = Unknown
 
 	url := fmt.Sprintf("%s/%s", s.url, certfnsh)
 	params := neturl.Values{
 		"Mode":                {"newreq"},
 		"CertRequest":         {csr},
-		"CertAttrib":          {"CertificateTemplate:" + template},
+		"CertAttrib":          {certAttrib(template, attributes)},
 		"FriendlyType":        {"Saved-Request Certificate"},
 		"TargetStoreFlags":    {"0"},
 		"SaveCert":            {"yes"},
@@ -210,46 +220,124 @@ func (s *NtlmCertsrv) RequestCertificate(csr string, template string) (AdcsRespo
 		glog.Errorf("ADCS Certserv error: %s", err.Error())
 		return certStatus, "", "", err
 	}
+	defer res.Body.Close()
 	body, err := ioutil.ReadAll(res.Body)
-	if res.Header.Get("Content-type") == ct_pkix {
-		return Ready, string(body), "none", nil
-	}
-
 	if err != nil {
 		glog.Errorf("Cannot read ADCS Certserv response: %s", err.Error())
 		return certStatus, "", "", err
 	}
 
+	if res.StatusCode != http.StatusOK {
+		glog.Errorf("ADCS Certserv response status %s", res.Status)
+		return certStatus, "", "", &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+
+	if res.Header.Get("Content-type") == ct_pkix {
+		// Not sent by ADCS, it responds with a page linking to the certificate.
+		// The request ID is unknown in this case.
+		glog.Warningf("Certificate issued immediately but the request ID is unknown")
+		return Ready, string(body), "", nil
+	}
+
 	bodyString := string(body)
 
 	glog.V(1).Infof("Body:\n%s", bodyString)
 
+	certId, err := requestIdFromBody(bodyString)
+	if err != nil {
+		glog.Errorf("Couldn't obtain new certificate ID")
+		return certStatus, "", "", err
+	}
+
+	return s.GetExistingCertificate(certId)
+}
+
+// Find the request ID in the certfnsh.asp response. The 'Certificate Issued' page links
+// to the certnew.cer page with the 'ReqID' parameter. The 'Certificate Pending' page
+// tells the ID in its text. Otherwise the disposition message is returned as error.
+func requestIdFromBody(body string) (string, error) {
 	exp := regexp.MustCompile(`certnew.cer\?ReqID=([0-9]+)&`)
-	found := exp.FindStringSubmatch(bodyString)
-	certId := ""
+	found := exp.FindStringSubmatch(body)
 	if len(found) > 1 {
-		certId = found[1]
-	} else {
-		exp = regexp.MustCompile(`Your Request Id is ([0-9]+).`)
-		found = exp.FindStringSubmatch(bodyString)
-		if len(found) > 1 {
-			certId = found[1]
-		} else {
-			errorString := ""
-			exp = regexp.MustCompile(`The disposition message is "([^"]+)`)
-			found = exp.FindStringSubmatch(bodyString)
-			if len(found) > 1 {
-				errorString = found[1]
-			} else {
-				errorString = "Unknown error occured"
-				glog.Errorf(bodyString)
-			}
-			glog.Errorf("Couldn't obtain new certificate ID")
-			return certStatus, "", "", fmt.Errorf(errorString)
-		}
+		return found[1], nil
+	}
+	exp = regexp.MustCompile(`Your Request Id is ([0-9]+).`)
+	found = exp.FindStringSubmatch(body)
+	if len(found) > 1 {
+		return found[1], nil
 	}
+	exp = regexp.MustCompile(`The disposition message is "([^"]+)`)
+	found = exp.FindStringSubmatch(body)
+	if len(found) > 1 {
+		return "", errors.New(found[1])
+	}
+	glog.Errorf("%s", body)
+	return "", fmt.Errorf("Unknown error occured")
+}
 
-	return s.GetExistingCertificate(certId)
+// The standard certsrv web enrollment pages can't deny requests. It's done with the certdeny.asp page
+// from config/certsrv that must be installed in the certsrv directory next to them (it calls
+// ICertAdmin::DenyRequest). A missing page, a page of another version or a page that doesn't allow
+// the controller's account gives ErrDenyNotSupported.
+func (s *NtlmCertsrv) DenyRequest(id string) error {
+	url := fmt.Sprintf("%s/%s", s.url, certdeny)
+	params := neturl.Values{
+		"ReqID": {id},
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewBufferString(params.Encode()))
+	if err != nil {
+		glog.Errorf("Cannot create request: %s", err.Error())
+		return err
+	}
+	req.SetBasicAuth(s.username, s.password)
+	req.Header.Set("User-agent", "Mozilla")
+	req.Header.Set("Content-type", ct_urlenc)
+	req.Header.Set(certdenyRequestHeader, "1")
+
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		glog.Errorf("ADCS Certserv error: %s", err.Error())
+		return err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		glog.Errorf("Cannot read ADCS Certserv response: %s", err.Error())
+		return err
+	}
+
+	version := res.Header.Get(certdenyVersionHeader)
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return ErrDenyNotSupported
+	case res.StatusCode == http.StatusOK && version == certdenyVersion:
+		glog.Infof("ADCS request %s denied", id)
+		return nil
+	case res.StatusCode == http.StatusForbidden && version == certdenyVersion:
+		// The account is not on the page's ALLOWED_USERS list
+		return fmt.Errorf("%w: %s doesn't allow user %s", ErrDenyNotSupported, certdeny, s.username)
+	case res.StatusCode == http.StatusOK || version != "" && version != certdenyVersion:
+		// Not our page (e.g. a custom error page) or an outdated certdeny.asp
+		return fmt.Errorf("%w: %s version %q found, version %q required", ErrDenyNotSupported, certdeny, version, certdenyVersion)
+	}
+	glog.Errorf("ADCS Certsrv response status %s: %s", res.Status, strings.TrimSpace(string(body)))
+	return &StatusError{StatusCode: res.StatusCode, Status: res.Status}
+}
+
+// Build the CertAttrib parameter: 'name:value' pairs separated by new lines.
+// The certificate template always goes first, other attributes are sorted by name.
+func certAttrib(template string, attributes map[string]string) string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"CertificateTemplate:" + template}
+	for _, name := range names {
+		lines = append(lines, name+":"+attributes[name])
+	}
+	return strings.Join(lines, "\n")
 }
 
 func (s *NtlmCertsrv) obtainCaCertificate(certPage string, expectedContentType string) (string, error) {
@@ -296,7 +384,7 @@ func (s *NtlmCertsrv) obtainCaCertificate(certPage string, expectedContentType s
 		ct := res2.Header.Get(http.CanonicalHeaderKey("content-type"))
 		if expectedContentType != ct {
 			err = fmt.Errorf("Unexpected content type %s:", ct)
-			glog.Errorf(err.Error())
+			glog.Errorf("%s", err.Error())
 			return "", err
 		}
 		body, err := ioutil.ReadAll(res2.Body)
@@ -306,7 +394,7 @@ func (s *NtlmCertsrv) obtainCaCertificate(certPage string, expectedContentType s
 		}
 		return string(body), nil
 	}
-	return "", fmt.Errorf("ADCS Certsrv response status %s. Error: %s", res2.Status, err.Error())
+	return "", &StatusError{StatusCode: res2.StatusCode, Status: res2.Status}
 }
 func (s *NtlmCertsrv) GetCaCertificate() (string, error) {
 	glog.Infof("Getting CA from ADCS Certsrv %s", s.url)
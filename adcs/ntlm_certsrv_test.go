@@ -0,0 +1,307 @@
+package adcs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testCertificate = "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n"
+
+// Response of certnew.cer for a request that was not issued, formatted as by ADCS
+func dispositionPage(message string, lastStatus string) string {
+	return fmt.Sprintf("<HTML><Body>\r\n"+
+		"\t<DT ID=locDispMsgLabel><Font Size=-1><B>Disposition message:</B></Font></DT><DD>\r\n\t\t%s\r\n\t</DD>\r\n"+
+		"\t<DT ID=locLastStatLabel><Font Size=-1><B>LastStatus:</B></Font></DT><DD>\r\n\t\t%s\r\n\t</DD>\r\n"+
+		"</Body></HTML>\r\n", message, lastStatus)
+}
+
+func TestRequestIdFromBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		id      string
+		wantErr string
+	}{
+		{
+			name: "issued",
+			body: `<A Href="certnew.cer?ReqID=1234&amp;Enc=b64">Download certificate</A>`,
+			id:   "1234",
+		},
+		{
+			name: "issued with chain link first",
+			body: `<A Href="certnew.p7b?ReqID=7&amp;Enc=b64">chain</A><A Href="certnew.cer?ReqID=7&amp;Enc=b64">cert</A>`,
+			id:   "7",
+		},
+		{
+			name: "pending",
+			body: `<P ID=locPageDesc>Your certificate request has been received. Your Request Id is 42.</P>`,
+			id:   "42",
+		},
+		{
+			name:    "denied",
+			body:    `The disposition message is "Denied by Policy Module".`,
+			wantErr: "Denied by Policy Module",
+		},
+		{
+			name:    "denied with percent sign",
+			body:    `The disposition message is "Quota 100% used".`,
+			wantErr: "Quota 100% used",
+		},
+		{
+			name:    "unknown page",
+			body:    `<html>Something went wrong</html>`,
+			wantErr: "Unknown error occured",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := requestIdFromBody(tt.body)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if id != tt.id {
+				t.Errorf("expected ID %q, got %q", tt.id, id)
+			}
+		})
+	}
+}
+
+func TestCertAttrib(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		attributes map[string]string
+		want       string
+	}{
+		{"template only", "WebServer", nil, "CertificateTemplate:WebServer"},
+		{"sorted attributes", "User", map[string]string{"san": "dns=a.example.com", "ValidityPeriod": "Days"},
+			"CertificateTemplate:User\nValidityPeriod:Days\nsan:dns=a.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := certAttrib(tt.template, tt.attributes); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetExistingCertificate(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        AdcsResponseStatus
+		description string
+		wantErr     bool
+	}{
+		{
+			name:        "issued",
+			status:      http.StatusOK,
+			contentType: ct_pkix,
+			body:        testCertificate,
+			want:        Ready,
+			description: testCertificate,
+		},
+		{
+			name:        "pending",
+			status:      http.StatusOK,
+			contentType: "text/html; charset=UTF-8",
+			body:        dispositionPage("Taken Under Submission", "The operation completed successfully. 0x0 (WIN32: 0)"),
+			want:        Pending,
+			description: "Taken Under Submission The operation completed successfully. 0x0 (WIN32: 0)",
+		},
+		{
+			name:        "denied",
+			status:      http.StatusOK,
+			contentType: ct_html,
+			body:        dispositionPage("Denied by Policy Module", "The request was denied. 0x80094014"),
+			want:        Rejected,
+			description: "Denied by Policy Module The request was denied. 0x80094014",
+		},
+		{
+			name:        "error",
+			status:      http.StatusOK,
+			contentType: ct_html,
+			body:        dispositionPage("Error Verifying Request Signature", "Bad signature. 0x80090006"),
+			want:        Errored,
+			description: "Error Verifying Request Signature Bad signature. 0x80090006",
+		},
+		{
+			name:        "unexpected page",
+			status:      http.StatusOK,
+			contentType: ct_html,
+			body:        "<html>Login</html>",
+			want:        Unknown,
+			wantErr:     true,
+		},
+		{
+			name:        "unexpected content type",
+			status:      http.StatusOK,
+			contentType: "application/json",
+			body:        "{}",
+			want:        Unknown,
+			wantErr:     true,
+		},
+		{
+			name:    "unauthorized",
+			status:  http.StatusUnauthorized,
+			want:    Unknown,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/"+certnew_cer || r.URL.Query().Get("ReqID") != "17" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				w.Header().Set("Content-Type", tt.contentType)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			s := &NtlmCertsrv{url: srv.URL, httpClient: srv.Client()}
+
+			status, description, id, err := s.GetExistingCertificate("17")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status != tt.want {
+				t.Errorf("expected status %v, got %v", tt.want, status)
+			}
+			if !tt.wantErr && description != tt.description {
+				t.Errorf("expected description %q, got %q", tt.description, description)
+			}
+			if id != "17" {
+				t.Errorf("expected ID 17, got %q", id)
+			}
+		})
+	}
+}
+
+func TestRequestCertificate(t *testing.T) {
+	tests := []struct {
+		name     string
+		certfnsh string
+		certnew  string
+		want     AdcsResponseStatus
+		id       string
+		wantErr  bool
+	}{
+		{
+			name:     "issued immediately",
+			certfnsh: `<P ID=locInfo>The certificate you requested was issued to you.</P><A Href="certnew.cer?ReqID=21&amp;Enc=b64">Download certificate</A>`,
+			want:     Ready,
+			id:       "21",
+		},
+		{
+			name:     "pending",
+			certfnsh: `Your certificate request has been received. Your Request Id is 21.`,
+			certnew:  dispositionPage("Taken Under Submission", "0x0"),
+			want:     Pending,
+			id:       "21",
+		},
+		{
+			name:     "denied",
+			certfnsh: `The disposition message is "Denied by Policy Module  0x80094014".`,
+			want:     Unknown,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/" + certfnsh:
+					if err := r.ParseForm(); err != nil || r.PostForm.Get("CertificateTemplate") != "WebServer" {
+						t.Errorf("unexpected request form %v", r.PostForm)
+					}
+					w.Header().Set("Content-Type", ct_html)
+					fmt.Fprint(w, tt.certfnsh)
+				case "/" + certnew_cer:
+					if tt.certnew != "" {
+						w.Header().Set("Content-Type", ct_html)
+						fmt.Fprint(w, tt.certnew)
+						return
+					}
+					w.Header().Set("Content-Type", ct_pkix)
+					fmt.Fprint(w, testCertificate)
+				default:
+					t.Errorf("unexpected request %s", r.URL)
+				}
+			}))
+			defer srv.Close()
+			s := &NtlmCertsrv{url: srv.URL, httpClient: srv.Client()}
+
+			status, _, id, err := s.RequestCertificate("csr", "WebServer", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status != tt.want {
+				t.Errorf("expected status %v, got %v", tt.want, status)
+			}
+			if id != tt.id {
+				t.Errorf("expected ID %q, got %q", tt.id, id)
+			}
+		})
+	}
+}
+
+func TestDenyRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		version     string
+		wantErr     bool
+		unsupported bool
+	}{
+		{"denied", http.StatusOK, certdenyVersion, false, false},
+		{"page missing", http.StatusNotFound, "", true, true},
+		{"not our page", http.StatusOK, "", true, true},
+		{"other version", http.StatusOK, "0", true, true},
+		{"other version failing", http.StatusInternalServerError, "0", true, true},
+		{"deny failed", http.StatusInternalServerError, certdenyVersion, true, false},
+		{"unauthorized", http.StatusUnauthorized, "", true, false},
+		{"user not allowed", http.StatusForbidden, certdenyVersion, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/"+certdeny {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				if r.Header.Get(certdenyRequestHeader) != "1" {
+					t.Errorf("expected %s header", certdenyRequestHeader)
+				}
+				if err := r.ParseForm(); err != nil || r.PostForm.Get("ReqID") != "21" {
+					t.Errorf("unexpected request form %v", r.PostForm)
+				}
+				if tt.version != "" {
+					w.Header().Set(certdenyVersionHeader, tt.version)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			s := &NtlmCertsrv{url: srv.URL, httpClient: srv.Client()}
+
+			err := s.DenyRequest("21")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if errors.Is(err, ErrDenyNotSupported) != tt.unsupported {
+				t.Errorf("expected unsupported %t, got %v", tt.unsupported, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AcmeAccountSpec defines the desired state of AcmeAccount
+type AcmeAccountSpec struct {
+	// Key is the account's public key as JSON Web Key (RFC 7517).
+	// The account name is derived from the key's thumbprint.
+	Key string `json:"key"`
+
+	// Contact URLs (e.g. 'mailto:admin@example.com') provided by the ACME client.
+	// +optional
+	Contact []string `json:"contact,omitempty"`
+}
+
+// AcmeAccountStatus defines the observed state of AcmeAccount
+type AcmeAccountStatus struct {
+	// Status of the account: 'valid' or 'deactivated'.
+	// +optional
+	Status AcmeStatus `json:"status,omitempty"`
+
+	// UsedNonces are the nonces of the account's requests that haven't expired
+	// yet. They are kept in the account so that all replicas of the ACME server
+	// reject replayed requests.
+	// +optional
+	UsedNonces []string `json:"usedNonces,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=acmeaccounts,scope=Namespaced
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.status"
+
+// AcmeAccount is an account registered with the ACME server
+type AcmeAccount struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AcmeAccountSpec   `json:"spec,omitempty"`
+	Status AcmeAccountStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AcmeAccountList contains a list of AcmeAccount
+type AcmeAccountList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AcmeAccount `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AcmeAccount{}, &AcmeAccountList{})
+}
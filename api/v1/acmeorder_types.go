@@ -0,0 +1,146 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+)
+
+// AcmeOrderSpec defines the desired state of AcmeOrder
+type AcmeOrderSpec struct {
+	// Account is the name of the AcmeAccount that created the order.
+	Account string `json:"account"`
+
+	// Identifiers the certificate is ordered for.
+	Identifiers []AcmeIdentifier `json:"identifiers"`
+
+	// IssuerRef references the issuer the certificate is requested from.
+	IssuerRef cmmeta.ObjectReference `json:"issuerRef"`
+
+	// Certificate signing request bytes in PEM encoding submitted to finalize the order.
+	// +optional
+	CSRPEM []byte `json:"csr,omitempty"`
+}
+
+// AcmeOrderStatus defines the observed state of AcmeOrder
+type AcmeOrderStatus struct {
+	// Status of the order: 'pending', 'ready', 'processing', 'valid' or 'invalid'.
+	// +optional
+	Status AcmeStatus `json:"status,omitempty"`
+
+	// Expires is the time after which pending orders can't be finalized anymore.
+	// +optional
+	Expires *metav1.Time `json:"expires,omitempty"`
+
+	// Authorizations of the order's identifiers.
+	// +optional
+	Authorizations []AcmeAuthorization `json:"authorizations,omitempty"`
+
+	// ID of the ADCS request. It's copied from the order's AdcsRequest.
+	// +optional
+	RequestID string `json:"requestId,omitempty"`
+
+	// Error describes why the order is invalid.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// AcmeIdentifier is an identifier the certificate is ordered for.
+type AcmeIdentifier struct {
+	// Type of the identifier. Only 'dns' is supported.
+	Type string `json:"type"`
+
+	// Value of the identifier e.g. 'www.example.com'.
+	Value string `json:"value"`
+}
+
+// AcmeAuthorization is the authorization of an identifier.
+type AcmeAuthorization struct {
+	Identifier AcmeIdentifier `json:"identifier"`
+
+	// Status of the authorization: 'pending', 'valid' or 'invalid'.
+	Status AcmeStatus `json:"status"`
+
+	// Wildcard is set for '*.' identifiers (the prefix is not part of the identifier value).
+	// +optional
+	Wildcard bool `json:"wildcard,omitempty"`
+
+	// Challenges offered to prove control of the identifier.
+	Challenges []AcmeChallenge `json:"challenges"`
+}
+
+// AcmeChallenge is a challenge of an authorization.
+type AcmeChallenge struct {
+	// Type of the challenge: 'http-01' or 'dns-01'.
+	Type string `json:"type"`
+
+	// Token of the challenge.
+	Token string `json:"token"`
+
+	// Status of the challenge: 'pending', 'processing', 'valid' or 'invalid'.
+	Status AcmeStatus `json:"status"`
+
+	// Validated is the time the challenge was validated.
+	// +optional
+	Validated *metav1.Time `json:"validated,omitempty"`
+
+	// Error describes why the validation failed.
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// AcmeStatus is the status of ACME objects (RFC 8555 section 7.1.6).
+// +kubebuilder:validation:Enum=pending;ready;processing;valid;invalid;deactivated
+type AcmeStatus string
+
+const (
+	AcmeStatusPending     AcmeStatus = "pending"
+	AcmeStatusReady       AcmeStatus = "ready"
+	AcmeStatusProcessing  AcmeStatus = "processing"
+	AcmeStatusValid       AcmeStatus = "valid"
+	AcmeStatusInvalid     AcmeStatus = "invalid"
+	AcmeStatusDeactivated AcmeStatus = "deactivated"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=acmeorders,scope=Namespaced
+// +kubebuilder:printcolumn:name="Status",type="string",JSONPath=".status.status"
+// +kubebuilder:printcolumn:name="ID",type="string",JSONPath=".status.requestId"
+
+// AcmeOrder is a certificate order received by the ACME server
+type AcmeOrder struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AcmeOrderSpec   `json:"spec,omitempty"`
+	Status AcmeOrderStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// AcmeOrderList contains a list of AcmeOrder
+type AcmeOrderList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AcmeOrder `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AcmeOrder{}, &AcmeOrderList{})
+}
@@ -1,7 +1,11 @@
 package v1
 
 import (
+	"context"
+	"fmt"
+	"net"
 	"regexp"
+	"strings"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -11,6 +15,7 @@ import (
 	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
@@ -19,7 +24,23 @@ import (
 
 var log = logf.Log.WithName("adcsissuer-resource")
 
+var (
+	sidFormat = regexp.MustCompile(`^S-1-[0-9]+(-[0-9]+)+$`)
+	// Sub-authorities of SIDs allowed to requests can end with '*'
+	sidPatternFormat = regexp.MustCompile(`^S-1-[0-9]+(-([0-9]+\*?|\*))+$`)
+)
+
+// EST and SCEP labels are used in URL paths and as label values of AdcsRequests
+var labelFormat = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+// Label of the EST and SCEP paths without label. Only cluster issuers can use it.
+const DefaultLabel = "default"
+
+// Reads the issuers to find the EST and SCEP labels used by other issuers. Set up with the webhooks.
+var issuerReader client.Reader
+
 func (r *AdcsIssuer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	issuerReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -36,9 +57,21 @@ func (r *AdcsIssuer) Default() {
 	if r.Spec.StatusCheckInterval == "" {
 		r.Spec.StatusCheckInterval = "6h"
 	}
+	if r.Spec.InitialStatusCheckInterval == "" {
+		r.Spec.InitialStatusCheckInterval = "1m"
+	}
 	if r.Spec.RetryInterval == "" {
 		r.Spec.RetryInterval = "1h"
 	}
+	if r.Spec.Template == "" {
+		r.Spec.Template = "BasicSSLWebServer"
+	}
+	if r.Spec.OnRejection == "" {
+		r.Spec.OnRejection = RejectionPolicyFail
+	}
+	if r.Spec.Mode == "" {
+		r.Spec.Mode = IssuerModeOnline
+	}
 }
 
 // +kubebuilder:webhook:verbs=create;update,path=/validate-adcs-certmanager-csf-nokia-com-v1-adcsissuer,mutating=false,failurePolicy=fail,groups=adcs.certmanager.csf.nokia.com,resources=adcsissuer,versions=v1,name=adcsissuer-validation.adcs.certmanager.csf.nokia.com
@@ -81,16 +114,112 @@ func (r *AdcsIssuer) validateAdcsIssuer() error {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("statusCheckInterval"), r.Spec.StatusCheckInterval, err.Error()))
 	}
 
-	// Validate URL. Must be valide http or https URL
-	re := regexp.MustCompile(`(http|https):\/\/([\w\-_]+(?:(?:\.[\w\-_]+)+))([\w\-\.,@?^=%&amp;:/~\+#]*[\w\-\@?^=%&amp;/~\+#])?`)
-	if !re.MatchString(r.Spec.URL) {
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("url"), r.Spec.URL, "Invalid URL format. Must be valid 'http://' or 'https://' URL."))
+	// Validate Initial Status Check Interval
+	if r.Spec.InitialStatusCheckInterval != "" {
+		d, err := time.ParseDuration(r.Spec.InitialStatusCheckInterval)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("initialStatusCheckInterval"), r.Spec.InitialStatusCheckInterval, err.Error()))
+		}
 	}
 
-	// Validate CA Bundle. Must be a valid certificate PEM.
-	_, err = pki.DecodeX509CertificateBytes(r.Spec.CABundle)
-	if err != nil {
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("caBundle"), r.Spec.CABundle, err.Error()))
+	// Validate Max Duration
+	if r.Spec.MaxDuration != "" {
+		d, err := time.ParseDuration(r.Spec.MaxDuration)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err == nil && d%time.Hour != 0 {
+			// ADCS validity period can't be shorter than an hour
+			err = fmt.Errorf("must be whole hours")
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("maxDuration"), r.Spec.MaxDuration, err.Error()))
+		}
+	}
+
+	// The ADCS connection is not used in the manual mode
+	if r.Spec.Mode != IssuerModeManual {
+		// Validate URL. Must be valide http or https URL
+		re := regexp.MustCompile(`(http|https):\/\/([\w\-_]+(?:(?:\.[\w\-_]+)+))([\w\-\.,@?^=%&amp;:/~\+#]*[\w\-\@?^=%&amp;/~\+#])?`)
+		if !re.MatchString(r.Spec.URL) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("url"), r.Spec.URL, "Invalid URL format. Must be valid 'http://' or 'https://' URL."))
+		}
+
+		// Validate CA Bundle. Must be a valid certificate PEM.
+		_, err = pki.DecodeX509CertificateBytes(r.Spec.CABundle)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("caBundle"), r.Spec.CABundle, err.Error()))
+		}
+
+		if r.Spec.CredentialsRef.Name == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("spec").Child("credentialsRef").Child("name"), "ADCS credentials Secret is required."))
+		}
+	} else {
+		allErrs = append(allErrs, validateManualPolicy(field.NewPath("spec").Child("manual"), r.Spec.Manual)...)
+	}
+
+	// Validate request attributes. They are sent as 'name:value' lines.
+	allErrs = append(allErrs, validateAttributes(field.NewPath("spec").Child("defaultAttributes"), r.Spec.DefaultAttributes)...)
+	for i, name := range r.Spec.AllowedAttributes {
+		if name == "" || strings.ContainsAny(name, ":\r\n") {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("allowedAttributes").Index(i), name, "Invalid attribute name."))
+		}
+	}
+
+	if r.Spec.SID != nil {
+		allErrs = append(allErrs, validateSIDPolicy(field.NewPath("spec").Child("sid"), r.Spec.SID, false)...)
+	}
+	if r.Spec.EnrollmentAgent != nil {
+		allErrs = append(allErrs, validateEnrollmentAgentPolicy(field.NewPath("spec").Child("enrollmentAgent"), r.Spec.EnrollmentAgent, false)...)
+	}
+	if r.Spec.SubCA != nil {
+		allErrs = append(allErrs, validateSubCAPolicy(field.NewPath("spec").Child("subCA"), r.Spec.SubCA, false)...)
+	}
+
+	// Validate circuit breaker
+	if r.Spec.CircuitBreaker != nil && r.Spec.CircuitBreaker.OpenDuration != "" {
+		d, err := time.ParseDuration(r.Spec.CircuitBreaker.OpenDuration)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("circuitBreaker").Child("openDuration"), r.Spec.CircuitBreaker.OpenDuration, err.Error()))
+		}
+	}
+
+	// Validate maintenance windows
+	for i, w := range r.Spec.MaintenanceWindows {
+		if !w.End.After(w.Start.Time) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("maintenanceWindows").Index(i).Child("end"), w.End, "Must be after start."))
+		}
+	}
+
+	if r.Spec.EST != nil || r.Spec.SCEP != nil {
+		estLabels, scepLabels, err := usedLabels(r, false)
+		if err != nil {
+			return err
+		}
+		if r.Spec.EST != nil {
+			allErrs = append(allErrs, validateESTPolicy(field.NewPath("spec").Child("est"), r.Spec.EST, false, estLabels)...)
+		}
+		if r.Spec.SCEP != nil {
+			allErrs = append(allErrs, validateSCEPPolicy(field.NewPath("spec").Child("scep"), r.Spec.SCEP, false, scepLabels)...)
+		}
+	}
+
+	if r.Spec.CSI != nil {
+		path := field.NewPath("spec").Child("csi").Child("serviceAccounts")
+		if len(r.Spec.CSI.ServiceAccounts) == 0 {
+			allErrs = append(allErrs, field.Required(path, "At least one service account is required."))
+		}
+		for i, sa := range r.Spec.CSI.ServiceAccounts {
+			if sa == "" {
+				allErrs = append(allErrs, field.Invalid(path.Index(i), sa, "Service account must not be empty."))
+			}
+		}
 	}
 
 	// TODO: Validate credentials secret name?
@@ -103,3 +232,229 @@ func (r *AdcsIssuer) validateAdcsIssuer() error {
 		r.Name, allErrs)
 
 }
+
+func validateAttributes(path *field.Path, attributes map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+	for name, value := range attributes {
+		if name == "" || strings.ContainsAny(name, ":\r\n") {
+			allErrs = append(allErrs, field.Invalid(path.Key(name), name, "Invalid attribute name."))
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			allErrs = append(allErrs, field.Invalid(path.Key(name), value, "Attribute value must be a single line."))
+		}
+	}
+	return allErrs
+}
+
+// Cluster issuers must limit the namespaces that can request SIDs
+func validateSIDPolicy(path *field.Path, policy *SIDPolicy, cluster bool) field.ErrorList {
+	var allErrs field.ErrorList
+	if policy.Default != "" && !sidFormat.MatchString(policy.Default) {
+		allErrs = append(allErrs, field.Invalid(path.Child("default"), policy.Default, "Invalid SID format."))
+	}
+	for i, rule := range policy.AllowedRequestSIDs {
+		p := path.Child("allowedRequestSIDs").Index(i)
+		allErrs = append(allErrs, validateRuleNamespaces(p.Child("namespaces"), rule.Namespaces, cluster)...)
+		if len(rule.SIDs) == 0 {
+			allErrs = append(allErrs, field.Required(p.Child("sids"), "At least one SID is required."))
+		}
+		for j, sid := range rule.SIDs {
+			if !sidPatternFormat.MatchString(sid) {
+				allErrs = append(allErrs, field.Invalid(p.Child("sids").Index(j), sid, "Invalid SID pattern."))
+			}
+		}
+	}
+	return allErrs
+}
+
+// Rules of cluster issuers must list the namespaces they apply to
+func validateRuleNamespaces(path *field.Path, namespaces []string, cluster bool) field.ErrorList {
+	var allErrs field.ErrorList
+	if cluster && len(namespaces) == 0 {
+		allErrs = append(allErrs, field.Required(path, "At least one namespace is required."))
+	}
+	for i, ns := range namespaces {
+		if ns == "" {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), ns, "Namespace must not be empty."))
+		}
+	}
+	return allErrs
+}
+
+// Cluster issuers must limit the namespaces that can set requester names
+func validateEnrollmentAgentPolicy(path *field.Path, policy *EnrollmentAgentPolicy, cluster bool) field.ErrorList {
+	var allErrs field.ErrorList
+	if policy.SecretRef == "" {
+		allErrs = append(allErrs, field.Required(path.Child("secretRef"), "Enrollment Agent certificate Secret is required."))
+	}
+	if strings.ContainsAny(policy.RequesterName, "\r\n") {
+		allErrs = append(allErrs, field.Invalid(path.Child("requesterName"), policy.RequesterName, "Requester name must be a single line."))
+	}
+	for i, rule := range policy.AllowedRequesterNames {
+		p := path.Child("allowedRequesterNames").Index(i)
+		allErrs = append(allErrs, validateRuleNamespaces(p.Child("namespaces"), rule.Namespaces, cluster)...)
+		if len(rule.Names) == 0 {
+			allErrs = append(allErrs, field.Required(p.Child("names"), "At least one name is required."))
+		}
+		for j, name := range rule.Names {
+			if name == "" || strings.ContainsAny(name, "\r\n") {
+				allErrs = append(allErrs, field.Invalid(p.Child("names").Index(j), name, "Invalid requester name."))
+			}
+		}
+	}
+	return allErrs
+}
+
+// The uploaded certificates are verified against the trust bundle
+func validateManualPolicy(path *field.Path, policy *ManualPolicy) field.ErrorList {
+	var allErrs field.ErrorList
+	if policy == nil || len(policy.CABundle) == 0 {
+		return append(allErrs, field.Required(path.Child("caBundle"), "CA bundle is required in the manual mode."))
+	}
+	if _, err := pki.DecodeX509CertificateChainBytes(policy.CABundle); err != nil {
+		allErrs = append(allErrs, field.Invalid(path.Child("caBundle"), policy.CABundle, err.Error()))
+	}
+	return allErrs
+}
+
+// Cluster issuers must limit the namespaces that can get CA certificates
+func validateSubCAPolicy(path *field.Path, policy *SubCAPolicy, cluster bool) field.ErrorList {
+	var allErrs field.ErrorList
+	if policy.Template == "" {
+		allErrs = append(allErrs, field.Required(path.Child("template"), "SubCA template is required."))
+	}
+	if policy.MaxPathLen != nil && *policy.MaxPathLen < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("maxPathLen"), *policy.MaxPathLen, "Must not be negative."))
+	}
+	if cluster && len(policy.AllowedNamespaces) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("allowedNamespaces"), "At least one namespace is required."))
+	}
+	for i, ns := range policy.AllowedNamespaces {
+		if ns == "" {
+			allErrs = append(allErrs, field.Invalid(path.Child("allowedNamespaces").Index(i), ns, "Namespace must not be empty."))
+		}
+	}
+	return allErrs
+}
+
+// EST operations can't be used as labels
+var estOperations = []string{"cacerts", "simpleenroll", "simplereenroll", "serverkeygen", "csrattrs", "fullcmc"}
+
+func validateESTPolicy(path *field.Path, policy *ESTPolicy, cluster bool, used map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(policy.Labels) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("labels"), "At least one label is required."))
+	}
+	allErrs = append(allErrs, validateLabels(path.Child("labels"), policy.Labels, cluster, used)...)
+	for i, label := range policy.Labels {
+		for _, op := range estOperations {
+			if label == op {
+				allErrs = append(allErrs, field.Invalid(path.Child("labels").Index(i), label, "EST operation can't be used as label."))
+			}
+		}
+	}
+	clients := map[string]bool{}
+	for i, c := range policy.Clients {
+		p := path.Child("clients").Index(i)
+		if c.Client == "" {
+			allErrs = append(allErrs, field.Required(p.Child("client"), "Client is required."))
+		}
+		if clients[c.Client] {
+			allErrs = append(allErrs, field.Duplicate(p.Child("client"), c.Client))
+		}
+		clients[c.Client] = true
+		for j, cidr := range c.IPAddresses {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				allErrs = append(allErrs, field.Invalid(p.Child("ipAddresses").Index(j), cidr, "Invalid CIDR."))
+			}
+		}
+	}
+	return allErrs
+}
+
+func validateSCEPPolicy(path *field.Path, policy *SCEPPolicy, cluster bool, used map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+	if len(policy.Labels) == 0 {
+		allErrs = append(allErrs, field.Required(path.Child("labels"), "At least one label is required."))
+	}
+	allErrs = append(allErrs, validateLabels(path.Child("labels"), policy.Labels, cluster, used)...)
+	if policy.RASecretRef == "" {
+		allErrs = append(allErrs, field.Required(path.Child("raSecretRef"), "RA certificate Secret is required."))
+	}
+	if len(policy.ChallengePasswordSecretRefs) == 0 && !policy.OneTimeChallenges {
+		allErrs = append(allErrs, field.Required(path.Child("challengePasswordSecretRefs"), "At least one challenge password Secret or one-time challenges are required."))
+	}
+	return allErrs
+}
+
+// Labels must be unique. The default label is reserved for cluster issuers.
+func validateLabels(path *field.Path, labels []string, cluster bool, used map[string]string) field.ErrorList {
+	var allErrs field.ErrorList
+	seen := map[string]bool{}
+	for i, label := range labels {
+		if !labelFormat.MatchString(label) {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), label, "Invalid label format."))
+		}
+		if !cluster && label == DefaultLabel {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), label, "Default label can be used by ClusterAdcsIssuers only."))
+		}
+		if seen[label] {
+			allErrs = append(allErrs, field.Duplicate(path.Index(i), label))
+		}
+		seen[label] = true
+		if other, ok := used[label]; ok {
+			allErrs = append(allErrs, field.Invalid(path.Index(i), label, fmt.Sprintf("Label is used by %s.", other)))
+		}
+	}
+	return allErrs
+}
+
+// EST and SCEP labels of issuers other than the one validated, mapped to the issuer using them.
+// AdcsIssuers can't use labels of any other issuer. ClusterAdcsIssuers take precedence so they
+// can't use labels of other ClusterAdcsIssuers only.
+func usedLabels(self client.Object, cluster bool) (estLabels, scepLabels map[string]string, err error) {
+	estLabels = map[string]string{}
+	scepLabels = map[string]string{}
+	if issuerReader == nil {
+		return estLabels, scepLabels, nil
+	}
+	ctx := context.Background()
+	add := func(name string, est *ESTPolicy, scep *SCEPPolicy) {
+		if est != nil {
+			for _, label := range est.Labels {
+				estLabels[label] = name
+			}
+		}
+		if scep != nil {
+			for _, label := range scep.Labels {
+				scepLabels[label] = name
+			}
+		}
+	}
+
+	clusterIssuers := new(ClusterAdcsIssuerList)
+	if err := issuerReader.List(ctx, clusterIssuers); err != nil {
+		return nil, nil, fmt.Errorf("Cannot list ClusterAdcsIssuers: %s", err.Error())
+	}
+	for _, issuer := range clusterIssuers.Items {
+		if cluster && issuer.Name == self.GetName() {
+			continue
+		}
+		add("ClusterAdcsIssuer "+issuer.Name, issuer.Spec.EST, issuer.Spec.SCEP)
+	}
+	if cluster {
+		return estLabels, scepLabels, nil
+	}
+
+	adcsIssuers := new(AdcsIssuerList)
+	if err := issuerReader.List(ctx, adcsIssuers); err != nil {
+		return nil, nil, fmt.Errorf("Cannot list AdcsIssuers: %s", err.Error())
+	}
+	for _, issuer := range adcsIssuers.Items {
+		if issuer.Namespace == self.GetNamespace() && issuer.Name == self.GetName() {
+			continue
+		}
+		add("AdcsIssuer "+issuer.Namespace+"/"+issuer.Name, issuer.Spec.EST, issuer.Spec.SCEP)
+	}
+	return estLabels, scepLabels, nil
+}
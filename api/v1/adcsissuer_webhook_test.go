@@ -0,0 +1,119 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidateLabels(t *testing.T) {
+	used := map[string]string{"taken": "AdcsIssuer team-b/adcs"}
+	tests := []struct {
+		name    string
+		labels  []string
+		cluster bool
+		errs    int
+	}{
+		{"valid", []string{"routers", "switches"}, false, 0},
+		{"invalid format", []string{"-routers"}, false, 1},
+		{"duplicate", []string{"routers", "routers"}, false, 1},
+		{"used by other issuer", []string{"taken"}, false, 1},
+		{"default label", []string{DefaultLabel}, false, 1},
+		{"default label of cluster issuer", []string{DefaultLabel}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateLabels(field.NewPath("labels"), tt.labels, tt.cluster, used)
+			if len(errs) != tt.errs {
+				t.Errorf("expected %d errors, got %v", tt.errs, errs)
+			}
+		})
+	}
+}
+
+func TestValidateSIDPolicy(t *testing.T) {
+	rule := func(namespaces []string, sids ...string) []RequestSIDRule {
+		return []RequestSIDRule{{Namespaces: namespaces, SIDs: sids}}
+	}
+	tests := []struct {
+		name    string
+		policy  *SIDPolicy
+		cluster bool
+		errs    int
+	}{
+		{"default", &SIDPolicy{Default: "S-1-5-21-1-2-3-1013"}, false, 0},
+		{"invalid default", &SIDPolicy{Default: "S-1-5-21-1-2-3-*"}, false, 1},
+		{"SIDs", &SIDPolicy{AllowedRequestSIDs: rule(nil, "S-1-5-21-1-2-3-1013", "S-1-5-21-1-2-3-11*")}, false, 0},
+		{"any account of the domain", &SIDPolicy{AllowedRequestSIDs: rule([]string{"team-a"}, "S-1-5-21-1-2-3-*")}, true, 0},
+		{"no SIDs", &SIDPolicy{AllowedRequestSIDs: rule(nil)}, false, 1},
+		{"invalid pattern", &SIDPolicy{AllowedRequestSIDs: rule(nil, "*", "S-1-5-", "S-1-5-*1")}, false, 3},
+		{"no namespaces in cluster issuer", &SIDPolicy{AllowedRequestSIDs: rule(nil, "S-1-5-21-1-2-3-1013")}, true, 1},
+		{"empty namespace", &SIDPolicy{AllowedRequestSIDs: rule([]string{""}, "S-1-5-21-1-2-3-1013")}, false, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateSIDPolicy(field.NewPath("sid"), tt.policy, tt.cluster)
+			if len(errs) != tt.errs {
+				t.Errorf("expected %d errors, got %v", tt.errs, errs)
+			}
+		})
+	}
+}
+
+func TestValidateEnrollmentAgentPolicy(t *testing.T) {
+	rule := func(namespaces []string, names ...string) []RequesterNameRule {
+		return []RequesterNameRule{{Namespaces: namespaces, Names: names}}
+	}
+	tests := []struct {
+		name    string
+		policy  *EnrollmentAgentPolicy
+		cluster bool
+		errs    int
+	}{
+		{"requester name", &EnrollmentAgentPolicy{SecretRef: "agent", RequesterName: `CORP\svc`}, false, 0},
+		{"no Secret", &EnrollmentAgentPolicy{}, false, 1},
+		{"allowed names", &EnrollmentAgentPolicy{SecretRef: "agent", AllowedRequesterNames: rule(nil, `CORP\kiosk-*`)}, false, 0},
+		{"allowed names in namespaces", &EnrollmentAgentPolicy{SecretRef: "agent", AllowedRequesterNames: rule([]string{"team-a"}, `CORP\user`)}, true, 0},
+		{"no namespaces in cluster issuer", &EnrollmentAgentPolicy{SecretRef: "agent", AllowedRequesterNames: rule(nil, `CORP\user`)}, true, 1},
+		{"no names", &EnrollmentAgentPolicy{SecretRef: "agent", AllowedRequesterNames: rule(nil)}, false, 1},
+		{"invalid names", &EnrollmentAgentPolicy{SecretRef: "agent", AllowedRequesterNames: rule(nil, "", "CORP\nuser")}, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateEnrollmentAgentPolicy(field.NewPath("enrollmentAgent"), tt.policy, tt.cluster)
+			if len(errs) != tt.errs {
+				t.Errorf("expected %d errors, got %v", tt.errs, errs)
+			}
+		})
+	}
+}
+
+func TestValidateMaxDuration(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxDuration string
+		valid       bool
+	}{
+		{"not set", "", true},
+		{"whole hours", "8760h", true},
+		{"not whole hours", "90m", false},
+		{"shorter than hour", "30m", false},
+		{"negative", "-1h", false},
+		{"invalid", "year", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := &AdcsIssuer{Spec: AdcsIssuerSpec{MaxDuration: tt.maxDuration}}
+			cluster := &ClusterAdcsIssuer{Spec: ClusterAdcsIssuerSpec{MaxDuration: tt.maxDuration}}
+			for kind, err := range map[string]error{
+				"AdcsIssuer":        issuer.validateAdcsIssuer(),
+				"ClusterAdcsIssuer": cluster.validateClusterAdcsIssuer(),
+			} {
+				if valid := err == nil || !strings.Contains(err.Error(), "spec.maxDuration"); valid != tt.valid {
+					t.Errorf("%s: expected valid %t, got %v", kind, tt.valid, err)
+				}
+			}
+		})
+	}
+}
@@ -18,6 +18,7 @@ package v1
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
 )
 
@@ -40,6 +41,51 @@ type AdcsRequestSpec struct {
 	// If the Issuer is not an 'ADCS' Issuer, an error will be returned and the
 	// ADCSRequest will be marked as failed.
 	IssuerRef cmmeta.ObjectReference `json:"issuerRef"`
+
+	// Attributes are additional request attributes (CertAttrib) sent to the ADCS.
+	// They must be allowed by the issuer's AllowedAttributes.
+	// +optional
+	Attributes map[string]string `json:"attributes,omitempty"`
+
+	// SID of the AD account the certificate is issued for.
+	// It must be allowed by the issuer's SID policy.
+	// +optional
+	SID string `json:"sid,omitempty"`
+
+	// RequesterName is the account (e.g. 'DOMAIN\user') the certificate is requested
+	// on behalf of. It must be allowed by the issuer's enrollment agent policy.
+	// +optional
+	RequesterName string `json:"requesterName,omitempty"`
+
+	// RenewalSecretName is the name of the Secret with the certificate and key
+	// being renewed. Issuers with SignRenewals sign the request with them.
+	// +optional
+	RenewalSecretName string `json:"renewalSecretName,omitempty"`
+
+	// Requested 'duration' (i.e. lifetime) of the certificate.
+	// If not set the validity period of the certificate template is used.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// Usages is the set of x509 usages that are requested for the certificate.
+	// They are used to select the certificate template.
+	// +optional
+	Usages []cmapi.KeyUsage `json:"usages,omitempty"`
+
+	// IsCA will request to mark the certificate as valid for certificate signing.
+	// +optional
+	IsCA bool `json:"isCA,omitempty"`
+
+	// SecretName is the name of the Secret the issued certificate is written to
+	// ('tls.crt' with the certificate chain and 'ca.crt' with the CA certificate).
+	// It's required for AdcsRequests created without a CertificateRequest.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+
+	// Keystores additionally written to the SecretName Secret. Keystores
+	// with the private key are created only if the Secret contains 'tls.key'.
+	// +optional
+	Keystores *cmapi.CertificateKeystores `json:"keystores,omitempty"`
 }
 
 // AdcsRequestStatus defines the observed state of AdcsRequest
@@ -54,6 +100,21 @@ type AdcsRequestStatus struct {
 	// +optional
 	Id string `json:"id,omitempty"`
 
+	// Serial number of the issued certificate (hexadecimal).
+	// This field is populated when the certificate is issued.
+	// +optional
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// HResult is the status code (e.g. '0x80094014') reported by the ADCS
+	// for the request.
+	// +optional
+	HResult string `json:"hresult,omitempty"`
+
+	// CA is the common name of the ADCS certification authority serving
+	// the request.
+	// +optional
+	CA string `json:"ca,omitempty"`
+
 	// State contains the current state of this ADCSRequest resource.
 	// States 'ready' and 'rejected' are 'final'
 	// +optional
@@ -63,6 +124,40 @@ type AdcsRequestStatus struct {
 	// the current state.
 	// +optional
 	Reason string `json:"reason,omitempty"`
+
+	// LastCheckTime is the time when the request status was last checked in the ADCS.
+	// +optional
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+
+	// SubmittedTime is when the request was submitted to the ADCS (or started
+	// waiting for the upload in the manual mode). Status checks back off from it.
+	// +optional
+	SubmittedTime *metav1.Time `json:"submittedTime,omitempty"`
+
+	// NotAfter is the expiration time of the issued certificate.
+	// +optional
+	NotAfter *metav1.Time `json:"notAfter,omitempty"`
+
+	// AdmittedTime is when the request was let through the namespace quota
+	// of its ClusterAdcsIssuer. The quota counts requests from this time.
+	// Requests waiting for the quota don't have it set.
+	// +optional
+	AdmittedTime *metav1.Time `json:"admittedTime,omitempty"`
+
+	// Certificate is the PEM encoded issued certificate. It's set only for
+	// requests with SecretName or created by the ACME, EST or SCEP server.
+	// +optional
+	Certificate []byte `json:"certificate,omitempty"`
+
+	// CACertificate is the PEM encoded CA certificate chain. It's set only
+	// for requests with SecretName or created by the ACME, EST or SCEP server.
+	// +optional
+	CACertificate []byte `json:"caCertificate,omitempty"`
+
+	// CSR is the PEM encoded certificate request to be signed offline by a CA
+	// officer. It's set for pending requests of issuers in the 'manual' mode.
+	// +optional
+	CSR string `json:"csr,omitempty"`
 }
 
 // State represents the state of an ADCSRequest.
@@ -97,6 +192,7 @@ const (
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=adcsrequests,scope=Namespaced
 // +kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.state"
+// +kubebuilder:printcolumn:name="ID",type="string",JSONPath=".status.id"
 
 // AdcsRequest is the Schema for the adcsrequests API
 type AdcsRequest struct {
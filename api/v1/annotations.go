@@ -0,0 +1,61 @@
+package v1
+
+const (
+	// AttributeAnnotationPrefix is the prefix of CertificateRequest annotations
+	// that set ADCS request attributes (CertAttrib) e.g.
+	// 'adcs.certmanager.csf.nokia.com/attribute.san: dns=example.com'.
+	AttributeAnnotationPrefix = "adcs.certmanager.csf.nokia.com/attribute."
+
+	// SIDAnnotation sets the SID of the AD account the certificate is issued for.
+	SIDAnnotation = "adcs.certmanager.csf.nokia.com/sid"
+
+	// RequesterNameAnnotation sets the account the certificate is requested on behalf
+	// of by issuers with an enrollment agent.
+	RequesterNameAnnotation = "adcs.certmanager.csf.nokia.com/requester-name"
+
+	// Annotations set on CertificateRequests with details of the ADCS request
+	// (the ID assigned by ADCS, the disposition message, the status code and
+	// the name of the CA).
+	RequestIDAnnotation   = "adcs.certmanager.csf.nokia.com/request-id"
+	DispositionAnnotation = "adcs.certmanager.csf.nokia.com/disposition"
+	HResultAnnotation     = "adcs.certmanager.csf.nokia.com/hresult"
+	CAAnnotation          = "adcs.certmanager.csf.nokia.com/ca"
+
+	// CheckNowAnnotation set on a pending AdcsRequest forces immediate check
+	// of its status in the ADCS. It's removed when the check is done.
+	CheckNowAnnotation = "adcs.certmanager.csf.nokia.com/check-now"
+
+	// IssuedCertificateAnnotation uploads the certificate signed offline to a pending
+	// AdcsRequest of an issuer in the 'manual' mode. The value is the PEM encoded
+	// certificate optionally followed by its CA certificate chain.
+	IssuedCertificateAnnotation = "adcs.certmanager.csf.nokia.com/issued-certificate"
+
+	// IssuedCertificateSecretAnnotation refers to a Secret (in the AdcsRequest's namespace)
+	// with the certificate signed offline in 'tls.crt' and optionally its CA certificate
+	// chain in 'ca.crt'. It's an alternative to IssuedCertificateAnnotation.
+	IssuedCertificateSecretAnnotation = "adcs.certmanager.csf.nokia.com/issued-certificate-secret"
+
+	// ESTLabel is the label of AdcsRequests created by the EST server with
+	// the EST label the client enrolled with.
+	ESTLabel = "adcs.certmanager.csf.nokia.com/est-label"
+
+	// ESTClientAnnotation is set on AdcsRequests created by the EST server with
+	// the authenticated client (user name or client certificate subject).
+	ESTClientAnnotation = "adcs.certmanager.csf.nokia.com/est-client"
+
+	// SCEPLabel is the label of AdcsRequests created by the SCEP server with
+	// the SCEP label the client enrolled with.
+	SCEPLabel = "adcs.certmanager.csf.nokia.com/scep-label"
+
+	// SCEPTransactionAnnotation is set on AdcsRequests created by the SCEP server
+	// with the transaction ID of the enrollment.
+	SCEPTransactionAnnotation = "adcs.certmanager.csf.nokia.com/scep-transaction-id"
+
+	// SCEPChallengeLabel marks Secrets with one-time SCEP challenge passwords.
+	// Its value is the SCEP label the password can be used with.
+	SCEPChallengeLabel = "adcs.certmanager.csf.nokia.com/scep-challenge"
+
+	// CSIVolumeAnnotation is set on AdcsRequests created by the CSI driver with
+	// the ID of the volume the certificate is issued for.
+	CSIVolumeAnnotation = "adcs.certmanager.csf.nokia.com/csi-volume"
+)
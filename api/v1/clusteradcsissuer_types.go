@@ -27,13 +27,25 @@ type ClusterAdcsIssuerSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// URL is the base URL for the ADCS instance
-	URL string `json:"url"`
+	// Mode defines how the certificates are issued. Default 'online'.
+	// +optional
+	Mode IssuerMode `json:"mode,omitempty"`
+
+	// Manual configures the 'manual' mode. Required in this mode.
+	// +optional
+	Manual *ManualPolicy `json:"manual,omitempty"`
+
+	// URL is the base URL for the ADCS instance.
+	// Not used in the 'manual' mode.
+	// +optional
+	URL string `json:"url,omitempty"`
 
 	// CredentialsRef is a reference to a Secret containing the username and
 	// password for the ADCS server.
 	// The secret must contain two keys, 'username' and 'password'.
-	CredentialsRef LocalObjectReference `json:"credentialsRef"`
+	// Not used in the 'manual' mode.
+	// +optional
+	CredentialsRef LocalObjectReference `json:"credentialsRef,omitempty"`
 
 	// CABundle is a PEM encoded TLS certifiate to use to verify connections to
 	// the ADCS server.
@@ -45,16 +57,135 @@ type ClusterAdcsIssuerSpec struct {
 	// +optional
 	StatusCheckInterval string `json:"statusCheckInterval,omitempty"`
 
+	// How often to check for request status right after the request is submitted
+	// (in time.ParseDuration() format). The checks get less frequent as the request
+	// waits longer, up to StatusCheckInterval.
+	// Default 1 minute.
+	// +optional
+	InitialStatusCheckInterval string `json:"initialStatusCheckInterval,omitempty"`
+
 	// How often to retry in case of communication errors (in time.ParseDuration() format)
 	// Default 1 hour.
 	// +optional
 	RetryInterval string `json:"retryInterval,omitempty"`
+
+	// OnRejection defines how the CertificateRequest is completed when ADCS
+	// rejects the request: 'fail' (cert-manager re-tries it with backoff),
+	// 'deny' or 'hold' (kept pending, never re-tried).
+	// Default 'fail'.
+	// +optional
+	OnRejection RejectionPolicy `json:"onRejection,omitempty"`
+
+	// CancelPendingRequests denies requests still pending on ADCS when their
+	// AdcsRequest is deleted. The standard certsrv pages can't deny requests,
+	// so it needs the certdeny.asp page from config/certsrv installed on the
+	// server (see README).
+	// +optional
+	CancelPendingRequests bool `json:"cancelPendingRequests,omitempty"`
+
+	// Maximum certificate duration that can be requested (in time.ParseDuration() format).
+	// Longer requested durations are shortened to this value.
+	// Requested duration is sent to ADCS as 'ValidityPeriod' and 'ValidityPeriodUnits'
+	// request attributes (the CA must have the EDITF_ATTRIBUTEENDDATE flag set).
+	// +optional
+	MaxDuration string `json:"maxDuration,omitempty"`
+
+	// Template is the name of the ADCS certificate template used for requests
+	// when no TemplateRules are defined.
+	// Default 'BasicSSLWebServer'.
+	// +optional
+	Template string `json:"template,omitempty"`
+
+	// TemplateRules select the certificate template by requested key usages.
+	// The first matching rule is used. Requests not matching any rule are rejected.
+	// +optional
+	TemplateRules []TemplateRule `json:"templateRules,omitempty"`
+
+	// DefaultAttributes are request attributes (CertAttrib) sent to the ADCS
+	// with every request e.g. 'ValidityPeriod: Weeks'.
+	// They can be overridden per request if allowed by AllowedAttributes.
+	// +optional
+	DefaultAttributes map[string]string `json:"defaultAttributes,omitempty"`
+
+	// AllowedAttributes lists names of the request attributes that can be set
+	// per request with 'adcs.certmanager.csf.nokia.com/attribute.<name>'
+	// annotations of the CertificateRequest. Requests with other attributes are
+	// rejected. Names are case-insensitive.
+	// +optional
+	AllowedAttributes []string `json:"allowedAttributes,omitempty"`
+
+	// SID configures strong certificate mapping for AD client authentication
+	// certificates.
+	// +optional
+	SID *SIDPolicy `json:"sid,omitempty"`
+
+	// EnrollmentAgent signs the requests with an Enrollment Agent certificate
+	// (enroll on behalf of). The requests are sent as PKCS#7 signed data with
+	// the requester name attribute.
+	// +optional
+	EnrollmentAgent *EnrollmentAgentPolicy `json:"enrollmentAgent,omitempty"`
+
+	// SignRenewals sends renewals of certificates issued by the issuer as PKCS#7
+	// requests signed with the existing certificate and key. It's needed by
+	// templates that allow reenrollment with a valid existing certificate only.
+	// +optional
+	SignRenewals bool `json:"signRenewals,omitempty"`
+
+	// SubCA routes requests with 'isCA' set to a SubCA template. Without it
+	// 'isCA' requests are served by the template rules only.
+	// +optional
+	SubCA *SubCAPolicy `json:"subCA,omitempty"`
+
+	// Verification configures checks of the issued certificates.
+	// +optional
+	Verification *VerificationPolicy `json:"verification,omitempty"`
+
+	// RateLimit limits the load the issuer puts on the ADCS.
+	// +optional
+	RateLimit *RateLimitPolicy `json:"rateLimit,omitempty"`
+
+	// CircuitBreaker pauses communication with the ADCS after consecutive
+	// authentication or server failures.
+	// +optional
+	CircuitBreaker *CircuitBreakerPolicy `json:"circuitBreaker,omitempty"`
+
+	// Quota limits the number of certificates each namespace can request
+	// from the issuer. Requests over the quota are kept pending.
+	// +optional
+	Quota *QuotaPolicy `json:"quota,omitempty"`
+
+	// Suspend holds submission of new requests and status checks of pending
+	// requests until it's set back to false.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// MaintenanceWindows are periods when requests are held as with Suspend.
+	// The held requests are processed automatically after the window ends.
+	// +optional
+	MaintenanceWindows []MaintenanceWindow `json:"maintenanceWindows,omitempty"`
+
+	// EST enables enrollment over EST (RFC 7030) with the issuer.
+	// +optional
+	EST *ESTPolicy `json:"est,omitempty"`
+
+	// SCEP enables enrollment over SCEP (RFC 8894) with the issuer.
+	// +optional
+	SCEP *SCEPPolicy `json:"scep,omitempty"`
 }
 
 // ClusterAdcsIssuerStatus defines the observed state of ClusterAdcsIssuer
 type ClusterAdcsIssuerStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// Conditions of the issuer.
+	// +optional
+	Conditions []IssuerCondition `json:"conditions,omitempty"`
+
+	// QuotaUsage records the requests let through the namespace quotas. The hourly
+	// and daily quotas are counted from it, so deleting requests doesn't reset them.
+	// +optional
+	QuotaUsage []NamespaceQuotaUsage `json:"quotaUsage,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -16,7 +16,9 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
@@ -36,6 +38,7 @@ import (
 var clusteradcsissuerlog = logf.Log.WithName("clusteradcsissuer-resource")
 
 func (r *ClusterAdcsIssuer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	issuerReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -54,9 +57,21 @@ func (r *ClusterAdcsIssuer) Default() {
 	if r.Spec.StatusCheckInterval == "" {
 		r.Spec.StatusCheckInterval = "6h"
 	}
+	if r.Spec.InitialStatusCheckInterval == "" {
+		r.Spec.InitialStatusCheckInterval = "1m"
+	}
 	if r.Spec.RetryInterval == "" {
 		r.Spec.RetryInterval = "1h"
 	}
+	if r.Spec.Template == "" {
+		r.Spec.Template = "BasicSSLWebServer"
+	}
+	if r.Spec.OnRejection == "" {
+		r.Spec.OnRejection = RejectionPolicyFail
+	}
+	if r.Spec.Mode == "" {
+		r.Spec.Mode = IssuerModeOnline
+	}
 }
 
 // TODO(user): change verbs to "verbs=create;update;delete" if you want to enable deletion validation.
@@ -100,16 +115,100 @@ func (r *ClusterAdcsIssuer) validateClusterAdcsIssuer() error {
 		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("statusCheckInterval"), r.Spec.StatusCheckInterval, err.Error()))
 	}
 
-	// Validate URL. Must be valide http or https URL
-	re := regexp.MustCompile(`(http|https):\/\/([\w\-_]+(?:(?:\.[\w\-_]+)+))([\w\-\.,@?^=%&amp;:/~\+#]*[\w\-\@?^=%&amp;/~\+#])?`)
-	if !re.MatchString(r.Spec.URL) {
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("url"), r.Spec.URL, "Invalid URL format. Must be valid 'http://' or 'https://' URL."))
+	// Validate Initial Status Check Interval
+	if r.Spec.InitialStatusCheckInterval != "" {
+		d, err := time.ParseDuration(r.Spec.InitialStatusCheckInterval)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("initialStatusCheckInterval"), r.Spec.InitialStatusCheckInterval, err.Error()))
+		}
 	}
 
-	// Validate CA Bundle. Must be a valid certificate PEM.
-	_, err = pki.DecodeX509CertificateBytes(r.Spec.CABundle)
-	if err != nil {
-		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("caBundle"), r.Spec.CABundle, err.Error()))
+	// Validate Max Duration
+	if r.Spec.MaxDuration != "" {
+		d, err := time.ParseDuration(r.Spec.MaxDuration)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err == nil && d%time.Hour != 0 {
+			// ADCS validity period can't be shorter than an hour
+			err = fmt.Errorf("must be whole hours")
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("maxDuration"), r.Spec.MaxDuration, err.Error()))
+		}
+	}
+
+	// The ADCS connection is not used in the manual mode
+	if r.Spec.Mode != IssuerModeManual {
+		// Validate URL. Must be valide http or https URL
+		re := regexp.MustCompile(`(http|https):\/\/([\w\-_]+(?:(?:\.[\w\-_]+)+))([\w\-\.,@?^=%&amp;:/~\+#]*[\w\-\@?^=%&amp;/~\+#])?`)
+		if !re.MatchString(r.Spec.URL) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("url"), r.Spec.URL, "Invalid URL format. Must be valid 'http://' or 'https://' URL."))
+		}
+
+		// Validate CA Bundle. Must be a valid certificate PEM.
+		_, err = pki.DecodeX509CertificateBytes(r.Spec.CABundle)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("caBundle"), r.Spec.CABundle, err.Error()))
+		}
+
+		if r.Spec.CredentialsRef.Name == "" {
+			allErrs = append(allErrs, field.Required(field.NewPath("spec").Child("credentialsRef").Child("name"), "ADCS credentials Secret is required."))
+		}
+	} else {
+		allErrs = append(allErrs, validateManualPolicy(field.NewPath("spec").Child("manual"), r.Spec.Manual)...)
+	}
+
+	// Validate request attributes. They are sent as 'name:value' lines.
+	allErrs = append(allErrs, validateAttributes(field.NewPath("spec").Child("defaultAttributes"), r.Spec.DefaultAttributes)...)
+	for i, name := range r.Spec.AllowedAttributes {
+		if name == "" || strings.ContainsAny(name, ":\r\n") {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("allowedAttributes").Index(i), name, "Invalid attribute name."))
+		}
+	}
+
+	if r.Spec.SID != nil {
+		allErrs = append(allErrs, validateSIDPolicy(field.NewPath("spec").Child("sid"), r.Spec.SID, true)...)
+	}
+	if r.Spec.EnrollmentAgent != nil {
+		allErrs = append(allErrs, validateEnrollmentAgentPolicy(field.NewPath("spec").Child("enrollmentAgent"), r.Spec.EnrollmentAgent, true)...)
+	}
+	if r.Spec.SubCA != nil {
+		allErrs = append(allErrs, validateSubCAPolicy(field.NewPath("spec").Child("subCA"), r.Spec.SubCA, true)...)
+	}
+
+	// Validate circuit breaker
+	if r.Spec.CircuitBreaker != nil && r.Spec.CircuitBreaker.OpenDuration != "" {
+		d, err := time.ParseDuration(r.Spec.CircuitBreaker.OpenDuration)
+		if err == nil && d <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("circuitBreaker").Child("openDuration"), r.Spec.CircuitBreaker.OpenDuration, err.Error()))
+		}
+	}
+
+	// Validate maintenance windows
+	for i, w := range r.Spec.MaintenanceWindows {
+		if !w.End.After(w.Start.Time) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("maintenanceWindows").Index(i).Child("end"), w.End, "Must be after start."))
+		}
+	}
+
+	if r.Spec.EST != nil || r.Spec.SCEP != nil {
+		estLabels, scepLabels, err := usedLabels(r, true)
+		if err != nil {
+			return err
+		}
+		if r.Spec.EST != nil {
+			allErrs = append(allErrs, validateESTPolicy(field.NewPath("spec").Child("est"), r.Spec.EST, true, estLabels)...)
+		}
+		if r.Spec.SCEP != nil {
+			allErrs = append(allErrs, validateSCEPPolicy(field.NewPath("spec").Child("scep"), r.Spec.SCEP, true, scepLabels)...)
+		}
 	}
 
 	// TODO: Validate credentials secret name?
@@ -1,6 +1,419 @@
 package v1
 
+import (
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
 type LocalObjectReference struct {
 	// Name of the referent.
 	Name string `json:"name"`
 }
+
+// SIDPolicy configures the object SID sent to the ADCS for strong certificate
+// mapping of AD accounts (KB5014754).
+type SIDPolicy struct {
+	// Default is the SID (e.g. 'S-1-5-21-...') of the account the certificates are
+	// issued for. It is used for requests that don't set their own SID.
+	// +optional
+	Default string `json:"default,omitempty"`
+
+	// AllowedRequestSIDs lists the SIDs requests can set with the
+	// 'adcs.certmanager.csf.nokia.com/sid' CertificateRequest annotation.
+	// Requests with other SIDs are marked as errored. Requests can't set
+	// SIDs if not set.
+	// +optional
+	AllowedRequestSIDs []RequestSIDRule `json:"allowedRequestSIDs,omitempty"`
+
+	// Attribute is the name of the request attribute used to pass the SID
+	// to the CA policy module.
+	// If not set the SID is sent in the 'san' attribute as
+	// 'url=tag:microsoft.com,2022-09-14:sid:<SID>'.
+	// +optional
+	Attribute string `json:"attribute,omitempty"`
+
+	// Verify requires the issued certificate to carry the SID security extension
+	// (1.3.6.1.4.1.311.25.2) or the SID URL in its subject alternative names.
+	// Certificates without it are not accepted and the request is marked as errored.
+	// +optional
+	Verify bool `json:"verify,omitempty"`
+}
+
+// RequestSIDRule allows requests from the namespaces to set the SIDs.
+type RequestSIDRule struct {
+	// Namespaces the rule applies to. '*' matches all namespaces.
+	// Requests of CertificateSigningRequests are in the issuer's namespace
+	// (the cluster resource namespace for ClusterAdcsIssuers).
+	// Required for ClusterAdcsIssuers, the issuer's namespace if not set.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// SIDs that can be requested. '*' matches any characters, e.g.
+	// 'S-1-5-21-1004336348-1177238915-682003330-11*'. Note that a pattern ending
+	// with the domain SID and '-*' matches all accounts of the domain including
+	// its administrators.
+	SIDs []string `json:"sids"`
+}
+
+// TemplateRule selects the ADCS certificate template for requests with
+// matching key usages.
+type TemplateRule struct {
+	// Template is the name of the ADCS certificate template.
+	Template string `json:"template"`
+
+	// Usages the template can be used for. The rule matches requests whose
+	// all requested usages are on this list. Requests without usages are
+	// treated as requesting 'digital signature' and 'key encipherment'.
+	// +optional
+	Usages []cmapi.KeyUsage `json:"usages,omitempty"`
+
+	// IsCA must match the 'isCA' field of the request.
+	// +optional
+	IsCA bool `json:"isCA,omitempty"`
+}
+
+// VerificationPolicy configures verification of certificates issued by ADCS.
+// By default the certificate must match the public key and the subject
+// alternative names of the request, chain to the CA and be currently valid.
+type VerificationPolicy struct {
+	// Disabled turns off verification of issued certificates.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// AllowMissingSANs accepts certificates without some of the requested
+	// subject alternative names. Missing names are reported in the request status.
+	// +optional
+	AllowMissingSANs bool `json:"allowMissingSANs,omitempty"`
+
+	// SkipChain turns off checking that the certificate chains to the CA
+	// certificates obtained from ADCS.
+	// +optional
+	SkipChain bool `json:"skipChain,omitempty"`
+}
+
+// RateLimitPolicy limits the load the issuer puts on the ADCS.
+type RateLimitPolicy struct {
+	// RequestsPerMinute is the maximum rate of submitting new requests
+	// (token bucket refill rate). Zero means not limited.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RequestsPerMinute int32 `json:"requestsPerMinute,omitempty"`
+
+	// Burst is the number of requests that can be submitted at once
+	// (token bucket size). Default 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Burst int32 `json:"burst,omitempty"`
+
+	// MaxInFlight is the maximum number of concurrent calls to the ADCS.
+	// Zero means not limited.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxInFlight int32 `json:"maxInFlight,omitempty"`
+}
+
+// MaintenanceWindow is a period when the issuer doesn't communicate with the ADCS.
+type MaintenanceWindow struct {
+	// Start of the maintenance.
+	Start metav1.Time `json:"start"`
+
+	// End of the maintenance.
+	End metav1.Time `json:"end"`
+}
+
+// QuotaPolicy limits the number of certificates each namespace can request
+// from a shared issuer. Zero values mean not limited.
+type QuotaPolicy struct {
+	// CertificatesPerHour is the maximum number of requests submitted
+	// from a namespace in the last hour.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CertificatesPerHour int32 `json:"certificatesPerHour,omitempty"`
+
+	// CertificatesPerDay is the maximum number of requests submitted
+	// from a namespace in the last 24 hours.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	CertificatesPerDay int32 `json:"certificatesPerDay,omitempty"`
+
+	// MaxActive is the maximum number of pending requests and issued,
+	// not yet expired certificates of a namespace.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxActive int32 `json:"maxActive,omitempty"`
+}
+
+// NamespaceQuotaUsage lists the requests of a namespace let through the quota of
+// a ClusterAdcsIssuer. Requests are kept for 24 hours (an hour if there's no daily quota).
+type NamespaceQuotaUsage struct {
+	Namespace string `json:"namespace"`
+
+	// +optional
+	Admissions []QuotaAdmission `json:"admissions,omitempty"`
+}
+
+// QuotaAdmission is a request let through the namespace quota.
+type QuotaAdmission struct {
+	// Request is the name of the AdcsRequest.
+	Request string `json:"request"`
+
+	// UID of the AdcsRequest.
+	UID types.UID `json:"uid"`
+
+	// Time the request was let through.
+	Time metav1.Time `json:"time"`
+}
+
+// CircuitBreakerPolicy configures pausing communication with the ADCS after
+// consecutive authentication or server failures (e.g. to prevent locking
+// the service account in AD).
+type CircuitBreakerPolicy struct {
+	// Disabled turns off the circuit breaker.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures that open
+	// the circuit breaker. Default 5.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// OpenDuration is how long the circuit breaker stays open before the next
+	// attempt (in time.ParseDuration() format). Default 10 minutes.
+	// +optional
+	OpenDuration string `json:"openDuration,omitempty"`
+}
+
+// IssuerConditionType represents an issuer condition value.
+type IssuerConditionType string
+
+const (
+	// IssuerConditionAvailable indicates if the ADCS is used by the issuer.
+	// It's 'False' while the circuit breaker is open.
+	IssuerConditionAvailable IssuerConditionType = "Available"
+
+	// IssuerConditionSuspended is 'True' while the issuer is suspended or in
+	// a maintenance window and doesn't submit requests or check their status.
+	IssuerConditionSuspended IssuerConditionType = "Suspended"
+
+	// IssuerConditionCancelPendingRequests is 'False' when pending requests
+	// can't be denied on ADCS because the certdeny.asp page is missing or of
+	// another version. It's set only for issuers with cancelPendingRequests.
+	IssuerConditionCancelPendingRequests IssuerConditionType = "CancelPendingRequests"
+)
+
+// IssuerCondition contains condition information for an issuer.
+type IssuerCondition struct {
+	// Type of the condition.
+	Type IssuerConditionType `json:"type"`
+
+	// Status of the condition, one of ('True', 'False', 'Unknown').
+	Status cmmeta.ConditionStatus `json:"status"`
+
+	// LastTransitionTime is the timestamp corresponding to the last status
+	// change of this condition.
+	// +optional
+	LastTransitionTime *metav1.Time `json:"lastTransitionTime,omitempty"`
+
+	// Reason is a brief machine readable explanation for the condition's last
+	// transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable description of the details of the last
+	// transition, complementing reason.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// IssuerMode defines how the certificates of the issuer are issued.
+// +kubebuilder:validation:Enum=online;manual
+type IssuerMode string
+
+const (
+	// The requests are sent to the ADCS.
+	IssuerModeOnline IssuerMode = "online"
+
+	// The CSRs are signed offline by a CA officer who uploads the issued
+	// certificates to the AdcsRequests. It's used for CAs not reachable
+	// from the cluster.
+	IssuerModeManual IssuerMode = "manual"
+)
+
+// ManualPolicy configures the issuer in the manual mode.
+type ManualPolicy struct {
+	// CABundle is a PEM encoded bundle of the CA certificates the uploaded
+	// certificates must chain to. The CA certificates uploaded with the
+	// certificate are used as intermediates only.
+	CABundle []byte `json:"caBundle"`
+}
+
+// RejectionPolicy defines how CertificateRequests are completed when ADCS
+// rejects (denies) the request.
+// +kubebuilder:validation:Enum=fail;deny;hold
+type RejectionPolicy string
+
+const (
+	// The CertificateRequest is marked as 'Failed'. cert-manager re-tries
+	// the issuance with exponential backoff.
+	RejectionPolicyFail RejectionPolicy = "fail"
+
+	// The CertificateRequest is marked as 'Denied'.
+	RejectionPolicyDeny RejectionPolicy = "deny"
+
+	// The CertificateRequest is kept 'Pending' so it's never re-tried.
+	// The Certificate is issued again only after it's updated.
+	RejectionPolicyHold RejectionPolicy = "hold"
+)
+
+// ESTPolicy configures enrollment of the issuer's certificates over EST (RFC 7030).
+type ESTPolicy struct {
+	// Labels of the EST paths ('/.well-known/est/<label>/...') served by the issuer.
+	// Requests without a label are served by the issuer with the 'default' label.
+	Labels []string `json:"labels"`
+
+	// CredentialsSecretRefs are the names of Secrets with 'username' and 'password'
+	// keys of the clients allowed to enroll with HTTP basic authentication.
+	// The Secrets must be in the issuer's namespace (cluster resource namespace
+	// for ClusterAdcsIssuers).
+	// +optional
+	CredentialsSecretRefs []string `json:"credentialsSecretRefs,omitempty"`
+
+	// ClientCASecretRef is the name of a Secret with the 'ca.crt' key containing
+	// CA certificates of TLS client certificates allowed to enroll. Clients must
+	// authenticate with a client certificate for simplereenroll.
+	// +optional
+	ClientCASecretRef string `json:"clientCASecretRef,omitempty"`
+
+	// Clients restrict the subjects and SANs each client can enroll. If set, only
+	// the listed clients can enroll and only with the names allowed to them.
+	// Otherwise any authenticated client can enroll any names.
+	// +optional
+	Clients []ESTClientPolicy `json:"clients,omitempty"`
+}
+
+// ESTClientPolicy lists the names an EST client can enroll. Patterns can contain
+// '*' matching any characters except '.', ',', '=', '/' and '@'
+// e.g. '*.routers.example.com' or 'CN=router-*,O=Example'.
+type ESTClientPolicy struct {
+	// Client is the username of HTTP basic authentication or the subject of
+	// the client certificate (e.g. 'CN=router-1,O=Example').
+	Client string `json:"client"`
+
+	// Subjects are patterns of the allowed CSR subjects (e.g. 'CN=router-*,O=Example').
+	// CSRs with an empty subject are always allowed.
+	// +optional
+	Subjects []string `json:"subjects,omitempty"`
+
+	// DNSNames are patterns of the allowed DNS SANs.
+	// +optional
+	DNSNames []string `json:"dnsNames,omitempty"`
+
+	// IPAddresses are the networks (CIDR) of the allowed IP address SANs.
+	// +optional
+	IPAddresses []string `json:"ipAddresses,omitempty"`
+
+	// URIs are patterns of the allowed URI SANs.
+	// +optional
+	URIs []string `json:"uris,omitempty"`
+
+	// EmailAddresses are patterns of the allowed email SANs.
+	// +optional
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+}
+
+// SCEPPolicy configures enrollment of the issuer's certificates over SCEP (RFC 8894).
+type SCEPPolicy struct {
+	// Labels of the SCEP paths ('/scep/<label>') served by the issuer.
+	// Requests to '/scep' are served by the issuer with the 'default' label.
+	Labels []string `json:"labels"`
+
+	// RASecretRef is the name of a 'kubernetes.io/tls' Secret with the certificate
+	// and private key of the registration authority. Clients encrypt their requests
+	// to the RA certificate and responses are signed with its key.
+	RASecretRef string `json:"raSecretRef"`
+
+	// ChallengePasswordSecretRefs are the names of Secrets with the 'password' key.
+	// The challenge password of the CSR must match one of them.
+	// The Secrets must be in the issuer's namespace (cluster resource namespace
+	// for ClusterAdcsIssuers).
+	// The CSR is stored in the AdcsRequest and sent to ADCS with the password,
+	// so the password is readable by anyone who can read the AdcsRequests.
+	// +optional
+	ChallengePasswordSecretRefs []string `json:"challengePasswordSecretRefs,omitempty"`
+
+	// OneTimeChallenges enables challenge passwords that can be used for a single
+	// enrollment. They are the 'password' keys of Secrets in the issuer's namespace
+	// with the 'adcs.certmanager.csf.nokia.com/scep-challenge' label set to the SCEP
+	// label. The Secret is deleted when the enrollment is requested.
+	// +optional
+	OneTimeChallenges bool `json:"oneTimeChallenges,omitempty"`
+}
+
+// CSIPolicy lists the pods allowed to use the issuer in CSI driver volumes.
+type CSIPolicy struct {
+	// ServiceAccounts are the names of the service accounts of the pods allowed
+	// to use the issuer. '*' allows all service accounts of the namespace.
+	ServiceAccounts []string `json:"serviceAccounts"`
+}
+
+// EnrollmentAgentPolicy configures signing of the requests with an Enrollment Agent
+// certificate for templates that require enrollment on behalf of other users.
+type EnrollmentAgentPolicy struct {
+	// SecretRef is the name of a 'kubernetes.io/tls' Secret with the Enrollment Agent
+	// certificate (with the 'Certificate Request Agent' usage) and private key.
+	// The Secret must be in the issuer's namespace (cluster resource namespace
+	// for ClusterAdcsIssuers).
+	SecretRef string `json:"secretRef"`
+
+	// RequesterName is the account (e.g. 'DOMAIN\user') the certificates are
+	// requested on behalf of. It is used for requests that don't set their own.
+	// +optional
+	RequesterName string `json:"requesterName,omitempty"`
+
+	// AllowedRequesterNames lists the requester names requests can set with the
+	// 'adcs.certmanager.csf.nokia.com/requester-name' CertificateRequest annotation.
+	// Requests with other names are marked as errored. Requests can't set
+	// requester names if not set.
+	// +optional
+	AllowedRequesterNames []RequesterNameRule `json:"allowedRequesterNames,omitempty"`
+}
+
+// RequesterNameRule allows requests from the namespaces to set the requester names.
+type RequesterNameRule struct {
+	// Namespaces the rule applies to. '*' matches all namespaces.
+	// Requests of CertificateSigningRequests are in the issuer's namespace
+	// (the cluster resource namespace for ClusterAdcsIssuers).
+	// Required for ClusterAdcsIssuers, the issuer's namespace if not set.
+	// +optional
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// Names of the accounts (e.g. 'DOMAIN\user') certificates can be requested on
+	// behalf of. '*' matches any characters, e.g. 'CORP\kiosk-*'. Names are case
+	// insensitive.
+	Names []string `json:"names"`
+}
+
+// SubCAPolicy configures issuance of subordinate CA certificates for requests
+// with 'isCA' set. The issued certificate and its chain can back a cert-manager
+// CA Issuer.
+type SubCAPolicy struct {
+	// Template is the name of the ADCS SubCA certificate template. It's used for
+	// all 'isCA' requests, template rules are not applied to them.
+	Template string `json:"template"`
+
+	// MaxPathLen is the maximum path length constraint of the issued CA certificates.
+	// Certificates without the constraint or with a longer path length are not
+	// accepted and the request is marked as errored. Not checked if not set.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxPathLen *int `json:"maxPathLen,omitempty"`
+
+	// AllowedNamespaces are the namespaces that can request CA certificates.
+	// Requests from other namespaces are marked as errored. Required for
+	// ClusterAdcsIssuers.
+	// +optional
+	AllowedNamespaces []string `json:"allowedNamespaces,omitempty"`
+}
@@ -20,30 +20,32 @@ limitations under the License.
 package v1
 
 import (
+	certmanagerv1 "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsIssuer) DeepCopyInto(out *AdcsIssuer) {
+func (in *AcmeAccount) DeepCopyInto(out *AcmeAccount) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuer.
-func (in *AdcsIssuer) DeepCopy() *AdcsIssuer {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeAccount.
+func (in *AcmeAccount) DeepCopy() *AcmeAccount {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsIssuer)
+	out := new(AcmeAccount)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AdcsIssuer) DeepCopyObject() runtime.Object {
+func (in *AcmeAccount) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -51,31 +53,31 @@ func (in *AdcsIssuer) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsIssuerList) DeepCopyInto(out *AdcsIssuerList) {
+func (in *AcmeAccountList) DeepCopyInto(out *AcmeAccountList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]AdcsIssuer, len(*in))
+		*out = make([]AcmeAccount, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuerList.
-func (in *AdcsIssuerList) DeepCopy() *AdcsIssuerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeAccountList.
+func (in *AcmeAccountList) DeepCopy() *AcmeAccountList {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsIssuerList)
+	out := new(AcmeAccountList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AdcsIssuerList) DeepCopyObject() runtime.Object {
+func (in *AcmeAccountList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -83,62 +85,123 @@ func (in *AdcsIssuerList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsIssuerSpec) DeepCopyInto(out *AdcsIssuerSpec) {
+func (in *AcmeAccountSpec) DeepCopyInto(out *AcmeAccountSpec) {
 	*out = *in
-	out.CredentialsRef = in.CredentialsRef
-	if in.CABundle != nil {
-		in, out := &in.CABundle, &out.CABundle
-		*out = make([]byte, len(*in))
+	if in.Contact != nil {
+		in, out := &in.Contact, &out.Contact
+		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuerSpec.
-func (in *AdcsIssuerSpec) DeepCopy() *AdcsIssuerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeAccountSpec.
+func (in *AcmeAccountSpec) DeepCopy() *AcmeAccountSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsIssuerSpec)
+	out := new(AcmeAccountSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsIssuerStatus) DeepCopyInto(out *AdcsIssuerStatus) {
+func (in *AcmeAccountStatus) DeepCopyInto(out *AcmeAccountStatus) {
 	*out = *in
+	if in.UsedNonces != nil {
+		in, out := &in.UsedNonces, &out.UsedNonces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuerStatus.
-func (in *AdcsIssuerStatus) DeepCopy() *AdcsIssuerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeAccountStatus.
+func (in *AcmeAccountStatus) DeepCopy() *AcmeAccountStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsIssuerStatus)
+	out := new(AcmeAccountStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsRequest) DeepCopyInto(out *AdcsRequest) {
+func (in *AcmeAuthorization) DeepCopyInto(out *AcmeAuthorization) {
+	*out = *in
+	out.Identifier = in.Identifier
+	if in.Challenges != nil {
+		in, out := &in.Challenges, &out.Challenges
+		*out = make([]AcmeChallenge, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeAuthorization.
+func (in *AcmeAuthorization) DeepCopy() *AcmeAuthorization {
+	if in == nil {
+		return nil
+	}
+	out := new(AcmeAuthorization)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AcmeChallenge) DeepCopyInto(out *AcmeChallenge) {
+	*out = *in
+	if in.Validated != nil {
+		in, out := &in.Validated, &out.Validated
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeChallenge.
+func (in *AcmeChallenge) DeepCopy() *AcmeChallenge {
+	if in == nil {
+		return nil
+	}
+	out := new(AcmeChallenge)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AcmeIdentifier) DeepCopyInto(out *AcmeIdentifier) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeIdentifier.
+func (in *AcmeIdentifier) DeepCopy() *AcmeIdentifier {
+	if in == nil {
+		return nil
+	}
+	out := new(AcmeIdentifier)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AcmeOrder) DeepCopyInto(out *AcmeOrder) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequest.
-func (in *AdcsRequest) DeepCopy() *AdcsRequest {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeOrder.
+func (in *AcmeOrder) DeepCopy() *AcmeOrder {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsRequest)
+	out := new(AcmeOrder)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AdcsRequest) DeepCopyObject() runtime.Object {
+func (in *AcmeOrder) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -146,31 +209,31 @@ func (in *AdcsRequest) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsRequestList) DeepCopyInto(out *AdcsRequestList) {
+func (in *AcmeOrderList) DeepCopyInto(out *AcmeOrderList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]AdcsRequest, len(*in))
+		*out = make([]AcmeOrder, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequestList.
-func (in *AdcsRequestList) DeepCopy() *AdcsRequestList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeOrderList.
+func (in *AcmeOrderList) DeepCopy() *AcmeOrderList {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsRequestList)
+	out := new(AcmeOrderList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *AdcsRequestList) DeepCopyObject() runtime.Object {
+func (in *AcmeOrderList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -178,62 +241,78 @@ func (in *AdcsRequestList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsRequestSpec) DeepCopyInto(out *AdcsRequestSpec) {
+func (in *AcmeOrderSpec) DeepCopyInto(out *AcmeOrderSpec) {
 	*out = *in
+	if in.Identifiers != nil {
+		in, out := &in.Identifiers, &out.Identifiers
+		*out = make([]AcmeIdentifier, len(*in))
+		copy(*out, *in)
+	}
+	out.IssuerRef = in.IssuerRef
 	if in.CSRPEM != nil {
 		in, out := &in.CSRPEM, &out.CSRPEM
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
-	out.IssuerRef = in.IssuerRef
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequestSpec.
-func (in *AdcsRequestSpec) DeepCopy() *AdcsRequestSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeOrderSpec.
+func (in *AcmeOrderSpec) DeepCopy() *AcmeOrderSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsRequestSpec)
+	out := new(AcmeOrderSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *AdcsRequestStatus) DeepCopyInto(out *AdcsRequestStatus) {
+func (in *AcmeOrderStatus) DeepCopyInto(out *AcmeOrderStatus) {
 	*out = *in
+	if in.Expires != nil {
+		in, out := &in.Expires, &out.Expires
+		*out = (*in).DeepCopy()
+	}
+	if in.Authorizations != nil {
+		in, out := &in.Authorizations, &out.Authorizations
+		*out = make([]AcmeAuthorization, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequestStatus.
-func (in *AdcsRequestStatus) DeepCopy() *AdcsRequestStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcmeOrderStatus.
+func (in *AcmeOrderStatus) DeepCopy() *AcmeOrderStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(AdcsRequestStatus)
+	out := new(AcmeOrderStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ClusterAdcsIssuer) DeepCopyInto(out *ClusterAdcsIssuer) {
+func (in *AdcsIssuer) DeepCopyInto(out *AdcsIssuer) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuer.
-func (in *ClusterAdcsIssuer) DeepCopy() *ClusterAdcsIssuer {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuer.
+func (in *AdcsIssuer) DeepCopy() *AdcsIssuer {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterAdcsIssuer)
+	out := new(AdcsIssuer)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ClusterAdcsIssuer) DeepCopyObject() runtime.Object {
+func (in *AdcsIssuer) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -241,31 +320,31 @@ func (in *ClusterAdcsIssuer) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ClusterAdcsIssuerList) DeepCopyInto(out *ClusterAdcsIssuerList) {
+func (in *AdcsIssuerList) DeepCopyInto(out *AdcsIssuerList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]ClusterAdcsIssuer, len(*in))
+		*out = make([]AdcsIssuer, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuerList.
-func (in *ClusterAdcsIssuerList) DeepCopy() *ClusterAdcsIssuerList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuerList.
+func (in *AdcsIssuerList) DeepCopy() *AdcsIssuerList {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterAdcsIssuerList)
+	out := new(AdcsIssuerList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *ClusterAdcsIssuerList) DeepCopyObject() runtime.Object {
+func (in *AdcsIssuerList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -273,52 +352,873 @@ func (in *ClusterAdcsIssuerList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ClusterAdcsIssuerSpec) DeepCopyInto(out *ClusterAdcsIssuerSpec) {
+func (in *AdcsIssuerSpec) DeepCopyInto(out *AdcsIssuerSpec) {
 	*out = *in
+	if in.Manual != nil {
+		in, out := &in.Manual, &out.Manual
+		*out = new(ManualPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	out.CredentialsRef = in.CredentialsRef
 	if in.CABundle != nil {
 		in, out := &in.CABundle, &out.CABundle
 		*out = make([]byte, len(*in))
 		copy(*out, *in)
 	}
+	if in.TemplateRules != nil {
+		in, out := &in.TemplateRules, &out.TemplateRules
+		*out = make([]TemplateRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultAttributes != nil {
+		in, out := &in.DefaultAttributes, &out.DefaultAttributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AllowedAttributes != nil {
+		in, out := &in.AllowedAttributes, &out.AllowedAttributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SID != nil {
+		in, out := &in.SID, &out.SID
+		*out = new(SIDPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnrollmentAgent != nil {
+		in, out := &in.EnrollmentAgent, &out.EnrollmentAgent
+		*out = new(EnrollmentAgentPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SubCA != nil {
+		in, out := &in.SubCA, &out.SubCA
+		*out = new(SubCAPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(VerificationPolicy)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimitPolicy)
+		**out = **in
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreakerPolicy)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EST != nil {
+		in, out := &in.EST, &out.EST
+		*out = new(ESTPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SCEP != nil {
+		in, out := &in.SCEP, &out.SCEP
+		*out = new(SCEPPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CSI != nil {
+		in, out := &in.CSI, &out.CSI
+		*out = new(CSIPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuerSpec.
-func (in *ClusterAdcsIssuerSpec) DeepCopy() *ClusterAdcsIssuerSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuerSpec.
+func (in *AdcsIssuerSpec) DeepCopy() *AdcsIssuerSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterAdcsIssuerSpec)
+	out := new(AdcsIssuerSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ClusterAdcsIssuerStatus) DeepCopyInto(out *ClusterAdcsIssuerStatus) {
+func (in *AdcsIssuerStatus) DeepCopyInto(out *AdcsIssuerStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]IssuerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuerStatus.
-func (in *ClusterAdcsIssuerStatus) DeepCopy() *ClusterAdcsIssuerStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsIssuerStatus.
+func (in *AdcsIssuerStatus) DeepCopy() *AdcsIssuerStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(ClusterAdcsIssuerStatus)
+	out := new(AdcsIssuerStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LocalObjectReference) DeepCopyInto(out *LocalObjectReference) {
+func (in *AdcsRequest) DeepCopyInto(out *AdcsRequest) {
 	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalObjectReference.
-func (in *LocalObjectReference) DeepCopy() *LocalObjectReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequest.
+func (in *AdcsRequest) DeepCopy() *AdcsRequest {
 	if in == nil {
 		return nil
 	}
-	out := new(LocalObjectReference)
+	out := new(AdcsRequest)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AdcsRequest) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdcsRequestList) DeepCopyInto(out *AdcsRequestList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AdcsRequest, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequestList.
+func (in *AdcsRequestList) DeepCopy() *AdcsRequestList {
+	if in == nil {
+		return nil
+	}
+	out := new(AdcsRequestList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AdcsRequestList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdcsRequestSpec) DeepCopyInto(out *AdcsRequestSpec) {
+	*out = *in
+	if in.CSRPEM != nil {
+		in, out := &in.CSRPEM, &out.CSRPEM
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	out.IssuerRef = in.IssuerRef
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Duration != nil {
+		in, out := &in.Duration, &out.Duration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Usages != nil {
+		in, out := &in.Usages, &out.Usages
+		*out = make([]certmanagerv1.KeyUsage, len(*in))
+		copy(*out, *in)
+	}
+	if in.Keystores != nil {
+		in, out := &in.Keystores, &out.Keystores
+		*out = new(certmanagerv1.CertificateKeystores)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequestSpec.
+func (in *AdcsRequestSpec) DeepCopy() *AdcsRequestSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AdcsRequestSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AdcsRequestStatus) DeepCopyInto(out *AdcsRequestStatus) {
+	*out = *in
+	if in.LastCheckTime != nil {
+		in, out := &in.LastCheckTime, &out.LastCheckTime
+		*out = (*in).DeepCopy()
+	}
+	if in.SubmittedTime != nil {
+		in, out := &in.SubmittedTime, &out.SubmittedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.NotAfter != nil {
+		in, out := &in.NotAfter, &out.NotAfter
+		*out = (*in).DeepCopy()
+	}
+	if in.AdmittedTime != nil {
+		in, out := &in.AdmittedTime, &out.AdmittedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Certificate != nil {
+		in, out := &in.Certificate, &out.Certificate
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.CACertificate != nil {
+		in, out := &in.CACertificate, &out.CACertificate
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdcsRequestStatus.
+func (in *AdcsRequestStatus) DeepCopy() *AdcsRequestStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AdcsRequestStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CSIPolicy) DeepCopyInto(out *CSIPolicy) {
+	*out = *in
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CSIPolicy.
+func (in *CSIPolicy) DeepCopy() *CSIPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CSIPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CircuitBreakerPolicy) DeepCopyInto(out *CircuitBreakerPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CircuitBreakerPolicy.
+func (in *CircuitBreakerPolicy) DeepCopy() *CircuitBreakerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(CircuitBreakerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAdcsIssuer) DeepCopyInto(out *ClusterAdcsIssuer) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuer.
+func (in *ClusterAdcsIssuer) DeepCopy() *ClusterAdcsIssuer {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAdcsIssuer)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterAdcsIssuer) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAdcsIssuerList) DeepCopyInto(out *ClusterAdcsIssuerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ClusterAdcsIssuer, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuerList.
+func (in *ClusterAdcsIssuerList) DeepCopy() *ClusterAdcsIssuerList {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAdcsIssuerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ClusterAdcsIssuerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAdcsIssuerSpec) DeepCopyInto(out *ClusterAdcsIssuerSpec) {
+	*out = *in
+	if in.Manual != nil {
+		in, out := &in.Manual, &out.Manual
+		*out = new(ManualPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	out.CredentialsRef = in.CredentialsRef
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+	if in.TemplateRules != nil {
+		in, out := &in.TemplateRules, &out.TemplateRules
+		*out = make([]TemplateRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultAttributes != nil {
+		in, out := &in.DefaultAttributes, &out.DefaultAttributes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AllowedAttributes != nil {
+		in, out := &in.AllowedAttributes, &out.AllowedAttributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SID != nil {
+		in, out := &in.SID, &out.SID
+		*out = new(SIDPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnrollmentAgent != nil {
+		in, out := &in.EnrollmentAgent, &out.EnrollmentAgent
+		*out = new(EnrollmentAgentPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SubCA != nil {
+		in, out := &in.SubCA, &out.SubCA
+		*out = new(SubCAPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Verification != nil {
+		in, out := &in.Verification, &out.Verification
+		*out = new(VerificationPolicy)
+		**out = **in
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimitPolicy)
+		**out = **in
+	}
+	if in.CircuitBreaker != nil {
+		in, out := &in.CircuitBreaker, &out.CircuitBreaker
+		*out = new(CircuitBreakerPolicy)
+		**out = **in
+	}
+	if in.Quota != nil {
+		in, out := &in.Quota, &out.Quota
+		*out = new(QuotaPolicy)
+		**out = **in
+	}
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindow, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EST != nil {
+		in, out := &in.EST, &out.EST
+		*out = new(ESTPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SCEP != nil {
+		in, out := &in.SCEP, &out.SCEP
+		*out = new(SCEPPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuerSpec.
+func (in *ClusterAdcsIssuerSpec) DeepCopy() *ClusterAdcsIssuerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAdcsIssuerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterAdcsIssuerStatus) DeepCopyInto(out *ClusterAdcsIssuerStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]IssuerCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.QuotaUsage != nil {
+		in, out := &in.QuotaUsage, &out.QuotaUsage
+		*out = make([]NamespaceQuotaUsage, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterAdcsIssuerStatus.
+func (in *ClusterAdcsIssuerStatus) DeepCopy() *ClusterAdcsIssuerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterAdcsIssuerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ESTClientPolicy) DeepCopyInto(out *ESTClientPolicy) {
+	*out = *in
+	if in.Subjects != nil {
+		in, out := &in.Subjects, &out.Subjects
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DNSNames != nil {
+		in, out := &in.DNSNames, &out.DNSNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IPAddresses != nil {
+		in, out := &in.IPAddresses, &out.IPAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.URIs != nil {
+		in, out := &in.URIs, &out.URIs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EmailAddresses != nil {
+		in, out := &in.EmailAddresses, &out.EmailAddresses
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ESTClientPolicy.
+func (in *ESTClientPolicy) DeepCopy() *ESTClientPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ESTClientPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ESTPolicy) DeepCopyInto(out *ESTPolicy) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.CredentialsSecretRefs != nil {
+		in, out := &in.CredentialsSecretRefs, &out.CredentialsSecretRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Clients != nil {
+		in, out := &in.Clients, &out.Clients
+		*out = make([]ESTClientPolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ESTPolicy.
+func (in *ESTPolicy) DeepCopy() *ESTPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ESTPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnrollmentAgentPolicy) DeepCopyInto(out *EnrollmentAgentPolicy) {
+	*out = *in
+	if in.AllowedRequesterNames != nil {
+		in, out := &in.AllowedRequesterNames, &out.AllowedRequesterNames
+		*out = make([]RequesterNameRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnrollmentAgentPolicy.
+func (in *EnrollmentAgentPolicy) DeepCopy() *EnrollmentAgentPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(EnrollmentAgentPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IssuerCondition) DeepCopyInto(out *IssuerCondition) {
+	*out = *in
+	if in.LastTransitionTime != nil {
+		in, out := &in.LastTransitionTime, &out.LastTransitionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IssuerCondition.
+func (in *IssuerCondition) DeepCopy() *IssuerCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(IssuerCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalObjectReference) DeepCopyInto(out *LocalObjectReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalObjectReference.
+func (in *LocalObjectReference) DeepCopy() *LocalObjectReference {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalObjectReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindow) DeepCopyInto(out *MaintenanceWindow) {
+	*out = *in
+	in.Start.DeepCopyInto(&out.Start)
+	in.End.DeepCopyInto(&out.End)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindow.
+func (in *MaintenanceWindow) DeepCopy() *MaintenanceWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(MaintenanceWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManualPolicy) DeepCopyInto(out *ManualPolicy) {
+	*out = *in
+	if in.CABundle != nil {
+		in, out := &in.CABundle, &out.CABundle
+		*out = make([]byte, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManualPolicy.
+func (in *ManualPolicy) DeepCopy() *ManualPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ManualPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceQuotaUsage) DeepCopyInto(out *NamespaceQuotaUsage) {
+	*out = *in
+	if in.Admissions != nil {
+		in, out := &in.Admissions, &out.Admissions
+		*out = make([]QuotaAdmission, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceQuotaUsage.
+func (in *NamespaceQuotaUsage) DeepCopy() *NamespaceQuotaUsage {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceQuotaUsage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaAdmission) DeepCopyInto(out *QuotaAdmission) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaAdmission.
+func (in *QuotaAdmission) DeepCopy() *QuotaAdmission {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaAdmission)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *QuotaPolicy) DeepCopyInto(out *QuotaPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuotaPolicy.
+func (in *QuotaPolicy) DeepCopy() *QuotaPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(QuotaPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimitPolicy) DeepCopyInto(out *RateLimitPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimitPolicy.
+func (in *RateLimitPolicy) DeepCopy() *RateLimitPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimitPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestSIDRule) DeepCopyInto(out *RequestSIDRule) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SIDs != nil {
+		in, out := &in.SIDs, &out.SIDs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestSIDRule.
+func (in *RequestSIDRule) DeepCopy() *RequestSIDRule {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestSIDRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequesterNameRule) DeepCopyInto(out *RequesterNameRule) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Names != nil {
+		in, out := &in.Names, &out.Names
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequesterNameRule.
+func (in *RequesterNameRule) DeepCopy() *RequesterNameRule {
+	if in == nil {
+		return nil
+	}
+	out := new(RequesterNameRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SCEPPolicy) DeepCopyInto(out *SCEPPolicy) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ChallengePasswordSecretRefs != nil {
+		in, out := &in.ChallengePasswordSecretRefs, &out.ChallengePasswordSecretRefs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SCEPPolicy.
+func (in *SCEPPolicy) DeepCopy() *SCEPPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SCEPPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SIDPolicy) DeepCopyInto(out *SIDPolicy) {
+	*out = *in
+	if in.AllowedRequestSIDs != nil {
+		in, out := &in.AllowedRequestSIDs, &out.AllowedRequestSIDs
+		*out = make([]RequestSIDRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SIDPolicy.
+func (in *SIDPolicy) DeepCopy() *SIDPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SIDPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubCAPolicy) DeepCopyInto(out *SubCAPolicy) {
+	*out = *in
+	if in.MaxPathLen != nil {
+		in, out := &in.MaxPathLen, &out.MaxPathLen
+		*out = new(int)
+		**out = **in
+	}
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubCAPolicy.
+func (in *SubCAPolicy) DeepCopy() *SubCAPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(SubCAPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TemplateRule) DeepCopyInto(out *TemplateRule) {
+	*out = *in
+	if in.Usages != nil {
+		in, out := &in.Usages, &out.Usages
+		*out = make([]certmanagerv1.KeyUsage, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TemplateRule.
+func (in *TemplateRule) DeepCopy() *TemplateRule {
+	if in == nil {
+		return nil
+	}
+	out := new(TemplateRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VerificationPolicy) DeepCopyInto(out *VerificationPolicy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerificationPolicy.
+func (in *VerificationPolicy) DeepCopy() *VerificationPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(VerificationPolicy)
 	in.DeepCopyInto(out)
 	return out
 }
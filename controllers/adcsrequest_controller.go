@@ -17,22 +17,48 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-logr/logr"
 	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	cmapiutil "github.com/jetstack/cert-manager/pkg/api/util"
 	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
 	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
 
+	"github.com/nokia/adcs-issuer/adcs"
 	api "github.com/nokia/adcs-issuer/api/v1"
 	"github.com/nokia/adcs-issuer/issuers"
 )
 
+const (
+	// Set on pending requests to deny them on ADCS when deleted
+	adcsRequestFinalizer = "adcs.certmanager.csf.nokia.com/cancel-pending"
+	// How long to re-try denying a request before giving up
+	cancelTimeout       = time.Hour
+	cancelRetryInterval = time.Minute
+	// Requests are re-tried on issuer changes, this is just a fallback
+	issuerNotReadyRetryInterval = 10 * time.Minute
+
+	// CertificateRequest Ready condition reason used while the issuer can't be used
+	ReasonIssuerNotReady = "IssuerNotReady"
+)
+
 // AdcsRequestReconciler reconciles a AdcsRequest object
 type AdcsRequestReconciler struct {
 	client.Client
@@ -40,6 +66,20 @@ type AdcsRequestReconciler struct {
 	IssuerFactory                issuers.IssuerFactory
 	Recorder                     record.EventRecorder
 	CertificateRequestController *CertificateRequestReconciler
+	// Re-queues pending requests when their status should be checked
+	Poller *PendingRequestPoller
+	// Maximum number of requests processed at the same time (default 1).
+	// Calls to ADCS are additionally limited by issuers' MaxInFlight.
+	MaxConcurrentReconciles int
+	// Reads the issuer quota usage bypassing the cache (default Client)
+	APIReader client.Reader
+}
+
+func (r *AdcsRequestReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
 }
 
 // +kubebuilder:rbac:groups=adcs.certmanager.csf.nokia.com,resources=adcsrequests,verbs=get;list;watch;create;update;patch;delete
@@ -60,14 +100,86 @@ func (r *AdcsRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		// The Manager will log other errors.
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
+	if !ar.DeletionTimestamp.IsZero() {
+		// The request is no longer needed (most likely its CertificateRequest was deleted)
+		return r.finalize(ctx, log, ar)
+	}
+
+	// Requests in final states have been already processed
+	if ar.Status.State == api.Ready || ar.Status.State == api.Rejected || ar.Status.State == api.Errored {
+		log.V(4).Info("Request already completed", "state", ar.Status.State)
+		if ar.Status.State == api.Ready && ar.Spec.SecretName != "" {
+			// Re-create the Secret if it was changed or previous attempt failed
+			if err := r.writeSecret(ctx, ar); err != nil {
+				log.Error(err, "Cannot write certificate to Secret", "secret", ar.Spec.SecretName)
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, r.updateFinalizer(ctx, ar, false)
+	}
+
+	// Standalone requests have nowhere else to put the certificate
+	if ar.Status.State == api.Unknown && certificateRequestOwner(ar) == nil && !keepsCertificate(ar) {
+		ar.Status.State = api.Errored
+		ar.Status.Reason = "AdcsRequest created without CertificateRequest requires secretName"
+		return ctrl.Result{}, r.setStatus(ctx, ar)
+	}
+
 	// Find the issuer
 	issuer, err := r.IssuerFactory.GetIssuer(ctx, ar.Spec.IssuerRef, ar.Namespace)
 	if err != nil {
 		log.WithValues("issuer", ar.Spec.IssuerRef).Error(err, "Couldn't get issuer")
-		return ctrl.Result{}, err
+		// Wait for the issuer. Changes of the issuer or its Secret trigger another attempt.
+		message := fmt.Sprintf("Waiting for %s %s: %s", ar.Spec.IssuerRef.Kind, ar.Spec.IssuerRef.Name, err.Error())
+		r.reportWaiting(ctx, ar, core.EventTypeWarning, ReasonIssuerNotReady, message)
+		return ctrl.Result{Requeue: true, RequeueAfter: issuerNotReadyRetryInterval}, nil
+	}
+
+	if ar.Status.State == api.Unknown && ar.Status.AdmittedTime == nil {
+		// Shared issuers can limit the number of certificates of each namespace.
+		// Requests not created for CertificateRequests are held only here.
+		message, retryAfter, err := admitQuota(ctx, r.Client, r.apiReader(), time.Now(), ar)
+		if err != nil {
+			log.Error(err, "failed to check the issuer quota")
+			return ctrl.Result{}, err
+		}
+		if message != "" {
+			log.Info("Quota exceeded", "retryAfter", retryAfter)
+			r.reportWaiting(ctx, ar, core.EventTypeNormal, cmapi.CertificateRequestReasonPending, message)
+			return ctrl.Result{RequeueAfter: retryAfter}, nil
+		}
+		now := metav1.Now()
+		ar.Status.AdmittedTime = &now
+		if err := r.Client.Status().Update(ctx, ar); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if ar.Status.State == api.Pending && !issuer.StatusCheckDue(ar, time.Now()) {
+		// Not checking too often. Re-queued for the next check in case the poller
+		// doesn't pick the request up (e.g. it's not running).
+		next := issuer.NextStatusCheck(ar)
+		log.V(4).Info("Status check not due yet", "next", next)
+		return ctrl.Result{RequeueAfter: time.Until(next)}, nil
 	}
 
 	cert, caCert, err := issuer.Issue(ctx, ar)
+	var throttled *issuers.ThrottledError
+	if errors.As(err, &throttled) {
+		// Rate limit reached or circuit breaker open. Not an error of the request.
+		log.Info(throttled.Error())
+		return ctrl.Result{Requeue: true, RequeueAfter: throttled.RetryAfter}, nil
+	}
+	var suspended *issuers.SuspendedError
+	if errors.As(err, &suspended) {
+		// Held until the issuer is resumed. Changes of the issuer re-queue the request.
+		log.Info(suspended.Error())
+		r.reportWaiting(ctx, ar, core.EventTypeNormal, cmapi.CertificateRequestReasonPending, suspended.Error())
+		if d := suspended.ChangesIn(time.Now()); d > 0 {
+			return ctrl.Result{Requeue: true, RequeueAfter: d}, nil
+		}
+		return ctrl.Result{}, nil
+	}
 	if err != nil {
 		// This is a local error.
 		// We don't change the request status and just put it back on the queue
@@ -75,33 +187,158 @@ func (r *AdcsRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		log.Error(err, fmt.Sprintf("Failed request will be re-tried in %v", issuer.RetryInterval))
 		return ctrl.Result{Requeue: true, RequeueAfter: issuer.RetryInterval}, nil
 	}
+	if _, ok := ar.Annotations[api.CheckNowAnnotation]; ok {
+		// The forced check is done
+		delete(ar.Annotations, api.CheckNowAnnotation)
+		if err := r.updateMetadata(ctx, ar); err != nil {
+			log.Error(err, "Cannot remove annotation", "annotation", api.CheckNowAnnotation)
+		}
+	}
 
 	// Get the original CertificateRequest to set result in
-	cr, err := r.CertificateRequestController.GetCertificateRequest(ctx, req.NamespacedName)
+	cr := r.certificateRequest(ctx, ar)
 	switch ar.Status.State {
 	case api.Pending:
 		// Check again later
-		log.Info(fmt.Sprintf("Pending request will be re-tried in %v", issuer.StatusCheckInterval))
+		next := issuer.NextStatusCheck(ar)
+		log.Info(fmt.Sprintf("Pending request will be checked again in %v", time.Until(next).Round(time.Second)))
+		r.updateCertificateRequest(ctx, cr, ar, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, statusMessage("ADCS request pending", ar))
+		// Make sure the request is denied on ADCS if it's deleted while still pending
+		if err := r.updateFinalizer(ctx, ar, issuer.CancelPendingRequests); err != nil {
+			log.Error(err, "Cannot update finalizer")
+		}
 		r.setStatus(ctx, ar)
-		return ctrl.Result{Requeue: true, RequeueAfter: issuer.StatusCheckInterval}, nil
+		if r.Poller == nil {
+			return ctrl.Result{Requeue: true, RequeueAfter: time.Until(next)}, nil
+		}
+		return ctrl.Result{}, nil
 	case api.Ready:
-		cr.Status.Certificate = cert
-		cr.Status.CA = caCert
-		r.CertificateRequestController.SetStatus(ctx, &cr, cmmeta.ConditionTrue, cmapi.CertificateRequestReasonIssued, "ADCS request successfull")
+		if cr != nil {
+			cr.Status.Certificate = cert
+			cr.Status.CA = caCert
+			if ar.Spec.IsCA {
+				// The CA certificate can back a cert-manager CA Issuer. It needs the intermediates
+				// in 'tls.crt' and the roots only in 'ca.crt'.
+				if err := setCAChain(cr, cert, caCert); err != nil {
+					log.Error(err, "Cannot split CA chain")
+				}
+			}
+		}
+		if keepsCertificate(ar) {
+			// Kept to write the Secret again, for the CertificateSigningRequest or to be downloaded by the ACME client
+			ar.Status.Certificate = cert
+			ar.Status.CACertificate = caCert
+		}
+		// Reason contains notes if the certificate was issued not exactly as requested
+		r.updateCertificateRequest(ctx, cr, ar, cmmeta.ConditionTrue, cmapi.CertificateRequestReasonIssued, statusMessage("ADCS request successfull", ar))
 	case api.Rejected:
-		// This is a little hack for strange cert-manager behavior in case of failed request. Cert-manager automatically
-		// re-tries such requests (re-created CertificateRequest object) what doesn't make sense in case of rejection.
-		// We keep the Reason 'Pending' to prevent from re-trying while the actual status is in the Status Condition's Message field.
-		// TODO: change it when cert-manager handles this better.
-		r.CertificateRequestController.SetStatus(ctx, &cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "ADCS request rejected")
+		message := statusMessage("ADCS request rejected", ar)
+		switch issuer.OnRejection {
+		case api.RejectionPolicyHold:
+			// Cert-manager automatically re-tries failed requests. When it doesn't make sense
+			// we keep the Reason 'Pending' to prevent from re-trying while the actual status
+			// is in the Status Condition's Message field.
+			r.updateCertificateRequest(ctx, cr, ar, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, message)
+		case api.RejectionPolicyDeny:
+			r.updateCertificateRequest(ctx, cr, ar, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonDenied, message)
+		default:
+			// Failed request is re-tried by cert-manager with exponential backoff
+			r.updateCertificateRequest(ctx, cr, ar, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, message)
+		}
 	case api.Errored:
-		r.CertificateRequestController.SetStatus(ctx, &cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, "ADCS request errored")
+		r.updateCertificateRequest(ctx, cr, ar, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonFailed, statusMessage("ADCS request errored", ar))
+	}
+	if err := r.updateFinalizer(ctx, ar, false); err != nil {
+		log.Error(err, "Cannot remove finalizer")
 	}
 	r.setStatus(ctx, ar)
+	if ar.Status.State == api.Ready && ar.Spec.SecretName != "" {
+		if err := r.writeSecret(ctx, ar); err != nil {
+			log.Error(err, "Cannot write certificate to Secret", "secret", ar.Spec.SecretName)
+			return ctrl.Result{}, err
+		}
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// Deny the pending request on ADCS (if the issuer has cancelPendingRequests set) and let
+// the AdcsRequest go. If ADCS can't be reached the denial is re-tried until cancelTimeout elapses.
+func (r *AdcsRequestReconciler) finalize(ctx context.Context, log logr.Logger, ar *api.AdcsRequest) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(ar, adcsRequestFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	issuer, err := r.IssuerFactory.GetIssuer(ctx, ar.Spec.IssuerRef, ar.Namespace)
+	if err == nil && !issuer.CancelPendingRequests {
+		// Denying was turned off after the finalizer had been added
+		log.Info("Pending ADCS request left in the CA pending queue", "id", ar.Status.Id)
+		controllerutil.RemoveFinalizer(ar, adcsRequestFinalizer)
+		return ctrl.Result{}, r.Client.Update(ctx, ar)
+	}
+	if err == nil {
+		err = issuer.Cancel(ctx, ar)
+		if err != nil && cancelRetryable(err) && time.Since(ar.DeletionTimestamp.Time) < cancelTimeout {
+			retryAfter := cancelRetryInterval
+			var throttled *issuers.ThrottledError
+			if errors.As(err, &throttled) {
+				retryAfter = throttled.RetryAfter
+			}
+			log.Error(err, fmt.Sprintf("Cannot deny ADCS request %s. Will be re-tried in %v", ar.Status.Id, retryAfter))
+			return ctrl.Result{Requeue: true, RequeueAfter: retryAfter}, nil
+		}
+	}
+	if err != nil {
+		log.Error(err, "Pending ADCS request not denied", "id", ar.Status.Id)
+		r.Recorder.Event(ar, core.EventTypeWarning, "CancelFailed",
+			fmt.Sprintf("ADCS request %s left in the CA pending queue: %s", ar.Status.Id, err.Error()))
+	} else if ar.Status.State == api.Pending {
+		log.Info("Pending ADCS request denied", "id", ar.Status.Id)
+		r.Recorder.Event(ar, core.EventTypeNormal, "Cancelled", fmt.Sprintf("ADCS request %s denied", ar.Status.Id))
+	}
+
+	controllerutil.RemoveFinalizer(ar, adcsRequestFinalizer)
+	return ctrl.Result{}, r.Client.Update(ctx, ar)
+}
+
+// Denying is re-tried unless ADCS refused it
+func cancelRetryable(err error) bool {
+	if errors.Is(err, adcs.ErrDenyNotSupported) {
+		return false
+	}
+	var statusErr *adcs.StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests:
+			return true
+		}
+		return statusErr.StatusCode >= 500
+	}
+	return true
+}
+
+// The finalizer is kept only as long as the request is pending on ADCS.
+func (r *AdcsRequestReconciler) updateFinalizer(ctx context.Context, ar *api.AdcsRequest, pending bool) error {
+	if controllerutil.ContainsFinalizer(ar, adcsRequestFinalizer) == pending {
+		return nil
+	}
+	if pending {
+		controllerutil.AddFinalizer(ar, adcsRequestFinalizer)
+	} else {
+		controllerutil.RemoveFinalizer(ar, adcsRequestFinalizer)
+	}
+	return r.updateMetadata(ctx, ar)
+}
+
+// Update the AdcsRequest keeping the new status to be set next
+// (Update overwrites the object with what's stored).
+func (r *AdcsRequestReconciler) updateMetadata(ctx context.Context, ar *api.AdcsRequest) error {
+	status := ar.Status.DeepCopy()
+	err := r.Client.Update(ctx, ar)
+	ar.Status = *status
+	return err
+}
+
 func (r *AdcsRequestReconciler) setStatus(ctx context.Context, ar *api.AdcsRequest) error {
 
 	// Fire an Event to additionally inform users of the change
@@ -114,8 +351,176 @@ func (r *AdcsRequestReconciler) setStatus(ctx context.Context, ar *api.AdcsReque
 	return r.Client.Status().Update(ctx, ar)
 }
 
+// Requests with SecretName and the ones created by the ACME, EST or SCEP server,
+// the CSI driver or for a CertificateSigningRequest keep
+// the issued certificate in the status.
+func keepsCertificate(ar *api.AdcsRequest) bool {
+	if _, est := ar.Labels[api.ESTLabel]; est || ar.Spec.SecretName != "" {
+		return true
+	}
+	if _, scep := ar.Labels[api.SCEPLabel]; scep {
+		return true
+	}
+	if _, csi := ar.Annotations[api.CSIVolumeAnnotation]; csi {
+		return true
+	}
+	owner := metav1.GetControllerOf(ar)
+	if owner == nil {
+		return false
+	}
+	return owner.Kind == "AcmeOrder" && owner.APIVersion == api.GroupVersion.String() ||
+		owner.Kind == certificateSigningRequestGvk.Kind && owner.APIVersion == certificateSigningRequestGvk.GroupVersion().String()
+}
+
+// Get the CertificateRequest owning the AdcsRequest.
+// Nil for standalone AdcsRequests or if it can't be found.
+func (r *AdcsRequestReconciler) certificateRequest(ctx context.Context, ar *api.AdcsRequest) *cmapi.CertificateRequest {
+	owner := certificateRequestOwner(ar)
+	if owner == nil {
+		return nil
+	}
+	cr, err := r.CertificateRequestController.GetCertificateRequest(ctx, types.NamespacedName{Namespace: ar.Namespace, Name: owner.Name})
+	if err != nil {
+		return nil
+	}
+	return &cr
+}
+
+// Report why the request waits in the controller. Standalone AdcsRequests
+// get an Event, otherwise the CertificateRequest is updated.
+func (r *AdcsRequestReconciler) reportWaiting(ctx context.Context, ar *api.AdcsRequest, eventType, reason, message string) {
+	if certificateRequestOwner(ar) == nil {
+		r.Recorder.Event(ar, eventType, reason, message)
+		return
+	}
+	r.updateCertificateRequest(ctx, r.certificateRequest(ctx, ar), ar, cmmeta.ConditionFalse, reason, message)
+}
+
+// Set ADCS request details in the CertificateRequest annotations and its Ready condition.
+// The change is also reported with an Event on the Certificate owning the CertificateRequest.
+// Nothing is done if the CertificateRequest already has the same condition or there's
+// no CertificateRequest.
+func (r *AdcsRequestReconciler) updateCertificateRequest(ctx context.Context, cr *cmapi.CertificateRequest, ar *api.AdcsRequest,
+	status cmmeta.ConditionStatus, reason, message string) error {
+	if cr == nil {
+		return nil
+	}
+	if cmapiutil.CertificateRequestHasCondition(cr, cmapi.CertificateRequestCondition{
+		Type:    cmapi.CertificateRequestConditionReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}) {
+		return nil
+	}
+
+	patch := client.MergeFrom(cr.DeepCopy())
+	if cr.Annotations == nil {
+		cr.Annotations = map[string]string{}
+	}
+	disposition := ar.Status.Reason
+	if ar.Status.State == api.Ready {
+		disposition = "Issued"
+	}
+	for key, value := range map[string]string{
+		api.RequestIDAnnotation:   ar.Status.Id,
+		api.DispositionAnnotation: disposition,
+		api.HResultAnnotation:     ar.Status.HResult,
+		api.CAAnnotation:          ar.Status.CA,
+	} {
+		if value != "" {
+			cr.Annotations[key] = value
+		} else {
+			delete(cr.Annotations, key)
+		}
+	}
+	// Patch doesn't touch the status so keep it to be updated next
+	crStatus := cr.Status.DeepCopy()
+	if err := r.Client.Patch(ctx, cr, patch); err != nil {
+		return err
+	}
+	cr.Status = *crStatus
+
+	eventType := core.EventTypeNormal
+	if status == cmmeta.ConditionFalse && reason != cmapi.CertificateRequestReasonPending {
+		eventType = core.EventTypeWarning
+	}
+	r.recordCertificateEvent(cr, eventType, reason, message)
+
+	if reason == cmapi.CertificateRequestReasonFailed || reason == cmapi.CertificateRequestReasonDenied {
+		r.CertificateRequestController.SetFailureTime(cr)
+	}
+	return r.CertificateRequestController.SetStatus(ctx, cr, status, reason, "%s", message)
+}
+
+// Fire an Event on the Certificate owning the CertificateRequest (if any).
+func (r *AdcsRequestReconciler) recordCertificateEvent(cr *cmapi.CertificateRequest, eventType, reason, message string) {
+	owner := metav1.GetControllerOf(cr)
+	if owner == nil || owner.Kind != cmapi.CertificateKind {
+		return
+	}
+	certificate := &cmapi.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      owner.Name,
+			Namespace: cr.Namespace,
+			UID:       owner.UID,
+		},
+	}
+	r.Recorder.Event(certificate, eventType, reason, fmt.Sprintf("%s: %s", cr.Name, message))
+}
+
+// Build CertificateRequest condition message with ADCS request details
+// e.g. 'ADCS request rejected (request ID 18, CA ADCSSim, HRESULT 0x80094014): Denied by CS simulator'
+func statusMessage(summary string, ar *api.AdcsRequest) string {
+	var details []string
+	if ar.Status.Id != "" {
+		details = append(details, "request ID "+ar.Status.Id)
+	}
+	if ar.Status.CA != "" {
+		details = append(details, "CA "+ar.Status.CA)
+	}
+	if ar.Status.HResult != "" {
+		details = append(details, "HRESULT "+ar.Status.HResult)
+	}
+	message := summary
+	if len(details) > 0 {
+		message = fmt.Sprintf("%s (%s)", message, strings.Join(details, ", "))
+	}
+	if ar.Status.Reason != "" {
+		message = message + ": " + ar.Status.Reason
+	}
+	return message
+}
+
 func (r *AdcsRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := setupIndexes(context.Background(), mgr); err != nil {
+		return err
+	}
+	blder := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
 		For(&api.AdcsRequest{}).
-		Complete(r)
+		Watches(&source.Kind{Type: &api.AdcsIssuer{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForAdcsIssuer),
+			builder.WithPredicates(issuerChanged)).
+		Watches(&source.Kind{Type: &api.ClusterAdcsIssuer{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForClusterAdcsIssuer),
+			builder.WithPredicates(issuerChanged)).
+		Watches(&source.Kind{Type: &core.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.requestsForSecret))
+	if r.Poller != nil {
+		blder = blder.Watches(r.Poller.Source(), &handler.EnqueueRequestForObject{})
+	}
+	return blder.Complete(r)
+}
+
+// Set the certificate followed by the intermediates and the root CA certificates
+func setCAChain(cr *cmapi.CertificateRequest, cert []byte, caCert []byte) error {
+	chain, err := issuers.CertificateChain(cert, caCert)
+	if err != nil {
+		return err
+	}
+	roots, err := issuers.RootCertificates(caCert)
+	if err != nil {
+		return err
+	}
+	cr.Status.Certificate = chain
+	cr.Status.CA = roots
+	return nil
 }
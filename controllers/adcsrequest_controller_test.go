@@ -0,0 +1,317 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	fakeclock "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+// Fake ADCS denying all requests with the given disposition message
+func newDenyingAdcs(t *testing.T, disposition, lastStatus string) *httptest.Server {
+	ca, _ := testutil.NewCA(t, "ADCS CA", nil, nil)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/certfnsh.asp":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<P ID=locPageDesc>Your certificate request has been received. Your Request Id is 18.</P>")
+		case "/certnew.cer":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<DT><B>Disposition message:</B></DT><DD>\r\n\t\t%s\r\n</DD>\r\n"+
+				"<DT><B>LastStatus:</B></DT><DD>\r\n\t\t%s\r\n</DD>\r\n", disposition, lastStatus)
+		case "/certcarc.asp":
+			fmt.Fprint(w, "var nRenewals=0;")
+		case "/certnew.p7b":
+			w.Header().Set("Content-Type", "application/x-pkcs7-certificates")
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// AdcsRequest created for a CertificateRequest of a Certificate
+func newCertificateRequestObjects(t *testing.T, issuer string) (*cmapi.CertificateRequest, *api.AdcsRequest) {
+	controller := true
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a", Name: "web-1", UID: "cr-uid",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: cmapi.SchemeGroupVersion.String(), Kind: cmapi.CertificateKind,
+				Name: "web", UID: "certificate-uid", Controller: &controller,
+			}},
+		},
+	}
+	ar := &api.AdcsRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "team-a", Name: "web-1",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: cmapi.SchemeGroupVersion.String(), Kind: "CertificateRequest",
+				Name: cr.Name, UID: cr.UID, Controller: &controller,
+			}},
+		},
+		Spec: api.AdcsRequestSpec{
+			CSRPEM:    testutil.NewCSR(t, testutil.NewKey(t), &x509.CertificateRequest{Subject: pkix.Name{CommonName: "web.example.com"}}),
+			IssuerRef: cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "AdcsIssuer", Name: issuer},
+		},
+	}
+	return cr, ar
+}
+
+func TestReconcileRejection(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name        string
+		policy      api.RejectionPolicy
+		reason      string
+		failureTime bool
+	}{
+		{"hold", api.RejectionPolicyHold, cmapi.CertificateRequestReasonPending, false},
+		{"deny", api.RejectionPolicyDeny, cmapi.CertificateRequestReasonDenied, true},
+		{"default", "", cmapi.CertificateRequestReasonFailed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newDenyingAdcs(t, "Denied by Policy Module", "The request was denied. 0x80094014")
+			issuer := &api.AdcsIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "adcs"},
+				Spec: api.AdcsIssuerSpec{
+					URL:            srv.URL,
+					CredentialsRef: api.LocalObjectReference{Name: "adcs-credentials"},
+					CABundle:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}),
+					OnRejection:    tt.policy,
+				},
+			}
+			secret := &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "adcs-credentials"},
+				Data:       map[string][]byte{"username": []byte("user"), "password": []byte("password")},
+			}
+			cr, ar := newCertificateRequestObjects(t, issuer.Name)
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(issuer, secret, cr, ar).Build()
+			r := &AdcsRequestReconciler{
+				Client:        c,
+				Log:           logr.Discard(),
+				IssuerFactory: issuers.IssuerFactory{Client: c, Log: logr.Discard()},
+				Recorder:      record.NewFakeRecorder(10),
+				CertificateRequestController: &CertificateRequestReconciler{
+					Client:   c,
+					Log:      logr.Discard(),
+					Recorder: record.NewFakeRecorder(10),
+					Clock:    fakeclock.NewFakeClock(now),
+				},
+			}
+
+			if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ar)}); err != nil {
+				t.Fatal(err)
+			}
+
+			updated := new(api.AdcsRequest)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(ar), updated); err != nil {
+				t.Fatal(err)
+			}
+			if updated.Status.State != api.Rejected {
+				t.Fatalf("expected request rejected, got %s: %s", updated.Status.State, updated.Status.Reason)
+			}
+			updatedCR := new(cmapi.CertificateRequest)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(cr), updatedCR); err != nil {
+				t.Fatal(err)
+			}
+			if len(updatedCR.Status.Conditions) != 1 || updatedCR.Status.Conditions[0].Reason != tt.reason {
+				t.Fatalf("expected Ready condition with reason %s, got %+v", tt.reason, updatedCR.Status.Conditions)
+			}
+			if updatedCR.Status.Conditions[0].Status != cmmeta.ConditionFalse {
+				t.Errorf("expected Ready condition False, got %s", updatedCR.Status.Conditions[0].Status)
+			}
+			if tt.failureTime {
+				if updatedCR.Status.FailureTime == nil || updatedCR.Status.FailureTime.Unix() != now.Unix() {
+					t.Errorf("expected failure time %v, got %v", now, updatedCR.Status.FailureTime)
+				}
+			} else if updatedCR.Status.FailureTime != nil {
+				t.Errorf("expected no failure time, got %v", updatedCR.Status.FailureTime)
+			}
+		})
+	}
+}
+
+func TestUpdateCertificateRequest(t *testing.T) {
+	rejected := api.AdcsRequestStatus{
+		State:   api.Rejected,
+		Id:      "18",
+		Reason:  "Denied by Policy Module The request was denied. 0x80094014",
+		HResult: "0x80094014",
+		CA:      "ADCS CA",
+	}
+	rejectedMessage := "ADCS request rejected (request ID 18, CA ADCS CA, HRESULT 0x80094014): " +
+		"Denied by Policy Module The request was denied. 0x80094014"
+	tests := []struct {
+		name        string
+		status      api.AdcsRequestStatus
+		conditions  []cmapi.CertificateRequestCondition
+		annotations map[string]string
+		standalone  bool
+		summary     string
+		reason      string
+		want        map[string]string
+		message     string
+		event       string
+	}{
+		{
+			name:    "rejected",
+			status:  rejected,
+			summary: "ADCS request rejected",
+			reason:  cmapi.CertificateRequestReasonDenied,
+			want: map[string]string{
+				api.RequestIDAnnotation:   "18",
+				api.DispositionAnnotation: rejected.Reason,
+				api.HResultAnnotation:     "0x80094014",
+				api.CAAnnotation:          "ADCS CA",
+			},
+			message: rejectedMessage,
+			event:   "Warning Denied web-1: " + rejectedMessage,
+		},
+		{
+			name:        "issued",
+			status:      api.AdcsRequestStatus{State: api.Ready, Id: "18", CA: "ADCS CA"},
+			annotations: map[string]string{api.HResultAnnotation: "0x80094014", "other": "kept"},
+			summary:     "ADCS request successfull",
+			reason:      cmapi.CertificateRequestReasonIssued,
+			want: map[string]string{
+				api.RequestIDAnnotation:   "18",
+				api.DispositionAnnotation: "Issued",
+				api.CAAnnotation:          "ADCS CA",
+				"other":                   "kept",
+			},
+			message: "ADCS request successfull (request ID 18, CA ADCS CA)",
+			event:   "Normal Issued web-1: ADCS request successfull (request ID 18, CA ADCS CA)",
+		},
+		{
+			name:    "pending",
+			status:  api.AdcsRequestStatus{State: api.Pending, Id: "18", Reason: "Taken Under Submission", CA: "ADCS CA"},
+			summary: "ADCS request pending",
+			reason:  cmapi.CertificateRequestReasonPending,
+			want: map[string]string{
+				api.RequestIDAnnotation:   "18",
+				api.DispositionAnnotation: "Taken Under Submission",
+				api.CAAnnotation:          "ADCS CA",
+			},
+			message: "ADCS request pending (request ID 18, CA ADCS CA): Taken Under Submission",
+			event:   "Normal Pending web-1: ADCS request pending (request ID 18, CA ADCS CA): Taken Under Submission",
+		},
+		{
+			name:   "condition unchanged",
+			status: rejected,
+			conditions: []cmapi.CertificateRequestCondition{{
+				Type: cmapi.CertificateRequestConditionReady, Status: cmmeta.ConditionFalse,
+				Reason: cmapi.CertificateRequestReasonDenied, Message: rejectedMessage,
+			}},
+			summary: "ADCS request rejected",
+			reason:  cmapi.CertificateRequestReasonDenied,
+			message: rejectedMessage,
+		},
+		{
+			name:       "without Certificate",
+			status:     rejected,
+			standalone: true,
+			summary:    "ADCS request rejected",
+			reason:     cmapi.CertificateRequestReasonDenied,
+			want: map[string]string{
+				api.RequestIDAnnotation:   "18",
+				api.DispositionAnnotation: rejected.Reason,
+				api.HResultAnnotation:     "0x80094014",
+				api.CAAnnotation:          "ADCS CA",
+			},
+			message: rejectedMessage,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cr, ar := newCertificateRequestObjects(t, "adcs")
+			cr.Annotations = tt.annotations
+			cr.Status.Conditions = tt.conditions
+			if tt.standalone {
+				cr.OwnerReferences = nil
+			}
+			ar.Status = tt.status
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(cr).Build()
+			recorder := record.NewFakeRecorder(10)
+			r := &AdcsRequestReconciler{
+				Client:   c,
+				Log:      logr.Discard(),
+				Recorder: recorder,
+				CertificateRequestController: &CertificateRequestReconciler{
+					Client:   c,
+					Log:      logr.Discard(),
+					Recorder: record.NewFakeRecorder(10),
+					Clock:    fakeclock.NewFakeClock(time.Now()),
+				},
+			}
+
+			status := cmmeta.ConditionFalse
+			if ar.Status.State == api.Ready {
+				status = cmmeta.ConditionTrue
+			}
+			err := r.updateCertificateRequest(context.Background(), cr, ar, status, tt.reason, statusMessage(tt.summary, ar))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			updated := new(cmapi.CertificateRequest)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(cr), updated); err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(updated.Annotations) != fmt.Sprint(tt.want) {
+				t.Errorf("expected annotations %v, got %v", tt.want, updated.Annotations)
+			}
+			if len(updated.Status.Conditions) != 1 || updated.Status.Conditions[0].Message != tt.message {
+				t.Errorf("expected Ready condition with message %q, got %+v", tt.message, updated.Status.Conditions)
+			}
+			select {
+			case e := <-recorder.Events:
+				if e != tt.event {
+					t.Errorf("expected Certificate event %q, got %q", tt.event, e)
+				}
+			default:
+				if tt.event != "" {
+					t.Errorf("expected Certificate event %q", tt.event)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	jks "github.com/pavel-v-chernykh/keystore-go"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Keystores are encoded the same way as by cert-manager for Certificates.
+
+// PKCS #12 keystore with the private key, its certificate and the CA certificates
+func encodePKCS12Keystore(key crypto.PrivateKey, cert *x509.Certificate, caCerts []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.Encode(rand.Reader, key, cert, caCerts, password)
+}
+
+// PKCS #12 truststore with the CA certificates
+func encodePKCS12Truststore(caCerts []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.EncodeTrustStore(rand.Reader, caCerts, password)
+}
+
+// Java KeyStore with the private key and its certificate chain under the 'certificate' alias
+// and the CA certificates as trusted certificate entries
+func encodeJKSKeystore(key crypto.PrivateKey, cert *x509.Certificate, caCerts []*x509.Certificate, password string) ([]byte, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot encode private key: %s", err.Error())
+	}
+	var chain []jks.Certificate
+	for _, c := range append([]*x509.Certificate{cert}, caCerts...) {
+		chain = append(chain, jksCertificate(c))
+	}
+	ks := jksTrustedCertificates(caCerts)
+	ks["certificate"] = &jks.PrivateKeyEntry{
+		Entry:     jks.Entry{CreationDate: time.Now()},
+		PrivKey:   keyDER,
+		CertChain: chain,
+	}
+	return encodeJKS(ks, password)
+}
+
+// Java KeyStore with the CA certificates as trusted certificate entries
+func encodeJKSTruststore(caCerts []*x509.Certificate, password string) ([]byte, error) {
+	return encodeJKS(jksTrustedCertificates(caCerts), password)
+}
+
+func jksTrustedCertificates(caCerts []*x509.Certificate) jks.KeyStore {
+	ks := jks.KeyStore{}
+	for i, c := range caCerts {
+		alias := "ca"
+		if i > 0 {
+			alias = fmt.Sprintf("ca-%d", i)
+		}
+		ks[alias] = &jks.TrustedCertificateEntry{
+			Entry:       jks.Entry{CreationDate: time.Now()},
+			Certificate: jksCertificate(c),
+		}
+	}
+	return ks
+}
+
+func jksCertificate(cert *x509.Certificate) jks.Certificate {
+	return jks.Certificate{Type: "X509", Content: cert.Raw}
+}
+
+func encodeJKS(ks jks.KeyStore, password string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := jks.Encode(buf, ks, []byte(password)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,114 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	jks "github.com/pavel-v-chernykh/keystore-go"
+	"software.sslmate.com/src/go-pkcs12"
+
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func TestPKCS12Keystores(t *testing.T) {
+	ca, caKey := testutil.NewCA(t, "Root CA", nil, nil)
+	key := testutil.NewKey(t)
+	cert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "host.example.com"}}, &key.PublicKey, ca, caKey)
+
+	data, err := encodePKCS12Keystore(key, cert, []*x509.Certificate{ca}, "changeit")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decodedKey, decodedCert, decodedCAs, err := pkcs12.DecodeChain(data, "changeit")
+	if err != nil {
+		t.Fatalf("cannot decode keystore: %v", err)
+	}
+	if !key.Equal(decodedKey) {
+		t.Errorf("unexpected private key")
+	}
+	if !decodedCert.Equal(cert) {
+		t.Errorf("unexpected certificate %s", decodedCert.Subject)
+	}
+	if len(decodedCAs) != 1 || !decodedCAs[0].Equal(ca) {
+		t.Errorf("unexpected CA certificates %v", decodedCAs)
+	}
+
+	data, err = encodePKCS12Truststore([]*x509.Certificate{ca}, "changeit")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	trusted, err := pkcs12.DecodeTrustStore(data, "changeit")
+	if err != nil {
+		t.Fatalf("cannot decode truststore: %v", err)
+	}
+	if len(trusted) != 1 || !trusted[0].Equal(ca) {
+		t.Errorf("unexpected trusted certificates %v", trusted)
+	}
+}
+
+func TestJKSKeystores(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	ca, caKey := testutil.NewCA(t, "Issuing CA", root, rootKey)
+	key := testutil.NewKey(t)
+	cert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "host.example.com"}}, &key.PublicKey, ca, caKey)
+	caCerts := []*x509.Certificate{ca, root}
+
+	data, err := encodeJKSKeystore(key, cert, caCerts, "changeit")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ks, err := jks.Decode(bytes.NewReader(data), []byte("changeit"))
+	if err != nil {
+		t.Fatalf("cannot decode keystore: %v", err)
+	}
+	entry, ok := ks["certificate"].(*jks.PrivateKeyEntry)
+	if !ok {
+		t.Fatalf("expected private key entry 'certificate', got %v", ks["certificate"])
+	}
+	decodedKey, err := x509.ParsePKCS8PrivateKey(entry.PrivKey)
+	if err != nil || !key.Equal(decodedKey) {
+		t.Errorf("unexpected private key: %v", err)
+	}
+	if len(entry.CertChain) != 3 || !bytes.Equal(entry.CertChain[0].Content, cert.Raw) ||
+		!bytes.Equal(entry.CertChain[1].Content, ca.Raw) || !bytes.Equal(entry.CertChain[2].Content, root.Raw) {
+		t.Errorf("unexpected certificate chain of %d certificates", len(entry.CertChain))
+	}
+	for alias, c := range map[string]*x509.Certificate{"ca": ca, "ca-1": root} {
+		trusted, ok := ks[alias].(*jks.TrustedCertificateEntry)
+		if !ok || !bytes.Equal(trusted.Certificate.Content, c.Raw) {
+			t.Errorf("expected trusted certificate %s under alias %s", c.Subject.CommonName, alias)
+		}
+	}
+
+	data, err = encodeJKSTruststore(caCerts, "changeit")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ks, err = jks.Decode(bytes.NewReader(data), []byte("changeit"))
+	if err != nil {
+		t.Fatalf("cannot decode truststore: %v", err)
+	}
+	if len(ks) != 2 {
+		t.Errorf("expected 2 trusted certificates, got %d entries", len(ks))
+	}
+	if _, err := jks.Decode(bytes.NewReader(data), []byte("wrong")); err == nil {
+		t.Errorf("expected truststore protected by the password")
+	}
+}
@@ -0,0 +1,181 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	core "k8s.io/api/core/v1"
+	apimacherrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	pkcs12KeystoreKey   = "keystore.p12"
+	pkcs12TruststoreKey = "truststore.p12"
+	jksKeystoreKey      = "keystore.jks"
+	jksTruststoreKey    = "truststore.jks"
+)
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+var adcsRequestGvk = api.GroupVersion.WithKind("AdcsRequest")
+
+// Write the issued certificate to the request's Secret.
+// The Secret is created (controlled by the AdcsRequest) if it doesn't exist. An existing Secret
+// is updated only if it's controlled by the AdcsRequest, so requests can't overwrite Secrets of
+// other workloads. Its other keys (e.g. 'tls.key' stored by the requester) are kept.
+// Keystores are re-created only when the certificate changes or they are missing.
+func (r *AdcsRequestReconciler) writeSecret(ctx context.Context, ar *api.AdcsRequest) error {
+	cert, err := pki.DecodeX509CertificateBytes(ar.Status.Certificate)
+	if err != nil {
+		return fmt.Errorf("Cannot decode issued certificate: %s", err.Error())
+	}
+	caCerts, err := pki.DecodeX509CertificateChainBytes(ar.Status.CACertificate)
+	if err != nil {
+		return fmt.Errorf("Cannot decode CA certificates: %s", err.Error())
+	}
+	chain, root := issuers.SplitChain(caCerts)
+	chainPEM := issuers.EncodeCertificates(append([]*x509.Certificate{cert}, chain...))
+	caPEM := issuers.EncodeCertificates(root)
+
+	secret := new(core.Secret)
+	err = r.Client.Get(ctx, client.ObjectKey{Namespace: ar.Namespace, Name: ar.Spec.SecretName}, secret)
+	if err != nil && !apimacherrors.IsNotFound(err) {
+		return err
+	}
+	create := err != nil
+	if create {
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       ar.Namespace,
+				Name:            ar.Spec.SecretName,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(ar, adcsRequestGvk)},
+			},
+			Type: core.SecretTypeOpaque,
+		}
+	} else if !metav1.IsControlledBy(secret, ar) {
+		return fmt.Errorf("Secret %s exists and is not controlled by the AdcsRequest", ar.Spec.SecretName)
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+	changed := !bytes.Equal(secret.Data[core.TLSCertKey], chainPEM) || !bytes.Equal(secret.Data[cmmeta.TLSCAKey], caPEM)
+	secret.Data[core.TLSCertKey] = chainPEM
+	secret.Data[cmmeta.TLSCAKey] = caPEM
+
+	// The private key is known only if the requester stored it in the Secret.
+	// Keys not matching the certificate are not put in the keystores.
+	var key crypto.Signer
+	if keyPEM, ok := secret.Data[core.TLSPrivateKeyKey]; ok {
+		if signer, err := pki.DecodePrivateKeyBytes(keyPEM); err == nil {
+			if matches, err := pki.PublicKeyMatchesCertificate(signer.Public(), cert); err == nil && matches {
+				key = signer
+			}
+		}
+	}
+
+	if ks := ar.Spec.Keystores; ks != nil {
+		if ks.PKCS12 != nil && ks.PKCS12.Create {
+			err := r.writeKeystores(ctx, ar, secret, changed, ks.PKCS12.PasswordSecretRef, pkcs12KeystoreKey, pkcs12TruststoreKey,
+				func(password string) ([]byte, error) { return encodePKCS12Keystore(key, cert, caCerts, password) },
+				func(password string) ([]byte, error) { return encodePKCS12Truststore(caCerts, password) },
+				key != nil)
+			if err != nil {
+				return err
+			}
+		}
+		if ks.JKS != nil && ks.JKS.Create {
+			err := r.writeKeystores(ctx, ar, secret, changed, ks.JKS.PasswordSecretRef, jksKeystoreKey, jksTruststoreKey,
+				func(password string) ([]byte, error) { return encodeJKSKeystore(key, cert, caCerts, password) },
+				func(password string) ([]byte, error) { return encodeJKSTruststore(caCerts, password) },
+				key != nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	for key, value := range map[string]string{
+		api.RequestIDAnnotation: ar.Status.Id,
+		api.CAAnnotation:        ar.Status.CA,
+	} {
+		if secret.Annotations[key] != value {
+			secret.Annotations[key] = value
+			changed = true
+		}
+	}
+
+	if create {
+		return r.Client.Create(ctx, secret)
+	}
+	if !changed {
+		return nil
+	}
+	return r.Client.Update(ctx, secret)
+}
+
+// Encode the keystore (if the private key is known) and the truststore if the certificate
+// changed or they are missing in the Secret.
+func (r *AdcsRequestReconciler) writeKeystores(ctx context.Context, ar *api.AdcsRequest, secret *core.Secret, changed bool,
+	passwordRef cmmeta.SecretKeySelector, keystoreKey, truststoreKey string,
+	encodeKeystore, encodeTruststore func(password string) ([]byte, error), withKey bool) error {
+	_, hasKeystore := secret.Data[keystoreKey]
+	_, hasTruststore := secret.Data[truststoreKey]
+	if !changed && (hasKeystore || !withKey) && hasTruststore {
+		return nil
+	}
+	password, err := r.keystorePassword(ctx, ar.Namespace, passwordRef)
+	if err != nil {
+		return err
+	}
+	if withKey {
+		if secret.Data[keystoreKey], err = encodeKeystore(password); err != nil {
+			return fmt.Errorf("Cannot encode %s: %s", keystoreKey, err.Error())
+		}
+	} else {
+		delete(secret.Data, keystoreKey)
+	}
+	if secret.Data[truststoreKey], err = encodeTruststore(password); err != nil {
+		return fmt.Errorf("Cannot encode %s: %s", truststoreKey, err.Error())
+	}
+	return nil
+}
+
+func (r *AdcsRequestReconciler) keystorePassword(ctx context.Context, namespace string, ref cmmeta.SecretKeySelector) (string, error) {
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: ref.Name}, secret); err != nil {
+		return "", fmt.Errorf("Cannot get keystore password Secret %s: %s", ref.Name, err.Error())
+	}
+	password, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("Key %s not found in keystore password Secret %s", ref.Key, ref.Name)
+	}
+	return string(password), nil
+}
@@ -0,0 +1,226 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func encodeTestCertificate(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func encodeTestKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+// Issued standalone AdcsRequest writing the certificate to the 'host-tls' Secret
+func newTestSecretRequest(t *testing.T, key *ecdsa.PrivateKey) (*api.AdcsRequest, *x509.Certificate) {
+	ca, caKey := testutil.NewCA(t, "Root CA", nil, nil)
+	cert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "host.example.com"}}, &key.PublicKey, ca, caKey)
+	return &api.AdcsRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host", UID: "ar-uid"},
+		Spec:       api.AdcsRequestSpec{SecretName: "host-tls"},
+		Status: api.AdcsRequestStatus{
+			State:         api.Ready,
+			Id:            "21",
+			CA:            "Root CA",
+			Certificate:   encodeTestCertificate(cert),
+			CACertificate: encodeTestCertificate(ca),
+		},
+	}, ca
+}
+
+func TestWriteSecret(t *testing.T) {
+	key := testutil.NewKey(t)
+	ar, ca := newTestSecretRequest(t, key)
+	r := &AdcsRequestReconciler{Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).Build()}
+	ctx := context.Background()
+
+	if err := r.writeSecret(ctx, ar); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "host-tls"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if !metav1.IsControlledBy(secret, ar) {
+		t.Errorf("expected Secret controlled by the AdcsRequest, got owners %v", secret.OwnerReferences)
+	}
+	if !bytes.Equal(secret.Data[core.TLSCertKey], ar.Status.Certificate) {
+		t.Errorf("unexpected %s:\n%s", core.TLSCertKey, secret.Data[core.TLSCertKey])
+	}
+	if !bytes.Equal(secret.Data[cmmeta.TLSCAKey], encodeTestCertificate(ca)) {
+		t.Errorf("unexpected %s:\n%s", cmmeta.TLSCAKey, secret.Data[cmmeta.TLSCAKey])
+	}
+	if secret.Annotations[api.RequestIDAnnotation] != "21" {
+		t.Errorf("expected request ID annotation, got %v", secret.Annotations)
+	}
+
+	// The requester stores the private key in the Secret
+	keyPEM := encodeTestKey(t, key)
+	secret.Data[core.TLSPrivateKeyKey] = keyPEM
+	if err := r.Client.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.writeSecret(ctx, ar); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(secret.Data[core.TLSPrivateKeyKey], keyPEM) {
+		t.Errorf("expected %s kept", core.TLSPrivateKeyKey)
+	}
+}
+
+func TestWriteSecretNotControlled(t *testing.T) {
+	ar, _ := newTestSecretRequest(t, testutil.NewKey(t))
+	tests := []struct {
+		name   string
+		owners []metav1.OwnerReference
+	}{
+		{"no owner", nil},
+		{"other controller", []metav1.OwnerReference{*metav1.NewControllerRef(&api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "other", UID: "other-uid"}}, adcsRequestGvk)}},
+		{"same name other UID", []metav1.OwnerReference{*metav1.NewControllerRef(&api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host", UID: "old-uid"}}, adcsRequestGvk)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			existing := &core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host-tls", OwnerReferences: tt.owners},
+				Data:       map[string][]byte{core.TLSCertKey: []byte("other workload")},
+			}
+			r := &AdcsRequestReconciler{Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(existing).Build()}
+
+			if err := r.writeSecret(context.Background(), ar); err == nil {
+				t.Fatal("expected error")
+			}
+			secret := new(core.Secret)
+			if err := r.Client.Get(context.Background(), client.ObjectKeyFromObject(existing), secret); err != nil {
+				t.Fatal(err)
+			}
+			if string(secret.Data[core.TLSCertKey]) != "other workload" {
+				t.Errorf("Secret overwritten: %s", secret.Data[core.TLSCertKey])
+			}
+		})
+	}
+}
+
+func TestWriteSecretKeystores(t *testing.T) {
+	key := testutil.NewKey(t)
+	ar, _ := newTestSecretRequest(t, key)
+	passwordRef := cmmeta.SecretKeySelector{LocalObjectReference: cmmeta.LocalObjectReference{Name: "keystore-password"}, Key: "password"}
+	ar.Spec.Keystores = &cmapi.CertificateKeystores{
+		PKCS12: &cmapi.PKCS12Keystore{Create: true, PasswordSecretRef: passwordRef},
+		JKS:    &cmapi.JKSKeystore{Create: true, PasswordSecretRef: passwordRef},
+	}
+	password := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "keystore-password"},
+		Data:       map[string][]byte{"password": []byte("changeit")},
+	}
+	ctx := context.Background()
+
+	t.Run("without key", func(t *testing.T) {
+		r := &AdcsRequestReconciler{Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(password).Build()}
+		if err := r.writeSecret(ctx, ar); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		secret := new(core.Secret)
+		if err := r.Client.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: "host-tls"}, secret); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{pkcs12TruststoreKey, jksTruststoreKey} {
+			if len(secret.Data[k]) == 0 {
+				t.Errorf("expected %s", k)
+			}
+		}
+		for _, k := range []string{pkcs12KeystoreKey, jksKeystoreKey} {
+			if _, ok := secret.Data[k]; ok {
+				t.Errorf("unexpected %s without private key", k)
+			}
+		}
+	})
+
+	t.Run("with key", func(t *testing.T) {
+		secret := &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host-tls",
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(ar, adcsRequestGvk)}},
+			Data: map[string][]byte{core.TLSPrivateKeyKey: encodeTestKey(t, key)},
+		}
+		r := &AdcsRequestReconciler{Client: fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(password, secret).Build()}
+		if err := r.writeSecret(ctx, ar); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+			t.Fatal(err)
+		}
+		first := secret.DeepCopy()
+		for _, k := range []string{pkcs12KeystoreKey, pkcs12TruststoreKey, jksKeystoreKey, jksTruststoreKey} {
+			if len(secret.Data[k]) == 0 {
+				t.Errorf("expected %s", k)
+			}
+		}
+
+		// Keystores are encoded with random salts so equal bytes mean they weren't rebuilt
+		if err := r.writeSecret(ctx, ar); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+			t.Fatal(err)
+		}
+		if secret.ResourceVersion != first.ResourceVersion {
+			t.Errorf("Secret updated without a change")
+		}
+
+		// Renewed certificate
+		renewed, _ := newTestSecretRequest(t, key)
+		ar.Status.Certificate = renewed.Status.Certificate
+		ar.Status.CACertificate = renewed.Status.CACertificate
+		if err := r.writeSecret(ctx, ar); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), secret); err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range []string{pkcs12KeystoreKey, pkcs12TruststoreKey, jksKeystoreKey, jksTruststoreKey} {
+			if bytes.Equal(secret.Data[k], first.Data[k]) {
+				t.Errorf("expected %s rebuilt for the renewed certificate", k)
+			}
+		}
+	})
+}
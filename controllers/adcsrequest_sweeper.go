@@ -0,0 +1,107 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// AdcsRequestSweeper periodically looks for orphan AdcsRequests
+// i.e. the ones whose CertificateRequest doesn't exist anymore.
+// AdcsRequests created without a CertificateRequest are not checked.
+type AdcsRequestSweeper struct {
+	client.Client
+	Log      logr.Logger
+	Recorder record.EventRecorder
+	Interval time.Duration
+	// Delete orphans (pending ones are denied on ADCS by the finalizer if the issuer cancels pending requests)
+	DeleteOrphans bool
+}
+
+// Start implements manager.Runnable
+func (s *AdcsRequestSweeper) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.sweep(ctx)
+		}
+	}
+}
+
+func (s *AdcsRequestSweeper) sweep(ctx context.Context) {
+	list := new(api.AdcsRequestList)
+	if err := s.Client.List(ctx, list); err != nil {
+		s.Log.Error(err, "Cannot list AdcsRequests")
+		return
+	}
+
+	orphans := 0
+	for i := range list.Items {
+		ar := &list.Items[i]
+		owner := certificateRequestOwner(ar)
+		if owner == nil || !ar.DeletionTimestamp.IsZero() {
+			continue
+		}
+		cr := new(cmapi.CertificateRequest)
+		err := s.Client.Get(ctx, types.NamespacedName{Namespace: ar.Namespace, Name: owner.Name}, cr)
+		if err != nil && !apierrors.IsNotFound(err) {
+			s.Log.Error(err, "Cannot get CertificateRequest", "certificaterequest", owner.Name)
+			continue
+		}
+		if err == nil && cr.UID == owner.UID {
+			continue
+		}
+
+		orphans++
+		log := s.Log.WithValues("adcsrequest", types.NamespacedName{Namespace: ar.Namespace, Name: ar.Name})
+		log.Info("Orphan AdcsRequest found", "state", ar.Status.State, "id", ar.Status.Id)
+		s.Recorder.Event(ar, core.EventTypeWarning, "Orphan",
+			fmt.Sprintf("CertificateRequest %s doesn't exist anymore", owner.Name))
+		if s.DeleteOrphans {
+			if err := s.Client.Delete(ctx, ar); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Cannot delete orphan AdcsRequest")
+			}
+		}
+	}
+	s.Log.Info("AdcsRequests checked", "total", len(list.Items), "orphans", orphans)
+}
+
+func certificateRequestOwner(ar *api.AdcsRequest) *metav1.OwnerReference {
+	for i, owner := range ar.OwnerReferences {
+		if owner.Kind == cmapi.CertificateRequestKind {
+			return &ar.OwnerReferences[i]
+		}
+	}
+	return nil
+}
@@ -18,6 +18,7 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"k8s.io/utils/clock"
@@ -50,7 +51,7 @@ var (
 
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=cert-manager.io,resources=certificaterequests/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups="",resources=events,verbs=patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := r.Log.WithValues("certificaterequest", req.NamespacedName)
@@ -106,10 +107,7 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 	if cmapiutil.CertificateRequestIsDenied(&cr) {
 		log.V(4).Info("CertificateRequest has been denied. Marking as failed.")
 
-		if cr.Status.FailureTime == nil {
-			nowTime := metav1.NewTime(r.Clock.Now())
-			cr.Status.FailureTime = &nowTime
-		}
+		r.SetFailureTime(&cr)
 
 		message := "The CertificateRequest was denied by an approval controller"
 		return ctrl.Result{}, r.SetStatus(ctx, &cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonDenied, message)
@@ -157,6 +155,22 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 		}
 	}
 
+	// Shared issuers can limit the number of certificates of each namespace
+	message, retryAfter, err := r.checkQuota(ctx, &cr)
+	if err != nil {
+		log.Error(err, "failed to check the issuer quota")
+		return ctrl.Result{}, err
+	}
+	if message != "" {
+		log.Info("Quota exceeded", "retryAfter", retryAfter)
+		if c := cmapiutil.GetCertificateRequestCondition(&cr, cmapi.CertificateRequestConditionReady); c == nil || c.Message != message {
+			if err := r.SetStatus(ctx, &cr, cmmeta.ConditionFalse, cmapi.CertificateRequestReasonPending, "%s", message); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
 	log.Info("Creating new AdcsRequest")
 	err = r.createAdcsRequest(ctx, &cr)
 	if err != nil {
@@ -167,9 +181,20 @@ func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.R
 }
 
 func (r *CertificateRequestReconciler) createAdcsRequest(ctx context.Context, cmRequest *cmapi.CertificateRequest) error {
+	renewalSecret, err := r.renewalSecret(ctx, cmRequest)
+	if err != nil {
+		return err
+	}
 	spec := api.AdcsRequestSpec{
-		CSRPEM:    cmRequest.Spec.Request,
-		IssuerRef: cmRequest.Spec.IssuerRef,
+		CSRPEM:            cmRequest.Spec.Request,
+		IssuerRef:         cmRequest.Spec.IssuerRef,
+		Attributes:        requestAttributes(cmRequest.Annotations),
+		SID:               cmRequest.Annotations[api.SIDAnnotation],
+		RequesterName:     cmRequest.Annotations[api.RequesterNameAnnotation],
+		RenewalSecretName: renewalSecret,
+		Duration:          cmRequest.Spec.Duration,
+		Usages:            cmRequest.Spec.Usages,
+		IsCA:              cmRequest.Spec.IsCA,
 	}
 	return r.Create(ctx, &api.AdcsRequest{
 		ObjectMeta: metav1.ObjectMeta{
@@ -187,6 +212,21 @@ func (r *CertificateRequestReconciler) SetupWithManager(mgr ctrl.Manager) error
 		Complete(r)
 }
 
+// Get ADCS request attributes from the CertificateRequest (or CertificateSigningRequest) annotations.
+func requestAttributes(annotations map[string]string) map[string]string {
+	var attributes map[string]string
+	for key, value := range annotations {
+		if !strings.HasPrefix(key, api.AttributeAnnotationPrefix) {
+			continue
+		}
+		if attributes == nil {
+			attributes = map[string]string{}
+		}
+		attributes[strings.TrimPrefix(key, api.AttributeAnnotationPrefix)] = value
+	}
+	return attributes
+}
+
 func RequestDiffers(adcsReq *api.AdcsRequest, certReq *cmapi.CertificateRequest) bool {
 	a := adcsReq.Spec.CSRPEM
 	b := certReq.Spec.Request
@@ -213,6 +253,14 @@ func (r *CertificateRequestReconciler) GetCertificateRequest(ctx context.Context
 	return *cr, nil
 }
 
+// Set FailureTime of the CertificateRequest if not already set.
+func (r *CertificateRequestReconciler) SetFailureTime(cr *cmapi.CertificateRequest) {
+	if cr.Status.FailureTime == nil {
+		nowTime := metav1.NewTime(r.Clock.Now())
+		cr.Status.FailureTime = &nowTime
+	}
+}
+
 func (r *CertificateRequestReconciler) SetStatus(ctx context.Context, cr *cmapi.CertificateRequest, status cmmeta.ConditionStatus, reason, message string, args ...interface{}) error {
 	completeMessage := fmt.Sprintf(message, args...)
 	cmapiutil.SetCertificateRequestCondition(cr, cmapi.CertificateRequestConditionReady, status, reason, completeMessage)
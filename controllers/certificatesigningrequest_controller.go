@@ -0,0 +1,297 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	certificates "k8s.io/api/certificates/v1"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	// Annotations cert-manager uses for the request parameters CertificateSigningRequests
+	// have no field for
+	csrDurationAnnotation = "experimental.cert-manager.io/request-duration"
+	csrIsCAAnnotation     = "experimental.cert-manager.io/request-is-ca"
+)
+
+var certificateSigningRequestGvk = certificates.SchemeGroupVersion.WithKind("CertificateSigningRequest")
+
+// CertificateSigningRequestReconciler signs Kubernetes CertificateSigningRequests
+// with signer names 'adcs.certmanager.csf.nokia.com/adcsissuer.<namespace>.<name>'
+// or 'adcs.certmanager.csf.nokia.com/clusteradcsissuer.<name>'.
+// Approved requests are sent to ADCS with an AdcsRequest controlled by the CertificateSigningRequest
+// (named 'csr-<uid>', in the issuer's namespace or the cluster resource namespace).
+// The state of the ADCS request is kept only there, out of reach of the requester.
+type CertificateSigningRequestReconciler struct {
+	client.Client
+	Log           logr.Logger
+	IssuerFactory issuers.IssuerFactory
+	Recorder      record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups=certificates.k8s.io,resources=certificatesigningrequests/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=certificates.k8s.io,resources=signers,resourceNames=adcs.certmanager.csf.nokia.com/*,verbs=sign
+
+func (r *CertificateSigningRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("certificatesigningrequest", req.Name)
+
+	csr := new(certificates.CertificateSigningRequest)
+	if err := r.Client.Get(ctx, req.NamespacedName, csr); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	issuerRef, namespace, ok := parseSignerName(csr.Spec.SignerName)
+	if !ok {
+		log.V(4).Info("CertificateSigningRequest is not for us", "signerName", csr.Spec.SignerName)
+		return ctrl.Result{}, nil
+	}
+	if len(csr.Status.Certificate) > 0 {
+		log.V(4).Info("CertificateSigningRequest already signed")
+		return ctrl.Result{}, nil
+	}
+	if csrHasCondition(csr, certificates.CertificateDenied) || csrHasCondition(csr, certificates.CertificateFailed) {
+		log.V(4).Info("CertificateSigningRequest denied or failed")
+		return ctrl.Result{}, nil
+	}
+	if !csrHasCondition(csr, certificates.CertificateApproved) {
+		log.V(4).Info("CertificateSigningRequest not approved yet")
+		return ctrl.Result{}, nil
+	}
+
+	if namespace == "" {
+		namespace = r.IssuerFactory.ClusterResourceNamespace
+	}
+	key := client.ObjectKey{Namespace: namespace, Name: "csr-" + string(csr.UID)}
+	log = log.WithValues("adcsrequest", key)
+	ar := new(api.AdcsRequest)
+	err := r.Client.Get(ctx, key, ar)
+	if apierrors.IsNotFound(err) {
+		spec, err := r.adcsRequestSpec(ctx, csr, issuerRef)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		// Changes of the AdcsRequest trigger the next steps
+		log.Info("Creating AdcsRequest")
+		return ctrl.Result{}, r.Client.Create(ctx, &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            key.Name,
+				Namespace:       key.Namespace,
+				OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(csr, certificateSigningRequestGvk)},
+			},
+			Spec: spec,
+		})
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !metav1.IsControlledBy(ar, csr) || !bytes.Equal(ar.Spec.CSRPEM, csr.Spec.Request) || ar.Spec.IssuerRef != issuerRef {
+		// Someone else created it. Never put the certificate of another request in the CSR.
+		log.Info("AdcsRequest doesn't belong to the CertificateSigningRequest")
+		r.Recorder.Event(csr, core.EventTypeWarning, cmapi.CertificateRequestReasonFailed,
+			fmt.Sprintf("AdcsRequest %s exists and doesn't belong to the CertificateSigningRequest", key))
+		return ctrl.Result{}, nil
+	}
+
+	if _, ok := csr.Annotations[api.CheckNowAnnotation]; ok && ar.Status.State == api.Pending {
+		// Forward the forced check to the AdcsRequest
+		patch := client.MergeFrom(ar.DeepCopy())
+		if ar.Annotations == nil {
+			ar.Annotations = map[string]string{}
+		}
+		ar.Annotations[api.CheckNowAnnotation] = ""
+		if err := r.Client.Patch(ctx, ar, patch); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	// The ADCS request details are reported in the annotations
+	changed, err := r.updateAnnotations(ctx, csr, ar)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	switch ar.Status.State {
+	case api.Ready:
+		// The issued certificate followed by the intermediates
+		chain, err := issuers.CertificateChain(ar.Status.Certificate, ar.Status.CACertificate)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		csr.Status.Certificate = chain
+		r.Recorder.Event(csr, core.EventTypeNormal, cmapi.CertificateRequestReasonIssued, statusMessage("ADCS request successfull", ar))
+	case api.Rejected, api.Errored:
+		message := statusMessage(fmt.Sprintf("ADCS request %s", ar.Status.State), ar)
+		csr.Status.Conditions = append(csr.Status.Conditions, certificates.CertificateSigningRequestCondition{
+			Type:               certificates.CertificateFailed,
+			Status:             core.ConditionTrue,
+			Reason:             cmapi.CertificateRequestReasonFailed,
+			Message:            message,
+			LastUpdateTime:     metav1.Now(),
+			LastTransitionTime: metav1.Now(),
+		})
+		r.Recorder.Event(csr, core.EventTypeWarning, cmapi.CertificateRequestReasonFailed, message)
+	default:
+		if changed && ar.Status.State == api.Pending {
+			r.Recorder.Event(csr, core.EventTypeNormal, cmapi.CertificateRequestReasonPending, statusMessage("ADCS request pending", ar))
+		}
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{}, r.Client.Status().Update(ctx, csr)
+}
+
+// Report ADCS request details in the annotations of the CertificateSigningRequest
+// (there's no status field for them). They are never read back.
+// Returns true if they changed.
+func (r *CertificateSigningRequestReconciler) updateAnnotations(ctx context.Context, csr *certificates.CertificateSigningRequest, ar *api.AdcsRequest) (bool, error) {
+	patch := client.MergeFrom(csr.DeepCopy())
+	if csr.Annotations == nil {
+		csr.Annotations = map[string]string{}
+	}
+	disposition := ar.Status.Reason
+	if ar.Status.State == api.Ready {
+		disposition = "Issued"
+	}
+	changed := false
+	for key, value := range map[string]string{
+		api.RequestIDAnnotation:   ar.Status.Id,
+		api.DispositionAnnotation: disposition,
+		api.HResultAnnotation:     ar.Status.HResult,
+		api.CAAnnotation:          ar.Status.CA,
+	} {
+		if csr.Annotations[key] == value {
+			continue
+		}
+		changed = true
+		if value != "" {
+			csr.Annotations[key] = value
+		} else {
+			delete(csr.Annotations, key)
+		}
+	}
+	if _, ok := csr.Annotations[api.CheckNowAnnotation]; ok {
+		// The forced check is passed to the AdcsRequest
+		delete(csr.Annotations, api.CheckNowAnnotation)
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	// Patch doesn't touch the status so keep it to be updated next
+	status := csr.Status.DeepCopy()
+	err := r.Client.Patch(ctx, csr, patch)
+	csr.Status = *status
+	return true, err
+}
+
+// Build the AdcsRequest from the CertificateSigningRequest. The requested duration is taken
+// from 'spec.expirationSeconds' or cert-manager's duration annotation, the CA intent from
+// cert-manager's isCA annotation.
+func (r *CertificateSigningRequestReconciler) adcsRequestSpec(ctx context.Context, csr *certificates.CertificateSigningRequest,
+	issuerRef cmmeta.ObjectReference) (api.AdcsRequestSpec, error) {
+	spec := api.AdcsRequestSpec{
+		CSRPEM:        csr.Spec.Request,
+		IssuerRef:     issuerRef,
+		Attributes:    requestAttributes(csr.Annotations),
+		SID:           csr.Annotations[api.SIDAnnotation],
+		IsCA:          csr.Annotations[csrIsCAAnnotation] == "true",
+		RequesterName: csr.Annotations[api.RequesterNameAnnotation],
+	}
+	for _, usage := range csr.Spec.Usages {
+		spec.Usages = append(spec.Usages, cmapi.KeyUsage(usage))
+	}
+	expirationSeconds, err := r.expirationSeconds(ctx, csr)
+	if err != nil {
+		return spec, err
+	}
+	spec.Duration = csrDuration(expirationSeconds, csr.Annotations[csrDurationAnnotation])
+	return spec, nil
+}
+
+// Get 'spec.expirationSeconds' of the CertificateSigningRequest (Kubernetes 1.22+).
+// The API types in use don't have the field so it's read from the unstructured object.
+func (r *CertificateSigningRequestReconciler) expirationSeconds(ctx context.Context, csr *certificates.CertificateSigningRequest) (int64, error) {
+	u := new(unstructured.Unstructured)
+	u.SetGroupVersionKind(certificateSigningRequestGvk)
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(csr), u); err != nil {
+		return 0, err
+	}
+	seconds, _, err := unstructured.NestedInt64(u.Object, "spec", "expirationSeconds")
+	return seconds, err
+}
+
+// The requested duration. 'spec.expirationSeconds' takes precedence over the annotation.
+func csrDuration(expirationSeconds int64, annotation string) *metav1.Duration {
+	if expirationSeconds > 0 {
+		return &metav1.Duration{Duration: time.Duration(expirationSeconds) * time.Second}
+	}
+	if d, err := time.ParseDuration(annotation); err == nil && d > 0 {
+		return &metav1.Duration{Duration: d}
+	}
+	return nil
+}
+
+// Get the issuer from signer name
+// 'adcs.certmanager.csf.nokia.com/<issuer-kind>.<namespace>.<name>'.
+// Cluster issuers have no namespace. Returns the issuer reference and its namespace.
+func parseSignerName(signerName string) (cmmeta.ObjectReference, string, bool) {
+	prefix := api.GroupVersion.Group + "/"
+	if !strings.HasPrefix(signerName, prefix) {
+		return cmmeta.ObjectReference{}, "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(signerName, prefix), ".", 3)
+	switch {
+	case len(parts) == 3 && parts[0] == "adcsissuer":
+		return cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "AdcsIssuer", Name: parts[2]}, parts[1], true
+	case len(parts) >= 2 && parts[0] == "clusteradcsissuer":
+		// The empty namespace part is optional
+		name := strings.TrimPrefix(strings.Join(parts[1:], "."), ".")
+		return cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "ClusterAdcsIssuer", Name: name}, "", true
+	}
+	return cmmeta.ObjectReference{}, "", false
+}
+
+func csrHasCondition(csr *certificates.CertificateSigningRequest, conditionType certificates.RequestConditionType) bool {
+	for _, c := range csr.Status.Conditions {
+		if c.Type == conditionType && c.Status != core.ConditionFalse {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *CertificateSigningRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&certificates.CertificateSigningRequest{}).
+		Owns(&api.AdcsRequest{}).
+		Complete(r)
+}
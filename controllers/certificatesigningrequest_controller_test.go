@@ -0,0 +1,106 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseSignerName(t *testing.T) {
+	tests := []struct {
+		name       string
+		signerName string
+		issuerRef  cmmeta.ObjectReference
+		namespace  string
+		ok         bool
+	}{
+		{
+			name:       "issuer",
+			signerName: "adcs.certmanager.csf.nokia.com/adcsissuer.team-a.adcs",
+			issuerRef:  cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "AdcsIssuer", Name: "adcs"},
+			namespace:  "team-a",
+			ok:         true,
+		},
+		{
+			name:       "issuer name with dots",
+			signerName: "adcs.certmanager.csf.nokia.com/adcsissuer.team-a.adcs.example.com",
+			issuerRef:  cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "AdcsIssuer", Name: "adcs.example.com"},
+			namespace:  "team-a",
+			ok:         true,
+		},
+		{
+			name:       "cluster issuer",
+			signerName: "adcs.certmanager.csf.nokia.com/clusteradcsissuer.adcs",
+			issuerRef:  cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "ClusterAdcsIssuer", Name: "adcs"},
+			ok:         true,
+		},
+		{
+			name:       "cluster issuer with empty namespace",
+			signerName: "adcs.certmanager.csf.nokia.com/clusteradcsissuer..adcs",
+			issuerRef:  cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "ClusterAdcsIssuer", Name: "adcs"},
+			ok:         true,
+		},
+		{
+			name:       "cluster issuer name with dots",
+			signerName: "adcs.certmanager.csf.nokia.com/clusteradcsissuer.adcs.example.com",
+			issuerRef:  cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "ClusterAdcsIssuer", Name: "adcs.example.com"},
+			ok:         true,
+		},
+		{name: "issuer without name", signerName: "adcs.certmanager.csf.nokia.com/adcsissuer.team-a"},
+		{name: "unknown kind", signerName: "adcs.certmanager.csf.nokia.com/issuer.team-a.adcs"},
+		{name: "other signer", signerName: "kubernetes.io/kube-apiserver-client"},
+		{name: "other group", signerName: "example.com/adcsissuer.team-a.adcs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuerRef, namespace, ok := parseSignerName(tt.signerName)
+			if ok != tt.ok {
+				t.Fatalf("expected ok %t, got %t", tt.ok, ok)
+			}
+			if issuerRef != tt.issuerRef || namespace != tt.namespace {
+				t.Errorf("expected %v in %q, got %v in %q", tt.issuerRef, tt.namespace, issuerRef, namespace)
+			}
+		})
+	}
+}
+
+func TestCSRDuration(t *testing.T) {
+	tests := []struct {
+		name              string
+		expirationSeconds int64
+		annotation        string
+		want              *metav1.Duration
+	}{
+		{"not requested", 0, "", nil},
+		{"expiration seconds", 3600, "", &metav1.Duration{Duration: time.Hour}},
+		{"annotation", 0, "48h", &metav1.Duration{Duration: 48 * time.Hour}},
+		{"expiration seconds take precedence", 3600, "48h", &metav1.Duration{Duration: time.Hour}},
+		{"invalid annotation", 0, "2 days", nil},
+		{"negative annotation", 0, "-1h", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := csrDuration(tt.expirationSeconds, tt.annotation)
+			if (got == nil) != (tt.want == nil) || got != nil && got.Duration != tt.want.Duration {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -28,7 +29,8 @@ import (
 // ClusterAdcsIssuerReconciler reconciles a ClusterAdcsIssuer object
 type ClusterAdcsIssuerReconciler struct {
 	client.Client
-	Log logr.Logger
+	Log      logr.Logger
+	Recorder record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=adcs.certmanager.csf.nokia.com,resources=clusteradcsissuers,verbs=get;list;watch;create;update;patch;delete
@@ -50,7 +52,8 @@ func (r *ClusterAdcsIssuerReconciler) Reconcile(ctx context.Context, req ctrl.Re
 	}
 	log.Info("Registered cluster issuer")
 
-	return ctrl.Result{}, nil
+	return updateSuspension(ctx, r.Client, r.Recorder, issuer, &issuer.Status.Conditions,
+		issuer.Spec.Suspend, issuer.Spec.MaintenanceWindows)
 }
 
 func (r *ClusterAdcsIssuerReconciler) SetupWithManager(mgr ctrl.Manager) error {
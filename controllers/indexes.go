@@ -0,0 +1,197 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	// AdcsRequests by the issuer they refer to ('<kind>/<name>')
+	issuerRefIndex = ".spec.issuerRef"
+	// Issuers by the name of their credentials Secret
+	credentialsRefIndex = ".spec.credentialsRef.name"
+	// AdcsRequests by their state
+	stateIndex = ".status.state"
+	// AdcsRequests by the name of the Secret they write the certificate to
+	secretNameIndex = ".spec.secretName"
+	// AdcsRequests by the name of the Secret the certificate signed offline is uploaded with
+	certificateSecretIndex = ".metadata.annotations.issued-certificate-secret"
+)
+
+// Register indexes used to find AdcsRequests affected by changes of issuers and their Secrets.
+func setupIndexes(ctx context.Context, mgr ctrl.Manager) error {
+	indexer := mgr.GetFieldIndexer()
+	if err := indexer.IndexField(ctx, &api.AdcsRequest{}, issuerRefIndex, func(o client.Object) []string {
+		ar := o.(*api.AdcsRequest)
+		return []string{issuerKey(ar.Spec.IssuerRef.Kind, ar.Spec.IssuerRef.Name)}
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &api.AdcsRequest{}, stateIndex, func(o client.Object) []string {
+		return []string{string(o.(*api.AdcsRequest).Status.State)}
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &api.AdcsRequest{}, secretNameIndex, func(o client.Object) []string {
+		return []string{o.(*api.AdcsRequest).Spec.SecretName}
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &api.AdcsRequest{}, certificateSecretIndex, func(o client.Object) []string {
+		if name, ok := o.GetAnnotations()[api.IssuedCertificateSecretAnnotation]; ok {
+			return []string{name}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := indexer.IndexField(ctx, &api.AdcsIssuer{}, credentialsRefIndex, func(o client.Object) []string {
+		return []string{o.(*api.AdcsIssuer).Spec.CredentialsRef.Name}
+	}); err != nil {
+		return err
+	}
+	return indexer.IndexField(ctx, &api.ClusterAdcsIssuer{}, credentialsRefIndex, func(o client.Object) []string {
+		return []string{o.(*api.ClusterAdcsIssuer).Spec.CredentialsRef.Name}
+	})
+}
+
+func issuerKey(kind string, name string) string {
+	return strings.ToLower(kind) + "/" + name
+}
+
+// Issuer updates that may let the waiting AdcsRequests proceed: spec changes (including
+// resuming) and ADCS becoming available again. Other status updates, e.g. the circuit breaker
+// opening or the Suspended condition, don't re-queue the requests.
+var issuerChanged = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld.GetGeneration() != e.ObjectNew.GetGeneration() {
+			return true
+		}
+		return !issuerAvailable(e.ObjectOld) && issuerAvailable(e.ObjectNew)
+	},
+}
+
+func issuerAvailable(o client.Object) bool {
+	var conditions []api.IssuerCondition
+	switch issuer := o.(type) {
+	case *api.AdcsIssuer:
+		conditions = issuer.Status.Conditions
+	case *api.ClusterAdcsIssuer:
+		conditions = issuer.Status.Conditions
+	}
+	for _, c := range conditions {
+		if c.Type == api.IssuerConditionAvailable {
+			return c.Status == cmmeta.ConditionTrue
+		}
+	}
+	return false
+}
+
+// Find AdcsRequests waiting for the AdcsIssuer
+func (r *AdcsRequestReconciler) requestsForAdcsIssuer(o client.Object) []reconcile.Request {
+	return r.waitingRequests(issuerKey("AdcsIssuer", o.GetName()), client.InNamespace(o.GetNamespace()))
+}
+
+// Find AdcsRequests waiting for the ClusterAdcsIssuer
+func (r *AdcsRequestReconciler) requestsForClusterAdcsIssuer(o client.Object) []reconcile.Request {
+	return r.waitingRequests(issuerKey("ClusterAdcsIssuer", o.GetName()))
+}
+
+// Find AdcsRequests waiting for issuers using the Secret, issued AdcsRequests
+// writing their certificates to it and pending AdcsRequests uploaded with it.
+func (r *AdcsRequestReconciler) requestsForSecret(o client.Object) []reconcile.Request {
+	ctx := context.Background()
+	var requests []reconcile.Request
+
+	outputs := new(api.AdcsRequestList)
+	if err := r.Client.List(ctx, outputs, client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{secretNameIndex: o.GetName()}); err != nil {
+		r.Log.Error(err, "Cannot list AdcsRequests")
+	}
+	for _, ar := range outputs.Items {
+		if ar.Status.State == api.Ready {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: ar.Namespace, Name: ar.Name},
+			})
+		}
+	}
+
+	uploads := new(api.AdcsRequestList)
+	if err := r.Client.List(ctx, uploads, client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{certificateSecretIndex: o.GetName()}); err != nil {
+		r.Log.Error(err, "Cannot list AdcsRequests")
+	}
+	for _, ar := range uploads.Items {
+		if ar.Status.State == api.Pending {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: ar.Namespace, Name: ar.Name},
+			})
+		}
+	}
+
+	issuers := new(api.AdcsIssuerList)
+	if err := r.Client.List(ctx, issuers, client.InNamespace(o.GetNamespace()),
+		client.MatchingFields{credentialsRefIndex: o.GetName()}); err != nil {
+		r.Log.Error(err, "Cannot list AdcsIssuers")
+	}
+	for i := range issuers.Items {
+		requests = append(requests, r.requestsForAdcsIssuer(&issuers.Items[i])...)
+	}
+
+	if o.GetNamespace() != r.IssuerFactory.ClusterResourceNamespace {
+		return requests
+	}
+	clusterIssuers := new(api.ClusterAdcsIssuerList)
+	if err := r.Client.List(ctx, clusterIssuers, client.MatchingFields{credentialsRefIndex: o.GetName()}); err != nil {
+		r.Log.Error(err, "Cannot list ClusterAdcsIssuers")
+	}
+	for i := range clusterIssuers.Items {
+		requests = append(requests, r.requestsForClusterAdcsIssuer(&clusterIssuers.Items[i])...)
+	}
+	return requests
+}
+
+// Requests in final states don't need the issuer anymore
+func (r *AdcsRequestReconciler) waitingRequests(issuer string, opts ...client.ListOption) []reconcile.Request {
+	list := new(api.AdcsRequestList)
+	opts = append(opts, client.MatchingFields{issuerRefIndex: issuer})
+	if err := r.Client.List(context.Background(), list, opts...); err != nil {
+		r.Log.Error(err, "Cannot list AdcsRequests", "issuer", issuer)
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, ar := range list.Items {
+		if ar.Status.State == api.Ready || ar.Status.State == api.Rejected || ar.Status.State == api.Errored {
+			continue
+		}
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: ar.Namespace, Name: ar.Name},
+		})
+	}
+	return requests
+}
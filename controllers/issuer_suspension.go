@@ -0,0 +1,55 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+// Reflect suspension of the issuer in its 'Suspended' condition and announce the changes with an event.
+// The issuer is reconciled again when the maintenance window starts or ends.
+func updateSuspension(ctx context.Context, c client.Client, recorder record.EventRecorder, issuer client.Object,
+	conditions *[]api.IssuerCondition, suspend bool, windows []api.MaintenanceWindow) (ctrl.Result, error) {
+	now := time.Now()
+	s := issuers.GetSuspension(suspend, windows, now)
+	condition := s.Condition()
+	known := false
+	for _, existing := range *conditions {
+		known = known || existing.Type == api.IssuerConditionSuspended
+	}
+	if issuers.SetIssuerCondition(conditions, condition) {
+		if err := c.Status().Update(ctx, issuer); err != nil {
+			return ctrl.Result{}, err
+		}
+		// Issuers that were never suspended don't need to announce it
+		if known || s.Suspended {
+			recorder.Event(issuer, core.EventTypeNormal, condition.Reason, condition.Message)
+		}
+	}
+	if d := s.ChangesIn(now); d > 0 {
+		return ctrl.Result{RequeueAfter: d}, nil
+	}
+	return ctrl.Result{}, nil
+}
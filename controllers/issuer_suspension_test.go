@@ -0,0 +1,148 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+func TestUpdateSuspension(t *testing.T) {
+	now := time.Now()
+	window := func(start, end time.Duration) api.MaintenanceWindow {
+		return api.MaintenanceWindow{Start: metav1.NewTime(now.Add(start)), End: metav1.NewTime(now.Add(end))}
+	}
+	suspended := issuers.GetSuspension(true, nil, now).Condition()
+	resumed := issuers.GetSuspension(false, nil, now).Condition()
+
+	tests := []struct {
+		name       string
+		conditions []api.IssuerCondition
+		suspend    bool
+		windows    []api.MaintenanceWindow
+		reason     string
+		event      string
+		requeue    time.Duration
+	}{
+		{
+			name:   "never suspended",
+			reason: "Resumed",
+		},
+		{
+			name:    "window started",
+			windows: []api.MaintenanceWindow{window(0, time.Hour)},
+			reason:  "MaintenanceWindow",
+			event:   "MaintenanceWindow",
+			requeue: time.Hour,
+		},
+		{
+			name:    "adjoining windows",
+			windows: []api.MaintenanceWindow{window(-time.Hour, time.Hour), window(time.Hour, 2*time.Hour)},
+			reason:  "MaintenanceWindow",
+			event:   "MaintenanceWindow",
+			requeue: 2 * time.Hour,
+		},
+		{
+			name:    "next window",
+			windows: []api.MaintenanceWindow{window(-2*time.Hour, -time.Hour), window(time.Hour, 2*time.Hour)},
+			reason:  "Resumed",
+			requeue: time.Hour,
+		},
+		{
+			name:       "resumed",
+			conditions: []api.IssuerCondition{suspended},
+			windows:    []api.MaintenanceWindow{window(-2*time.Hour, -time.Hour)},
+			reason:     "Resumed",
+			event:      "Resumed",
+		},
+		{
+			name:       "manual suspend and window",
+			conditions: []api.IssuerCondition{resumed},
+			suspend:    true,
+			windows:    []api.MaintenanceWindow{window(-time.Hour, time.Hour)},
+			reason:     "Suspended",
+			event:      "Suspended",
+		},
+		{
+			name:       "unchanged",
+			conditions: []api.IssuerCondition{suspended},
+			suspend:    true,
+			reason:     "Suspended",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := &api.AdcsIssuer{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "adcs"},
+				Status:     api.AdcsIssuerStatus{Conditions: tt.conditions},
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(issuer).Build()
+			recorder := record.NewFakeRecorder(10)
+
+			result, err := updateSuspension(context.Background(), c, recorder, issuer, &issuer.Status.Conditions, tt.suspend, tt.windows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The time passed since now is subtracted
+			if result.RequeueAfter > tt.requeue || result.RequeueAfter < tt.requeue-time.Minute {
+				t.Errorf("expected re-queue after %v, got %v", tt.requeue, result.RequeueAfter)
+			}
+
+			updated := new(api.AdcsIssuer)
+			if err := c.Get(context.Background(), client.ObjectKeyFromObject(issuer), updated); err != nil {
+				t.Fatal(err)
+			}
+			var condition *api.IssuerCondition
+			for i := range updated.Status.Conditions {
+				if updated.Status.Conditions[i].Type == api.IssuerConditionSuspended {
+					condition = &updated.Status.Conditions[i]
+				}
+			}
+			if condition == nil || condition.Reason != tt.reason {
+				t.Fatalf("expected Suspended condition with reason %s, got %+v", tt.reason, condition)
+			}
+			status := cmmeta.ConditionTrue
+			if tt.reason == "Resumed" {
+				status = cmmeta.ConditionFalse
+			}
+			if condition.Status != status {
+				t.Errorf("expected condition %s, got %s", status, condition.Status)
+			}
+
+			select {
+			case e := <-recorder.Events:
+				if tt.event == "" || !strings.Contains(e, " "+tt.event+" ") {
+					t.Errorf("expected event %q, got %q", tt.event, e)
+				}
+			default:
+				if tt.event != "" {
+					t.Errorf("expected event %s", tt.event)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+// PendingRequestPoller periodically finds pending AdcsRequests whose status
+// should be checked in the ADCS and passes them to the AdcsRequest controller.
+// Requests are processed in batches per issuer, limited to ChecksPerSecond
+// so a large number of pending requests doesn't overload the ADCS.
+type PendingRequestPoller struct {
+	client.Client
+	Log           logr.Logger
+	IssuerFactory issuers.IssuerFactory
+	// How often to look for requests to check
+	Interval time.Duration
+	// Status checks per second per issuer
+	ChecksPerSecond float64
+
+	once     sync.Once
+	events   chan event.GenericEvent
+	limiters map[string]*rate.Limiter
+}
+
+func (p *PendingRequestPoller) init() {
+	p.once.Do(func() {
+		p.events = make(chan event.GenericEvent)
+		p.limiters = map[string]*rate.Limiter{}
+	})
+}
+
+// Source of the requests to be checked for the AdcsRequest controller
+func (p *PendingRequestPoller) Source() source.Source {
+	p.init()
+	return &source.Channel{Source: p.events}
+}
+
+// Start implements manager.Runnable
+func (p *PendingRequestPoller) Start(ctx context.Context) error {
+	p.init()
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			p.poll(ctx)
+		}
+	}
+}
+
+func (p *PendingRequestPoller) poll(ctx context.Context) {
+	list := new(api.AdcsRequestList)
+	if err := p.Client.List(ctx, list, client.MatchingFields{stateIndex: string(api.Pending)}); err != nil {
+		p.Log.Error(err, "Cannot list pending AdcsRequests")
+		return
+	}
+
+	// Group the requests by issuer
+	batches := map[string][]*api.AdcsRequest{}
+	for i := range list.Items {
+		ar := &list.Items[i]
+		if !ar.DeletionTimestamp.IsZero() {
+			continue
+		}
+		key := issuerKey(ar.Spec.IssuerRef.Kind, ar.Spec.IssuerRef.Name)
+		if ar.Spec.IssuerRef.Kind != "ClusterAdcsIssuer" {
+			key = ar.Namespace + "/" + key
+		}
+		batches[key] = append(batches[key], ar)
+	}
+
+	now := time.Now()
+	for key, batch := range batches {
+		log := p.Log.WithValues("issuer", key)
+		issuer, err := p.IssuerFactory.GetIssuer(ctx, batch[0].Spec.IssuerRef, batch[0].Namespace)
+		if err != nil {
+			// The requests are re-queued when the issuer is fixed
+			log.Error(err, "Couldn't get issuer")
+			continue
+		}
+		if issuer.Suspension(now).Suspended {
+			// The requests are re-queued when the issuer is resumed
+			log.V(1).Info("Issuer suspended", "pending", len(batch))
+			continue
+		}
+
+		var due []*api.AdcsRequest
+		for _, ar := range batch {
+			if issuer.StatusCheckDue(ar, now) {
+				due = append(due, ar)
+			}
+		}
+		// The longest waiting first
+		sort.Slice(due, func(i, j int) bool {
+			return issuer.NextStatusCheck(due[i]).Before(issuer.NextStatusCheck(due[j]))
+		})
+
+		limiter := p.limiter(key)
+		for n, ar := range due {
+			if !limiter.Allow() {
+				log.Info("Status check rate limit reached", "postponed", len(due)-n)
+				break
+			}
+			select {
+			case p.events <- event.GenericEvent{Object: ar}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		log.V(1).Info("Pending requests checked", "pending", len(batch), "due", len(due))
+	}
+}
+
+func (p *PendingRequestPoller) limiter(key string) *rate.Limiter {
+	limiter, ok := p.limiters[key]
+	if !ok {
+		// Allow a full interval worth of checks at once
+		burst := int(p.ChecksPerSecond * p.Interval.Seconds())
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(p.ChecksPerSecond), burst)
+		p.limiters[key] = limiter
+	}
+	return limiter
+}
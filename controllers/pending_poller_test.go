@@ -0,0 +1,117 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+func TestPendingRequestPollerRateLimit(t *testing.T) {
+	now := time.Now()
+	ca, _ := testutil.NewCA(t, "ADCS CA", nil, nil)
+	objects := []client.Object{&core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "adcs-credentials"},
+		Data:       map[string][]byte{"username": []byte("user"), "password": []byte("password")},
+	}}
+	for _, name := range []string{"a", "b"} {
+		objects = append(objects, &api.AdcsIssuer{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec: api.AdcsIssuerSpec{
+				URL:            "https://adcs.example.com/certsrv",
+				CredentialsRef: api.LocalObjectReference{Name: "adcs-credentials"},
+				CABundle:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}),
+			},
+		})
+	}
+	request := func(issuer string, n int, lastCheck *metav1.Time) *api.AdcsRequest {
+		// The first ones wait the longest
+		submitted := metav1.NewTime(now.Add(-time.Duration(10-n) * time.Hour))
+		return &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: fmt.Sprintf("%s-%d", issuer, n)},
+			Spec: api.AdcsRequestSpec{IssuerRef: cmmeta.ObjectReference{
+				Group: "adcs.certmanager.csf.nokia.com", Kind: "AdcsIssuer", Name: issuer,
+			}},
+			Status: api.AdcsRequestStatus{State: api.Pending, SubmittedTime: &submitted, LastCheckTime: lastCheck},
+		}
+	}
+	for n := 0; n < 5; n++ {
+		objects = append(objects, request("a", n, nil))
+	}
+	for n := 0; n < 2; n++ {
+		objects = append(objects, request("b", n, nil))
+	}
+	checked := metav1.NewTime(now)
+	objects = append(objects, request("b", 2, &checked))
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).Build()
+
+	p := &PendingRequestPoller{
+		Client:        c,
+		Log:           logr.Discard(),
+		IssuerFactory: issuers.IssuerFactory{Client: c, Log: logr.Discard()},
+		Interval:      3 * time.Second,
+		// Burst of 3 checks per issuer
+		ChecksPerSecond: 1,
+	}
+	p.init()
+	poll := func() map[string][]string {
+		done := make(chan struct{})
+		go func() {
+			p.poll(context.Background())
+			close(done)
+		}()
+		checks := map[string][]string{}
+		for {
+			select {
+			case e := <-p.events:
+				ar := e.Object.(*api.AdcsRequest)
+				checks[ar.Spec.IssuerRef.Name] = append(checks[ar.Spec.IssuerRef.Name], ar.Name)
+			case <-done:
+				return checks
+			}
+		}
+	}
+
+	checks := poll()
+	sort.Strings(checks["a"])
+	if fmt.Sprint(checks["a"]) != "[a-0 a-1 a-2]" {
+		t.Errorf("expected the 3 longest waiting requests of issuer a checked, got %v", checks["a"])
+	}
+	sort.Strings(checks["b"])
+	if fmt.Sprint(checks["b"]) != "[b-0 b-1]" {
+		t.Errorf("expected the due requests of issuer b checked, got %v", checks["b"])
+	}
+
+	// The limit is used up
+	if checks := poll(); len(checks["a"]) > 1 {
+		t.Errorf("expected checks of issuer a postponed, got %v", checks["a"])
+	}
+}
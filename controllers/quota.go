@@ -0,0 +1,308 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	apimacherrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	quotaPerHour   = "per_hour"
+	quotaPerDay    = "per_day"
+	quotaMaxActive = "max_active"
+
+	// How often to re-check requests held because of the MaxActive quota.
+	// Active certificates are released by expiration or rejection which
+	// doesn't trigger the CertificateRequest reconciliation.
+	quotaRetryInterval = 5 * time.Minute
+)
+
+var (
+	quotaUsage = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "adcs_issuer_quota_usage",
+		Help: "Certificates counted against the namespace quota of a ClusterAdcsIssuer.",
+	}, []string{"issuer", "namespace", "quota"})
+	quotaLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "adcs_issuer_quota_limit",
+		Help: "Namespace quota of a ClusterAdcsIssuer.",
+	}, []string{"issuer", "namespace", "quota"})
+	quotaExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "adcs_issuer_quota_exceeded_total",
+		Help: "Requests held pending because of an exceeded namespace quota.",
+	}, []string{"issuer", "namespace", "quota"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(quotaUsage, quotaLimit, quotaExceeded)
+}
+
+// Check the namespace quota of the ClusterAdcsIssuer for a new AdcsRequest.
+// Returns a non-empty message if the request must be held and when to check again.
+// Issuers that can't be found are left to the AdcsRequest controller to report.
+// This is only a pre-check from the cache, requests are let through the quota by
+// the AdcsRequest controller.
+func (r *CertificateRequestReconciler) checkQuota(ctx context.Context, cr *cmapi.CertificateRequest) (string, time.Duration, error) {
+	return checkQuota(ctx, r.Client, r.Clock.Now(), cr.Spec.IssuerRef, cr.Namespace, cr.Name)
+}
+
+// Check the quota for the request with the name in the namespace without recording it.
+func checkQuota(ctx context.Context, c client.Reader, now time.Time, issuerRef cmmeta.ObjectReference, namespace, name string) (string, time.Duration, error) {
+	if strings.ToLower(issuerRef.Kind) != "clusteradcsissuer" {
+		return "", 0, nil
+	}
+	issuer := new(api.ClusterAdcsIssuer)
+	if err := c.Get(ctx, client.ObjectKey{Name: issuerRef.Name}, issuer); err != nil {
+		if apimacherrors.IsNotFound(err) {
+			return "", 0, nil
+		}
+		return "", 0, err
+	}
+	if issuer.Spec.Quota == nil {
+		return "", 0, nil
+	}
+	list := new(api.AdcsRequestList)
+	if err := c.List(ctx, list, client.InNamespace(namespace),
+		client.MatchingFields{issuerRefIndex: issuerKey("ClusterAdcsIssuer", issuer.Name)}); err != nil {
+		return "", 0, err
+	}
+	message, retryAfter := evaluateQuota(issuer, namespace, list.Items, name, "", now)
+	return message, retryAfter, nil
+}
+
+// Let the AdcsRequest through the namespace quota of its ClusterAdcsIssuer. The
+// AdcsRequest controller checks every request before it's submitted, so AdcsRequests
+// not created for CertificateRequests are held too.
+//
+// Admissions are recorded in the issuer status. The issuer and the requests are
+// read through the reader bypassing the cache (if it's the API reader), so recent
+// admissions are never missed. Updates of the issuer status conflict if another
+// request was let through in the meantime (e.g. by another replica), admissions in
+// this process are additionally serialized per issuer and namespace.
+// Returns a non-empty message if the request must be held and when to check again.
+func admitQuota(ctx context.Context, c client.Client, reader client.Reader, now time.Time, ar *api.AdcsRequest) (string, time.Duration, error) {
+	if strings.ToLower(ar.Spec.IssuerRef.Kind) != "clusteradcsissuer" {
+		return "", 0, nil
+	}
+	unlock := lockQuota(ar.Spec.IssuerRef.Name, ar.Namespace)
+	defer unlock()
+
+	var message string
+	var retryAfter time.Duration
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		message, retryAfter = "", 0
+		issuer := new(api.ClusterAdcsIssuer)
+		if err := reader.Get(ctx, client.ObjectKey{Name: ar.Spec.IssuerRef.Name}, issuer); err != nil {
+			if apimacherrors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if issuer.Spec.Quota == nil {
+			return nil
+		}
+		for _, admission := range namespaceAdmissions(issuer, ar.Namespace) {
+			if admission.UID == ar.UID {
+				// Recorded before the request status could be updated
+				return nil
+			}
+		}
+		list := new(api.AdcsRequestList)
+		if err := reader.List(ctx, list, client.InNamespace(ar.Namespace)); err != nil {
+			return err
+		}
+		requests := make([]api.AdcsRequest, 0, len(list.Items))
+		for _, item := range list.Items {
+			// There is no field index on uncached lists
+			if issuerKey(item.Spec.IssuerRef.Kind, item.Spec.IssuerRef.Name) == issuerKey("ClusterAdcsIssuer", issuer.Name) {
+				requests = append(requests, item)
+			}
+		}
+		message, retryAfter = evaluateQuota(issuer, ar.Namespace, requests, ar.Name, ar.UID, now)
+		if message != "" {
+			return nil
+		}
+		recordAdmission(issuer, ar, now)
+		return c.Status().Update(ctx, issuer)
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return message, retryAfter, nil
+}
+
+var quotaLocks sync.Map
+
+// Lock the namespace quota of the issuer. Returns the unlock function.
+func lockQuota(issuer, namespace string) func() {
+	l, _ := quotaLocks.LoadOrStore(issuer+"/"+namespace, new(sync.Mutex))
+	mu := l.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Get the requests of the namespace let through the issuer quota.
+func namespaceAdmissions(issuer *api.ClusterAdcsIssuer, namespace string) []api.QuotaAdmission {
+	for _, usage := range issuer.Status.QuotaUsage {
+		if usage.Namespace == namespace {
+			return usage.Admissions
+		}
+	}
+	return nil
+}
+
+// Add the request to the issuer quota usage and drop admissions that are out of
+// all the windows.
+func recordAdmission(issuer *api.ClusterAdcsIssuer, ar *api.AdcsRequest, now time.Time) {
+	window := time.Hour
+	if issuer.Spec.Quota.CertificatesPerDay > 0 {
+		window = 24 * time.Hour
+	}
+	admission := api.QuotaAdmission{Request: ar.Name, UID: ar.UID, Time: metav1.NewTime(now)}
+	found := false
+	var usage []api.NamespaceQuotaUsage
+	for _, u := range issuer.Status.QuotaUsage {
+		if u.Namespace == ar.Namespace {
+			u.Admissions = append(u.Admissions, admission)
+			found = true
+		}
+		var admissions []api.QuotaAdmission
+		for _, a := range u.Admissions {
+			if now.Sub(a.Time.Time) < window {
+				admissions = append(admissions, a)
+			}
+		}
+		if len(admissions) > 0 {
+			usage = append(usage, api.NamespaceQuotaUsage{Namespace: u.Namespace, Admissions: admissions})
+		}
+	}
+	if !found {
+		usage = append(usage, api.NamespaceQuotaUsage{Namespace: ar.Namespace, Admissions: []api.QuotaAdmission{admission}})
+	}
+	issuer.Status.QuotaUsage = usage
+}
+
+// Check the quota for the request with the name (and the UID if it exists) in the
+// namespace. The hourly and daily quotas are counted from the admissions recorded
+// in the issuer status, the active certificates from the requests.
+func evaluateQuota(issuer *api.ClusterAdcsIssuer, namespace string, requests []api.AdcsRequest, name string, uid types.UID, now time.Time) (string, time.Duration) {
+	quota := issuer.Spec.Quota
+	admitted := map[types.UID]bool{}
+	var lastHour, lastDay []time.Time
+	for _, admission := range namespaceAdmissions(issuer, namespace) {
+		if uid != "" && admission.UID == uid {
+			continue
+		}
+		admitted[admission.UID] = true
+		if now.Sub(admission.Time.Time) < time.Hour {
+			lastHour = append(lastHour, admission.Time.Time)
+		}
+		if now.Sub(admission.Time.Time) < 24*time.Hour {
+			lastDay = append(lastDay, admission.Time.Time)
+		}
+	}
+	active := 0
+	for _, ar := range requests {
+		if ar.Name == name {
+			// Replaced by the new request or the request itself
+			continue
+		}
+		if ar.Status.State == api.Unknown && ar.Status.AdmittedTime == nil && !admitted[ar.UID] {
+			// Held by the quota
+			continue
+		}
+		switch ar.Status.State {
+		case api.Unknown, api.Pending:
+			active++
+		case api.Ready:
+			// Certificates issued before the expiration was recorded are not counted
+			if ar.Status.NotAfter != nil && now.Before(ar.Status.NotAfter.Time) {
+				active++
+			}
+		}
+	}
+
+	labels := func(q string) prometheus.Labels {
+		return prometheus.Labels{"issuer": issuer.Name, "namespace": namespace, "quota": q}
+	}
+	quotaUsage.With(labels(quotaPerHour)).Set(float64(len(lastHour)))
+	quotaUsage.With(labels(quotaPerDay)).Set(float64(len(lastDay)))
+	quotaUsage.With(labels(quotaMaxActive)).Set(float64(active))
+	for q, limit := range map[string]int32{
+		quotaPerHour:   quota.CertificatesPerHour,
+		quotaPerDay:    quota.CertificatesPerDay,
+		quotaMaxActive: quota.MaxActive,
+	} {
+		if limit > 0 {
+			quotaLimit.With(labels(q)).Set(float64(limit))
+		} else {
+			quotaLimit.Delete(labels(q))
+		}
+	}
+
+	var messages []string
+	var retryAfter time.Duration
+	hold := func(q string, after time.Duration, format string, args ...interface{}) {
+		quotaExceeded.With(labels(q)).Inc()
+		messages = append(messages, fmt.Sprintf(format, args...))
+		if after > retryAfter {
+			retryAfter = after
+		}
+	}
+	if limit := int(quota.CertificatesPerHour); limit > 0 && len(lastHour) >= limit {
+		hold(quotaPerHour, windowRelease(lastHour, limit, time.Hour, now),
+			"%d certificates per hour", limit)
+	}
+	if limit := int(quota.CertificatesPerDay); limit > 0 && len(lastDay) >= limit {
+		hold(quotaPerDay, windowRelease(lastDay, limit, 24*time.Hour, now),
+			"%d certificates per day", limit)
+	}
+	if limit := int(quota.MaxActive); limit > 0 && active >= limit {
+		hold(quotaMaxActive, quotaRetryInterval, "%d active certificates", limit)
+	}
+	if len(messages) == 0 {
+		return "", 0
+	}
+	return fmt.Sprintf("Quota of ClusterAdcsIssuer %s for namespace %s exceeded: %s. Waiting.",
+		issuer.Name, namespace, strings.Join(messages, ", ")), retryAfter
+}
+
+// Get the time after which a request fits into the rolling window again
+// i.e. when enough of the counted requests leave the window.
+func windowRelease(created []time.Time, limit int, window time.Duration, now time.Time) time.Duration {
+	sort.Slice(created, func(i, j int) bool { return created[i].Before(created[j]) })
+	after := created[len(created)-limit].Add(window).Sub(now)
+	if after < time.Second {
+		after = time.Second
+	}
+	return after
+}
@@ -0,0 +1,255 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func TestCheckQuota(t *testing.T) {
+	now := time.Now()
+	issuerRef := cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "ClusterAdcsIssuer", Name: "shared"}
+	request := func(name string, status api.AdcsRequestStatus) *api.AdcsRequest {
+		return &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name, UID: types.UID(name)},
+			Spec:       api.AdcsRequestSpec{IssuerRef: issuerRef},
+			Status:     status,
+		}
+	}
+	admission := func(name string, ago time.Duration) api.QuotaAdmission {
+		return api.QuotaAdmission{Request: name, UID: types.UID(name), Time: metav1.NewTime(now.Add(-ago))}
+	}
+	admitted := metav1.NewTime(now)
+
+	tests := []struct {
+		name       string
+		quota      api.QuotaPolicy
+		admissions []api.QuotaAdmission
+		requests   []client.Object
+		held       bool
+	}{
+		{
+			name:       "within quota",
+			quota:      api.QuotaPolicy{CertificatesPerHour: 2},
+			admissions: []api.QuotaAdmission{admission("a", 10*time.Minute)},
+		},
+		{
+			name:       "hourly quota exceeded",
+			quota:      api.QuotaPolicy{CertificatesPerHour: 2},
+			admissions: []api.QuotaAdmission{admission("a", 10*time.Minute), admission("b", 20*time.Minute)},
+			held:       true,
+		},
+		{
+			name:       "admissions out of the window not counted",
+			quota:      api.QuotaPolicy{CertificatesPerHour: 2},
+			admissions: []api.QuotaAdmission{admission("a", 10*time.Minute), admission("b", 2*time.Hour)},
+		},
+		{
+			name:       "daily quota exceeded",
+			quota:      api.QuotaPolicy{CertificatesPerDay: 2},
+			admissions: []api.QuotaAdmission{admission("a", 10*time.Minute), admission("b", 20*time.Hour)},
+			held:       true,
+		},
+		{
+			name:       "deleted requests counted",
+			quota:      api.QuotaPolicy{CertificatesPerHour: 2},
+			admissions: []api.QuotaAdmission{admission("a", 10*time.Minute), admission("b", 20*time.Minute)},
+			requests:   []client.Object{},
+			held:       true,
+		},
+		{
+			name:  "active requests counted",
+			quota: api.QuotaPolicy{MaxActive: 2},
+			requests: []client.Object{
+				request("a", api.AdcsRequestStatus{State: api.Pending}),
+				request("b", api.AdcsRequestStatus{AdmittedTime: &admitted}),
+			},
+			held: true,
+		},
+		{
+			name:       "requests in the ledger counted as active",
+			quota:      api.QuotaPolicy{MaxActive: 2},
+			admissions: []api.QuotaAdmission{admission("b", time.Minute)},
+			requests: []client.Object{
+				request("a", api.AdcsRequestStatus{State: api.Pending}),
+				request("b", api.AdcsRequestStatus{}),
+			},
+			held: true,
+		},
+		{
+			name:  "held requests not counted as active",
+			quota: api.QuotaPolicy{MaxActive: 2},
+			requests: []client.Object{
+				request("a", api.AdcsRequestStatus{State: api.Pending}),
+				request("b", api.AdcsRequestStatus{}),
+			},
+		},
+		{
+			name:  "request itself not counted as active",
+			quota: api.QuotaPolicy{MaxActive: 2},
+			requests: []client.Object{
+				request("a", api.AdcsRequestStatus{State: api.Pending}),
+				request("new", api.AdcsRequestStatus{AdmittedTime: &admitted}),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quota := tt.quota
+			issuer := &api.ClusterAdcsIssuer{
+				ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+				Spec:       api.ClusterAdcsIssuerSpec{Quota: &quota},
+				Status: api.ClusterAdcsIssuerStatus{QuotaUsage: []api.NamespaceQuotaUsage{
+					{Namespace: "team-a", Admissions: tt.admissions},
+				}},
+			}
+			c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(append(tt.requests, issuer)...).Build()
+
+			message, retryAfter, err := checkQuota(context.Background(), c, now, issuerRef, "team-a", "new")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if (message != "") != tt.held {
+				t.Fatalf("expected held %t, got message %q", tt.held, message)
+			}
+			if tt.held && (retryAfter <= 0 || retryAfter > 24*time.Hour) {
+				t.Errorf("unexpected retry after %v", retryAfter)
+			}
+		})
+	}
+}
+
+func TestAdmitQuota(t *testing.T) {
+	now := time.Now()
+	issuerRef := cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "ClusterAdcsIssuer", Name: "shared"}
+	request := func(name string) *api.AdcsRequest {
+		return &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name, UID: types.UID(name)},
+			Spec:       api.AdcsRequestSpec{IssuerRef: issuerRef},
+		}
+	}
+	issuer := &api.ClusterAdcsIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+		Spec:       api.ClusterAdcsIssuerSpec{Quota: &api.QuotaPolicy{CertificatesPerHour: 2}},
+		Status: api.ClusterAdcsIssuerStatus{QuotaUsage: []api.NamespaceQuotaUsage{
+			{Namespace: "team-b", Admissions: []api.QuotaAdmission{
+				{Request: "old", UID: "old", Time: metav1.NewTime(now.Add(-2 * time.Hour))},
+			}},
+		}},
+	}
+	a, b, c := request("a"), request("b"), request("c")
+	cl := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(issuer, a, b, c).Build()
+	ctx := context.Background()
+
+	for _, ar := range []*api.AdcsRequest{a, b} {
+		if message, _, err := admitQuota(ctx, cl, cl, now, ar); err != nil || message != "" {
+			t.Fatalf("expected %s let through, got %q, %v", ar.Name, message, err)
+		}
+	}
+	// Let through already, its status wasn't updated
+	if message, _, err := admitQuota(ctx, cl, cl, now, a); err != nil || message != "" {
+		t.Fatalf("expected recorded request let through again, got %q, %v", message, err)
+	}
+	// Deleting the requests doesn't reset the quota
+	for _, ar := range []*api.AdcsRequest{a, b} {
+		if err := cl.Delete(ctx, ar); err != nil {
+			t.Fatal(err)
+		}
+	}
+	message, retryAfter, err := admitQuota(ctx, cl, cl, now, c)
+	if err != nil || message == "" {
+		t.Fatalf("expected request held, got %q, %v", message, err)
+	}
+	// Recorded times are truncated to seconds
+	if retryAfter <= time.Hour-time.Second || retryAfter > time.Hour {
+		t.Errorf("expected retry after an hour, got %v", retryAfter)
+	}
+
+	updated := new(api.ClusterAdcsIssuer)
+	if err := cl.Get(ctx, client.ObjectKey{Name: "shared"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Status.QuotaUsage) != 1 || updated.Status.QuotaUsage[0].Namespace != "team-a" ||
+		len(updated.Status.QuotaUsage[0].Admissions) != 2 {
+		t.Errorf("expected two admissions of team-a and old ones dropped, got %+v", updated.Status.QuotaUsage)
+	}
+}
+
+func TestAdmitQuotaConcurrent(t *testing.T) {
+	issuerRef := cmmeta.ObjectReference{Group: "adcs.certmanager.csf.nokia.com", Kind: "ClusterAdcsIssuer", Name: "shared"}
+	issuer := &api.ClusterAdcsIssuer{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared"},
+		Spec:       api.ClusterAdcsIssuerSpec{Quota: &api.QuotaPolicy{CertificatesPerHour: 3}},
+	}
+	objects := []client.Object{issuer}
+	var requests []*api.AdcsRequest
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("r%d", i)
+		ar := &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name, UID: types.UID(name)},
+			Spec:       api.AdcsRequestSpec{IssuerRef: issuerRef},
+		}
+		requests = append(requests, ar)
+		objects = append(objects, ar)
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(objects...).Build()
+
+	var admitted int32
+	var wg sync.WaitGroup
+	for _, ar := range requests {
+		wg.Add(1)
+		go func(ar *api.AdcsRequest) {
+			defer wg.Done()
+			message, _, err := admitQuota(context.Background(), c, c, time.Now(), ar)
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if message == "" {
+				atomic.AddInt32(&admitted, 1)
+			}
+		}(ar)
+	}
+	wg.Wait()
+	if admitted != 3 {
+		t.Errorf("expected 3 requests let through, got %d", admitted)
+	}
+}
+
+func TestCheckQuotaWithoutQuota(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).Build()
+	for _, issuerRef := range []cmmeta.ObjectReference{
+		{Kind: "AdcsIssuer", Name: "adcs"},
+		{Kind: "ClusterAdcsIssuer", Name: "missing"},
+	} {
+		message, _, err := checkQuota(context.Background(), c, time.Now(), issuerRef, "team-a", "new")
+		if err != nil || message != "" {
+			t.Errorf("expected request to %s let through, got %q, %v", issuerRef.Kind, message, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+/*
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch
+
+// Find the Secret of the Certificate renewed by the CertificateRequest. It's returned
+// only if the CertificateRequest is controlled by the Certificate (not just annotated with
+// its name) and its certificate was issued by the same issuer so that the renewal can be
+// signed with it. Empty name is returned for new certificates.
+func (r *CertificateRequestReconciler) renewalSecret(ctx context.Context, cr *cmapi.CertificateRequest) (string, error) {
+	owner := metav1.GetControllerOf(cr)
+	if owner == nil || owner.Kind != cmapi.CertificateKind {
+		return "", nil
+	}
+	crt := new(cmapi.Certificate)
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: cr.Namespace, Name: owner.Name}, crt); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	if crt.UID != owner.UID {
+		return "", nil
+	}
+	secret := new(core.Secret)
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: cr.Namespace, Name: crt.Spec.SecretName}, secret); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+	if len(secret.Data[core.TLSCertKey]) == 0 || len(secret.Data[core.TLSPrivateKeyKey]) == 0 {
+		return "", nil
+	}
+	// Set by cert-manager on the Secrets of Certificates
+	if secret.Annotations[cmapi.IssuerNameAnnotationKey] != cr.Spec.IssuerRef.Name ||
+		!strings.EqualFold(secret.Annotations[cmapi.IssuerKindAnnotationKey], cr.Spec.IssuerRef.Kind) ||
+		secret.Annotations[cmapi.IssuerGroupAnnotationKey] != cr.Spec.IssuerRef.Group {
+		return "", nil
+	}
+	return secret.Name, nil
+}
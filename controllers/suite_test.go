@@ -22,7 +22,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
 	adcsv1 "github.com/nokia/adcs-issuer/api/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -84,3 +86,18 @@ var _ = AfterSuite(func() {
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
 })
+
+// Scheme of the fake clients
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := adcsv1.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmapi.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
@@ -0,0 +1,317 @@
+// Package csi implements a CSI node driver delivering certificates issued by ADCS
+// directly into pods with inline ephemeral volumes:
+//
+//	volumes:
+//	- name: tls
+//	  csi:
+//	    driver: csi.adcs.certmanager.csf.nokia.com
+//	    readOnly: true
+//	    volumeAttributes:
+//	      csi.adcs.certmanager.csf.nokia.com/issuer-name: adcs-issuer
+//	      csi.adcs.certmanager.csf.nokia.com/dns-names: ${POD_NAME}.${POD_NAMESPACE}.svc
+//
+// The private key is generated by the driver on the node. The CSR is sent in
+// AdcsRequest created in the pod's namespace and processed by the AdcsRequest
+// controller. The certificate is renewed before it expires.
+package csi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	// DriverName is the name of the CSI driver used in pod volumes
+	DriverName    = "csi.adcs.certmanager.csf.nokia.com"
+	driverVersion = "v1.0.0"
+
+	renewCheckInterval = time.Minute
+	// Delay before failed renewal is re-tried
+	renewRetryInterval = 5 * time.Minute
+)
+
+var (
+	_ csi.IdentityServer = &Driver{}
+	_ csi.NodeServer     = &Driver{}
+)
+
+// Driver is the CSI node driver
+type Driver struct {
+	// Creates and reads AdcsRequests
+	Client client.Client
+	Log    logr.Logger
+
+	// Unix socket kubelet connects to e.g. 'unix:///csi/csi.sock'
+	Endpoint string
+	// Name of the node the driver runs on
+	NodeID string
+	// Directory with the volumes' state, keys and certificates
+	DataRoot string
+	// How long NodePublishVolume waits for the certificate. Kubelet re-tries
+	// the call if the certificate isn't issued in time.
+	Wait time.Duration
+
+	lock    sync.Mutex
+	volumes map[string]*volume
+}
+
+// Start serves the CSI Identity and Node services until the context is done
+func (d *Driver) Start(ctx context.Context) error {
+	if err := d.loadVolumes(); err != nil {
+		return err
+	}
+
+	server := grpc.NewServer()
+	csi.RegisterIdentityServer(server, d)
+	csi.RegisterNodeServer(server, d)
+
+	socket := strings.TrimPrefix(d.Endpoint, "unix://")
+	if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Cannot remove old socket %s: %s", socket, err.Error())
+	}
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		d.Log.Info("Starting CSI driver", "endpoint", d.Endpoint, "node", d.NodeID, "volumes", len(d.volumes))
+		errs <- server.Serve(listener)
+	}()
+	go d.renewCertificates(ctx)
+
+	select {
+	case <-ctx.Done():
+		server.GracefulStop()
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+// Load the state of volumes published before restart
+func (d *Driver) loadVolumes() error {
+	d.volumes = map[string]*volume{}
+	if err := os.MkdirAll(d.DataRoot, 0700); err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(d.DataRoot)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		v, err := loadVolume(filepath.Join(d.DataRoot, entry.Name()))
+		if err != nil {
+			d.Log.Error(err, "Cannot load volume", "volume", entry.Name())
+			continue
+		}
+		d.volumes[v.ID] = v
+	}
+	return nil
+}
+
+func (d *Driver) GetPluginInfo(ctx context.Context, request *csi.GetPluginInfoRequest) (*csi.GetPluginInfoResponse, error) {
+	return &csi.GetPluginInfoResponse{Name: DriverName, VendorVersion: driverVersion}, nil
+}
+
+// Capabilities aren't needed by node-only driver of ephemeral volumes
+func (d *Driver) GetPluginCapabilities(ctx context.Context, request *csi.GetPluginCapabilitiesRequest) (*csi.GetPluginCapabilitiesResponse, error) {
+	return &csi.GetPluginCapabilitiesResponse{}, nil
+}
+
+func (d *Driver) Probe(ctx context.Context, request *csi.ProbeRequest) (*csi.ProbeResponse, error) {
+	return &csi.ProbeResponse{}, nil
+}
+
+func (d *Driver) NodeGetCapabilities(ctx context.Context, request *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
+	return &csi.NodeGetCapabilitiesResponse{}, nil
+}
+
+func (d *Driver) NodeGetInfo(ctx context.Context, request *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
+	return &csi.NodeGetInfoResponse{NodeId: d.NodeID}, nil
+}
+
+// Staging, stats and expansion don't apply to ephemeral volumes
+
+func (d *Driver) NodeStageVolume(ctx context.Context, request *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "NodeStageVolume not supported")
+}
+
+func (d *Driver) NodeUnstageVolume(ctx context.Context, request *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "NodeUnstageVolume not supported")
+}
+
+func (d *Driver) NodeGetVolumeStats(ctx context.Context, request *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "NodeGetVolumeStats not supported")
+}
+
+func (d *Driver) NodeExpandVolume(ctx context.Context, request *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "NodeExpandVolume not supported")
+}
+
+// Issue the certificate (unless already done by previous call) and mount it into the pod
+func (d *Driver) NodePublishVolume(ctx context.Context, request *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	volumeID, targetPath := request.GetVolumeId(), request.GetTargetPath()
+	attributes := request.GetVolumeContext()
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+	if !validVolumeID(volumeID) || targetPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID and target path are required")
+	}
+	if err := validateAttributes(attributes); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	v, err := d.volume(volumeID, targetPath, attributes)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	v.lock.Lock()
+	defer v.lock.Unlock()
+	if !v.issued() {
+		ok, err := d.issue(ctx, v, d.Wait)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "Cannot issue certificate: %s", err.Error())
+		}
+		if !ok {
+			return nil, status.Errorf(codes.Unavailable, "Waiting for AdcsRequest %s/%s", v.namespace(), v.Request)
+		}
+	}
+
+	mounted, err := isMountPoint(targetPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if !mounted {
+		if err := os.MkdirAll(targetPath, 0750); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		if err := bindMount(v.dataPath(), targetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "Cannot mount %s: %s", targetPath, err.Error())
+		}
+		d.Log.Info("Volume published", "volume", volumeID, "targetPath", targetPath)
+	}
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+// Unmount the volume and remove its state including the pending request
+func (d *Driver) NodeUnpublishVolume(ctx context.Context, request *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+	volumeID, targetPath := request.GetVolumeId(), request.GetTargetPath()
+	if !validVolumeID(volumeID) || targetPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "Volume ID and target path are required")
+	}
+
+	mounted, err := isMountPoint(targetPath)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if mounted {
+		if err := unmount(targetPath); err != nil {
+			return nil, status.Errorf(codes.Internal, "Cannot unmount %s: %s", targetPath, err.Error())
+		}
+	}
+	if err := os.Remove(targetPath); err != nil && !os.IsNotExist(err) {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	d.lock.Lock()
+	v := d.volumes[volumeID]
+	delete(d.volumes, volumeID)
+	d.lock.Unlock()
+	if v != nil {
+		v.lock.Lock()
+		defer v.lock.Unlock()
+		if v.Request != "" {
+			ar := &api.AdcsRequest{}
+			ar.Namespace, ar.Name = v.namespace(), v.Request
+			if err := d.Client.Delete(ctx, ar); client.IgnoreNotFound(err) != nil {
+				d.Log.Error(err, "Cannot delete AdcsRequest", "volume", volumeID)
+			}
+		}
+	}
+	if err := os.RemoveAll(filepath.Join(d.DataRoot, volumeID)); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	d.Log.Info("Volume unpublished", "volume", volumeID)
+	return &csi.NodeUnpublishVolumeResponse{}, nil
+}
+
+// Get the published volume or create new one
+func (d *Driver) volume(id, targetPath string, attributes map[string]string) (*volume, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if v, ok := d.volumes[id]; ok {
+		return v, nil
+	}
+	v := &volume{
+		ID:         id,
+		TargetPath: targetPath,
+		Attributes: attributes,
+		dir:        filepath.Join(d.DataRoot, id),
+	}
+	if err := os.MkdirAll(v.dir, 0700); err != nil {
+		return nil, err
+	}
+	if err := v.save(); err != nil {
+		return nil, err
+	}
+	d.volumes[id] = v
+	return v, nil
+}
+
+// Renew certificates due for renewal. Pending renewals are checked on each run.
+func (d *Driver) renewCertificates(ctx context.Context) {
+	ticker := time.NewTicker(renewCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		d.lock.Lock()
+		volumes := make([]*volume, 0, len(d.volumes))
+		for _, v := range d.volumes {
+			volumes = append(volumes, v)
+		}
+		d.lock.Unlock()
+		for _, v := range volumes {
+			v.lock.Lock()
+			if !v.RenewAt.IsZero() && time.Now().After(v.RenewAt) {
+				if _, err := d.issue(ctx, v, 0); err != nil {
+					d.Log.Error(err, "Cannot renew certificate", "volume", v.ID)
+					v.RenewAt = time.Now().Add(renewRetryInterval)
+					if err := v.save(); err != nil {
+						d.Log.Error(err, "Cannot save volume", "volume", v.ID)
+					}
+				}
+			}
+			v.lock.Unlock()
+		}
+	}
+}
+
+// Volume IDs are used as directory names
+func validVolumeID(id string) bool {
+	return id != "" && id != "." && id != ".." && !strings.ContainsAny(id, `/\`)
+}
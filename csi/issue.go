@@ -0,0 +1,263 @@
+package csi
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	pollInterval = time.Second
+	// Symlink to the directory with the current files
+	currentDataLink = "..data"
+)
+
+// Get the volume's certificate. A new key and request are created unless a request
+// is already pending. Returns false if the certificate isn't issued within wait.
+func (d *Driver) issue(ctx context.Context, v *volume, wait time.Duration) (bool, error) {
+	if v.Request == "" {
+		if err := d.submit(ctx, v); err != nil {
+			return false, err
+		}
+	}
+
+	key := client.ObjectKey{Namespace: v.namespace(), Name: v.Request}
+	log := d.Log.WithValues("volume", v.ID, "adcsrequest", key)
+	ar := new(api.AdcsRequest)
+	deadline := time.Now().Add(wait)
+	for {
+		if err := d.Client.Get(ctx, key, ar); err != nil {
+			if apierrors.IsNotFound(err) {
+				// A new request is sent next time
+				v.Request = ""
+				if err := v.save(); err != nil {
+					return false, err
+				}
+				return false, fmt.Errorf("AdcsRequest %s not found", key)
+			}
+			return false, err
+		}
+		if ar.Status.State != api.Unknown && ar.Status.State != api.Pending {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			return false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return false, nil
+		case <-time.After(pollInterval):
+		}
+	}
+
+	var err error
+	if ar.Status.State == api.Ready {
+		err = d.writeFiles(v, ar)
+		if err == nil {
+			log.Info("Certificate issued", "serialNumber", ar.Status.SerialNumber, "renewAt", v.RenewAt)
+		}
+	} else {
+		err = fmt.Errorf("Request %s: %s", ar.Status.State, ar.Status.Reason)
+	}
+	// The request is done. Failed ones are sent again on the next attempt.
+	if err := d.Client.Delete(ctx, ar); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Cannot delete AdcsRequest")
+	}
+	v.Request = ""
+	if saveErr := v.save(); err == nil {
+		err = saveErr
+	}
+	return err == nil, err
+}
+
+// Check that the AdcsIssuer allows the pod's service account to use it in CSI volumes.
+// It's checked for every request so that renewals stop when the pod is no longer allowed.
+func (d *Driver) checkIssuer(ctx context.Context, v *volume) error {
+	issuer := new(api.AdcsIssuer)
+	if err := d.Client.Get(ctx, client.ObjectKey{Namespace: v.namespace(), Name: v.issuerRef().Name}, issuer); err != nil {
+		return fmt.Errorf("Cannot get AdcsIssuer %s: %s", v.issuerRef().Name, err.Error())
+	}
+	if issuer.Spec.CSI == nil {
+		return fmt.Errorf("AdcsIssuer %s doesn't allow CSI volumes", issuer.Name)
+	}
+	serviceAccount := v.Attributes[serviceAccountAttribute]
+	for _, sa := range issuer.Spec.CSI.ServiceAccounts {
+		if sa == "*" || sa == serviceAccount {
+			return nil
+		}
+	}
+	return fmt.Errorf("AdcsIssuer %s doesn't allow CSI volumes of service account %s", issuer.Name, serviceAccount)
+}
+
+// Generate a new private key and send its CSR in AdcsRequest created in the pod's namespace.
+// The request is owned by the pod so that it's removed with the pod.
+func (d *Driver) submit(ctx context.Context, v *volume) error {
+	if err := d.checkIssuer(ctx, v); err != nil {
+		return err
+	}
+	crt, err := v.certificate()
+	if err != nil {
+		return err
+	}
+	key, err := pki.GeneratePrivateKeyForCertificate(crt)
+	if err != nil {
+		return fmt.Errorf("Cannot generate private key: %s", err.Error())
+	}
+	keyPEM, err := pki.EncodePrivateKey(key, crt.Spec.PrivateKey.Encoding)
+	if err != nil {
+		return fmt.Errorf("Cannot encode private key: %s", err.Error())
+	}
+	template, err := pki.GenerateCSR(crt)
+	if err != nil {
+		return fmt.Errorf("Cannot generate CSR: %s", err.Error())
+	}
+	csrDER, err := pki.EncodeCSR(template, key)
+	if err != nil {
+		return fmt.Errorf("Cannot encode CSR: %s", err.Error())
+	}
+	if err := writeFileAtomic(filepath.Join(v.dir, pendingKeyFile), keyPEM, 0600); err != nil {
+		return err
+	}
+
+	ar := &api.AdcsRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "csi-",
+			Namespace:    v.namespace(),
+			Annotations:  map[string]string{api.CSIVolumeAnnotation: v.ID},
+		},
+		Spec: api.AdcsRequestSpec{
+			CSRPEM:    pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrDER}),
+			IssuerRef: v.issuerRef(),
+			Duration:  crt.Spec.Duration,
+		},
+	}
+	if uid := v.Attributes[podUIDAttribute]; uid != "" {
+		ar.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Pod",
+			Name:       v.Attributes[podNameAttribute],
+			UID:        types.UID(uid),
+		}}
+	}
+	if err := d.Client.Create(ctx, ar); err != nil {
+		return fmt.Errorf("Cannot create AdcsRequest: %s", err.Error())
+	}
+	d.Log.Info("Certificate requested", "volume", v.ID, "adcsrequest", client.ObjectKeyFromObject(ar))
+	v.Request = ar.Name
+	return v.save()
+}
+
+// Write the key, certificate chain and CA files. The files are written to a new
+// directory which replaces the current one atomically (as kubelet does for Secret
+// volumes) so that the pod never sees key and certificate not matching.
+func (d *Driver) writeFiles(v *volume, ar *api.AdcsRequest) error {
+	keyPEM, err := ioutil.ReadFile(filepath.Join(v.dir, pendingKeyFile))
+	if err != nil {
+		return fmt.Errorf("Cannot read private key: %s", err.Error())
+	}
+	key, err := pki.DecodePrivateKeyBytes(keyPEM)
+	if err != nil {
+		return fmt.Errorf("Cannot decode private key: %s", err.Error())
+	}
+	cert, err := pki.DecodeX509CertificateBytes(ar.Status.Certificate)
+	if err != nil {
+		return fmt.Errorf("Cannot decode issued certificate: %s", err.Error())
+	}
+	if matches, err := pki.PublicKeyMatchesCertificate(key.Public(), cert); err != nil || !matches {
+		return fmt.Errorf("Issued certificate doesn't match the private key")
+	}
+	chainPEM, err := issuers.CertificateChain(ar.Status.Certificate, ar.Status.CACertificate)
+	if err != nil {
+		return err
+	}
+	var caPEM []byte
+	if len(ar.Status.CACertificate) > 0 {
+		caCerts, err := pki.DecodeX509CertificateChainBytes(ar.Status.CACertificate)
+		if err != nil {
+			return fmt.Errorf("Cannot decode CA certificates: %s", err.Error())
+		}
+		_, roots := issuers.SplitChain(caCerts)
+		caPEM = issuers.EncodeCertificates(roots)
+	}
+	gid, _ := v.fsGroup()
+	keyMode := os.FileMode(0600)
+	if gid >= 0 {
+		keyMode = 0640
+	}
+	files := []struct {
+		name string
+		data []byte
+		mode os.FileMode
+	}{
+		{v.fileName(certificateFileAttribute, defaultCertificateFileName), chainPEM, 0644},
+		{v.fileName(keyFileAttribute, defaultKeyFileName), keyPEM, keyMode},
+		{v.fileName(caFileAttribute, defaultCAFileName), caPEM, 0644},
+	}
+
+	base := v.dataPath()
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return err
+	}
+	dirName := ".." + strconv.FormatInt(time.Now().UnixNano(), 10)
+	dir := filepath.Join(base, dirName)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		if err := ioutil.WriteFile(path, f.data, f.mode); err != nil {
+			return err
+		}
+		if gid >= 0 {
+			if err := os.Chown(path, -1, gid); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Switch the current data link to the new directory
+	previous, _ := os.Readlink(filepath.Join(base, currentDataLink))
+	tmpLink := filepath.Join(base, currentDataLink+".tmp")
+	os.Remove(tmpLink)
+	if err := os.Symlink(dirName, tmpLink); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpLink, filepath.Join(base, currentDataLink)); err != nil {
+		return err
+	}
+	for _, f := range files {
+		link := filepath.Join(base, f.name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			if err := os.Symlink(filepath.Join(currentDataLink, f.name), link); err != nil {
+				return err
+			}
+		}
+	}
+	if previous != "" && previous != dirName {
+		os.RemoveAll(filepath.Join(base, previous))
+	}
+
+	os.Remove(filepath.Join(v.dir, pendingKeyFile))
+	v.RenewAt = v.renewalTime(cert.NotBefore, cert.NotAfter)
+	return nil
+}
+
+// The certificate files exist
+func (v *volume) issued() bool {
+	_, err := os.Stat(filepath.Join(v.dataPath(), currentDataLink))
+	return err == nil
+}
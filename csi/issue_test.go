@@ -0,0 +1,156 @@
+package csi
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func newTestDriver(t *testing.T, objects ...client.Object) *Driver {
+	scheme := runtime.NewScheme()
+	if err := api.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return &Driver{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		Log:      logr.Discard(),
+		DataRoot: t.TempDir(),
+		volumes:  map[string]*volume{},
+	}
+}
+
+func newTestIssuer(policy *api.CSIPolicy) *api.AdcsIssuer {
+	return &api.AdcsIssuer{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "adcs"},
+		Spec:       api.AdcsIssuerSpec{CSI: policy},
+	}
+}
+
+func TestCheckIssuer(t *testing.T) {
+	tests := []struct {
+		name   string
+		issuer *api.AdcsIssuer
+		ok     bool
+	}{
+		{"allowed", newTestIssuer(&api.CSIPolicy{ServiceAccounts: []string{"default", "web"}}), true},
+		{"all allowed", newTestIssuer(&api.CSIPolicy{ServiceAccounts: []string{"*"}}), true},
+		{"other service account", newTestIssuer(&api.CSIPolicy{ServiceAccounts: []string{"default"}}), false},
+		{"CSI not enabled", newTestIssuer(nil), false},
+		{"issuer not found", &api.AdcsIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "adcs"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDriver(t, tt.issuer)
+			v := &volume{Attributes: testAttributes(nil)}
+			if err := d.checkIssuer(context.Background(), v); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestIssue(t *testing.T) {
+	d := newTestDriver(t, newTestIssuer(&api.CSIPolicy{ServiceAccounts: []string{"web"}}))
+	v, err := d.volume("csi-0123", "/target", testAttributes(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	ok, err := d.issue(ctx, v, 0)
+	if err != nil || ok {
+		t.Fatalf("expected pending request, got %t, %v", ok, err)
+	}
+	ar := new(api.AdcsRequest)
+	if err := d.Client.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: v.Request}, ar); err != nil {
+		t.Fatal(err)
+	}
+	if ar.Spec.IssuerRef.Kind != "AdcsIssuer" || ar.Spec.IssuerRef.Name != "adcs" || ar.Annotations[api.CSIVolumeAnnotation] != v.ID {
+		t.Errorf("unexpected AdcsRequest %+v", ar)
+	}
+	if len(ar.OwnerReferences) != 1 || ar.OwnerReferences[0].Kind != "Pod" {
+		t.Errorf("expected AdcsRequest owned by the pod, got %v", ar.OwnerReferences)
+	}
+	// The pending request survives restart
+	loaded, err := loadVolume(v.dir)
+	if err != nil || loaded.Request != v.Request {
+		t.Fatalf("expected saved request %s, got %v", v.Request, err)
+	}
+
+	// Issue the certificate for the pending key
+	csr, err := pki.DecodeX509CertificateRequestBytes(ar.Spec.CSRPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM, err := ioutil.ReadFile(filepath.Join(v.dir, pendingKeyFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+	notAfter := notBefore.Add(30 * time.Hour)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "web-0"},
+		DNSNames:     csr.DNSNames,
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+	}
+	if _, err := pki.DecodePrivateKeyBytes(keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	caKey := testutil.NewKey(t)
+	caCert := testutil.NewCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, caKey.Public(), nil, caKey)
+	issued := testutil.NewCertificate(t, template, csr.PublicKey, caCert, caKey)
+	ar.Status.State = api.Ready
+	ar.Status.Certificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: issued.Raw})
+	ar.Status.CACertificate = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caCert.Raw})
+	if err := d.Client.Status().Update(ctx, ar); err != nil {
+		t.Fatal(err)
+	}
+
+	ok, err = d.issue(ctx, v, 0)
+	if err != nil || !ok {
+		t.Fatalf("expected certificate issued, got %t, %v", ok, err)
+	}
+	if !v.issued() || v.Request != "" {
+		t.Errorf("expected issued volume without request, got %+v", v)
+	}
+	if expected := notAfter.Add(-10 * time.Hour); !v.RenewAt.Equal(expected) {
+		t.Errorf("expected renewal at %s, got %s", expected, v.RenewAt)
+	}
+	cert, err := ioutil.ReadFile(filepath.Join(v.dataPath(), defaultCertificateFileName))
+	if err != nil || len(cert) == 0 {
+		t.Errorf("expected certificate file, got %v", err)
+	}
+	ca, err := ioutil.ReadFile(filepath.Join(v.dataPath(), defaultCAFileName))
+	if err != nil || string(ca) != string(ar.Status.CACertificate) {
+		t.Errorf("expected CA file with the CA certificate, got %v", err)
+	}
+	if err := d.Client.Get(ctx, client.ObjectKeyFromObject(ar), new(api.AdcsRequest)); err == nil {
+		t.Errorf("expected completed AdcsRequest deleted")
+	}
+}
@@ -0,0 +1,47 @@
+//go:build linux
+// +build linux
+
+package csi
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// Bind mount the directory read-only
+func bindMount(source, target string) error {
+	if err := syscall.Mount(source, target, "", syscall.MS_BIND, ""); err != nil {
+		return err
+	}
+	return syscall.Mount("", target, "", syscall.MS_BIND|syscall.MS_REMOUNT|syscall.MS_RDONLY, "")
+}
+
+func unmount(target string) error {
+	return syscall.Unmount(target, 0)
+}
+
+// Find the path in the mount table. Bind mounts can't be detected by comparing
+// devices of the path and its parent.
+func isMountPoint(path string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// The mount point is the 5th field with spaces escaped as '\040'
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 4 && strings.Replace(fields[4], `\040`, " ", -1) == path {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
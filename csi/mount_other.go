@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package csi
+
+import "fmt"
+
+func bindMount(source, target string) error {
+	return fmt.Errorf("Mounting is supported only on Linux")
+}
+
+func unmount(target string) error {
+	return fmt.Errorf("Mounting is supported only on Linux")
+}
+
+func isMountPoint(path string) (bool, error) {
+	return false, nil
+}
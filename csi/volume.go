@@ -0,0 +1,263 @@
+package csi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Volume attributes
+const (
+	attributePrefix = DriverName + "/"
+
+	issuerNameAttribute      = attributePrefix + "issuer-name"
+	issuerKindAttribute      = attributePrefix + "issuer-kind"
+	commonNameAttribute      = attributePrefix + "common-name"
+	dnsNamesAttribute        = attributePrefix + "dns-names"
+	uriSANsAttribute         = attributePrefix + "uri-sans"
+	ipSANsAttribute          = attributePrefix + "ip-sans"
+	durationAttribute        = attributePrefix + "duration"
+	renewBeforeAttribute     = attributePrefix + "renew-before"
+	keyAlgorithmAttribute    = attributePrefix + "key-algorithm"
+	keySizeAttribute         = attributePrefix + "key-size"
+	keyEncodingAttribute     = attributePrefix + "key-encoding"
+	certificateFileAttribute = attributePrefix + "certificate-file"
+	keyFileAttribute         = attributePrefix + "key-file"
+	caFileAttribute          = attributePrefix + "ca-file"
+	fsGroupAttribute         = attributePrefix + "fs-group"
+
+	// Pod information passed by kubelet with CSIDriver 'podInfoOnMount'
+	podNameAttribute           = "csi.storage.k8s.io/pod.name"
+	podNamespaceAttribute      = "csi.storage.k8s.io/pod.namespace"
+	podUIDAttribute            = "csi.storage.k8s.io/pod.uid"
+	serviceAccountAttribute    = "csi.storage.k8s.io/serviceAccount.name"
+	ephemeralVolumeAttribute   = "csi.storage.k8s.io/ephemeral"
+	defaultCertificateFileName = "tls.crt"
+	defaultKeyFileName         = "tls.key"
+	defaultCAFileName          = "ca.crt"
+)
+
+// Volume is the state of a published volume. It's persisted in the driver's
+// data directory so that the certificates are renewed after restart.
+type volume struct {
+	ID         string            `json:"id"`
+	TargetPath string            `json:"targetPath"`
+	Attributes map[string]string `json:"attributes"`
+	// Name of the AdcsRequest waiting for the certificate
+	Request string `json:"request,omitempty"`
+	// When the current certificate should be renewed. Zero if not issued yet.
+	RenewAt time.Time `json:"renewAt,omitempty"`
+
+	// Serializes issuance of the volume's certificate
+	lock sync.Mutex
+	// Directory with the volume's state
+	dir string
+}
+
+const (
+	stateFile = "volume.json"
+	// Private key of the pending request
+	pendingKeyFile = "pending.key"
+	// Directory mounted into the pod
+	dataDir = "data"
+)
+
+func loadVolume(dir string) (*volume, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil {
+		return nil, err
+	}
+	v := &volume{dir: dir}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, fmt.Errorf("Cannot parse volume state %s: %s", dir, err.Error())
+	}
+	return v, nil
+}
+
+func (v *volume) save() error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(v.dir, stateFile), data, 0600)
+}
+
+func (v *volume) dataPath() string {
+	return filepath.Join(v.dir, dataDir)
+}
+
+// Only AdcsIssuers in the pod's namespace can be used
+func (v *volume) issuerRef() cmmeta.ObjectReference {
+	return cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "AdcsIssuer", Name: v.Attributes[issuerNameAttribute]}
+}
+
+func (v *volume) namespace() string {
+	return v.Attributes[podNamespaceAttribute]
+}
+
+func (v *volume) fileName(attribute, defaultName string) string {
+	if name := v.Attributes[attribute]; name != "" {
+		return name
+	}
+	return defaultName
+}
+
+// Check the volume attributes. The pod namespace is required as the request is
+// created there.
+func validateAttributes(attributes map[string]string) error {
+	if attributes[ephemeralVolumeAttribute] != "true" {
+		return fmt.Errorf("Only inline ephemeral volumes are supported")
+	}
+	if attributes[podNamespaceAttribute] == "" || attributes[podNameAttribute] == "" {
+		return fmt.Errorf("Pod information missing, CSIDriver must have podInfoOnMount enabled")
+	}
+	if attributes[issuerNameAttribute] == "" {
+		return fmt.Errorf("%s is required", issuerNameAttribute)
+	}
+	if kind := attributes[issuerKindAttribute]; kind != "" && kind != "AdcsIssuer" {
+		// ClusterAdcsIssuers would let any pod author get certificates of the cluster's issuers
+		return fmt.Errorf("%s must be AdcsIssuer, only issuers in the pod's namespace can be used", issuerKindAttribute)
+	}
+	if attributes[serviceAccountAttribute] == "" {
+		return fmt.Errorf("Pod service account missing, CSIDriver must have podInfoOnMount enabled")
+	}
+	v := &volume{Attributes: attributes}
+	crt, err := v.certificate()
+	if err != nil {
+		return err
+	}
+	if crt.Spec.CommonName == "" && len(crt.Spec.DNSNames) == 0 && len(crt.Spec.URIs) == 0 && len(crt.Spec.IPAddresses) == 0 {
+		return fmt.Errorf("At least one of %s, %s, %s or %s is required", commonNameAttribute, dnsNamesAttribute, uriSANsAttribute, ipSANsAttribute)
+	}
+	if _, err := v.renewBefore(); err != nil {
+		return err
+	}
+	if _, err := v.fsGroup(); err != nil {
+		return err
+	}
+	for _, attribute := range []string{certificateFileAttribute, keyFileAttribute, caFileAttribute} {
+		if name := attributes[attribute]; name != "" && (strings.Contains(name, "/") || strings.HasPrefix(name, ".")) {
+			return fmt.Errorf("%s must be a plain file name", attribute)
+		}
+	}
+	return nil
+}
+
+// Build the certificate spec the CSR is generated from. The ${POD_NAME}, ${POD_NAMESPACE},
+// ${POD_UID} and ${SERVICE_ACCOUNT_NAME} variables are substituted in the names.
+func (v *volume) certificate() (*cmapi.Certificate, error) {
+	expand := strings.NewReplacer(
+		"${POD_NAME}", v.Attributes[podNameAttribute],
+		"${POD_NAMESPACE}", v.Attributes[podNamespaceAttribute],
+		"${POD_UID}", v.Attributes[podUIDAttribute],
+		"${SERVICE_ACCOUNT_NAME}", v.Attributes[serviceAccountAttribute],
+	).Replace
+	list := func(attribute string) []string {
+		var values []string
+		for _, value := range strings.Split(v.Attributes[attribute], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, expand(value))
+			}
+		}
+		return values
+	}
+
+	crt := &cmapi.Certificate{
+		Spec: cmapi.CertificateSpec{
+			CommonName:  expand(v.Attributes[commonNameAttribute]),
+			DNSNames:    list(dnsNamesAttribute),
+			URIs:        list(uriSANsAttribute),
+			IPAddresses: list(ipSANsAttribute),
+			PrivateKey: &cmapi.CertificatePrivateKey{
+				Algorithm: cmapi.RSAKeyAlgorithm,
+				Encoding:  cmapi.PKCS1,
+			},
+		},
+	}
+	if value := v.Attributes[durationAttribute]; value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %s", durationAttribute, err.Error())
+		}
+		crt.Spec.Duration = &metav1.Duration{Duration: d}
+	}
+	switch algorithm := v.Attributes[keyAlgorithmAttribute]; algorithm {
+	case "", "RSA":
+	case "ECDSA":
+		crt.Spec.PrivateKey.Algorithm = cmapi.ECDSAKeyAlgorithm
+	default:
+		return nil, fmt.Errorf("%s must be RSA or ECDSA", keyAlgorithmAttribute)
+	}
+	if value := v.Attributes[keySizeAttribute]; value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid %s: %s", keySizeAttribute, err.Error())
+		}
+		crt.Spec.PrivateKey.Size = size
+	}
+	switch encoding := v.Attributes[keyEncodingAttribute]; encoding {
+	case "", "PKCS1":
+	case "PKCS8":
+		crt.Spec.PrivateKey.Encoding = cmapi.PKCS8
+	default:
+		return nil, fmt.Errorf("%s must be PKCS1 or PKCS8", keyEncodingAttribute)
+	}
+	return crt, nil
+}
+
+// How long before expiry the certificate is renewed. Zero means 1/3 of its lifetime.
+func (v *volume) renewBefore() (time.Duration, error) {
+	value := v.Attributes[renewBeforeAttribute]
+	if value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %s", renewBeforeAttribute, err.Error())
+	}
+	return d, nil
+}
+
+// Group owning the files. -1 if not set.
+func (v *volume) fsGroup() (int, error) {
+	value := v.Attributes[fsGroupAttribute]
+	if value == "" {
+		return -1, nil
+	}
+	gid, err := strconv.Atoi(value)
+	if err != nil || gid < 0 {
+		return -1, fmt.Errorf("Invalid %s: %s", fsGroupAttribute, value)
+	}
+	return gid, nil
+}
+
+// When to renew the certificate
+func (v *volume) renewalTime(notBefore, notAfter time.Time) time.Time {
+	renewBefore, _ := v.renewBefore()
+	lifetime := notAfter.Sub(notBefore)
+	if renewBefore <= 0 || renewBefore >= lifetime {
+		renewBefore = lifetime / 3
+	}
+	return notAfter.Add(-renewBefore)
+}
+
+// Write the file so that readers never see partial content
+func writeFileAtomic(path string, data []byte, mode os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,152 @@
+package csi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testAttributes(extra map[string]string) map[string]string {
+	attributes := map[string]string{
+		ephemeralVolumeAttribute: "true",
+		podNameAttribute:         "web-0",
+		podNamespaceAttribute:    "team-a",
+		podUIDAttribute:          "0c1f6a9e-1b2c-4d5e-8f90-123456789abc",
+		serviceAccountAttribute:  "web",
+		issuerNameAttribute:      "adcs",
+		dnsNamesAttribute:        "${POD_NAME}.${POD_NAMESPACE}.svc",
+	}
+	for k, v := range extra {
+		if v == "" {
+			delete(attributes, k)
+		} else {
+			attributes[k] = v
+		}
+	}
+	return attributes
+}
+
+func TestValidateAttributes(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra map[string]string
+		ok    bool
+	}{
+		{"valid", nil, true},
+		{"AdcsIssuer", map[string]string{issuerKindAttribute: "AdcsIssuer"}, true},
+		{"ClusterAdcsIssuer", map[string]string{issuerKindAttribute: "ClusterAdcsIssuer"}, false},
+		{"not ephemeral", map[string]string{ephemeralVolumeAttribute: "false"}, false},
+		{"no pod info", map[string]string{podNameAttribute: ""}, false},
+		{"no service account", map[string]string{serviceAccountAttribute: ""}, false},
+		{"no issuer", map[string]string{issuerNameAttribute: ""}, false},
+		{"no names", map[string]string{dnsNamesAttribute: ""}, false},
+		{"common name only", map[string]string{dnsNamesAttribute: "", commonNameAttribute: "web"}, true},
+		{"invalid duration", map[string]string{durationAttribute: "1 day"}, false},
+		{"invalid renew-before", map[string]string{renewBeforeAttribute: "soon"}, false},
+		{"invalid key algorithm", map[string]string{keyAlgorithmAttribute: "DSA"}, false},
+		{"invalid key size", map[string]string{keySizeAttribute: "big"}, false},
+		{"invalid key encoding", map[string]string{keyEncodingAttribute: "DER"}, false},
+		{"invalid fs-group", map[string]string{fsGroupAttribute: "-1"}, false},
+		{"file name with path", map[string]string{keyFileAttribute: "../key.pem"}, false},
+		{"hidden file name", map[string]string{certificateFileAttribute: ".crt"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAttributes(testAttributes(tt.extra)); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	v := &volume{Attributes: testAttributes(map[string]string{
+		commonNameAttribute:   "${SERVICE_ACCOUNT_NAME}",
+		dnsNamesAttribute:     "${POD_NAME}.${POD_NAMESPACE}.svc, ${POD_NAME}.example.com,,",
+		uriSANsAttribute:      "spiffe://cluster.local/ns/${POD_NAMESPACE}/sa/${SERVICE_ACCOUNT_NAME},urn:uuid:${POD_UID}",
+		ipSANsAttribute:       "10.0.0.1",
+		durationAttribute:     "24h",
+		keyAlgorithmAttribute: "ECDSA",
+		keySizeAttribute:      "384",
+		keyEncodingAttribute:  "PKCS8",
+	})}
+	crt, err := v.certificate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crt.Spec.CommonName != "web" {
+		t.Errorf("unexpected common name %s", crt.Spec.CommonName)
+	}
+	if expected := []string{"web-0.team-a.svc", "web-0.example.com"}; !reflect.DeepEqual(crt.Spec.DNSNames, expected) {
+		t.Errorf("expected DNS names %v, got %v", expected, crt.Spec.DNSNames)
+	}
+	expected := []string{"spiffe://cluster.local/ns/team-a/sa/web", "urn:uuid:0c1f6a9e-1b2c-4d5e-8f90-123456789abc"}
+	if !reflect.DeepEqual(crt.Spec.URIs, expected) {
+		t.Errorf("expected URIs %v, got %v", expected, crt.Spec.URIs)
+	}
+	if !reflect.DeepEqual(crt.Spec.IPAddresses, []string{"10.0.0.1"}) {
+		t.Errorf("unexpected IP addresses %v", crt.Spec.IPAddresses)
+	}
+	if crt.Spec.Duration == nil || crt.Spec.Duration.Duration != 24*time.Hour {
+		t.Errorf("unexpected duration %v", crt.Spec.Duration)
+	}
+	if pk := crt.Spec.PrivateKey; pk.Algorithm != "ECDSA" || pk.Size != 384 || pk.Encoding != "PKCS8" {
+		t.Errorf("unexpected private key %+v", pk)
+	}
+	if ref := v.issuerRef(); ref.Kind != "AdcsIssuer" || ref.Name != "adcs" {
+		t.Errorf("unexpected issuer %+v", ref)
+	}
+}
+
+func TestVolumeState(t *testing.T) {
+	dir := t.TempDir()
+	renewAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	v := &volume{
+		ID:         "csi-0123",
+		TargetPath: "/var/lib/kubelet/pods/0c1f6a9e/volumes/kubernetes.io~csi/tls/mount",
+		Attributes: testAttributes(nil),
+		Request:    "csi-abcde",
+		RenewAt:    renewAt,
+		dir:        dir,
+	}
+	if err := v.save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadVolume(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.ID != v.ID || loaded.TargetPath != v.TargetPath || loaded.Request != v.Request ||
+		!loaded.RenewAt.Equal(renewAt) || !reflect.DeepEqual(loaded.Attributes, v.Attributes) {
+		t.Errorf("expected %+v, got %+v", v, loaded)
+	}
+	if loaded.dir != dir {
+		t.Errorf("expected directory %s, got %s", dir, loaded.dir)
+	}
+
+	if _, err := loadVolume(t.TempDir()); err == nil {
+		t.Errorf("expected error for missing state")
+	}
+}
+
+func TestRenewalTime(t *testing.T) {
+	notBefore := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(90 * 24 * time.Hour)
+	tests := []struct {
+		name        string
+		renewBefore string
+		expected    time.Time
+	}{
+		{"default", "", notBefore.Add(60 * 24 * time.Hour)},
+		{"renew-before", "240h", notAfter.Add(-240 * time.Hour)},
+		{"longer than lifetime", "2160h", notBefore.Add(60 * 24 * time.Hour)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &volume{Attributes: map[string]string{renewBeforeAttribute: tt.renewBefore}}
+			if got := v.renewalTime(notBefore, notAfter); !got.Equal(tt.expected) {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package est
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+)
+
+// Authenticate the client with the TLS client certificate or HTTP basic authentication.
+// Returns the client's name and its certificate (nil for basic authentication).
+func (s *Server) authenticate(ctx context.Context, r *http.Request, issuer *estIssuer) (string, *x509.Certificate, error) {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 && issuer.policy.ClientCASecretRef != "" {
+		cert, err := s.verifyClientCertificate(ctx, r.TLS.PeerCertificates, issuer)
+		if err != nil {
+			return "", nil, err
+		}
+		return cert.Subject.String(), cert, nil
+	}
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", nil, fmt.Errorf("No credentials")
+	}
+	for _, name := range issuer.policy.CredentialsSecretRefs {
+		secret := new(core.Secret)
+		if err := s.Client.Get(ctx, client.ObjectKey{Namespace: issuer.namespace, Name: name}, secret); err != nil {
+			s.Log.Error(err, "Cannot get EST credentials Secret", "secret", name)
+			continue
+		}
+		if subtle.ConstantTimeCompare(secret.Data[core.BasicAuthUsernameKey], []byte(username)) == 1 &&
+			subtle.ConstantTimeCompare(secret.Data[core.BasicAuthPasswordKey], []byte(password)) == 1 {
+			return username, nil, nil
+		}
+	}
+	return "", nil, fmt.Errorf("Invalid credentials of user %s", username)
+}
+
+// Verify the client certificate chain with the CA certificates of the issuer's EST policy
+func (s *Server) verifyClientCertificate(ctx context.Context, chain []*x509.Certificate, issuer *estIssuer) (*x509.Certificate, error) {
+	secret := new(core.Secret)
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: issuer.namespace, Name: issuer.policy.ClientCASecretRef}, secret); err != nil {
+		return nil, fmt.Errorf("Cannot get client CA Secret %s: %s", issuer.policy.ClientCASecretRef, err.Error())
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(secret.Data[cmmeta.TLSCAKey]) {
+		return nil, fmt.Errorf("No CA certificates in Secret %s", issuer.policy.ClientCASecretRef)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range chain[1:] {
+		intermediates.AddCert(c)
+	}
+	_, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("Invalid client certificate %s: %s", chain[0].Subject, err.Error())
+	}
+	return chain[0], nil
+}
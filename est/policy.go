@@ -0,0 +1,144 @@
+package est
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Re-enrollment must keep the subject and SANs of the client certificate (RFC 7030 section 4.2.2)
+func checkReenroll(csr *x509.CertificateRequest, cert *x509.Certificate) error {
+	if csr.Subject.String() != cert.Subject.String() {
+		return fmt.Errorf("CSR subject doesn't match the client certificate")
+	}
+	if !sameNames(csrNames(csr), certNames(cert)) {
+		return fmt.Errorf("CSR SANs don't match the client certificate")
+	}
+	return nil
+}
+
+// Check the CSR subject and SANs with the policy of the client.
+// All names are allowed if the EST policy has no client policies.
+func checkClientNames(policy *api.ESTPolicy, client string, csr *x509.CertificateRequest) error {
+	if len(policy.Clients) == 0 {
+		return nil
+	}
+	var cp *api.ESTClientPolicy
+	for i := range policy.Clients {
+		if policy.Clients[i].Client == client {
+			cp = &policy.Clients[i]
+			break
+		}
+	}
+	if cp == nil {
+		return fmt.Errorf("No names are allowed to client %s", client)
+	}
+
+	if subject := csr.Subject.String(); subject != "" && !matchAny(cp.Subjects, subject) {
+		return fmt.Errorf("Subject %s is not allowed", subject)
+	}
+	for _, name := range csr.DNSNames {
+		if !matchAny(cp.DNSNames, name) {
+			return fmt.Errorf("DNS name %s is not allowed", name)
+		}
+	}
+	for _, email := range csr.EmailAddresses {
+		if !matchAny(cp.EmailAddresses, email) {
+			return fmt.Errorf("Email address %s is not allowed", email)
+		}
+	}
+	for _, uri := range csr.URIs {
+		if !matchAny(cp.URIs, uri.String()) {
+			return fmt.Errorf("URI %s is not allowed", uri)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		allowed := false
+		for _, cidr := range cp.IPAddresses {
+			if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("IP address %s is not allowed", ip)
+		}
+	}
+	return nil
+}
+
+// The '*' of patterns doesn't match name separators
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		re, err := regexp.Compile("^" + strings.Join(parts, `[^.,=/@]*`) + "$")
+		if err == nil && re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func csrNames(csr *x509.CertificateRequest) []string {
+	names := prefixed("dns:", csr.DNSNames)
+	names = append(names, prefixed("email:", csr.EmailAddresses)...)
+	for _, uri := range csr.URIs {
+		names = append(names, "uri:"+uri.String())
+	}
+	for _, ip := range csr.IPAddresses {
+		names = append(names, "ip:"+ip.String())
+	}
+	return names
+}
+
+func certNames(cert *x509.Certificate) []string {
+	return csrNames(&x509.CertificateRequest{
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		URIs:           cert.URIs,
+		IPAddresses:    cert.IPAddresses,
+	})
+}
+
+func prefixed(prefix string, values []string) []string {
+	var names []string
+	for _, v := range values {
+		names = append(names, prefix+v)
+	}
+	return names
+}
+
+// Compare the names as sets
+func sameNames(a, b []string) bool {
+	a, b = uniqueSorted(a), uniqueSorted(b)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func uniqueSorted(names []string) []string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, n := range names {
+		if !seen[n] {
+			seen[n] = true
+			unique = append(unique, n)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
@@ -0,0 +1,96 @@
+package est
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/url"
+	"testing"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func TestCheckReenroll(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "router-1"},
+		DNSNames:    []string{"router-1.example.com", "router-1.lab.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	tests := []struct {
+		name string
+		csr  *x509.CertificateRequest
+		ok   bool
+	}{
+		{"same names", &x509.CertificateRequest{
+			Subject:     pkix.Name{CommonName: "router-1"},
+			DNSNames:    []string{"router-1.lab.example.com", "router-1.example.com"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		}, true},
+		{"other subject", &x509.CertificateRequest{
+			Subject:     pkix.Name{CommonName: "router-2"},
+			DNSNames:    []string{"router-1.example.com", "router-1.lab.example.com"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		}, false},
+		{"added SAN", &x509.CertificateRequest{
+			Subject:     pkix.Name{CommonName: "router-1"},
+			DNSNames:    []string{"router-1.example.com", "router-1.lab.example.com", "www.example.com"},
+			IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+		}, false},
+		{"missing SAN", &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: "router-1"},
+			DNSNames: []string{"router-1.example.com", "router-1.lab.example.com"},
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkReenroll(tt.csr, cert); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+}
+
+func TestCheckClientNames(t *testing.T) {
+	policy := &api.ESTPolicy{Clients: []api.ESTClientPolicy{{
+		Client:         "CN=router-1,O=Example",
+		Subjects:       []string{"CN=router-1,O=Example"},
+		DNSNames:       []string{"router-1.example.com", "*.router-1.example.com"},
+		IPAddresses:    []string{"10.0.0.0/24"},
+		URIs:           []string{"spiffe://example.com/routers/*"},
+		EmailAddresses: []string{"*@example.com"},
+	}}}
+	uri, _ := url.Parse("spiffe://example.com/routers/1")
+	otherURI, _ := url.Parse("spiffe://example.com/routers/1/admin")
+
+	tests := []struct {
+		name   string
+		policy *api.ESTPolicy
+		client string
+		csr    *x509.CertificateRequest
+		ok     bool
+	}{
+		{"not restricted", &api.ESTPolicy{}, "anyone", &x509.CertificateRequest{DNSNames: []string{"www.example.com"}}, true},
+		{"allowed", policy, "CN=router-1,O=Example", &x509.CertificateRequest{
+			Subject:        pkix.Name{CommonName: "router-1", Organization: []string{"Example"}},
+			DNSNames:       []string{"router-1.example.com", "mgmt.router-1.example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+			URIs:           []*url.URL{uri},
+			EmailAddresses: []string{"noc@example.com"},
+		}, true},
+		{"empty subject", policy, "CN=router-1,O=Example", &x509.CertificateRequest{DNSNames: []string{"router-1.example.com"}}, true},
+		{"unknown client", policy, "CN=router-2,O=Example", &x509.CertificateRequest{}, false},
+		{"other subject", policy, "CN=router-1,O=Example", &x509.CertificateRequest{Subject: pkix.Name{CommonName: "router-2"}}, false},
+		{"other DNS name", policy, "CN=router-1,O=Example", &x509.CertificateRequest{DNSNames: []string{"www.example.com"}}, false},
+		{"wildcard doesn't match dots", policy, "CN=router-1,O=Example", &x509.CertificateRequest{DNSNames: []string{"a.b.router-1.example.com"}}, false},
+		{"IP address outside network", policy, "CN=router-1,O=Example", &x509.CertificateRequest{IPAddresses: []net.IP{net.ParseIP("10.0.1.1")}}, false},
+		{"wildcard doesn't match slashes", policy, "CN=router-1,O=Example", &x509.CertificateRequest{URIs: []*url.URL{otherURI}}, false},
+		{"other email domain", policy, "CN=router-1,O=Example", &x509.CertificateRequest{EmailAddresses: []string{"noc@example.org"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkClientNames(tt.policy, tt.client, tt.csr); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,325 @@
+// Package est implements an EST (RFC 7030) server for devices that can't use certsrv.
+// EST labels are mapped to issuers by their 'est' policy. Enrollments create
+// AdcsRequests which are sent to ADCS by the AdcsRequest controller.
+package est
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	basePath = "/.well-known/est/"
+	// Label used by requests without one
+	defaultLabel = api.DefaultLabel
+
+	// Retry-After of requests not submitted to ADCS yet (e.g. throttled)
+	defaultRetryAfter = time.Minute
+	minRetryAfter     = 10 * time.Second
+	pollInterval      = time.Second
+	maxRequestSize    = 64 * 1024
+	shutdownTimeout   = 10 * time.Second
+)
+
+// Server is the EST server. It implements manager.Runnable.
+type Server struct {
+	// Reads issuers and Secrets, writes AdcsRequests
+	Client client.Client
+	// Reads AdcsRequests bypassing the cache
+	Reader        client.Reader
+	Log           logr.Logger
+	IssuerFactory issuers.IssuerFactory
+
+	// Address to listen on e.g. ':8444'
+	Addr string
+	// TLS certificate and key files of the server
+	CertFile string
+	KeyFile  string
+	// Namespace of ClusterAdcsIssuers' Secrets and AdcsRequests
+	ClusterResourceNamespace string
+	// Namespaces whose AdcsIssuers can serve EST labels other than the default one.
+	// Only ClusterAdcsIssuers are served if empty.
+	IssuerNamespaces []string
+	// How long to wait for the certificate before responding with 202 (Accepted)
+	Wait time.Duration
+}
+
+// Issuer serving an EST label
+type estIssuer struct {
+	ref cmmeta.ObjectReference
+	// Namespace of the Secrets and AdcsRequests
+	namespace string
+	policy    *api.ESTPolicy
+}
+
+// Start implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:    s.Addr,
+		Handler: s,
+		// Client certificates are verified with the CAs of the label's issuer
+		TLSConfig: &tls.Config{ClientAuth: tls.RequestClientCert},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		s.Log.Info("Starting EST server", "addr", s.Addr)
+		errs <- server.ListenAndServeTLS(s.CertFile, s.KeyFile)
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errs:
+		return err
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// The server runs in all replicas.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, basePath) {
+		http.NotFound(w, r)
+		return
+	}
+	label, operation := defaultLabel, ""
+	switch parts := strings.Split(strings.TrimPrefix(r.URL.Path, basePath), "/"); len(parts) {
+	case 1:
+		operation = parts[0]
+	case 2:
+		label, operation = parts[0], parts[1]
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	issuer, err := s.findIssuer(r.Context(), label)
+	if err != nil {
+		s.Log.Error(err, "Cannot find issuer", "label", label)
+		http.Error(w, "Cannot find issuer", http.StatusInternalServerError)
+		return
+	}
+	if issuer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case operation == "cacerts" && r.Method == http.MethodGet:
+		s.caCerts(w, r, issuer)
+	case operation == "simpleenroll" && r.Method == http.MethodPost:
+		s.enroll(w, r, label, issuer, false)
+	case operation == "simplereenroll" && r.Method == http.MethodPost:
+		s.enroll(w, r, label, issuer, true)
+	case operation == "cacerts" || operation == "simpleenroll" || operation == "simplereenroll":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Distribute the CA certificates. No authentication is required.
+func (s *Server) caCerts(w http.ResponseWriter, r *http.Request, issuer *estIssuer) {
+	i, err := s.IssuerFactory.GetIssuer(r.Context(), issuer.ref, issuer.namespace)
+	if err != nil {
+		s.Log.Error(err, "Cannot get issuer", "issuer", issuer.ref)
+		http.Error(w, "Issuer not ready", http.StatusServiceUnavailable)
+		return
+	}
+	caCerts, err := i.CACertificates(r.Context())
+	if err != nil {
+		s.Log.Error(err, "Cannot get CA certificates", "issuer", issuer.ref)
+		http.Error(w, "Cannot get CA certificates", http.StatusServiceUnavailable)
+		return
+	}
+	s.writeCertificates(w, caCerts)
+}
+
+// Create AdcsRequest for the CSR (or find the one created by a previous attempt)
+// and wait for the certificate. If it's not issued in time the client is asked to re-try.
+func (s *Server) enroll(w http.ResponseWriter, r *http.Request, label string, issuer *estIssuer, reenroll bool) {
+	user, clientCert, err := s.authenticate(r.Context(), r, issuer)
+	if err != nil {
+		s.Log.Info("EST client not authenticated", "label", label, "reason", err.Error())
+		w.Header().Set("WWW-Authenticate", `Basic realm="EST"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if reenroll && clientCert == nil {
+		http.Error(w, "Re-enrollment requires client certificate authentication", http.StatusForbidden)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "Cannot read request", http.StatusBadRequest)
+		return
+	}
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		http.Error(w, "Cannot decode CSR", http.StatusBadRequest)
+		return
+	}
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	csr, err := pki.DecodeX509CertificateRequestBytes(csrPEM)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid CSR: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+	if reenroll {
+		if err := checkReenroll(csr, clientCert); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := checkClientNames(issuer.policy, user, csr); err != nil {
+		s.Log.Info("EST enrollment not allowed", "label", label, "client", user, "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Re-tried requests are found by the CSR
+	sum := sha256.Sum256(der)
+	key := client.ObjectKey{Namespace: issuer.namespace, Name: "est-" + hex.EncodeToString(sum[:10])}
+	log := s.Log.WithValues("adcsrequest", key, "client", user)
+	ar := new(api.AdcsRequest)
+	err = s.Reader.Get(r.Context(), key, ar)
+	if apierrors.IsNotFound(err) {
+		ar = &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Namespace:   key.Namespace,
+				Labels:      map[string]string{api.ESTLabel: label},
+				Annotations: map[string]string{api.ESTClientAnnotation: user},
+			},
+			Spec: api.AdcsRequestSpec{
+				CSRPEM:    csrPEM,
+				IssuerRef: issuer.ref,
+			},
+		}
+		err = s.Client.Create(r.Context(), ar)
+		if err == nil {
+			log.Info("EST enrollment requested", "label", label)
+		}
+	}
+	if err != nil {
+		log.Error(err, "Cannot get AdcsRequest")
+		http.Error(w, "Cannot process request", http.StatusInternalServerError)
+		return
+	}
+
+	// Wait for the AdcsRequest controller
+	err = wait.PollImmediate(pollInterval, s.Wait, func() (bool, error) {
+		if err := s.Reader.Get(r.Context(), key, ar); err != nil {
+			return false, err
+		}
+		return ar.Status.State != api.Unknown && ar.Status.State != api.Pending, nil
+	})
+	if err != nil && err != wait.ErrWaitTimeout {
+		log.Error(err, "Cannot get AdcsRequest")
+		http.Error(w, "Cannot process request", http.StatusInternalServerError)
+		return
+	}
+
+	switch ar.Status.State {
+	case api.Ready:
+		cert, err := pki.DecodeX509CertificateBytes(ar.Status.Certificate)
+		if err != nil {
+			log.Error(err, "Cannot decode issued certificate")
+			http.Error(w, "Cannot decode issued certificate", http.StatusInternalServerError)
+			return
+		}
+		log.Info("EST enrollment completed", "serialNumber", ar.Status.SerialNumber)
+		s.writeCertificates(w, []*x509.Certificate{cert})
+	case api.Rejected, api.Errored:
+		log.Info("EST enrollment failed", "state", ar.Status.State, "reason", ar.Status.Reason)
+		status := http.StatusBadRequest
+		if ar.Status.State == api.Rejected {
+			status = http.StatusForbidden
+		}
+		http.Error(w, fmt.Sprintf("Request %s: %s", ar.Status.State, ar.Status.Reason), status)
+	default:
+		// RFC 7030 section 4.2.3
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.retryAfter(r.Context(), issuer, ar).Seconds())))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	// Completed requests are removed so that the same CSR can be enrolled again
+	if err := s.Client.Delete(context.Background(), ar); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Cannot delete AdcsRequest")
+	}
+}
+
+// When to re-try the request waiting in the controller or ADCS
+func (s *Server) retryAfter(ctx context.Context, issuer *estIssuer, ar *api.AdcsRequest) time.Duration {
+	if ar.Status.State != api.Pending {
+		return defaultRetryAfter
+	}
+	i, err := s.IssuerFactory.GetIssuer(ctx, issuer.ref, issuer.namespace)
+	if err != nil {
+		return defaultRetryAfter
+	}
+	if d := time.Until(i.NextStatusCheck(ar)); d > minRetryAfter {
+		return d.Round(time.Second)
+	}
+	return minRetryAfter
+}
+
+// Find the issuer with the label in its EST policy
+func (s *Server) findIssuer(ctx context.Context, label string) (*estIssuer, error) {
+	found, err := issuers.FindLabeledIssuer(ctx, s.Client, s.ClusterResourceNamespace, s.IssuerNamespaces, label,
+		func(i *issuers.LabeledIssuer) []string {
+			if i.EST == nil {
+				return nil
+			}
+			return i.EST.Labels
+		})
+	if found == nil || err != nil {
+		return nil, err
+	}
+	return &estIssuer{ref: found.Ref, namespace: found.Namespace, policy: found.EST}, nil
+}
+
+// Respond with PKCS #7 certs-only message (RFC 7030 section 4.1.3)
+func (s *Server) writeCertificates(w http.ResponseWriter, certs []*x509.Certificate) {
+	der, err := issuers.EncodeCertificatesPKCS7(certs)
+	if err != nil {
+		s.Log.Error(err, "Cannot encode certificates")
+		http.Error(w, "Cannot encode certificates", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkcs7-mime; smime-type=certs-only")
+	w.Header().Set("Content-Transfer-Encoding", "base64")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(base64.StdEncoding.EncodeToString(der)))
+}
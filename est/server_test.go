@@ -0,0 +1,65 @@
+package est
+
+import (
+	"context"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func TestFindIssuer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := api.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	policy := func(labels ...string) *api.ESTPolicy { return &api.ESTPolicy{Labels: labels} }
+	objects := []client.Object{
+		&api.ClusterAdcsIssuer{ObjectMeta: metav1.ObjectMeta{Name: "cluster"}, Spec: api.ClusterAdcsIssuerSpec{EST: policy("default", "shared")}},
+		&api.AdcsIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "a"}, Spec: api.AdcsIssuerSpec{EST: policy("default", "shared", "routers", "both")}},
+		&api.AdcsIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "team-b", Name: "b"}, Spec: api.AdcsIssuerSpec{EST: policy("switches", "both")}},
+		&api.AdcsIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "team-c", Name: "c"}, Spec: api.AdcsIssuerSpec{EST: policy("printers")}},
+	}
+	s := &Server{
+		Client:                   fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build(),
+		ClusterResourceNamespace: "adcs-issuer-system",
+		IssuerNamespaces:         []string{"team-a", "team-b"},
+	}
+
+	tests := []struct {
+		label     string
+		kind      string
+		namespace string
+		err       bool
+	}{
+		{label: "default", kind: "ClusterAdcsIssuer", namespace: "adcs-issuer-system"},
+		{label: "shared", kind: "ClusterAdcsIssuer", namespace: "adcs-issuer-system"},
+		{label: "routers", kind: "AdcsIssuer", namespace: "team-a"},
+		{label: "switches", kind: "AdcsIssuer", namespace: "team-b"},
+		// Namespace not allowed
+		{label: "printers"},
+		{label: "both", err: true},
+		{label: "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			issuer, err := s.findIssuer(context.Background(), tt.label)
+			if (err != nil) != tt.err {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if issuer == nil {
+				if tt.kind != "" {
+					t.Errorf("expected %s in %s, got none", tt.kind, tt.namespace)
+				}
+				return
+			}
+			if issuer.ref.Kind != tt.kind || issuer.namespace != tt.namespace {
+				t.Errorf("expected %s in %s, got %s in %s", tt.kind, tt.namespace, issuer.ref.Kind, issuer.namespace)
+			}
+		})
+	}
+}
@@ -4,11 +4,19 @@ go 1.16
 
 require (
 	github.com/Azure/go-ntlmssp v0.0.0-20200615164410-66371956d46c
+	github.com/container-storage-interface/spec v1.3.0
 	github.com/go-logr/logr v0.3.0
 	github.com/golang/glog v0.0.0-20160126235308-23def4e6c14b
 	github.com/jetstack/cert-manager v1.3.1
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
+	github.com/pavel-v-chernykh/keystore-go v2.1.0+incompatible
+	github.com/prometheus/client_golang v1.7.1
+	go.mozilla.org/pkcs7 v0.9.0
+	golang.org/x/time v0.0.0-20200630173020-3af7569d3a1e
+	google.golang.org/grpc v1.28.1
+	gopkg.in/square/go-jose.v2 v2.3.1
+	istio.io/api v0.0.0-20210128181506-0c4b8e54850f
 	k8s.io/api v0.20.2
 	k8s.io/apiextensions-apiserver v0.20.2 // indirect
 	k8s.io/apimachinery v0.20.2
@@ -16,4 +24,5 @@ require (
 	k8s.io/klog v1.0.0
 	k8s.io/utils v0.0.0-20210111153108-fddb29f9d009
 	sigs.k8s.io/controller-runtime v0.8.3
+	software.sslmate.com/src/go-pkcs12 v0.0.0-20200830195227-52f69702a001
 )
@@ -0,0 +1,35 @@
+package issuers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Check if the request namespace is on the list of a rule. '*' matches all namespaces.
+// Rules without namespaces apply to the namespace of an AdcsIssuer only.
+func (i *Issuer) namespaceAllowed(namespaces []string, namespace string) bool {
+	if len(namespaces) == 0 {
+		return i.namespace != "" && i.namespace == namespace
+	}
+	for _, ns := range namespaces {
+		if ns == "*" || ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// Match the value against the patterns where '*' matches any characters.
+// The match is case insensitive as AD names and SIDs are.
+func matchesPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		parts := strings.Split(pattern, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+		if regexp.MustCompile(`(?i)^` + strings.Join(parts, ".*") + `$`).MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
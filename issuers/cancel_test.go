@@ -0,0 +1,101 @@
+package issuers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-logr/logr"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/nokia/adcs-issuer/adcs"
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Certsrv answering with canned responses
+type fakeCertsrv struct {
+	adcs.AdcsCertsrv
+	denyErr error
+	denied  []string
+}
+
+func (s *fakeCertsrv) DenyRequest(id string) error {
+	s.denied = append(s.denied, id)
+	return s.denyErr
+}
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	if err := api.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestCancel(t *testing.T) {
+	tests := []struct {
+		name      string
+		denyErr   error
+		wantErr   bool
+		condition cmmeta.ConditionStatus
+	}{
+		{"denied", nil, false, cmmeta.ConditionTrue},
+		{"page missing", adcs.ErrDenyNotSupported, true, cmmeta.ConditionFalse},
+		{"other version", fmt.Errorf("%w: certdeny.asp version %q found", adcs.ErrDenyNotSupported, "0"), true, cmmeta.ConditionFalse},
+		{"server error", &adcs.StatusError{StatusCode: 500, Status: "500 Internal Server Error"}, true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adcsIssuer := &api.AdcsIssuer{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "adcs"}}
+			certServ := &fakeCertsrv{denyErr: tt.denyErr}
+			issuer := &Issuer{
+				Client:                fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(adcsIssuer).Build(),
+				Log:                   logr.Discard(),
+				kind:                  "AdcsIssuer",
+				namespace:             "team-a",
+				name:                  "adcs",
+				certServ:              certServ,
+				guard:                 getGuard("test/cancel/"+tt.name, nil, &api.CircuitBreakerPolicy{Disabled: true}),
+				CancelPendingRequests: true,
+			}
+			ar := &api.AdcsRequest{Status: api.AdcsRequestStatus{State: api.Pending, Id: "21"}}
+
+			if err := issuer.Cancel(context.Background(), ar); (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(certServ.denied) != 1 || certServ.denied[0] != "21" {
+				t.Errorf("expected request 21 denied, got %v", certServ.denied)
+			}
+
+			if err := issuer.Client.Get(context.Background(), client.ObjectKeyFromObject(adcsIssuer), adcsIssuer); err != nil {
+				t.Fatal(err)
+			}
+			var status cmmeta.ConditionStatus
+			for _, c := range adcsIssuer.Status.Conditions {
+				if c.Type == api.IssuerConditionCancelPendingRequests {
+					status = c.Status
+				}
+			}
+			if status != tt.condition {
+				t.Errorf("expected CancelPendingRequests condition %q, got %q", tt.condition, status)
+			}
+		})
+	}
+}
+
+func TestCancelNotPending(t *testing.T) {
+	certServ := &fakeCertsrv{}
+	issuer := &Issuer{certServ: certServ, CancelPendingRequests: true}
+	for _, status := range []api.AdcsRequestStatus{{State: api.Ready, Id: "21"}, {State: api.Pending}} {
+		if err := issuer.Cancel(context.Background(), &api.AdcsRequest{Status: status}); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	if len(certServ.denied) != 0 {
+		t.Errorf("expected nothing denied, got %v", certServ.denied)
+	}
+}
@@ -0,0 +1,69 @@
+package issuers
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	cms "go.mozilla.org/pkcs7"
+)
+
+// EncodeCertificates PEM encodes the certificates. Unlike pki.EncodeX509Chain it keeps self-signed ones.
+func EncodeCertificates(certs []*x509.Certificate) []byte {
+	var out []byte
+	for _, c := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return out
+}
+
+// EncodeCertificatesPKCS7 returns the certificates as a DER encoded degenerate PKCS #7 SignedData
+// ('certs-only' message, as in a .p7b file).
+func EncodeCertificatesPKCS7(certs []*x509.Certificate) ([]byte, error) {
+	var der []byte
+	for _, c := range certs {
+		der = append(der, c.Raw...)
+	}
+	return cms.DegenerateCertificate(der)
+}
+
+// SplitChain splits the CA certificates into the intermediates (sent with the certificate)
+// and the self-signed roots. If there's no root, the last certificate is used as the CA.
+func SplitChain(caCerts []*x509.Certificate) ([]*x509.Certificate, []*x509.Certificate) {
+	var chain, roots []*x509.Certificate
+	for _, c := range caCerts {
+		if bytes.Equal(c.RawIssuer, c.RawSubject) && c.CheckSignatureFrom(c) == nil {
+			roots = append(roots, c)
+		} else {
+			chain = append(chain, c)
+		}
+	}
+	if len(roots) == 0 && len(chain) > 0 {
+		roots = chain[len(chain)-1:]
+		chain = chain[:len(chain)-1]
+	}
+	return chain, roots
+}
+
+// CertificateChain returns the PEM encoded certificate followed by the intermediate CA certificates
+// as returned by Issue.
+func CertificateChain(cert, ca []byte) ([]byte, error) {
+	caCerts, err := pki.DecodeX509CertificateChainBytes(ca)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode CA certificates: %s", err.Error())
+	}
+	intermediates, _ := SplitChain(caCerts)
+	return append(append([]byte{}, cert...), EncodeCertificates(intermediates)...), nil
+}
+
+// RootCertificates returns the PEM encoded root CA certificates as returned by Issue.
+func RootCertificates(ca []byte) ([]byte, error) {
+	caCerts, err := pki.DecodeX509CertificateChainBytes(ca)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode CA certificates: %s", err.Error())
+	}
+	_, roots := SplitChain(caCerts)
+	return EncodeCertificates(roots), nil
+}
@@ -0,0 +1,94 @@
+package issuers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Reflect the circuit breaker state in the issuer's 'Available' condition
+func (i *Issuer) updateAvailableCondition(ctx context.Context) error {
+	condition := api.IssuerCondition{
+		Type:    api.IssuerConditionAvailable,
+		Status:  cmmeta.ConditionTrue,
+		Reason:  "CircuitBreakerClosed",
+		Message: "Communication with ADCS works",
+	}
+	if open, failures, lastError, openUntil := i.guard.state(); open {
+		condition.Status = cmmeta.ConditionFalse
+		condition.Reason = "CircuitBreakerOpen"
+		condition.Message = fmt.Sprintf("Communication with ADCS paused until %s after %d consecutive failures: %s",
+			openUntil.Format(time.RFC3339), failures, lastError)
+	}
+	return i.setCondition(ctx, condition)
+}
+
+// Reflect in the issuer's 'CancelPendingRequests' condition whether pending requests
+// can be denied on ADCS. Requests that couldn't be denied stay in the CA pending queue.
+func (i *Issuer) updateCancelCondition(ctx context.Context, denyErr error) error {
+	condition := api.IssuerCondition{
+		Type:    api.IssuerConditionCancelPendingRequests,
+		Status:  cmmeta.ConditionTrue,
+		Reason:  "Denied",
+		Message: "Pending requests are denied on ADCS",
+	}
+	if denyErr != nil {
+		condition.Status = cmmeta.ConditionFalse
+		condition.Reason = "DenyNotSupported"
+		condition.Message = fmt.Sprintf("Pending requests are left in the CA pending queue and must be denied manually: %s", denyErr.Error())
+	}
+	return i.setCondition(ctx, condition)
+}
+
+// Set the condition in the status of the issuer object
+func (i *Issuer) setCondition(ctx context.Context, condition api.IssuerCondition) error {
+	switch i.kind {
+	case "AdcsIssuer":
+		issuer := new(api.AdcsIssuer)
+		if err := i.Client.Get(ctx, client.ObjectKey{Namespace: i.namespace, Name: i.name}, issuer); err != nil {
+			return err
+		}
+		if !SetIssuerCondition(&issuer.Status.Conditions, condition) {
+			return nil
+		}
+		return i.Client.Status().Update(ctx, issuer)
+	case "ClusterAdcsIssuer":
+		issuer := new(api.ClusterAdcsIssuer)
+		if err := i.Client.Get(ctx, client.ObjectKey{Name: i.name}, issuer); err != nil {
+			return err
+		}
+		if !SetIssuerCondition(&issuer.Status.Conditions, condition) {
+			return nil
+		}
+		return i.Client.Status().Update(ctx, issuer)
+	}
+	return fmt.Errorf("Unsupported issuer kind %s.", i.kind)
+}
+
+// Replace the condition of the same type. The transition time is changed only if the status changes.
+// Returns false if the condition was already set.
+func SetIssuerCondition(conditions *[]api.IssuerCondition, condition api.IssuerCondition) bool {
+	now := metav1.Now()
+	condition.LastTransitionTime = &now
+	for idx, c := range *conditions {
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == condition.Status {
+			if c.Reason == condition.Reason && c.Message == condition.Message {
+				return false
+			}
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+		(*conditions)[idx] = condition
+		return true
+	}
+	*conditions = append(*conditions, condition)
+	return true
+}
@@ -0,0 +1,91 @@
+package issuers
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	validityPeriodAttribute      = "ValidityPeriod"
+	validityPeriodUnitsAttribute = "ValidityPeriodUnits"
+
+	// ADCS backdates certificates (10 minutes by default) to cope with clock skew
+	durationTolerance = 15 * time.Minute
+)
+
+// Get the certificate duration to request from the ADCS.
+// It's the duration requested in AdcsRequest limited by the issuer's maximum.
+// Zero is returned if no duration was requested.
+func (i *Issuer) requestDuration(ar *api.AdcsRequest) time.Duration {
+	if ar.Spec.Duration == nil || ar.Spec.Duration.Duration <= 0 {
+		return 0
+	}
+	duration := ar.Spec.Duration.Duration
+	if i.MaxDuration > 0 && duration > i.MaxDuration {
+		duration = i.MaxDuration
+	}
+	return duration
+}
+
+// Add 'ValidityPeriod' and 'ValidityPeriodUnits' attributes for the duration.
+// The biggest unit the duration can be expressed in is used. Other durations are
+// rounded down to full hours (at least one) so the issuer's maximum isn't exceeded.
+func addValidityPeriodAttributes(attributes map[string]string, duration time.Duration) {
+	for name := range attributes {
+		if isValidityPeriodAttribute(name) {
+			delete(attributes, name)
+		}
+	}
+	units := []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"Weeks", 7 * 24 * time.Hour},
+		{"Days", 24 * time.Hour},
+		{"Hours", time.Hour},
+	}
+	for _, unit := range units {
+		if duration%unit.duration == 0 {
+			attributes[validityPeriodAttribute] = unit.name
+			attributes[validityPeriodUnitsAttribute] = strconv.FormatInt(int64(duration/unit.duration), 10)
+			return
+		}
+	}
+	hours := int64(duration / time.Hour)
+	if hours < 1 {
+		hours = 1
+	}
+	attributes[validityPeriodAttribute] = "Hours"
+	attributes[validityPeriodUnitsAttribute] = strconv.FormatInt(hours, 10)
+}
+
+func isValidityPeriodAttribute(name string) bool {
+	return strings.EqualFold(name, validityPeriodAttribute) || strings.EqualFold(name, validityPeriodUnitsAttribute)
+}
+
+// Describe the differences between the requested certificate duration and the issued one.
+// Empty string is returned if the certificate was issued as requested.
+func (i *Issuer) durationDifference(ar *api.AdcsRequest, cert *x509.Certificate) string {
+	duration := i.requestDuration(ar)
+	if duration == 0 {
+		return ""
+	}
+	var notes []string
+	if duration != ar.Spec.Duration.Duration {
+		notes = append(notes, fmt.Sprintf("Requested duration %v exceeds the issuer's maximum %v.", ar.Spec.Duration.Duration, duration))
+	}
+	issued := cert.NotAfter.Sub(cert.NotBefore)
+	diff := issued - duration
+	if diff < 0 {
+		diff = -diff
+	}
+	if diff > durationTolerance {
+		notes = append(notes, fmt.Sprintf("ADCS issued the certificate for %v instead of %v.", issued.Round(time.Minute), duration))
+	}
+	return strings.Join(notes, " ")
+}
@@ -0,0 +1,157 @@
+package issuers
+
+import (
+	"crypto/x509"
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func durationRequest(d time.Duration, attributes map[string]string) *api.AdcsRequest {
+	ar := &api.AdcsRequest{Spec: api.AdcsRequestSpec{Attributes: attributes}}
+	if d != 0 {
+		ar.Spec.Duration = &metav1.Duration{Duration: d}
+	}
+	return ar
+}
+
+func TestRequestDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		max      time.Duration
+		duration time.Duration
+		want     time.Duration
+	}{
+		{"not requested", 0, 0, 0},
+		{"no maximum", 0, 48 * time.Hour, 48 * time.Hour},
+		{"below maximum", 72 * time.Hour, 48 * time.Hour, 48 * time.Hour},
+		{"limited by maximum", 24 * time.Hour, 48 * time.Hour, 24 * time.Hour},
+		{"negative", 0, -time.Hour, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{MaxDuration: tt.max}
+			if got := i.requestDuration(durationRequest(tt.duration, nil)); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAddValidityPeriodAttributes(t *testing.T) {
+	tests := []struct {
+		name       string
+		attributes map[string]string
+		duration   time.Duration
+		want       map[string]string
+	}{
+		{"weeks", map[string]string{}, 14 * 24 * time.Hour, map[string]string{"ValidityPeriod": "Weeks", "ValidityPeriodUnits": "2"}},
+		{"days", map[string]string{}, 3 * 24 * time.Hour, map[string]string{"ValidityPeriod": "Days", "ValidityPeriodUnits": "3"}},
+		{"hours", map[string]string{}, 5 * time.Hour, map[string]string{"ValidityPeriod": "Hours", "ValidityPeriodUnits": "5"}},
+		{"rounded down", map[string]string{}, 90 * time.Minute, map[string]string{"ValidityPeriod": "Hours", "ValidityPeriodUnits": "1"}},
+		{"at least an hour", map[string]string{}, 30 * time.Minute, map[string]string{"ValidityPeriod": "Hours", "ValidityPeriodUnits": "1"}},
+		{"replaces existing in any case",
+			map[string]string{"validityperiod": "Years", "VALIDITYPERIODUNITS": "5", "san": "dns=a"},
+			24 * time.Hour,
+			map[string]string{"ValidityPeriod": "Days", "ValidityPeriodUnits": "1", "san": "dns=a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addValidityPeriodAttributes(tt.attributes, tt.duration)
+			if !reflect.DeepEqual(tt.attributes, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, tt.attributes)
+			}
+		})
+	}
+}
+
+func TestRequestAttributesDuration(t *testing.T) {
+	tests := []struct {
+		name       string
+		issuer     Issuer
+		duration   time.Duration
+		attributes map[string]string
+		want       map[string]string
+		wantErr    bool
+	}{
+		{
+			name:     "duration replaces issuer default",
+			issuer:   Issuer{DefaultAttributes: map[string]string{"ValidityPeriod": "Years", "ValidityPeriodUnits": "2"}},
+			duration: 24 * time.Hour,
+			want:     map[string]string{"ValidityPeriod": "Days", "ValidityPeriodUnits": "1"},
+		},
+		{
+			name:       "duration replaces allowed request attributes",
+			issuer:     Issuer{AllowedAttributes: []string{"ValidityPeriod", "ValidityPeriodUnits"}},
+			duration:   24 * time.Hour,
+			attributes: map[string]string{"validityperiod": "Years", "ValidityPeriodUnits": "5"},
+			want:       map[string]string{"ValidityPeriod": "Days", "ValidityPeriodUnits": "1"},
+		},
+		{
+			name:       "request attributes without maximum",
+			issuer:     Issuer{AllowedAttributes: []string{"ValidityPeriod", "ValidityPeriodUnits"}},
+			attributes: map[string]string{"ValidityPeriod": "Years", "ValidityPeriodUnits": "5"},
+			want:       map[string]string{"ValidityPeriod": "Years", "ValidityPeriodUnits": "5"},
+		},
+		{
+			name:       "request attributes can't bypass maximum",
+			issuer:     Issuer{MaxDuration: 24 * time.Hour, AllowedAttributes: []string{"ValidityPeriod", "ValidityPeriodUnits"}},
+			duration:   24 * time.Hour,
+			attributes: map[string]string{"ValidityPeriodUnits": "500"},
+			wantErr:    true,
+		},
+		{
+			name:     "duration limited by maximum",
+			issuer:   Issuer{MaxDuration: 7 * 24 * time.Hour},
+			duration: 365 * 24 * time.Hour,
+			want:     map[string]string{"ValidityPeriod": "Weeks", "ValidityPeriodUnits": "1"},
+		},
+		{
+			name:     "maximum not exceeded by rounding",
+			issuer:   Issuer{MaxDuration: 90 * time.Minute},
+			duration: 24 * time.Hour,
+			want:     map[string]string{"ValidityPeriod": "Hours", "ValidityPeriodUnits": "1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.issuer.requestAttributes(durationRequest(tt.duration, tt.attributes))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDurationDifference(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		max      time.Duration
+		duration time.Duration
+		issued   time.Duration
+		want     string
+	}{
+		{"not requested", 0, 0, time.Hour, ""},
+		{"as requested", 0, 24 * time.Hour, 24 * time.Hour, ""},
+		{"backdated within tolerance", 0, 24 * time.Hour, 24*time.Hour + 10*time.Minute, ""},
+		{"template validity", 0, 24 * time.Hour, 365 * 24 * time.Hour, "ADCS issued the certificate for 8760h0m0s instead of 24h0m0s."},
+		{"limited by maximum", 24 * time.Hour, 48 * time.Hour, 24 * time.Hour, "Requested duration 48h0m0s exceeds the issuer's maximum 24h0m0s."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{MaxDuration: tt.max}
+			cert := &x509.Certificate{NotBefore: now, NotAfter: now.Add(tt.issued)}
+			if got := i.durationDifference(durationRequest(tt.duration, nil), cert); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
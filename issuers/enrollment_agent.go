@@ -0,0 +1,89 @@
+package issuers
+
+import (
+	"context"
+	"encoding/asn1"
+	"fmt"
+	"unicode/utf16"
+
+	cms "go.mozilla.org/pkcs7"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const requesterNameAttribute = "RequesterName"
+
+// szOID_ENROLLMENT_NAME_VALUE_PAIR
+var oidEnrollmentNameValuePair = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 13, 2, 1}
+
+// EnrollmentNameValuePair ::= SEQUENCE { name BMPString, value BMPString }
+type enrollmentNameValuePair struct {
+	Name  asn1.RawValue
+	Value asn1.RawValue
+}
+
+// Get the account the certificate is requested on behalf of.
+// Empty string is returned if the issuer has no enrollment agent.
+func (i *Issuer) requesterName(ar *api.AdcsRequest) (string, error) {
+	if ar.Spec.RequesterName != "" {
+		if !i.requesterNameAllowed(ar.Spec.RequesterName, ar.Namespace) {
+			return "", fmt.Errorf("Request requester name %s is not allowed by the issuer in namespace %s", ar.Spec.RequesterName, ar.Namespace)
+		}
+		return ar.Spec.RequesterName, nil
+	}
+	if i.EnrollmentAgent == nil {
+		return "", nil
+	}
+	if i.EnrollmentAgent.RequesterName == "" {
+		return "", fmt.Errorf("Requester name is required by the issuer's enrollment agent")
+	}
+	return i.EnrollmentAgent.RequesterName, nil
+}
+
+// Check if a rule of the issuer's enrollment agent allows the requester name in the namespace
+func (i *Issuer) requesterNameAllowed(name string, namespace string) bool {
+	if i.EnrollmentAgent == nil {
+		return false
+	}
+	for _, rule := range i.EnrollmentAgent.AllowedRequesterNames {
+		if i.namespaceAllowed(rule.Namespaces, namespace) && matchesPattern(rule.Names, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the request sent to the ADCS. Renewals are signed with the existing certificate
+// if the issuer signs renewals. Otherwise the CSR is signed by the enrollment agent
+// with the requester name attribute if the issuer has one.
+func (i *Issuer) requestData(ctx context.Context, ar *api.AdcsRequest) (string, error) {
+	name, err := i.requesterName(ar)
+	if err != nil {
+		return "", err
+	}
+	if signer := i.renewalSigner(ctx, ar); signer != nil {
+		if name != "" {
+			i.Log.Info("Renewal signed with the existing certificate instead of the enrollment agent", "requester", name)
+		}
+		return signRenewal(ar, signer)
+	}
+	if name == "" {
+		return string(ar.Spec.CSRPEM), nil
+	}
+	attribute := cms.Attribute{
+		Type: oidEnrollmentNameValuePair,
+		Value: enrollmentNameValuePair{
+			Name:  bmpString(requesterNameAttribute),
+			Value: bmpString(name),
+		},
+	}
+	return signRequest(ar.Spec.CSRPEM, i.enrollmentAgent, attribute)
+}
+
+func bmpString(s string) asn1.RawValue {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(c>>8), byte(c))
+	}
+	return asn1.RawValue{Tag: asn1.TagBMPString, Bytes: b}
+}
@@ -0,0 +1,192 @@
+package issuers
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/go-logr/logr"
+	cms "go.mozilla.org/pkcs7"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func encodeTestKey(t *testing.T, key interface{}) []byte {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func TestGetRequestSigner(t *testing.T) {
+	ca, caKey := testutil.NewCA(t, "Issuing CA", nil, nil)
+	key := testutil.NewKey(t)
+	cert := testutil.NewCertificate(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Enrollment Agent"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}, &key.PublicKey, ca, caKey)
+	certPEM := EncodeCertificates([]*x509.Certificate{cert, ca})
+	keyPEM := encodeTestKey(t, key)
+
+	tests := []struct {
+		name  string
+		data  map[string][]byte
+		chain int
+	}{
+		{"certificate and key", map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM}, 2},
+		{"other key", map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: encodeTestKey(t, testutil.NewKey(t))}, 0},
+		{"no certificate", map[string][]byte{corev1.TLSPrivateKeyKey: keyPEM}, 0},
+		{"no key", map[string][]byte{corev1.TLSCertKey: certPEM}, 0},
+		{"invalid key", map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: []byte("not PEM")}, 0},
+		{"missing Secret", nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := fake.NewClientBuilder().WithScheme(scheme.Scheme)
+			if tt.data != nil {
+				builder = builder.WithObjects(&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "enrollment-agent"},
+					Data:       tt.data,
+				})
+			}
+			factory := &IssuerFactory{Client: builder.Build()}
+			signer, err := factory.getRequestSigner(context.Background(), "enrollment-agent", "team-a")
+			if tt.chain == 0 {
+				if err == nil {
+					t.Errorf("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(signer.chain) != tt.chain || !signer.chain[0].Equal(cert) {
+				t.Errorf("expected chain of %d starting with the agent certificate, got %d", tt.chain, len(signer.chain))
+			}
+		})
+	}
+}
+
+func TestRequesterName(t *testing.T) {
+	allowed := func(namespaces []string, names ...string) []api.RequesterNameRule {
+		return []api.RequesterNameRule{{Namespaces: namespaces, Names: names}}
+	}
+	tests := []struct {
+		name      string
+		kind      string
+		agent     *api.EnrollmentAgentPolicy
+		requester string
+		expected  string
+		ok        bool
+	}{
+		{"no agent", "AdcsIssuer", nil, "", "", true},
+		{"issuer requester", "AdcsIssuer", &api.EnrollmentAgentPolicy{RequesterName: `EXAMPLE\svc`}, "", `EXAMPLE\svc`, true},
+		{"no requester", "AdcsIssuer", &api.EnrollmentAgentPolicy{}, "", "", false},
+		{"request requester", "AdcsIssuer", &api.EnrollmentAgentPolicy{RequesterName: `EXAMPLE\svc`, AllowedRequesterNames: allowed(nil, `EXAMPLE\user`)},
+			`EXAMPLE\user`, `EXAMPLE\user`, true},
+		{"request requester pattern", "ClusterAdcsIssuer", &api.EnrollmentAgentPolicy{AllowedRequesterNames: allowed([]string{"team-a"}, `example\kiosk-*`)},
+			`EXAMPLE\kiosk-7`, `EXAMPLE\kiosk-7`, true},
+		{"request requester in all namespaces", "ClusterAdcsIssuer", &api.EnrollmentAgentPolicy{AllowedRequesterNames: allowed([]string{"*"}, `EXAMPLE\user`)},
+			`EXAMPLE\user`, `EXAMPLE\user`, true},
+		{"request requester not allowed", "AdcsIssuer", &api.EnrollmentAgentPolicy{RequesterName: `EXAMPLE\svc`}, `EXAMPLE\user`, "", false},
+		{"other requester", "AdcsIssuer", &api.EnrollmentAgentPolicy{AllowedRequesterNames: allowed(nil, `EXAMPLE\kiosk-*`)}, `EXAMPLE\Administrator`, "", false},
+		{"other namespace", "ClusterAdcsIssuer", &api.EnrollmentAgentPolicy{AllowedRequesterNames: allowed([]string{"team-b"}, `EXAMPLE\user`)},
+			`EXAMPLE\user`, "", false},
+		{"cluster issuer rule without namespaces", "ClusterAdcsIssuer", &api.EnrollmentAgentPolicy{AllowedRequesterNames: allowed(nil, `EXAMPLE\user`)},
+			`EXAMPLE\user`, "", false},
+		{"request requester without agent", "AdcsIssuer", nil, `EXAMPLE\user`, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := &Issuer{kind: tt.kind, EnrollmentAgent: tt.agent}
+			if tt.kind == "AdcsIssuer" {
+				issuer.namespace = "team-a"
+			}
+			name, err := issuer.requesterName(&api.AdcsRequest{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"},
+				Spec:       api.AdcsRequestSpec{RequesterName: tt.requester},
+			})
+			if (err == nil) != tt.ok {
+				t.Fatalf("expected ok %t, got %v", tt.ok, err)
+			}
+			if name != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestRequestData(t *testing.T) {
+	ca, caKey := testutil.NewCA(t, "Issuing CA", nil, nil)
+	agentKey := testutil.NewKey(t)
+	agentCert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "Enrollment Agent"}}, &agentKey.PublicKey, ca, caKey)
+	csrPEM := testutil.NewCSR(t, testutil.NewKey(t), &x509.CertificateRequest{Subject: pkix.Name{CommonName: "user"}})
+	csrBlock, _ := pem.Decode(csrPEM)
+	ar := &api.AdcsRequest{Spec: api.AdcsRequestSpec{CSRPEM: csrPEM}}
+
+	t.Run("no agent", func(t *testing.T) {
+		issuer := &Issuer{Log: logr.Discard()}
+		data, err := issuer.requestData(context.Background(), ar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data != string(csrPEM) {
+			t.Errorf("expected the CSR sent as is, got %s", data)
+		}
+	})
+
+	t.Run("on behalf of", func(t *testing.T) {
+		issuer := &Issuer{
+			Log:             logr.Discard(),
+			EnrollmentAgent: &api.EnrollmentAgentPolicy{RequesterName: `EXAMPLE\user`},
+			enrollmentAgent: &requestSigner{chain: []*x509.Certificate{agentCert, ca}, key: agentKey},
+		}
+		data, err := issuer.requestData(context.Background(), ar)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block, _ := pem.Decode([]byte(data))
+		if block == nil || block.Type != "PKCS7" {
+			t.Fatalf("expected PKCS7 PEM, got %s", data)
+		}
+		p7, err := cms.Parse(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p7.Verify(); err != nil {
+			t.Fatalf("invalid signature: %s", err.Error())
+		}
+		if string(p7.Content) != string(csrBlock.Bytes) {
+			t.Errorf("signed content is not the CSR")
+		}
+		if signer := p7.GetOnlySigner(); signer == nil || !signer.Equal(agentCert) {
+			t.Errorf("expected signed by the enrollment agent")
+		}
+
+		var pair enrollmentNameValuePair
+		if err := p7.UnmarshalSignedAttribute(oidEnrollmentNameValuePair, &pair); err != nil {
+			t.Fatal(err)
+		}
+		if name, value := decodeBMPString(pair.Name), decodeBMPString(pair.Value); name != requesterNameAttribute || value != `EXAMPLE\user` {
+			t.Errorf("expected %s=EXAMPLE\\user, got %s=%s", requesterNameAttribute, name, value)
+		}
+	})
+}
+
+func decodeBMPString(v asn1.RawValue) string {
+	var u []uint16
+	for i := 0; i+1 < len(v.Bytes); i += 2 {
+		u = append(u, uint16(v.Bytes[i])<<8|uint16(v.Bytes[i+1]))
+	}
+	return string(utf16.Decode(u))
+}
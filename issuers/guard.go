@@ -0,0 +1,197 @@
+package issuers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/nokia/adcs-issuer/adcs"
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	defaultFailureThreshold = 5
+	defaultOpenDuration     = 10 * time.Minute
+	// How long to wait for a free slot when MaxInFlight calls are in progress
+	inFlightRetryAfter = 5 * time.Second
+)
+
+// Returned instead of calling ADCS when the issuer's rate limits
+// or the circuit breaker don't allow it.
+type ThrottledError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s. Will be re-tried in %v", e.Reason, e.RetryAfter.Round(time.Second))
+}
+
+// Guards are kept for the whole controller lifetime as Issuers are created for each request.
+var guards = struct {
+	sync.Mutex
+	byIssuer map[string]*guard
+}{byIssuer: map[string]*guard{}}
+
+// Rate limits and circuit breaker of a single issuer
+type guard struct {
+	sync.Mutex
+	limiter  *rate.Limiter
+	inFlight chan struct{}
+
+	failureThreshold int
+	openDuration     time.Duration
+	failures         int
+	openUntil        time.Time
+	lastError        string
+}
+
+// Get the guard of the issuer configured with its current policies
+func getGuard(key string, rateLimit *api.RateLimitPolicy, breaker *api.CircuitBreakerPolicy) *guard {
+	guards.Lock()
+	g, ok := guards.byIssuer[key]
+	if !ok {
+		g = &guard{}
+		guards.byIssuer[key] = g
+	}
+	guards.Unlock()
+
+	g.configure(rateLimit, breaker)
+	return g
+}
+
+func (g *guard) configure(rateLimit *api.RateLimitPolicy, breaker *api.CircuitBreakerPolicy) {
+	g.Lock()
+	defer g.Unlock()
+
+	if rateLimit == nil {
+		rateLimit = &api.RateLimitPolicy{}
+	}
+	if rateLimit.RequestsPerMinute > 0 {
+		limit := rate.Limit(float64(rateLimit.RequestsPerMinute) / 60)
+		burst := int(rateLimit.Burst)
+		if burst < 1 {
+			burst = 1
+		}
+		if g.limiter == nil {
+			g.limiter = rate.NewLimiter(limit, burst)
+		} else {
+			g.limiter.SetLimit(limit)
+			g.limiter.SetBurst(burst)
+		}
+	} else {
+		g.limiter = nil
+	}
+	if rateLimit.MaxInFlight > 0 {
+		if g.inFlight == nil || cap(g.inFlight) != int(rateLimit.MaxInFlight) {
+			// Calls in progress release the old channel
+			g.inFlight = make(chan struct{}, rateLimit.MaxInFlight)
+		}
+	} else {
+		g.inFlight = nil
+	}
+
+	g.failureThreshold = 0
+	if breaker == nil || !breaker.Disabled {
+		g.failureThreshold = defaultFailureThreshold
+		g.openDuration = defaultOpenDuration
+		if breaker != nil && breaker.FailureThreshold > 0 {
+			g.failureThreshold = int(breaker.FailureThreshold)
+		}
+		if breaker != nil && breaker.OpenDuration != "" {
+			if d, err := time.ParseDuration(breaker.OpenDuration); err == nil && d > 0 {
+				g.openDuration = d
+			}
+		}
+	}
+}
+
+// Get permission to call the ADCS. Submissions of new requests are subject to the rate limit.
+// The returned function must be called when the call is done.
+func (g *guard) acquire(submission bool) (func(), error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if wait := time.Until(g.openUntil); g.failureThreshold > 0 && wait > 0 {
+		return nil, &ThrottledError{
+			Reason:     fmt.Sprintf("Circuit breaker open after %d consecutive failures (%s)", g.failures, g.lastError),
+			RetryAfter: wait,
+		}
+	}
+
+	inFlight := g.inFlight
+	if inFlight != nil {
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			return nil, &ThrottledError{
+				Reason:     fmt.Sprintf("Maximum of %d calls to ADCS in progress", cap(inFlight)),
+				RetryAfter: inFlightRetryAfter,
+			}
+		}
+	}
+	release := func() {
+		if inFlight != nil {
+			<-inFlight
+		}
+	}
+
+	if submission && g.limiter != nil {
+		reservation := g.limiter.Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			release()
+			return nil, &ThrottledError{Reason: "Submission rate limit reached", RetryAfter: delay}
+		}
+	}
+	return release, nil
+}
+
+// Record result of the ADCS call. Returns true if the circuit breaker state changed.
+func (g *guard) record(err error) bool {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.failureThreshold == 0 {
+		return false
+	}
+	wasOpen := g.failures >= g.failureThreshold
+	if !breakerFailure(err) {
+		g.failures = 0
+		g.lastError = ""
+		g.openUntil = time.Time{}
+		return wasOpen
+	}
+	g.failures++
+	g.lastError = err.Error()
+	if g.failures >= g.failureThreshold {
+		// Opened again after each failed attempt
+		g.openUntil = time.Now().Add(g.openDuration)
+	}
+	return !wasOpen && g.failures >= g.failureThreshold
+}
+
+// Get the circuit breaker state
+func (g *guard) state() (open bool, failures int, lastError string, openUntil time.Time) {
+	g.Lock()
+	defer g.Unlock()
+	return g.failureThreshold > 0 && g.failures >= g.failureThreshold, g.failures, g.lastError, g.openUntil
+}
+
+// Authentication failures, server errors and unreachable server count for the circuit breaker.
+// Other errors are related to the particular request.
+func breakerFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var statusErr *adcs.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == 401 || statusErr.StatusCode == 403 || statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,195 @@
+package issuers
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nokia/adcs-issuer/adcs"
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+var (
+	errUnavailable = &adcs.StatusError{StatusCode: 503, Status: "503 Service Unavailable"}
+	errBadRequest  = &adcs.StatusError{StatusCode: 400, Status: "400 Bad Request"}
+)
+
+func TestBreakerFailure(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"success", nil, false},
+		{"unauthorized", &adcs.StatusError{StatusCode: 401, Status: "401 Unauthorized"}, true},
+		{"forbidden", &adcs.StatusError{StatusCode: 403, Status: "403 Forbidden"}, true},
+		{"server error", errUnavailable, true},
+		{"bad request", errBadRequest, false},
+		{"not found", &adcs.StatusError{StatusCode: 404, Status: "404 Not Found"}, false},
+		{"unreachable", &url.Error{Op: "Post", URL: "https://adcs.example.com", Err: errors.New("connection refused")}, true},
+		{"request error", errors.New("Cannot decode CSR"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := breakerFailure(tt.err); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGuardCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker *api.CircuitBreakerPolicy
+		results []error
+		// Expected return of record for each result
+		changed []bool
+		open    bool
+	}{
+		{
+			name:    "opens after default threshold",
+			results: []error{errUnavailable, errUnavailable, errUnavailable, errUnavailable, errUnavailable},
+			changed: []bool{false, false, false, false, true},
+			open:    true,
+		},
+		{
+			name:    "custom threshold",
+			breaker: &api.CircuitBreakerPolicy{FailureThreshold: 2},
+			results: []error{errUnavailable, errUnavailable, errUnavailable},
+			changed: []bool{false, true, false},
+			open:    true,
+		},
+		{
+			name:    "success resets failures",
+			breaker: &api.CircuitBreakerPolicy{FailureThreshold: 2},
+			results: []error{errUnavailable, nil, errUnavailable},
+			changed: []bool{false, false, false},
+		},
+		{
+			name:    "success closes",
+			breaker: &api.CircuitBreakerPolicy{FailureThreshold: 1},
+			results: []error{errUnavailable, nil},
+			changed: []bool{true, true},
+		},
+		{
+			name:    "request errors don't count",
+			breaker: &api.CircuitBreakerPolicy{FailureThreshold: 1},
+			results: []error{errBadRequest, errBadRequest},
+			changed: []bool{false, false},
+		},
+		{
+			name:    "disabled",
+			breaker: &api.CircuitBreakerPolicy{Disabled: true, FailureThreshold: 1},
+			results: []error{errUnavailable, errUnavailable},
+			changed: []bool{false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &guard{}
+			g.configure(nil, tt.breaker)
+			for n, err := range tt.results {
+				if got := g.record(err); got != tt.changed[n] {
+					t.Errorf("result %d: expected state change %t, got %t", n, tt.changed[n], got)
+				}
+			}
+			if open, _, _, _ := g.state(); open != tt.open {
+				t.Errorf("expected open %t, got %t", tt.open, open)
+			}
+			_, err := g.acquire(false)
+			var throttled *ThrottledError
+			if errors.As(err, &throttled) != tt.open {
+				t.Errorf("unexpected acquire result: %v", err)
+			}
+		})
+	}
+}
+
+func TestGuardOpenDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		breaker *api.CircuitBreakerPolicy
+		want    time.Duration
+	}{
+		{"default", &api.CircuitBreakerPolicy{FailureThreshold: 1}, defaultOpenDuration},
+		{"custom", &api.CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: "1m"}, time.Minute},
+		{"invalid", &api.CircuitBreakerPolicy{FailureThreshold: 1, OpenDuration: "soon"}, defaultOpenDuration},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &guard{}
+			g.configure(nil, tt.breaker)
+			g.record(errUnavailable)
+			_, err := g.acquire(false)
+			var throttled *ThrottledError
+			if !errors.As(err, &throttled) {
+				t.Fatalf("expected ThrottledError, got %v", err)
+			}
+			if throttled.RetryAfter > tt.want || throttled.RetryAfter < tt.want-time.Second {
+				t.Errorf("expected retry after %v, got %v", tt.want, throttled.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestGuardRateLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		rateLimit  *api.RateLimitPolicy
+		submission bool
+		allowed    int
+	}{
+		{"not limited", nil, true, 10},
+		{"single submission per burst", &api.RateLimitPolicy{RequestsPerMinute: 1}, true, 1},
+		{"burst", &api.RateLimitPolicy{RequestsPerMinute: 1, Burst: 3}, true, 3},
+		{"status checks not limited", &api.RateLimitPolicy{RequestsPerMinute: 1}, false, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &guard{}
+			g.configure(tt.rateLimit, nil)
+			allowed := 0
+			for n := 0; n < 10; n++ {
+				release, err := g.acquire(tt.submission)
+				if err != nil {
+					var throttled *ThrottledError
+					if !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+						t.Fatalf("unexpected error: %v", err)
+					}
+					continue
+				}
+				release()
+				allowed++
+			}
+			if allowed != tt.allowed {
+				t.Errorf("expected %d calls allowed, got %d", tt.allowed, allowed)
+			}
+		})
+	}
+}
+
+func TestGuardMaxInFlight(t *testing.T) {
+	g := &guard{}
+	g.configure(&api.RateLimitPolicy{MaxInFlight: 2}, nil)
+
+	first, err := g.acquire(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := g.acquire(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.acquire(false); err == nil {
+		t.Fatal("expected third call to be throttled")
+	}
+	first()
+	third, err := g.acquire(false)
+	if err != nil {
+		t.Fatalf("expected call allowed after release, got %v", err)
+	}
+	second()
+	third()
+}
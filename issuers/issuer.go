@@ -2,27 +2,59 @@ package issuers
 
 import (
 	"context"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	"github.com/go-logr/logr"
 	//cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
 	//cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
-	//metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
 	"github.com/nokia/adcs-issuer/adcs"
 	api "github.com/nokia/adcs-issuer/api/v1"
 )
 
-const (
-	adcsCertTemplate = "BasicSSLWebServer"
-)
+var hresultExp = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
 
 type Issuer struct {
 	client.Client
-	certServ            adcs.AdcsCertsrv
+	Log logr.Logger
+	// The issuer object
+	kind      string
+	name      string
+	namespace string
+
+	certServ        adcs.AdcsCertsrv
+	guard           *guard
+	enrollmentAgent *requestSigner
+	Mode            api.IssuerMode
+	// CA certificates the uploaded certificates must chain to in the manual mode
+	ManualCABundle      []byte
 	RetryInterval       time.Duration
 	StatusCheckInterval time.Duration
+	// Status check interval right after submission
+	InitialStatusCheckInterval time.Duration
+	OnRejection                api.RejectionPolicy
+	CancelPendingRequests      bool
+	MaxDuration                time.Duration
+	Template                   string
+	TemplateRules              []api.TemplateRule
+	DefaultAttributes          map[string]string
+	AllowedAttributes          []string
+	SID                        *api.SIDPolicy
+	EnrollmentAgent            *api.EnrollmentAgentPolicy
+	SignRenewals               bool
+	SubCA                      *api.SubCAPolicy
+	Verification               *api.VerificationPolicy
+	Suspend                    bool
+	MaintenanceWindows         []api.MaintenanceWindow
 }
 
 // Go to ADCS for a certificate. If current status is 'Pending' then
@@ -34,6 +66,14 @@ func (i *Issuer) Issue(ctx context.Context, ar *api.AdcsRequest) ([]byte, []byte
 	var desc string
 	var id string
 	var err error
+	if ar.Status.State == api.Unknown || ar.Status.State == api.Pending {
+		if s := i.Suspension(time.Now()); s.Suspended {
+			return nil, nil, &SuspendedError{s}
+		}
+	}
+	if i.Mode == api.IssuerModeManual {
+		return i.issueManually(ctx, ar)
+	}
 	if ar.Status.State != api.Unknown {
 		// Of all the statuses only Pending requires processing.
 		// All others are final
@@ -42,19 +82,45 @@ func (i *Issuer) Issue(ctx context.Context, ar *api.AdcsRequest) ([]byte, []byte
 			if ar.Status.Id == "" {
 				return nil, nil, fmt.Errorf("ADCS ID not set.")
 			}
+			release, guardErr := i.guard.acquire(false)
+			if guardErr != nil {
+				return nil, nil, guardErr
+			}
+			defer release()
 			adcsResponseStatus, desc, id, err = i.certServ.GetExistingCertificate(ar.Status.Id)
+			i.record(ctx, err)
 		} else {
 			// Nothing to do
 			return nil, nil, nil
 		}
 	} else {
 		// New request
-		adcsResponseStatus, desc, id, err = i.certServ.RequestCertificate(string(ar.Spec.CSRPEM), adcsCertTemplate)
+		template, tmplErr := i.selectTemplate(ar)
+		attributes, attrErr := i.requestAttributes(ar)
+		request, reqErr := i.requestData(ctx, ar)
+		if tmplErr != nil || attrErr != nil || reqErr != nil {
+			// The request is not acceptable for this issuer. There's no point in sending it.
+			ar.Status.State = api.Errored
+			ar.Status.Reason = fmt.Sprint(firstError(tmplErr, attrErr, reqErr))
+			return nil, nil, nil
+		}
+		release, guardErr := i.guard.acquire(true)
+		if guardErr != nil {
+			return nil, nil, guardErr
+		}
+		defer release()
+		adcsResponseStatus, desc, id, err = i.certServ.RequestCertificate(request, template, attributes)
+		i.record(ctx, err)
 	}
 	if err != nil {
 		// This is a local error
 		return nil, nil, err
 	}
+	now := metav1.Now()
+	ar.Status.LastCheckTime = &now
+	if ar.Status.SubmittedTime == nil && ar.Status.State == api.Unknown {
+		ar.Status.SubmittedTime = &now
+	}
 
 	var cert []byte
 	switch adcsResponseStatus {
@@ -66,7 +132,9 @@ func (i *Issuer) Issue(ctx context.Context, ar *api.AdcsRequest) ([]byte, []byte
 	case adcs.Ready:
 		// Certificate obtained successfully
 		ar.Status.State = api.Ready
-		ar.Status.Id = id
+		if id != "" {
+			ar.Status.Id = id
+		}
 		ar.Status.Reason = ""
 		cert = []byte(desc)
 	case adcs.Rejected:
@@ -81,11 +149,189 @@ func (i *Issuer) Issue(ctx context.Context, ar *api.AdcsRequest) ([]byte, []byte
 		ar.Status.Reason = desc
 	}
 
+	if ar.Status.State != api.Ready {
+		ar.Status.HResult = hresult(desc)
+	}
+
 	ca, err := i.certServ.GetCaCertificateChain()
+	i.record(ctx, err)
 	if err != nil {
 		return nil, nil, err
 	}
+	if caCerts, err := decodeCertificates([]byte(ca)); err == nil && len(caCerts) > 0 {
+		ar.Status.CA = issuingCA(caCerts).Subject.CommonName
+	}
+
+	if ar.Status.State == api.Ready {
+		if err := i.checkCertificate(ar, cert, []byte(ca)); err != nil {
+			// The certificate can't be used
+			ar.Status.State = api.Errored
+			ar.Status.Reason = err.Error()
+			return nil, nil, nil
+		}
+	}
 
 	return cert, []byte(ca), nil
 
 }
+
+// Deny the pending request on ADCS. It's used when the request is no longer needed.
+// Nothing is done unless the issuer has CancelPendingRequests set.
+func (i *Issuer) Cancel(ctx context.Context, ar *api.AdcsRequest) error {
+	if !i.CancelPendingRequests {
+		return nil
+	}
+	if ar.Status.State != api.Pending || ar.Status.Id == "" {
+		// Nothing waits in the ADCS queue
+		return nil
+	}
+	release, err := i.guard.acquire(false)
+	if err != nil {
+		return err
+	}
+	defer release()
+	err = i.certServ.DenyRequest(ar.Status.Id)
+	i.record(ctx, err)
+	if err == nil || errors.Is(err, adcs.ErrDenyNotSupported) {
+		if err := i.updateCancelCondition(ctx, err); err != nil {
+			i.Log.Error(err, "Cannot update issuer condition")
+		}
+	}
+	return err
+}
+
+// Get the CA certificate chain from the ADCS.
+func (i *Issuer) CACertificates(ctx context.Context) ([]*x509.Certificate, error) {
+	if i.Mode == api.IssuerModeManual {
+		return nil, fmt.Errorf("CA certificates not available in manual mode")
+	}
+	release, err := i.guard.acquire(false)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	ca, err := i.certServ.GetCaCertificateChain()
+	i.record(ctx, err)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := decodeCertificates([]byte(ca))
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("No CA certificates received from ADCS")
+	}
+	return certs, nil
+}
+
+// Record result of the ADCS call for the circuit breaker
+func (i *Issuer) record(ctx context.Context, err error) {
+	if i.guard.record(err) {
+		if err := i.updateAvailableCondition(ctx); err != nil {
+			i.Log.Error(err, "Cannot update issuer condition")
+		}
+	}
+}
+
+// Check the issued certificate and record its details in the request status.
+// Returned error describes why the certificate is not acceptable.
+func (i *Issuer) checkCertificate(ar *api.AdcsRequest, cert []byte, ca []byte) error {
+	x509Cert, err := pki.DecodeX509CertificateBytes(cert)
+	if err != nil {
+		return fmt.Errorf("Cannot decode issued certificate: %s", err.Error())
+	}
+	ar.Status.SerialNumber = fmt.Sprintf("%x", x509Cert.SerialNumber)
+	ar.Status.HResult = ""
+	ar.Status.CA = x509Cert.Issuer.CommonName
+	notAfter := metav1.NewTime(x509Cert.NotAfter)
+	ar.Status.NotAfter = &notAfter
+
+	notes, err := i.verifyCertificate(ar, x509Cert, ca)
+	if err != nil {
+		return fmt.Errorf("Issued certificate %s rejected: %s", ar.Status.SerialNumber, err.Error())
+	}
+	if sid, _ := i.requestSID(ar); sid != "" && i.SID.Verify && !certificateHasSID(x509Cert, sid) {
+		// The certificate is useless for strong mapping
+		return fmt.Errorf("Issued certificate %s doesn't carry SID %s", ar.Status.SerialNumber, sid)
+	}
+	if ar.Spec.IsCA && i.SubCA != nil {
+		if err := i.checkCACertificate(x509Cert); err != nil {
+			return err
+		}
+	}
+	if diff := i.durationDifference(ar, x509Cert); diff != "" {
+		notes = append(notes, diff)
+	}
+	ar.Status.Reason = strings.Join(notes, " ")
+	return nil
+}
+
+// Get the status code reported by ADCS e.g. '0x80094014'.
+// It's the last one in the description as it comes from the 'LastStatus' part.
+func hresult(desc string) string {
+	found := hresultExp.FindAllString(desc, -1)
+	if len(found) == 0 {
+		return ""
+	}
+	return found[len(found)-1]
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge issuer's default attributes with the ones set in the request.
+// Request attributes must be on the issuer's allowed list.
+func (i *Issuer) requestAttributes(ar *api.AdcsRequest) (map[string]string, error) {
+	attributes := make(map[string]string, len(i.DefaultAttributes)+len(ar.Spec.Attributes))
+	for name, value := range i.DefaultAttributes {
+		attributes[name] = value
+	}
+	for name, value := range ar.Spec.Attributes {
+		if !i.attributeAllowed(name) {
+			return nil, fmt.Errorf("Request attribute %s is not allowed by the issuer", name)
+		}
+		if i.MaxDuration > 0 && isValidityPeriodAttribute(name) {
+			// It would bypass the issuer's maximum duration
+			return nil, fmt.Errorf("Request attribute %s is not allowed, the duration is limited by the issuer", name)
+		}
+		if strings.ContainsAny(name, ":\r\n") || strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("Invalid request attribute %s", name)
+		}
+		// Attribute names are case-insensitive so the request value replaces the default one
+		for defName := range attributes {
+			if strings.EqualFold(defName, name) {
+				delete(attributes, defName)
+			}
+		}
+		attributes[name] = value
+	}
+	// The requested duration (limited by the issuer) replaces the validity period attributes
+	if duration := i.requestDuration(ar); duration > 0 {
+		addValidityPeriodAttributes(attributes, duration)
+	}
+
+	sid, err := i.requestSID(ar)
+	if err != nil {
+		return nil, err
+	}
+	if sid != "" {
+		i.addSIDAttribute(attributes, sid)
+	}
+	return attributes, nil
+}
+
+func (i *Issuer) attributeAllowed(name string) bool {
+	for _, allowed := range i.AllowedAttributes {
+		if strings.EqualFold(allowed, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -18,8 +18,10 @@ import (
 )
 
 const (
-	defaultStatusCheckInterval = "6h"
-	defaultRetryInterval       = "1h"
+	defaultStatusCheckInterval        = "6h"
+	defaultInitialStatusCheckInterval = "1m"
+	defaultRetryInterval              = "1h"
+	defaultTemplate                   = "BasicSSLWebServer"
 )
 
 type IssuerFactory struct {
@@ -50,40 +52,69 @@ func (f *IssuerFactory) getAdcsIssuer(ctx context.Context, key client.ObjectKey)
 	}
 	// TODO: add checking issuer status
 
-	username, password, err := f.getUserPassword(ctx, issuer.Spec.CredentialsRef.Name, issuer.Namespace)
-	if err != nil {
-		return nil, err
-	}
-
-	certs := issuer.Spec.CABundle
-	if len(certs) == 0 {
-		return nil, fmt.Errorf("CA Bundle required")
-	}
-
-	caCertPool := x509.NewCertPool()
-	ok := caCertPool.AppendCertsFromPEM(certs)
-	if ok == false {
-		return nil, fmt.Errorf("error loading ADCS CA bundle")
+	// Certificates are uploaded to the requests in the manual mode
+	var certServ adcs.AdcsCertsrv
+	var err error
+	if issuer.Spec.Mode != api.IssuerModeManual {
+		certServ, err = f.newCertsrv(ctx, issuer.Spec.URL, issuer.Spec.CredentialsRef.Name, issuer.Spec.CABundle, issuer.Namespace)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	certServ, err := adcs.NewNtlmCertsrv(issuer.Spec.URL, username, password, caCertPool, false)
-	if err != nil {
-		return nil, err
+	var enrollmentAgent *requestSigner
+	if issuer.Spec.EnrollmentAgent != nil {
+		enrollmentAgent, err = f.getRequestSigner(ctx, issuer.Spec.EnrollmentAgent.SecretRef, issuer.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot get enrollment agent: %s", err.Error())
+		}
 	}
 
 	statusCheckInterval := getInterval(
 		issuer.Spec.StatusCheckInterval,
 		defaultStatusCheckInterval,
 		log.WithValues("interval", "statusCheckInterval"))
+	initialStatusCheckInterval := getInterval(
+		issuer.Spec.InitialStatusCheckInterval,
+		defaultInitialStatusCheckInterval,
+		log.WithValues("interval", "initialStatusCheckInterval"))
 	retryInterval := getInterval(
 		issuer.Spec.RetryInterval,
 		defaultRetryInterval,
 		log.WithValues("interval", "retryInterval"))
+	maxDuration := getMaxDuration(issuer.Spec.MaxDuration, log)
+	template := issuer.Spec.Template
+	if template == "" {
+		template = defaultTemplate
+	}
 	return &Issuer{
-		f.Client,
-		certServ,
-		retryInterval,
-		statusCheckInterval,
+		Client:                     f.Client,
+		Log:                        log,
+		kind:                       "AdcsIssuer",
+		name:                       issuer.Name,
+		namespace:                  issuer.Namespace,
+		certServ:                   certServ,
+		Mode:                       issuer.Spec.Mode,
+		ManualCABundle:             manualCABundle(issuer.Spec.Manual),
+		enrollmentAgent:            enrollmentAgent,
+		guard:                      getGuard("adcsissuer/"+issuer.Namespace+"/"+issuer.Name, issuer.Spec.RateLimit, issuer.Spec.CircuitBreaker),
+		RetryInterval:              retryInterval,
+		StatusCheckInterval:        statusCheckInterval,
+		InitialStatusCheckInterval: initialStatusCheckInterval,
+		OnRejection:                issuer.Spec.OnRejection,
+		CancelPendingRequests:      issuer.Spec.CancelPendingRequests,
+		MaxDuration:                maxDuration,
+		Template:                   template,
+		TemplateRules:              issuer.Spec.TemplateRules,
+		DefaultAttributes:          issuer.Spec.DefaultAttributes,
+		AllowedAttributes:          issuer.Spec.AllowedAttributes,
+		SID:                        issuer.Spec.SID,
+		EnrollmentAgent:            issuer.Spec.EnrollmentAgent,
+		SignRenewals:               issuer.Spec.SignRenewals,
+		SubCA:                      issuer.Spec.SubCA,
+		Verification:               issuer.Spec.Verification,
+		Suspend:                    issuer.Spec.Suspend,
+		MaintenanceWindows:         issuer.Spec.MaintenanceWindows,
 	}, nil
 }
 
@@ -98,40 +129,68 @@ func (f *IssuerFactory) getClusterAdcsIssuer(ctx context.Context, key client.Obj
 	}
 	// TODO: add checking issuer status
 
-	username, password, err := f.getUserPassword(ctx, issuer.Spec.CredentialsRef.Name, f.ClusterResourceNamespace)
-	if err != nil {
-		return nil, err
-	}
-
-	certs := issuer.Spec.CABundle
-	if len(certs) == 0 {
-		return nil, fmt.Errorf("CA Bundle required")
-	}
-
-	caCertPool := x509.NewCertPool()
-	ok := caCertPool.AppendCertsFromPEM(certs)
-	if ok == false {
-		return nil, fmt.Errorf("error loading ADCS CA bundle")
+	// Certificates are uploaded to the requests in the manual mode
+	var certServ adcs.AdcsCertsrv
+	var err error
+	if issuer.Spec.Mode != api.IssuerModeManual {
+		certServ, err = f.newCertsrv(ctx, issuer.Spec.URL, issuer.Spec.CredentialsRef.Name, issuer.Spec.CABundle, f.ClusterResourceNamespace)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	certServ, err := adcs.NewNtlmCertsrv(issuer.Spec.URL, username, password, caCertPool, false)
-	if err != nil {
-		return nil, err
+	var enrollmentAgent *requestSigner
+	if issuer.Spec.EnrollmentAgent != nil {
+		enrollmentAgent, err = f.getRequestSigner(ctx, issuer.Spec.EnrollmentAgent.SecretRef, f.ClusterResourceNamespace)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot get enrollment agent: %s", err.Error())
+		}
 	}
 
 	statusCheckInterval := getInterval(
 		issuer.Spec.StatusCheckInterval,
 		defaultStatusCheckInterval,
 		log.WithValues("interval", "statusCheckInterval"))
+	initialStatusCheckInterval := getInterval(
+		issuer.Spec.InitialStatusCheckInterval,
+		defaultInitialStatusCheckInterval,
+		log.WithValues("interval", "initialStatusCheckInterval"))
 	retryInterval := getInterval(
 		issuer.Spec.RetryInterval,
 		defaultRetryInterval,
 		log.WithValues("interval", "retryInterval"))
+	maxDuration := getMaxDuration(issuer.Spec.MaxDuration, log)
+	template := issuer.Spec.Template
+	if template == "" {
+		template = defaultTemplate
+	}
 	return &Issuer{
-		f.Client,
-		certServ,
-		retryInterval,
-		statusCheckInterval,
+		Client:                     f.Client,
+		Log:                        log,
+		kind:                       "ClusterAdcsIssuer",
+		name:                       issuer.Name,
+		certServ:                   certServ,
+		Mode:                       issuer.Spec.Mode,
+		ManualCABundle:             manualCABundle(issuer.Spec.Manual),
+		enrollmentAgent:            enrollmentAgent,
+		guard:                      getGuard("clusteradcsissuer/"+issuer.Name, issuer.Spec.RateLimit, issuer.Spec.CircuitBreaker),
+		RetryInterval:              retryInterval,
+		StatusCheckInterval:        statusCheckInterval,
+		InitialStatusCheckInterval: initialStatusCheckInterval,
+		OnRejection:                issuer.Spec.OnRejection,
+		CancelPendingRequests:      issuer.Spec.CancelPendingRequests,
+		MaxDuration:                maxDuration,
+		Template:                   template,
+		TemplateRules:              issuer.Spec.TemplateRules,
+		DefaultAttributes:          issuer.Spec.DefaultAttributes,
+		AllowedAttributes:          issuer.Spec.AllowedAttributes,
+		SID:                        issuer.Spec.SID,
+		EnrollmentAgent:            issuer.Spec.EnrollmentAgent,
+		SignRenewals:               issuer.Spec.SignRenewals,
+		SubCA:                      issuer.Spec.SubCA,
+		Verification:               issuer.Spec.Verification,
+		Suspend:                    issuer.Spec.Suspend,
+		MaintenanceWindows:         issuer.Spec.MaintenanceWindows,
 	}, nil
 }
 
@@ -150,8 +209,41 @@ func getInterval(specValue string, def string, log logr.Logger) time.Duration {
 	return interval
 }
 
+// Zero means that the duration is not limited
+func getMaxDuration(specValue string, log logr.Logger) time.Duration {
+	if specValue == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(specValue)
+	if err != nil {
+		log.Error(err, "Cannot parse max duration. Not limiting.")
+		return 0
+	}
+	return d
+}
+
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
+// Create ADCS client
+func (f *IssuerFactory) newCertsrv(ctx context.Context, url string, credentialsName string, caBundle []byte, namespace string) (adcs.AdcsCertsrv, error) {
+	username, password, err := f.getUserPassword(ctx, credentialsName, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(caBundle) == 0 {
+		return nil, fmt.Errorf("CA Bundle required")
+	}
+
+	caCertPool := x509.NewCertPool()
+	ok := caCertPool.AppendCertsFromPEM(caBundle)
+	if ok == false {
+		return nil, fmt.Errorf("error loading ADCS CA bundle")
+	}
+
+	return adcs.NewNtlmCertsrv(url, username, password, caCertPool, false)
+}
+
 func (f *IssuerFactory) getUserPassword(ctx context.Context, secretName string, namespace string) (string, string, error) {
 	secret := new(corev1.Secret)
 	if err := f.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: secretName}, secret); err != nil {
@@ -165,3 +257,11 @@ func (f *IssuerFactory) getUserPassword(ctx context.Context, secretName string,
 	}
 	return string(secret.Data["username"]), string(secret.Data["password"]), nil
 }
+
+// Trust bundle of the manual mode. Nothing is trusted without it.
+func manualCABundle(policy *api.ManualPolicy) []byte {
+	if policy == nil {
+		return nil
+	}
+	return policy.CABundle
+}
@@ -0,0 +1,81 @@
+package issuers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// LabeledIssuer is the issuer serving an EST or SCEP label
+type LabeledIssuer struct {
+	Ref cmmeta.ObjectReference
+	// Namespace of the Secrets and AdcsRequests
+	Namespace string
+	EST       *api.ESTPolicy
+	SCEP      *api.SCEPPolicy
+}
+
+// FindLabeledIssuer finds the issuer with the label in the policy whose labels are
+// returned by labels. Cluster issuers take precedence. AdcsIssuers are served only
+// in the allowed namespaces, not on the default label and only if no other
+// AdcsIssuer has the label. Returns nil if no issuer serves the label.
+func FindLabeledIssuer(ctx context.Context, c client.Reader, clusterResourceNamespace string, namespaces []string,
+	label string, labels func(*LabeledIssuer) []string) (*LabeledIssuer, error) {
+	clusterIssuers := new(api.ClusterAdcsIssuerList)
+	if err := c.List(ctx, clusterIssuers); err != nil {
+		return nil, err
+	}
+	sort.Slice(clusterIssuers.Items, func(i, j int) bool { return clusterIssuers.Items[i].Name < clusterIssuers.Items[j].Name })
+	for _, issuer := range clusterIssuers.Items {
+		found := &LabeledIssuer{
+			Ref:       cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "ClusterAdcsIssuer", Name: issuer.Name},
+			Namespace: clusterResourceNamespace,
+			EST:       issuer.Spec.EST,
+			SCEP:      issuer.Spec.SCEP,
+		}
+		if hasLabel(labels(found), label) {
+			return found, nil
+		}
+	}
+	if label == api.DefaultLabel {
+		return nil, nil
+	}
+
+	var found *LabeledIssuer
+	for _, namespace := range namespaces {
+		adcsIssuers := new(api.AdcsIssuerList)
+		if err := c.List(ctx, adcsIssuers, client.InNamespace(namespace)); err != nil {
+			return nil, err
+		}
+		for _, issuer := range adcsIssuers.Items {
+			i := &LabeledIssuer{
+				Ref:       cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "AdcsIssuer", Name: issuer.Name},
+				Namespace: issuer.Namespace,
+				EST:       issuer.Spec.EST,
+				SCEP:      issuer.Spec.SCEP,
+			}
+			if !hasLabel(labels(i), label) {
+				continue
+			}
+			if found != nil {
+				return nil, fmt.Errorf("Label %s is used by AdcsIssuers %s/%s and %s/%s", label, found.Namespace, found.Ref.Name, issuer.Namespace, issuer.Name)
+			}
+			found = i
+		}
+	}
+	return found, nil
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package issuers
+
+import (
+	"context"
+	"fmt"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Issue the certificate in the manual mode. New requests wait in the 'Pending' state
+// with the CSR in their status until a CA officer uploads the certificate signed offline.
+// The uploaded certificate is checked as if it was issued by the ADCS and it must
+// chain to the trust bundle of the issuer.
+func (i *Issuer) issueManually(ctx context.Context, ar *api.AdcsRequest) ([]byte, []byte, error) {
+	switch ar.Status.State {
+	case api.Unknown:
+		template, err := i.selectTemplate(ar)
+		if err != nil {
+			// The request is not acceptable for this issuer
+			ar.Status.State = api.Errored
+			ar.Status.Reason = err.Error()
+			return nil, nil, nil
+		}
+		submitted := metav1.Now()
+		ar.Status.State = api.Pending
+		ar.Status.SubmittedTime = &submitted
+		ar.Status.CSR = string(ar.Spec.CSRPEM)
+		ar.Status.Reason = fmt.Sprintf("Waiting for the certificate (template %s) to be uploaded", template)
+	case api.Pending:
+	default:
+		// Nothing to do
+		return nil, nil, nil
+	}
+	now := metav1.Now()
+	ar.Status.LastCheckTime = &now
+
+	cert, ca, err := i.uploadedCertificate(ctx, ar)
+	if err != nil {
+		// This is a local error
+		return nil, nil, err
+	}
+	if cert == nil {
+		return nil, nil, nil
+	}
+
+	ar.Status.State = api.Ready
+	ar.Status.CSR = ""
+	err = i.checkCertificate(ar, cert, ca)
+	if err == nil {
+		err = i.checkTrust(ar, cert, ca)
+	}
+	if err != nil {
+		// The certificate can't be used
+		ar.Status.State = api.Errored
+		ar.Status.Reason = err.Error()
+		return nil, nil, nil
+	}
+	return cert, ca, nil
+}
+
+// Check that the uploaded certificate chains to the issuer's trust bundle.
+// Unlike the ADCS response the upload itself can't be trusted.
+func (i *Issuer) checkTrust(ar *api.AdcsRequest, cert []byte, ca []byte) error {
+	x509Cert, err := pki.DecodeX509CertificateBytes(cert)
+	if err != nil {
+		return fmt.Errorf("Cannot decode issued certificate: %s", err.Error())
+	}
+	if err := verifyTrust(x509Cert, ca, i.ManualCABundle); err != nil {
+		return fmt.Errorf("Issued certificate %s rejected: %s", ar.Status.SerialNumber, err.Error())
+	}
+	return nil
+}
+
+// Check if the signed certificate was uploaded to the request in the manual mode.
+func (i *Issuer) certificateUploaded(ar *api.AdcsRequest) bool {
+	if i.Mode != api.IssuerModeManual {
+		return false
+	}
+	_, annotation := ar.Annotations[api.IssuedCertificateAnnotation]
+	_, secret := ar.Annotations[api.IssuedCertificateSecretAnnotation]
+	return annotation || secret
+}
+
+// Get the certificate and the CA certificate chain uploaded to the request.
+// Nil certificate is returned if nothing was uploaded yet.
+func (i *Issuer) uploadedCertificate(ctx context.Context, ar *api.AdcsRequest) ([]byte, []byte, error) {
+	var data, ca []byte
+	if value, ok := ar.Annotations[api.IssuedCertificateAnnotation]; ok {
+		data = []byte(value)
+	} else if name, ok := ar.Annotations[api.IssuedCertificateSecretAnnotation]; ok {
+		secret := new(corev1.Secret)
+		err := i.Client.Get(ctx, client.ObjectKey{Namespace: ar.Namespace, Name: name}, secret)
+		if apierrors.IsNotFound(err) {
+			ar.Status.Reason = fmt.Sprintf("Waiting for the certificate Secret %s", name)
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		data = secret.Data[corev1.TLSCertKey]
+		ca = secret.Data[cmmeta.TLSCAKey]
+	} else {
+		return nil, nil, nil
+	}
+
+	chain, err := pki.DecodeX509CertificateChainBytes(data)
+	if err != nil {
+		ar.Status.State = api.Errored
+		ar.Status.Reason = fmt.Sprintf("Cannot decode uploaded certificate: %s", err.Error())
+		return nil, nil, nil
+	}
+	// The certificate may be followed by its chain
+	ca = append(EncodeCertificates(chain[1:]), ca...)
+	return EncodeCertificates(chain[:1]), ca, nil
+}
@@ -0,0 +1,127 @@
+package issuers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func TestIssueManually(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	intermediate, intermediateKey := testutil.NewCA(t, "Issuing CA", root, rootKey)
+	other, otherKey := testutil.NewCA(t, "Other CA", nil, nil)
+	key := testutil.NewKey(t)
+	csrPEM := testutil.NewCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "host.example.com"}})
+	newCert := func(parent *x509.Certificate, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+		return testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "host.example.com"}}, &key.PublicKey, parent, parentKey)
+	}
+	cert := newCert(intermediate, intermediateKey)
+	untrusted := newCert(other, otherKey)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host-signed"},
+		Data: map[string][]byte{
+			corev1.TLSCertKey: EncodeCertificates([]*x509.Certificate{cert}),
+			cmmeta.TLSCAKey:   EncodeCertificates([]*x509.Certificate{intermediate, root}),
+		},
+	}
+	issuer := &Issuer{
+		Client:         fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(secret).Build(),
+		Log:            logr.Discard(),
+		Mode:           api.IssuerModeManual,
+		Template:       "WebServer",
+		ManualCABundle: EncodeCertificates([]*x509.Certificate{root}),
+	}
+	submitted := metav1.Now()
+	pending := api.AdcsRequestStatus{State: api.Pending, CSR: string(csrPEM), SubmittedTime: &submitted}
+
+	tests := []struct {
+		name        string
+		status      api.AdcsRequestStatus
+		annotations map[string]string
+		state       api.State
+		reason      string
+		issued      bool
+	}{
+		{"new request", api.AdcsRequestStatus{}, nil, api.Pending, "template WebServer", false},
+		{"certificate annotation", pending, map[string]string{
+			api.IssuedCertificateAnnotation: string(EncodeCertificates([]*x509.Certificate{cert, intermediate})),
+		}, api.Ready, "", true},
+		{"certificate Secret", pending, map[string]string{api.IssuedCertificateSecretAnnotation: secret.Name}, api.Ready, "", true},
+		{"missing Secret", pending, map[string]string{api.IssuedCertificateSecretAnnotation: "other"}, api.Pending, "Waiting for the certificate Secret other", false},
+		{"invalid PEM", pending, map[string]string{api.IssuedCertificateAnnotation: "not PEM"}, api.Errored, "Cannot decode uploaded certificate", false},
+		{"other key", pending, map[string]string{
+			api.IssuedCertificateAnnotation: string(EncodeCertificates([]*x509.Certificate{intermediate, root})),
+		}, api.Errored, "public key doesn't match the CSR", false},
+		{"not chaining to the bundle", pending, map[string]string{
+			api.IssuedCertificateAnnotation: string(EncodeCertificates([]*x509.Certificate{untrusted, other})),
+		}, api.Errored, "doesn't chain to the trusted CAs", false},
+		{"done", api.AdcsRequestStatus{State: api.Errored, Reason: "failed"}, map[string]string{
+			api.IssuedCertificateAnnotation: string(EncodeCertificates([]*x509.Certificate{cert, intermediate})),
+		}, api.Errored, "failed", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &api.AdcsRequest{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host", Annotations: tt.annotations},
+				Spec:       api.AdcsRequestSpec{CSRPEM: csrPEM},
+				Status:     tt.status,
+			}
+			issued, ca, err := issuer.issueManually(context.Background(), ar)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ar.Status.State != tt.state {
+				t.Fatalf("expected %s, got %s: %s", tt.state, ar.Status.State, ar.Status.Reason)
+			}
+			if !strings.Contains(ar.Status.Reason, tt.reason) {
+				t.Errorf("expected reason with %q, got %q", tt.reason, ar.Status.Reason)
+			}
+			if (issued != nil) != tt.issued {
+				t.Fatalf("expected issued %t, got %d bytes", tt.issued, len(issued))
+			}
+			switch ar.Status.State {
+			case api.Pending:
+				if ar.Status.CSR != string(csrPEM) {
+					t.Errorf("expected CSR in status, got %q", ar.Status.CSR)
+				}
+				if ar.Status.SubmittedTime == nil {
+					t.Errorf("expected submission time in status")
+				}
+			case api.Ready:
+				if ar.Status.CSR != "" {
+					t.Errorf("expected CSR removed from status")
+				}
+				if string(issued) != string(EncodeCertificates([]*x509.Certificate{cert})) {
+					t.Errorf("expected the uploaded certificate")
+				}
+				if !strings.Contains(string(ca), string(EncodeCertificates([]*x509.Certificate{intermediate}))) {
+					t.Errorf("expected the intermediate in the CA certificates")
+				}
+			}
+		})
+	}
+
+	t.Run("no template", func(t *testing.T) {
+		issuer := &Issuer{Mode: api.IssuerModeManual, TemplateRules: []api.TemplateRule{{Template: "SubCA", IsCA: true}}}
+		ar := &api.AdcsRequest{Spec: api.AdcsRequestSpec{CSRPEM: csrPEM}}
+		if _, _, err := issuer.issueManually(context.Background(), ar); err != nil {
+			t.Fatal(err)
+		}
+		if ar.Status.State != api.Errored || ar.Status.CSR != "" {
+			t.Errorf("expected Errored without CSR, got %s", ar.Status.State)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package issuers
+
+import (
+	"context"
+	"crypto"
+	"encoding/asn1"
+	"fmt"
+	"time"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	cms "go.mozilla.org/pkcs7"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// szOID_RENEWAL_CERTIFICATE
+var oidRenewalCertificate = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 13, 1}
+
+// Get the existing certificate and key the renewal is signed with. Nil is returned
+// if the request is not a renewal or the existing certificate can't be used
+// (e.g. it has expired). Such requests are sent unsigned.
+// Only renewals of CertificateRequests are signed and only if the CSR is for the
+// existing key or the Certificate's next private key, so that the existing key
+// can't be used to sign requests for other keys.
+func (i *Issuer) renewalSigner(ctx context.Context, ar *api.AdcsRequest) *requestSigner {
+	if !i.SignRenewals || ar.Spec.RenewalSecretName == "" {
+		return nil
+	}
+	log := i.Log.WithValues("secret", ar.Spec.RenewalSecretName)
+	if !ownedByCertificateRequest(ar) {
+		log.Info("Renewal Secret ignored, the AdcsRequest is not controlled by a CertificateRequest")
+		return nil
+	}
+	factory := IssuerFactory{Client: i.Client}
+	signer, err := factory.getRequestSigner(ctx, ar.Spec.RenewalSecretName, ar.Namespace)
+	if err == nil {
+		now := time.Now()
+		if cert := signer.chain[0]; now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			err = fmt.Errorf("Existing certificate not valid at %v", now)
+		}
+	}
+	if err == nil {
+		err = i.checkRenewalKey(ctx, ar, signer)
+	}
+	if err != nil {
+		log.Info("Renewal not signed with the existing certificate", "reason", err.Error())
+		return nil
+	}
+	return signer
+}
+
+// Sign the renewal with the existing certificate. The certificate is also sent in
+// the renewal certificate attribute.
+func signRenewal(ar *api.AdcsRequest, signer *requestSigner) (string, error) {
+	attribute := cms.Attribute{
+		Type:  oidRenewalCertificate,
+		Value: asn1.RawValue{FullBytes: signer.chain[0].Raw},
+	}
+	return signRequest(ar.Spec.CSRPEM, signer, attribute)
+}
+
+func ownedByCertificateRequest(ar *api.AdcsRequest) bool {
+	owner := metav1.GetControllerOf(ar)
+	if owner == nil || owner.Kind != cmapi.CertificateRequestKind {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	return err == nil && gv.Group == cmapi.SchemeGroupVersion.Group
+}
+
+// The CSR must be for the key of the existing certificate or, with rotationPolicy Always,
+// for the new key cert-manager generated for the Certificate. CMC renewals can be for
+// a new key, the existing certificate only authenticates the requester.
+func (i *Issuer) checkRenewalKey(ctx context.Context, ar *api.AdcsRequest, signer *requestSigner) error {
+	csr, err := pki.DecodeX509CertificateRequestBytes(ar.Spec.CSRPEM)
+	if err != nil {
+		return fmt.Errorf("Cannot decode CSR: %s", err.Error())
+	}
+	if matches, err := pki.PublicKeyMatchesCSR(signer.key.Public(), csr); err == nil && matches {
+		return nil
+	}
+	key, err := i.nextPrivateKey(ctx, ar)
+	if err != nil {
+		return fmt.Errorf("CSR public key doesn't match the existing key: %s", err.Error())
+	}
+	if matches, err := pki.PublicKeyMatchesCSR(key.Public(), csr); err != nil || !matches {
+		return fmt.Errorf("CSR public key doesn't match the existing key nor the next private key")
+	}
+	return nil
+}
+
+// Get the next private key cert-manager keeps in a Secret while the Certificate is
+// being issued. The CertificateRequest of the AdcsRequest and the Certificate of the
+// CertificateRequest must be their controllers (matching UIDs).
+func (i *Issuer) nextPrivateKey(ctx context.Context, ar *api.AdcsRequest) (crypto.Signer, error) {
+	owner := metav1.GetControllerOf(ar)
+	cr := new(cmapi.CertificateRequest)
+	if err := i.Client.Get(ctx, client.ObjectKey{Namespace: ar.Namespace, Name: owner.Name}, cr); err != nil {
+		return nil, fmt.Errorf("Cannot get CertificateRequest %s: %s", owner.Name, err.Error())
+	}
+	if cr.UID != owner.UID {
+		return nil, fmt.Errorf("CertificateRequest %s not found", owner.Name)
+	}
+	owner = metav1.GetControllerOf(cr)
+	if owner == nil || owner.Kind != cmapi.CertificateKind {
+		return nil, fmt.Errorf("CertificateRequest %s is not controlled by a Certificate", cr.Name)
+	}
+	crt := new(cmapi.Certificate)
+	if err := i.Client.Get(ctx, client.ObjectKey{Namespace: ar.Namespace, Name: owner.Name}, crt); err != nil {
+		return nil, fmt.Errorf("Cannot get Certificate %s: %s", owner.Name, err.Error())
+	}
+	if crt.UID != owner.UID {
+		return nil, fmt.Errorf("Certificate %s not found", owner.Name)
+	}
+	if crt.Status.NextPrivateKeySecretName == nil {
+		return nil, fmt.Errorf("Certificate %s has no next private key", crt.Name)
+	}
+	secret := new(corev1.Secret)
+	if err := i.Client.Get(ctx, client.ObjectKey{Namespace: ar.Namespace, Name: *crt.Status.NextPrivateKeySecretName}, secret); err != nil {
+		return nil, fmt.Errorf("Cannot get next private key: %s", err.Error())
+	}
+	key, err := pki.DecodePrivateKeyBytes(secret.Data[corev1.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode next private key: %s", err.Error())
+	}
+	return key, nil
+}
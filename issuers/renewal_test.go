@@ -0,0 +1,102 @@
+package issuers
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"testing"
+
+	"github.com/go-logr/logr"
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func TestRenewalSigner(t *testing.T) {
+	key := testutil.NewKey(t)
+	cert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "host.example.com"}}, &key.PublicKey, nil, key)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host-tls"},
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		},
+	}
+	nextKey := testutil.NewKey(t)
+	nextKeyDER, err := x509.MarshalECPrivateKey(nextKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nextKeySecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host-next"},
+		Data: map[string][]byte{
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: nextKeyDER}),
+		},
+	}
+	isController := true
+	crt := &cmapi.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host", UID: "certificate-uid"},
+		Spec:       cmapi.CertificateSpec{SecretName: secret.Name},
+		Status:     cmapi.CertificateStatus{NextPrivateKeySecretName: &nextKeySecret.Name},
+	}
+	cr := &cmapi.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host-1", UID: "certificate-request-uid",
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "cert-manager.io/v1", Kind: cmapi.CertificateKind, Name: crt.Name, UID: crt.UID, Controller: &isController}},
+		},
+	}
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmapi.AddToScheme(s); err != nil {
+		t.Fatal(err)
+	}
+	issuer := &Issuer{
+		Client:       fake.NewClientBuilder().WithScheme(s).WithObjects(secret, nextKeySecret, crt, cr).Build(),
+		Log:          logr.Discard(),
+		SignRenewals: true,
+	}
+
+	certificateRequest := metav1.OwnerReference{APIVersion: "cert-manager.io/v1", Kind: cmapi.CertificateRequestKind, Name: cr.Name, UID: cr.UID, Controller: &isController}
+	otherCertificateRequest := metav1.OwnerReference{APIVersion: "cert-manager.io/v1", Kind: cmapi.CertificateRequestKind, Name: cr.Name, UID: "other-uid", Controller: &isController}
+	sameKeyCSR := testutil.NewCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "host.example.com"}})
+	nextKeyCSR := testutil.NewCSR(t, nextKey, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "host.example.com"}})
+	otherKeyCSR := testutil.NewCSR(t, testutil.NewKey(t), &x509.CertificateRequest{Subject: pkix.Name{CommonName: "host.example.com"}})
+
+	tests := []struct {
+		name   string
+		owners []metav1.OwnerReference
+		csr    []byte
+		signed bool
+	}{
+		{"renewal", []metav1.OwnerReference{certificateRequest}, sameKeyCSR, true},
+		{"next private key", []metav1.OwnerReference{certificateRequest}, nextKeyCSR, true},
+		{"other key", []metav1.OwnerReference{certificateRequest}, otherKeyCSR, false},
+		{"next private key of other request", []metav1.OwnerReference{otherCertificateRequest}, nextKeyCSR, false},
+		{"not owned", nil, sameKeyCSR, false},
+		{"other owner", []metav1.OwnerReference{{APIVersion: "adcs.certmanager.csf.nokia.com/v1", Kind: "AcmeOrder", Name: "host", Controller: &isController}}, sameKeyCSR, false},
+		{"other group", []metav1.OwnerReference{{APIVersion: "example.com/v1", Kind: cmapi.CertificateRequestKind, Name: "host", Controller: &isController}}, sameKeyCSR, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &api.AdcsRequest{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "host", OwnerReferences: tt.owners},
+				Spec:       api.AdcsRequestSpec{CSRPEM: tt.csr, RenewalSecretName: secret.Name},
+			}
+			if signer := issuer.renewalSigner(context.Background(), ar); (signer != nil) != tt.signed {
+				t.Errorf("expected signed %t, got %v", tt.signed, signer)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package issuers
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"regexp"
+	"strings"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	// Prefix of the SAN URL used for strong certificate mapping
+	sidUrlPrefix = "tag:microsoft.com,2022-09-14:sid:"
+)
+
+var (
+	// szOID_NTDS_CA_SECURITY_EXT
+	oidNtdsCaSecurityExt = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 25, 2}
+	// szOID_NTDS_OBJECTSID
+	oidNtdsObjectSid = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 25, 2, 1}
+
+	sidFormat = regexp.MustCompile(`^S-1-[0-9]+(-[0-9]+)+$`)
+)
+
+// OtherName carrying the SID in the security extension
+type sidOtherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  []byte `asn1:"explicit,tag:0"`
+}
+
+// Get the SID the certificate should be issued for.
+// Empty string is returned if the issuer doesn't use strong mapping.
+func (i *Issuer) requestSID(ar *api.AdcsRequest) (string, error) {
+	if ar.Spec.SID != "" {
+		if !sidFormat.MatchString(ar.Spec.SID) {
+			return "", fmt.Errorf("Invalid request SID %s", ar.Spec.SID)
+		}
+		if !i.requestSIDAllowed(ar.Spec.SID, ar.Namespace) {
+			return "", fmt.Errorf("Request SID %s is not allowed by the issuer in namespace %s", ar.Spec.SID, ar.Namespace)
+		}
+		return ar.Spec.SID, nil
+	}
+	if i.SID == nil {
+		return "", nil
+	}
+	return i.SID.Default, nil
+}
+
+// Check if a rule of the issuer's SID policy allows the SID in the namespace
+func (i *Issuer) requestSIDAllowed(sid string, namespace string) bool {
+	if i.SID == nil {
+		return false
+	}
+	for _, rule := range i.SID.AllowedRequestSIDs {
+		if i.namespaceAllowed(rule.Namespaces, namespace) && matchesPattern(rule.SIDs, sid) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add the SID to the request attributes as configured in the issuer's SID policy.
+func (i *Issuer) addSIDAttribute(attributes map[string]string, sid string) {
+	if i.SID != nil && i.SID.Attribute != "" {
+		attributes[i.SID.Attribute] = sid
+		return
+	}
+	url := "url=" + sidUrlPrefix + sid
+	for name, value := range attributes {
+		if strings.EqualFold(name, "san") {
+			// Append to the alternative names already requested
+			attributes[name] = value + "&" + url
+			return
+		}
+	}
+	attributes["san"] = url
+}
+
+// Check if the certificate carries the SID either in the security extension
+// or as a SAN URL.
+func certificateHasSID(cert *x509.Certificate, sid string) bool {
+	for _, s := range certificateSIDs(cert) {
+		if strings.EqualFold(s, sid) {
+			return true
+		}
+	}
+	return false
+}
+
+func certificateSIDs(cert *x509.Certificate) []string {
+	var sids []string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidNtdsCaSecurityExt) {
+			continue
+		}
+		var names []asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &names); err != nil {
+			continue
+		}
+		for _, name := range names {
+			var on sidOtherName
+			if _, err := asn1.UnmarshalWithParams(name.FullBytes, &on, "tag:0"); err != nil {
+				continue
+			}
+			if on.TypeID.Equal(oidNtdsObjectSid) {
+				sids = append(sids, string(on.Value))
+			}
+		}
+	}
+	for _, url := range cert.URIs {
+		if s := url.String(); strings.HasPrefix(s, sidUrlPrefix) {
+			sids = append(sids, strings.TrimPrefix(s, sidUrlPrefix))
+		}
+	}
+	return sids
+}
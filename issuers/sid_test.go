@@ -0,0 +1,131 @@
+package issuers
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"net/url"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const testSID = "S-1-5-21-3623811015-3361044348-30300820-1013"
+
+// Certificate with the SID security extension as added by ADCS
+func certificateWithSIDExtension(t *testing.T, sid string) *x509.Certificate {
+	name, err := asn1.MarshalWithParams(sidOtherName{TypeID: oidNtdsObjectSid, Value: []byte(sid)}, "tag:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := asn1.Marshal([]asn1.RawValue{{FullBytes: name}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &x509.Certificate{Extensions: []pkix.Extension{{Id: oidNtdsCaSecurityExt, Value: value}}}
+}
+
+func TestRequestSID(t *testing.T) {
+	allowed := func(namespaces []string, sids ...string) []api.RequestSIDRule {
+		return []api.RequestSIDRule{{Namespaces: namespaces, SIDs: sids}}
+	}
+	tests := []struct {
+		name      string
+		kind      string
+		policy    *api.SIDPolicy
+		namespace string
+		sid       string
+		want      string
+		wantErr   bool
+	}{
+		{"no policy", "AdcsIssuer", nil, "team-a", "", "", false},
+		{"request SID without policy", "AdcsIssuer", nil, "team-a", testSID, "", true},
+		{"default", "AdcsIssuer", &api.SIDPolicy{Default: testSID}, "team-a", "", testSID, false},
+		{"request SID not allowed", "AdcsIssuer", &api.SIDPolicy{Default: "S-1-5-21-1-2-3-500"}, "team-a", testSID, "", true},
+		{"request SID allowed", "AdcsIssuer", &api.SIDPolicy{Default: "S-1-5-21-1-2-3-500", AllowedRequestSIDs: allowed(nil, testSID)},
+			"team-a", testSID, testSID, false},
+		{"request SID pattern", "ClusterAdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed([]string{"team-a"}, "S-1-5-21-3623811015-3361044348-30300820-10*")},
+			"team-a", testSID, testSID, false},
+		{"request SID in all namespaces", "ClusterAdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed([]string{"*"}, testSID)},
+			"team-a", testSID, testSID, false},
+		{"other SID", "AdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed(nil, testSID)},
+			"team-a", "S-1-5-21-3623811015-3361044348-30300820-512", "", true},
+		{"other namespace", "ClusterAdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed([]string{"team-b"}, testSID)},
+			"team-a", testSID, "", true},
+		{"cluster issuer rule without namespaces", "ClusterAdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed(nil, testSID)},
+			"team-a", testSID, "", true},
+		{"invalid request SID", "AdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed(nil, "S-1-*")}, "team-a", "S-1-x", "", true},
+		{"request SID with extra line", "AdcsIssuer", &api.SIDPolicy{AllowedRequestSIDs: allowed(nil, "S-1-*")},
+			"team-a", testSID + "\nsan:dns=evil", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{kind: tt.kind, SID: tt.policy}
+			if tt.kind == "AdcsIssuer" {
+				i.namespace = "team-a"
+			}
+			got, err := i.requestSID(&api.AdcsRequest{
+				ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace},
+				Spec:       api.AdcsRequestSpec{SID: tt.sid},
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAddSIDAttribute(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     *api.SIDPolicy
+		attributes map[string]string
+		want       map[string]string
+	}{
+		{"san URL", &api.SIDPolicy{}, map[string]string{},
+			map[string]string{"san": "url=tag:microsoft.com,2022-09-14:sid:" + testSID}},
+		{"appended to requested san", &api.SIDPolicy{}, map[string]string{"SAN": "dns=a.example.com"},
+			map[string]string{"SAN": "dns=a.example.com&url=tag:microsoft.com,2022-09-14:sid:" + testSID}},
+		{"custom attribute", &api.SIDPolicy{Attribute: "ObjectSid"}, map[string]string{"san": "dns=a.example.com"},
+			map[string]string{"san": "dns=a.example.com", "ObjectSid": testSID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{SID: tt.policy}
+			i.addSIDAttribute(tt.attributes, testSID)
+			if !reflect.DeepEqual(tt.attributes, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, tt.attributes)
+			}
+		})
+	}
+}
+
+func TestCertificateHasSID(t *testing.T) {
+	sidURL, _ := url.Parse(sidUrlPrefix + testSID)
+	otherURL, _ := url.Parse("https://example.com/" + testSID)
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want bool
+	}{
+		{"security extension", certificateWithSIDExtension(t, testSID), true},
+		{"other SID in extension", certificateWithSIDExtension(t, "S-1-5-21-1-2-3-500"), false},
+		{"SAN URL", &x509.Certificate{URIs: []*url.URL{sidURL}}, true},
+		{"other URL", &x509.Certificate{URIs: []*url.URL{otherURL}}, false},
+		{"malformed extension", &x509.Certificate{Extensions: []pkix.Extension{{Id: oidNtdsCaSecurityExt, Value: []byte{0x30, 0x05}}}}, false},
+		{"no SID", &x509.Certificate{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := certificateHasSID(tt.cert, testSID); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package issuers
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	cms "go.mozilla.org/pkcs7"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+)
+
+// Certificate (followed by its chain) and private key signing requests
+type requestSigner struct {
+	chain []*x509.Certificate
+	key   crypto.Signer
+}
+
+// Wrap the PKCS#10 CSR in PKCS#7 signed data. ADCS accepts such requests (CMC-style
+// 'PKCS#7' format) for enrollment on behalf of other users and renewals.
+// The attributes are added to the signed attributes of the signer.
+func signRequest(csrPEM []byte, signer *requestSigner, attributes ...cms.Attribute) (string, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil {
+		return "", fmt.Errorf("Cannot decode CSR")
+	}
+	signedData, err := cms.NewSignedData(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	signedData.SetDigestAlgorithm(cms.OIDDigestAlgorithmSHA256)
+	config := cms.SignerInfoConfig{ExtraSignedAttributes: attributes}
+	if err := signedData.AddSignerChain(signer.chain[0], signer.key, signer.chain[1:], config); err != nil {
+		return "", fmt.Errorf("Cannot sign request: %s", err.Error())
+	}
+	der, err := signedData.Finish()
+	if err != nil {
+		return "", fmt.Errorf("Cannot sign request: %s", err.Error())
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: der})), nil
+}
+
+// Get the signer from a 'kubernetes.io/tls' Secret
+func (f *IssuerFactory) getRequestSigner(ctx context.Context, secretName string, namespace string) (*requestSigner, error) {
+	secret := new(corev1.Secret)
+	if err := f.Client.Get(ctx, client.ObjectKey{Namespace: namespace, Name: secretName}, secret); err != nil {
+		return nil, err
+	}
+	return parseRequestSigner(secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey])
+}
+
+func parseRequestSigner(certPEM, keyPEM []byte) (*requestSigner, error) {
+	chain, err := pki.DecodeX509CertificateChainBytes(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode signer certificate: %s", err.Error())
+	}
+	key, err := pki.DecodePrivateKeyBytes(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode signer private key: %s", err.Error())
+	}
+	if matches, err := pki.PublicKeyMatchesCertificate(key.Public(), chain[0]); err != nil || !matches {
+		return nil, fmt.Errorf("Signer private key doesn't match the certificate")
+	}
+	return &requestSigner{chain: chain, key: key}, nil
+}
@@ -0,0 +1,48 @@
+package issuers
+
+import (
+	"time"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const (
+	// Part of the time the request has been waiting that passes between status checks
+	statusCheckBackoff = 4
+)
+
+// Get the time of the next status check of a pending request.
+// Requests are checked often right after submission (InitialStatusCheckInterval)
+// and less often as they wait longer, up to StatusCheckInterval. Requests submitted
+// before the submission time was recorded back off from their creation.
+func (i *Issuer) NextStatusCheck(ar *api.AdcsRequest) time.Time {
+	submitted := ar.CreationTimestamp.Time
+	if ar.Status.SubmittedTime != nil {
+		submitted = ar.Status.SubmittedTime.Time
+	}
+	if ar.Status.LastCheckTime == nil {
+		return submitted
+	}
+	last := ar.Status.LastCheckTime.Time
+	interval := last.Sub(submitted) / statusCheckBackoff
+	if interval < i.InitialStatusCheckInterval {
+		interval = i.InitialStatusCheckInterval
+	}
+	if interval > i.StatusCheckInterval {
+		interval = i.StatusCheckInterval
+	}
+	return last.Add(interval)
+}
+
+// Check if it's time to check the status of a pending request in the ADCS.
+// It's always the time when forced with the annotation or when the certificate
+// was uploaded in the manual mode.
+func (i *Issuer) StatusCheckDue(ar *api.AdcsRequest, now time.Time) bool {
+	if _, ok := ar.Annotations[api.CheckNowAnnotation]; ok {
+		return true
+	}
+	if i.certificateUploaded(ar) {
+		return true
+	}
+	return !i.NextStatusCheck(ar).After(now)
+}
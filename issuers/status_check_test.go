@@ -0,0 +1,110 @@
+package issuers
+
+import (
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func TestNextStatusCheck(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) *metav1.Time {
+		t := metav1.NewTime(now.Add(d))
+		return &t
+	}
+	tests := []struct {
+		name      string
+		created   time.Duration
+		submitted *metav1.Time
+		lastCheck *metav1.Time
+		want      time.Time
+	}{
+		{
+			name:    "never checked",
+			created: -time.Hour,
+			want:    now.Add(-time.Hour),
+		},
+		{
+			name:      "initial interval right after submission",
+			created:   -time.Hour,
+			submitted: at(-2 * time.Minute),
+			lastCheck: at(-time.Minute),
+			want:      now,
+		},
+		{
+			name:      "quarter of the waiting time",
+			created:   -10 * time.Hour,
+			submitted: at(-2 * time.Hour),
+			lastCheck: at(0),
+			want:      now.Add(30 * time.Minute),
+		},
+		{
+			name:      "limited by status check interval",
+			created:   -10 * 24 * time.Hour,
+			submitted: at(-10 * 24 * time.Hour),
+			lastCheck: at(0),
+			want:      now.Add(6 * time.Hour),
+		},
+		{
+			name:      "creation time without submission time",
+			created:   -2 * time.Hour,
+			lastCheck: at(0),
+			want:      now.Add(30 * time.Minute),
+		},
+		{
+			name:      "held before submission not counted",
+			created:   -24 * time.Hour,
+			submitted: at(-4 * time.Minute),
+			lastCheck: at(0),
+			want:      now.Add(time.Minute),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{InitialStatusCheckInterval: time.Minute, StatusCheckInterval: 6 * time.Hour}
+			ar := &api.AdcsRequest{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(now.Add(tt.created))},
+				Status:     api.AdcsRequestStatus{State: api.Pending, SubmittedTime: tt.submitted, LastCheckTime: tt.lastCheck},
+			}
+			if got := i.NextStatusCheck(ar); !got.Equal(tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStatusCheckDue(t *testing.T) {
+	now := time.Now()
+	submitted := metav1.NewTime(now.Add(-2 * time.Hour))
+	recent := metav1.NewTime(now.Add(-10 * time.Minute))
+	old := metav1.NewTime(now.Add(-time.Hour))
+	tests := []struct {
+		name        string
+		lastCheck   metav1.Time
+		annotations map[string]string
+		mode        api.IssuerMode
+		want        bool
+	}{
+		{"checked recently", recent, nil, "", false},
+		{"not checked for long", old, nil, "", true},
+		{"forced with annotation", recent, map[string]string{api.CheckNowAnnotation: ""}, "", true},
+		{"certificate uploaded", recent, map[string]string{api.IssuedCertificateAnnotation: ""}, api.IssuerModeManual, true},
+		{"certificate upload ignored without manual mode", recent, map[string]string{api.IssuedCertificateAnnotation: ""}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{Mode: tt.mode, InitialStatusCheckInterval: time.Minute, StatusCheckInterval: 6 * time.Hour}
+			lastCheck := tt.lastCheck
+			ar := &api.AdcsRequest{
+				ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations},
+				Status:     api.AdcsRequestStatus{State: api.Pending, SubmittedTime: &submitted, LastCheckTime: &lastCheck},
+			}
+			if got := i.StatusCheckDue(ar, now); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
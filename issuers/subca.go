@@ -0,0 +1,44 @@
+package issuers
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Get the SubCA template for the CA certificate request.
+func (i *Issuer) subCATemplate(ar *api.AdcsRequest) (string, error) {
+	if len(i.SubCA.AllowedNamespaces) == 0 {
+		return i.SubCA.Template, nil
+	}
+	for _, ns := range i.SubCA.AllowedNamespaces {
+		if ns == ar.Namespace {
+			return i.SubCA.Template, nil
+		}
+	}
+	return "", fmt.Errorf("Namespace %s is not allowed to request CA certificates", ar.Namespace)
+}
+
+// Check that the certificate issued from the SubCA template can be used as a CA
+// within the path length allowed by the issuer.
+func (i *Issuer) checkCACertificate(cert *x509.Certificate) error {
+	if !cert.BasicConstraintsValid || !cert.IsCA {
+		return fmt.Errorf("Issued certificate %x is not a CA certificate", cert.SerialNumber)
+	}
+	if i.SubCA.MaxPathLen == nil {
+		return nil
+	}
+	unlimited := cert.MaxPathLen < 0 || (cert.MaxPathLen == 0 && !cert.MaxPathLenZero)
+	if unlimited || cert.MaxPathLen > *i.SubCA.MaxPathLen {
+		return fmt.Errorf("Issued CA certificate %x path length %s exceeds %d", cert.SerialNumber, pathLen(cert), *i.SubCA.MaxPathLen)
+	}
+	return nil
+}
+
+func pathLen(cert *x509.Certificate) string {
+	if cert.MaxPathLen < 0 || (cert.MaxPathLen == 0 && !cert.MaxPathLenZero) {
+		return "unlimited"
+	}
+	return fmt.Sprint(cert.MaxPathLen)
+}
@@ -0,0 +1,80 @@
+package issuers
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func TestSubCATemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		namespace  string
+		ok         bool
+	}{
+		{"all namespaces", nil, "team-a", true},
+		{"allowed namespace", []string{"pki", "team-a"}, "team-a", true},
+		{"other namespace", []string{"pki"}, "team-a", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := &Issuer{SubCA: &api.SubCAPolicy{Template: "SubCA", AllowedNamespaces: tt.namespaces}}
+			template, err := issuer.subCATemplate(&api.AdcsRequest{ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace}})
+			if (err == nil) != tt.ok {
+				t.Fatalf("expected ok %t, got %v", tt.ok, err)
+			}
+			if tt.ok && template != "SubCA" {
+				t.Errorf("expected SubCA template, got %q", template)
+			}
+		})
+	}
+}
+
+func TestCheckCACertificate(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	key := testutil.NewKey(t)
+	// Path length -1 is no constraint
+	newCert := func(isCA bool, maxPathLen int) *x509.Certificate {
+		return testutil.NewCertificate(t, &x509.Certificate{
+			Subject:               pkix.Name{CommonName: "Team CA"},
+			IsCA:                  isCA,
+			BasicConstraintsValid: true,
+			MaxPathLen:            maxPathLen,
+			MaxPathLenZero:        maxPathLen == 0,
+		}, &key.PublicKey, root, rootKey)
+	}
+	noConstraints := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "host"}}, &key.PublicKey, root, rootKey)
+	zero, one := 0, 1
+
+	tests := []struct {
+		name       string
+		cert       *x509.Certificate
+		maxPathLen *int
+		ok         bool
+	}{
+		{"CA", newCert(true, -1), nil, true},
+		{"not CA", newCert(false, -1), nil, false},
+		{"no basic constraints", noConstraints, nil, false},
+		{"path length zero", newCert(true, 0), &zero, true},
+		{"path length within limit", newCert(true, 0), &one, true},
+		{"path length at limit", newCert(true, 1), &one, true},
+		{"path length over limit", newCert(true, 2), &one, false},
+		{"path length over zero", newCert(true, 1), &zero, false},
+		{"unlimited path length", newCert(true, -1), &one, false},
+		{"not CA with path length limit", newCert(false, -1), &one, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := &Issuer{SubCA: &api.SubCAPolicy{Template: "SubCA", MaxPathLen: tt.maxPathLen}}
+			if err := issuer.checkCACertificate(tt.cert); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package issuers
+
+import (
+	"fmt"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Returned instead of calling ADCS while the issuer is suspended or in a maintenance window.
+type SuspendedError struct {
+	Suspension
+}
+
+func (e *SuspendedError) Error() string {
+	return e.Message()
+}
+
+// Suspension state of an issuer at a given time
+type Suspension struct {
+	Suspended bool
+	// Set by the issuer's Suspend field i.e. not ending automatically
+	Manual bool
+	// End of the current maintenance window(s)
+	Until time.Time
+	// Start of the next maintenance window, zero if there's none
+	Next time.Time
+}
+
+// Get the suspension state. Adjoining or overlapping maintenance windows are merged.
+func GetSuspension(suspend bool, windows []api.MaintenanceWindow, now time.Time) Suspension {
+	s := Suspension{Suspended: suspend, Manual: suspend}
+	until := now
+	for extended := true; extended; {
+		extended = false
+		for _, w := range windows {
+			if !w.Start.Time.After(until) && w.End.Time.After(until) {
+				until = w.End.Time
+				extended = true
+			}
+		}
+	}
+	if until.After(now) {
+		s.Suspended = true
+		s.Until = until
+	}
+	for _, w := range windows {
+		if w.Start.Time.After(now) && (s.Next.IsZero() || w.Start.Time.Before(s.Next)) {
+			s.Next = w.Start.Time
+		}
+	}
+	return s
+}
+
+// How long until the suspension state changes. Zero if it doesn't change by itself.
+func (s Suspension) ChangesIn(now time.Time) time.Duration {
+	switch {
+	case s.Manual:
+		return 0
+	case s.Suspended:
+		return s.Until.Sub(now)
+	case !s.Next.IsZero():
+		return s.Next.Sub(now)
+	}
+	return 0
+}
+
+func (s Suspension) Message() string {
+	switch {
+	case s.Manual:
+		return "Issuer is suspended. Requests are held until it's resumed"
+	case s.Suspended:
+		return fmt.Sprintf("Issuer is in a maintenance window. Requests are held until %s", s.Until.Format(time.RFC3339))
+	}
+	return "Issuer processes requests"
+}
+
+// The 'Suspended' condition of the issuer
+func (s Suspension) Condition() api.IssuerCondition {
+	condition := api.IssuerCondition{
+		Type:    api.IssuerConditionSuspended,
+		Status:  cmmeta.ConditionFalse,
+		Reason:  "Resumed",
+		Message: s.Message(),
+	}
+	switch {
+	case s.Manual:
+		condition.Status = cmmeta.ConditionTrue
+		condition.Reason = "Suspended"
+	case s.Suspended:
+		condition.Status = cmmeta.ConditionTrue
+		condition.Reason = "MaintenanceWindow"
+	}
+	return condition
+}
+
+// Get the suspension state of the issuer.
+func (i *Issuer) Suspension(now time.Time) Suspension {
+	return GetSuspension(i.Suspend, i.MaintenanceWindows, now)
+}
@@ -0,0 +1,122 @@
+package issuers
+
+import (
+	"testing"
+	"time"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+func TestGetSuspension(t *testing.T) {
+	now := time.Now()
+	window := func(start, end time.Duration) api.MaintenanceWindow {
+		return api.MaintenanceWindow{Start: metav1.NewTime(now.Add(start)), End: metav1.NewTime(now.Add(end))}
+	}
+	tests := []struct {
+		name      string
+		suspend   bool
+		windows   []api.MaintenanceWindow
+		want      Suspension
+		changesIn time.Duration
+		reason    string
+	}{
+		{
+			name:   "not suspended",
+			want:   Suspension{},
+			reason: "Resumed",
+		},
+		{
+			name:      "in window",
+			windows:   []api.MaintenanceWindow{window(-time.Hour, time.Hour)},
+			want:      Suspension{Suspended: true, Until: now.Add(time.Hour)},
+			changesIn: time.Hour,
+			reason:    "MaintenanceWindow",
+		},
+		{
+			name:      "window starting now",
+			windows:   []api.MaintenanceWindow{window(0, time.Hour)},
+			want:      Suspension{Suspended: true, Until: now.Add(time.Hour)},
+			changesIn: time.Hour,
+			reason:    "MaintenanceWindow",
+		},
+		{
+			name:    "window ending now",
+			windows: []api.MaintenanceWindow{window(-time.Hour, 0)},
+			want:    Suspension{},
+			reason:  "Resumed",
+		},
+		{
+			name:    "past window",
+			windows: []api.MaintenanceWindow{window(-2*time.Hour, -time.Hour)},
+			want:    Suspension{},
+			reason:  "Resumed",
+		},
+		{
+			name:      "future window",
+			windows:   []api.MaintenanceWindow{window(2*time.Hour, 3*time.Hour), window(time.Hour, 90*time.Minute)},
+			want:      Suspension{Next: now.Add(time.Hour)},
+			changesIn: time.Hour,
+			reason:    "Resumed",
+		},
+		{
+			name:      "adjoining windows merged",
+			windows:   []api.MaintenanceWindow{window(time.Hour, 2*time.Hour), window(-time.Hour, time.Hour)},
+			want:      Suspension{Suspended: true, Until: now.Add(2 * time.Hour), Next: now.Add(time.Hour)},
+			changesIn: 2 * time.Hour,
+			reason:    "MaintenanceWindow",
+		},
+		{
+			name:      "overlapping windows merged",
+			windows:   []api.MaintenanceWindow{window(-time.Hour, time.Hour), window(30*time.Minute, 3*time.Hour), window(2*time.Hour, 4*time.Hour)},
+			want:      Suspension{Suspended: true, Until: now.Add(4 * time.Hour), Next: now.Add(30 * time.Minute)},
+			changesIn: 4 * time.Hour,
+			reason:    "MaintenanceWindow",
+		},
+		{
+			name:      "separate windows not merged",
+			windows:   []api.MaintenanceWindow{window(-time.Hour, time.Hour), window(2*time.Hour, 3*time.Hour)},
+			want:      Suspension{Suspended: true, Until: now.Add(time.Hour), Next: now.Add(2 * time.Hour)},
+			changesIn: time.Hour,
+			reason:    "MaintenanceWindow",
+		},
+		{
+			name:    "manual",
+			suspend: true,
+			want:    Suspension{Suspended: true, Manual: true},
+			reason:  "Suspended",
+		},
+		{
+			name:    "manual and window",
+			suspend: true,
+			windows: []api.MaintenanceWindow{window(-time.Hour, time.Hour), window(2*time.Hour, 3*time.Hour)},
+			want:    Suspension{Suspended: true, Manual: true, Until: now.Add(time.Hour), Next: now.Add(2 * time.Hour)},
+			reason:  "Suspended",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := GetSuspension(tt.suspend, tt.windows, now)
+			if s.Suspended != tt.want.Suspended || s.Manual != tt.want.Manual ||
+				!s.Until.Equal(tt.want.Until) || !s.Next.Equal(tt.want.Next) {
+				t.Fatalf("expected %+v, got %+v", tt.want, s)
+			}
+			if d := s.ChangesIn(now); d != tt.changesIn {
+				t.Errorf("expected change in %v, got %v", tt.changesIn, d)
+			}
+			condition := s.Condition()
+			if condition.Reason != tt.reason {
+				t.Errorf("expected reason %s, got %s", tt.reason, condition.Reason)
+			}
+			status := cmmeta.ConditionFalse
+			if tt.want.Suspended {
+				status = cmmeta.ConditionTrue
+			}
+			if condition.Status != status {
+				t.Errorf("expected condition %s, got %s", status, condition.Status)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package issuers
+
+import (
+	"fmt"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Select the ADCS certificate template for the request.
+// CA certificates are requested with the SubCA template if the issuer has one.
+// If the issuer has no template rules its default template is used.
+func (i *Issuer) selectTemplate(ar *api.AdcsRequest) (string, error) {
+	if ar.Spec.IsCA && i.SubCA != nil {
+		return i.subCATemplate(ar)
+	}
+	if len(i.TemplateRules) == 0 {
+		return i.Template, nil
+	}
+	usages := ar.Spec.Usages
+	if len(usages) == 0 {
+		usages = cmapi.DefaultKeyUsages()
+	}
+	for _, rule := range i.TemplateRules {
+		if rule.IsCA == ar.Spec.IsCA && usagesAllowed(usages, rule.Usages) {
+			return rule.Template, nil
+		}
+	}
+	return "", fmt.Errorf("No certificate template allowed for usages %v (isCA: %t)", usages, ar.Spec.IsCA)
+}
+
+// Check if all requested usages are allowed
+func usagesAllowed(requested []cmapi.KeyUsage, allowed []cmapi.KeyUsage) bool {
+	for _, r := range requested {
+		found := false
+		for _, a := range allowed {
+			if r == a {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
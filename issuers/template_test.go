@@ -0,0 +1,127 @@
+package issuers
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"strings"
+	"testing"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func TestSelectTemplate(t *testing.T) {
+	rules := []api.TemplateRule{
+		{Template: "SubCA", IsCA: true, Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment, cmapi.UsageCertSign}},
+		{Template: "WebServer", Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment, cmapi.UsageServerAuth}},
+		{Template: "Client", Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment, cmapi.UsageClientAuth}},
+		{Template: "Any", Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment, cmapi.UsageServerAuth, cmapi.UsageClientAuth}},
+	}
+	tests := []struct {
+		name    string
+		rules   []api.TemplateRule
+		usages  []cmapi.KeyUsage
+		isCA    bool
+		want    string
+		wantErr bool
+	}{
+		{name: "default template without rules", usages: []cmapi.KeyUsage{cmapi.UsageCodeSigning}, want: "User"},
+		{name: "default usages", rules: rules, want: "WebServer"},
+		{name: "first matching rule", rules: rules, usages: []cmapi.KeyUsage{cmapi.UsageServerAuth}, want: "WebServer"},
+		{name: "later rule", rules: rules, usages: []cmapi.KeyUsage{cmapi.UsageClientAuth}, want: "Client"},
+		{name: "all usages must be allowed", rules: rules, usages: []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageClientAuth}, want: "Any"},
+		{name: "CA", rules: rules, isCA: true, want: "SubCA"},
+		{name: "CA usages", rules: rules, usages: []cmapi.KeyUsage{cmapi.UsageCertSign}, isCA: true, want: "SubCA"},
+		{name: "CA rule not used for other requests", rules: rules[:1], wantErr: true},
+		{name: "other rules not used for CA", rules: rules[1:], isCA: true, wantErr: true},
+		{name: "no rule for usages", rules: rules, usages: []cmapi.KeyUsage{cmapi.UsageCodeSigning}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{Template: "User", TemplateRules: tt.rules}
+			ar := &api.AdcsRequest{Spec: api.AdcsRequestSpec{Usages: tt.usages, IsCA: tt.isCA}}
+			got, err := i.selectTemplate(ar)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSelectTemplateSubCA(t *testing.T) {
+	i := &Issuer{
+		Template: "User",
+		TemplateRules: []api.TemplateRule{
+			{Template: "RuleCA", IsCA: true, Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment}},
+			{Template: "WebServer", Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment}},
+		},
+		SubCA: &api.SubCAPolicy{Template: "SubCA", AllowedNamespaces: []string{"pki"}},
+	}
+	tests := []struct {
+		name      string
+		namespace string
+		isCA      bool
+		want      string
+		wantErr   bool
+	}{
+		{"SubCA template before the rules", "pki", true, "SubCA", false},
+		{"rules for other requests", "pki", false, "WebServer", false},
+		{"no fallback to the rules outside allowed namespaces", "team-a", true, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &api.AdcsRequest{ObjectMeta: metav1.ObjectMeta{Namespace: tt.namespace}, Spec: api.AdcsRequestSpec{IsCA: tt.isCA}}
+			got, err := i.selectTemplate(ar)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUsagesAllowed(t *testing.T) {
+	allowed := []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageServerAuth}
+	tests := []struct {
+		name      string
+		requested []cmapi.KeyUsage
+		want      bool
+	}{
+		{"none", nil, true},
+		{"subset", []cmapi.KeyUsage{cmapi.UsageServerAuth}, true},
+		{"all", []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageDigitalSignature}, true},
+		{"not allowed", []cmapi.KeyUsage{cmapi.UsageServerAuth, cmapi.UsageClientAuth}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usagesAllowed(tt.requested, allowed); got != tt.want {
+				t.Errorf("expected %t, got %t", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIssueWithoutTemplate(t *testing.T) {
+	key := testutil.NewKey(t)
+	i := &Issuer{TemplateRules: []api.TemplateRule{{Template: "SubCA", IsCA: true}}}
+	ar := &api.AdcsRequest{Spec: api.AdcsRequestSpec{
+		CSRPEM: testutil.NewCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: "host.example.com"}}),
+		Usages: []cmapi.KeyUsage{cmapi.UsageServerAuth},
+	}}
+	cert, _, err := i.Issue(context.Background(), ar)
+	if err != nil || cert != nil {
+		t.Fatalf("expected request completed without certificate, got %v", err)
+	}
+	if ar.Status.State != api.Errored || !strings.Contains(ar.Status.Reason, "No certificate template allowed") {
+		t.Errorf("expected Errored for missing template, got %s: %s", ar.Status.State, ar.Status.Reason)
+	}
+}
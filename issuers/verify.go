@@ -0,0 +1,233 @@
+package issuers
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+	cms "go.mozilla.org/pkcs7"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+// Verify that the certificate issued by the ADCS matches the request.
+// Returns notes on accepted deviations from the request or error if the certificate
+// is not acceptable.
+func (i *Issuer) verifyCertificate(ar *api.AdcsRequest, cert *x509.Certificate, ca []byte) ([]string, error) {
+	policy := i.Verification
+	if policy == nil {
+		policy = &api.VerificationPolicy{}
+	}
+	if policy.Disabled {
+		return nil, nil
+	}
+
+	csr, err := pki.DecodeX509CertificateRequestBytes(ar.Spec.CSRPEM)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode CSR: %s", err.Error())
+	}
+	if !publicKeysEqual(csr.PublicKey, cert.PublicKey) {
+		return nil, fmt.Errorf("public key doesn't match the CSR")
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) {
+		return nil, fmt.Errorf("not valid before %v", cert.NotBefore)
+	}
+	if now.After(cert.NotAfter) {
+		return nil, fmt.Errorf("expired at %v", cert.NotAfter)
+	}
+
+	if !policy.SkipChain {
+		if err := verifyChain(cert, ca); err != nil {
+			return nil, err
+		}
+	}
+
+	var notes []string
+	if missing := missingSANs(csr, cert); len(missing) > 0 {
+		if !policy.AllowMissingSANs {
+			return nil, fmt.Errorf("missing requested names %s", strings.Join(missing, ", "))
+		}
+		notes = append(notes, fmt.Sprintf("Certificate issued without requested names %s.", strings.Join(missing, ", ")))
+	}
+	return notes, nil
+}
+
+func publicKeysEqual(a interface{}, b interface{}) bool {
+	aBytes, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// Verify that the certificate chains to one of the CA certificates.
+func verifyChain(cert *x509.Certificate, ca []byte) error {
+	caCerts, err := decodeCertificates(ca)
+	if err != nil {
+		return fmt.Errorf("cannot decode CA chain: %s", err.Error())
+	}
+	if len(caCerts) == 0 {
+		return fmt.Errorf("no CA certificates to verify the chain")
+	}
+	roots := x509.NewCertPool()
+	intermediates := x509.NewCertPool()
+	hasRoot := false
+	for _, c := range caCerts {
+		if bytes.Equal(c.RawSubject, c.RawIssuer) && c.CheckSignatureFrom(c) == nil {
+			roots.AddCert(c)
+			hasRoot = true
+		} else {
+			intermediates.AddCert(c)
+		}
+	}
+	if !hasRoot {
+		// Only part of the chain is known. Trust what we've got from the ADCS.
+		roots = intermediates
+		intermediates = nil
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("doesn't chain to the CA: %s", err.Error())
+	}
+	return nil
+}
+
+// Verify that the uploaded certificate chains to one of the trusted CA certificates.
+// The CA certificates uploaded with it are used as intermediates only as anybody
+// can upload a self-signed chain.
+func verifyTrust(cert *x509.Certificate, ca []byte, trusted []byte) error {
+	trustedCerts, err := decodeCertificates(trusted)
+	if err != nil {
+		return fmt.Errorf("cannot decode trusted CA bundle: %s", err.Error())
+	}
+	if len(trustedCerts) == 0 {
+		return fmt.Errorf("no trusted CA certificates in the issuer")
+	}
+	caCerts, err := decodeCertificates(ca)
+	if err != nil {
+		return fmt.Errorf("cannot decode CA chain: %s", err.Error())
+	}
+	roots := x509.NewCertPool()
+	for _, c := range trustedCerts {
+		roots.AddCert(c)
+	}
+	intermediates := x509.NewCertPool()
+	for _, c := range caCerts {
+		intermediates.AddCert(c)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("doesn't chain to the trusted CAs: %s", err.Error())
+	}
+	return nil
+}
+
+// Decode PEM bundle with certificates. ADCS may return PKCS#7 (.p7b) data in
+// the bundle's blocks so these are accepted as well.
+func decodeCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if c, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, c)
+			continue
+		}
+		p7, err := cms.Parse(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, p7.Certificates...)
+	}
+	return certs, nil
+}
+
+// Find the CA certificate that doesn't issue any other certificate from the chain.
+func issuingCA(chain []*x509.Certificate) *x509.Certificate {
+	for _, c := range chain {
+		issuer := false
+		for _, other := range chain {
+			if other != c && bytes.Equal(other.RawIssuer, c.RawSubject) && !bytes.Equal(other.RawIssuer, other.RawSubject) {
+				issuer = true
+				break
+			}
+		}
+		if !issuer {
+			return c
+		}
+	}
+	return chain[0]
+}
+
+// Get the subject alternative names requested in the CSR that are missing in the certificate.
+func missingSANs(csr *x509.CertificateRequest, cert *x509.Certificate) []string {
+	var missing []string
+	for _, name := range csr.DNSNames {
+		if !containsFold(cert.DNSNames, name) {
+			missing = append(missing, "DNS:"+name)
+		}
+	}
+	for _, email := range csr.EmailAddresses {
+		if !containsFold(cert.EmailAddresses, email) {
+			missing = append(missing, "email:"+email)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if !containsIP(cert.IPAddresses, ip) {
+			missing = append(missing, "IP:"+ip.String())
+		}
+	}
+	for _, uri := range csr.URIs {
+		found := false
+		for _, u := range cert.URIs {
+			if u.String() == uri.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, "URI:"+uri.String())
+		}
+	}
+	return missing
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIP(list []net.IP, ip net.IP) bool {
+	for _, v := range list {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package issuers
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	cms "go.mozilla.org/pkcs7"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+func encodePEM(certs ...*x509.Certificate) []byte {
+	var out []byte
+	for _, c := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+	}
+	return out
+}
+
+func encodePKCS7(t *testing.T, certs ...*x509.Certificate) []byte {
+	var certsDER []byte
+	for _, c := range certs {
+		certsDER = append(certsDER, c.Raw...)
+	}
+	der, err := cms.DegenerateCertificate(certsDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: der})
+}
+
+func TestVerifyCertificate(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	intermediate, intermediateKey := testutil.NewCA(t, "Issuing CA", root, rootKey)
+	other, otherKey := testutil.NewCA(t, "Other CA", nil, nil)
+	ca := encodePEM(intermediate, root)
+
+	key := testutil.NewKey(t)
+	csr := testutil.NewCSR(t, key, &x509.CertificateRequest{DNSNames: []string{"a.example.com", "b.example.com"}})
+	leaf := func(dnsNames []string, notBefore, notAfter time.Time, pub crypto.PublicKey, parent *x509.Certificate, parentKey crypto.Signer) *x509.Certificate {
+		return testutil.NewCertificate(t, &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "a.example.com"},
+			DNSNames:  dnsNames,
+			NotBefore: notBefore,
+			NotAfter:  notAfter,
+		}, pub, parent, parentKey)
+	}
+	allNames := []string{"A.example.com", "b.example.com"}
+	valid := leaf(allNames, time.Time{}, time.Time{}, &key.PublicKey, intermediate, intermediateKey)
+
+	tests := []struct {
+		name    string
+		policy  *api.VerificationPolicy
+		cert    *x509.Certificate
+		ca      []byte
+		notes   []string
+		wantErr bool
+	}{
+		{name: "valid", cert: valid, ca: ca},
+		{name: "other public key", cert: leaf(allNames, time.Time{}, time.Time{}, &testutil.NewKey(t).PublicKey, intermediate, intermediateKey), ca: ca, wantErr: true},
+		{name: "expired", cert: leaf(allNames, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour), &key.PublicKey, intermediate, intermediateKey), ca: ca, wantErr: true},
+		{name: "not yet valid", cert: leaf(allNames, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour), &key.PublicKey, intermediate, intermediateKey), ca: ca, wantErr: true},
+		{name: "other CA", cert: leaf(allNames, time.Time{}, time.Time{}, &key.PublicKey, other, otherKey), ca: ca, wantErr: true},
+		{name: "other CA with chain check skipped", policy: &api.VerificationPolicy{SkipChain: true},
+			cert: leaf(allNames, time.Time{}, time.Time{}, &key.PublicKey, other, otherKey), ca: ca},
+		{name: "missing names", cert: leaf([]string{"a.example.com"}, time.Time{}, time.Time{}, &key.PublicKey, intermediate, intermediateKey), ca: ca, wantErr: true},
+		{name: "missing names allowed", policy: &api.VerificationPolicy{AllowMissingSANs: true},
+			cert: leaf([]string{"a.example.com"}, time.Time{}, time.Time{}, &key.PublicKey, intermediate, intermediateKey), ca: ca,
+			notes: []string{"Certificate issued without requested names DNS:b.example.com."}},
+		{name: "disabled", policy: &api.VerificationPolicy{Disabled: true},
+			cert: leaf(nil, time.Time{}, time.Time{}, &testutil.NewKey(t).PublicKey, other, otherKey), ca: ca},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			i := &Issuer{Verification: tt.policy}
+			ar := &api.AdcsRequest{Spec: api.AdcsRequestSpec{CSRPEM: csr}}
+			notes, err := i.verifyCertificate(ar, tt.cert, tt.ca)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(notes, tt.notes) {
+				t.Errorf("expected notes %v, got %v", tt.notes, notes)
+			}
+		})
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	intermediate, intermediateKey := testutil.NewCA(t, "Issuing CA", root, rootKey)
+	other, _ := testutil.NewCA(t, "Other CA", nil, nil)
+	key := testutil.NewKey(t)
+	cert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}, &key.PublicKey, intermediate, intermediateKey)
+
+	tests := []struct {
+		name    string
+		ca      []byte
+		wantErr bool
+	}{
+		{"full chain", encodePEM(intermediate, root), false},
+		{"root first", encodePEM(root, intermediate), false},
+		{"issuing CA only", encodePEM(intermediate), false},
+		{"PKCS #7 bundle", encodePKCS7(t, intermediate, root), false},
+		{"root without intermediate", encodePEM(root), true},
+		{"other CA", encodePEM(other), true},
+		{"no CA certificates", nil, true},
+		{"malformed", pem.EncodeToMemory(&pem.Block{Type: "PKCS7", Bytes: []byte{0x30, 0x03, 0x02, 0x01, 0x01}}), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyChain(cert, tt.ca); (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyTrust(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	intermediate, intermediateKey := testutil.NewCA(t, "Issuing CA", root, rootKey)
+	// Chain made up by the uploader with the same names
+	fakeRoot, fakeRootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	fakeIntermediate, fakeIntermediateKey := testutil.NewCA(t, "Issuing CA", fakeRoot, fakeRootKey)
+	key := testutil.NewKey(t)
+	cert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}, &key.PublicKey, intermediate, intermediateKey)
+	fakeCert := testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}, &key.PublicKey, fakeIntermediate, fakeIntermediateKey)
+
+	tests := []struct {
+		name    string
+		cert    *x509.Certificate
+		ca      []byte
+		trusted []byte
+		wantErr bool
+	}{
+		{"full chain", cert, encodePEM(intermediate, root), encodePEM(root), false},
+		{"issuing CA only", cert, encodePEM(intermediate), encodePEM(root), false},
+		{"trusted issuing CA", cert, nil, encodePEM(intermediate), false},
+		{"missing intermediate", cert, nil, encodePEM(root), true},
+		{"uploaded root not trusted", fakeCert, encodePEM(fakeIntermediate, fakeRoot), encodePEM(root), true},
+		{"no trust bundle", cert, encodePEM(intermediate, root), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := verifyTrust(tt.cert, tt.ca, tt.trusted); (err != nil) != tt.wantErr {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeCertificates(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	intermediate, _ := testutil.NewCA(t, "Issuing CA", root, rootKey)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    []*x509.Certificate
+		wantErr bool
+	}{
+		{"empty", nil, nil, false},
+		{"PEM certificates", encodePEM(intermediate, root), []*x509.Certificate{intermediate, root}, false},
+		{"PKCS #7", encodePKCS7(t, intermediate, root), []*x509.Certificate{intermediate, root}, false},
+		{"mixed", append(encodePEM(intermediate), encodePKCS7(t, root)...), []*x509.Certificate{intermediate, root}, false},
+		{"garbage block", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("garbage")}), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCertificates(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d certificates, got %d", len(tt.want), len(got))
+			}
+			for n := range got {
+				if !got[n].Equal(tt.want[n]) {
+					t.Errorf("certificate %d is %s, expected %s", n, got[n].Subject, tt.want[n].Subject)
+				}
+			}
+		})
+	}
+}
+
+func TestIssuingCA(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	policy, policyKey := testutil.NewCA(t, "Policy CA", root, rootKey)
+	issuing, _ := testutil.NewCA(t, "Issuing CA", policy, policyKey)
+
+	tests := []struct {
+		name  string
+		chain []*x509.Certificate
+		want  *x509.Certificate
+	}{
+		{"root only", []*x509.Certificate{root}, root},
+		{"issuing CA first", []*x509.Certificate{issuing, policy, root}, issuing},
+		{"root first", []*x509.Certificate{root, policy, issuing}, issuing},
+		{"unordered", []*x509.Certificate{policy, root, issuing}, issuing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issuingCA(tt.chain); !got.Equal(tt.want) {
+				t.Errorf("expected %s, got %s", tt.want.Subject, got.Subject)
+			}
+		})
+	}
+}
+
+func TestMissingSANs(t *testing.T) {
+	uri, _ := url.Parse("spiffe://cluster.local/ns/default/sa/app")
+	otherURI, _ := url.Parse("spiffe://cluster.local/ns/default/sa/other")
+	csr := &x509.CertificateRequest{
+		DNSNames:       []string{"a.example.com"},
+		EmailAddresses: []string{"user@example.com"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:           []*url.URL{uri},
+	}
+	tests := []struct {
+		name string
+		cert *x509.Certificate
+		want []string
+	}{
+		{"all present", &x509.Certificate{
+			DNSNames:       []string{"A.EXAMPLE.COM"},
+			EmailAddresses: []string{"User@Example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.1").To4()},
+			URIs:           []*url.URL{uri},
+		}, nil},
+		{"extra names", &x509.Certificate{
+			DNSNames:       []string{"a.example.com", "b.example.com"},
+			EmailAddresses: []string{"user@example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2")},
+			URIs:           []*url.URL{uri, otherURI},
+		}, nil},
+		{"none present", &x509.Certificate{},
+			[]string{"DNS:a.example.com", "email:user@example.com", "IP:10.0.0.1", "URI:" + uri.String()}},
+		{"different names", &x509.Certificate{
+			DNSNames:       []string{"b.example.com"},
+			EmailAddresses: []string{"user@example.com"},
+			IPAddresses:    []net.IP{net.ParseIP("10.0.0.2")},
+			URIs:           []*url.URL{otherURI},
+		}, []string{"DNS:a.example.com", "IP:10.0.0.1", "URI:" + uri.String()}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingSANs(csr, tt.cert); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package istio
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+const serviceAccountPrefix = "system:serviceaccount:"
+
+var oidOrganization = asn1.ObjectIdentifier{2, 5, 4, 10}
+
+// Authenticate the workload by its service account token sent in the 'authorization'
+// header (as istio-agent does). Returns the SPIFFE ID of the service account
+// 'spiffe://<trust domain>/ns/<namespace>/sa/<service account>'.
+func (s *Server) authenticate(ctx context.Context) (string, error) {
+	var header string
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) > 0 {
+		header = values[0]
+	}
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", status.Error(codes.Unauthenticated, "Bearer token required")
+	}
+	review := &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{
+			Token:     strings.TrimSpace(strings.TrimPrefix(header, "Bearer ")),
+			Audiences: s.Audiences,
+		},
+	}
+	if err := s.Client.Create(ctx, review); err != nil {
+		s.Log.Error(err, "Cannot review token")
+		return "", status.Error(codes.Unavailable, "Cannot review token")
+	}
+	if !review.Status.Authenticated {
+		message := "Invalid token"
+		if review.Status.Error != "" {
+			message += ": " + review.Status.Error
+		}
+		return "", status.Error(codes.Unauthenticated, message)
+	}
+	parts := strings.Split(strings.TrimPrefix(review.Status.User.Username, serviceAccountPrefix), ":")
+	if !strings.HasPrefix(review.Status.User.Username, serviceAccountPrefix) || len(parts) != 2 {
+		return "", status.Errorf(codes.PermissionDenied, "%s is not a service account", review.Status.User.Username)
+	}
+	return fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", s.TrustDomain, parts[0], parts[1]), nil
+}
+
+// Check that the CSR is for the workload's identity only. The SPIFFE ID must be
+// the only name. The subject may have the organization set by some Istio versions.
+func checkCSR(csr *x509.CertificateRequest, identity string) error {
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("Invalid CSR signature: %s", err.Error())
+	}
+	if len(csr.URIs) != 1 || csr.URIs[0].String() != identity {
+		return fmt.Errorf("CSR must have the single URI SAN %s", identity)
+	}
+	if len(csr.DNSNames) > 0 || len(csr.IPAddresses) > 0 || len(csr.EmailAddresses) > 0 {
+		return fmt.Errorf("CSR must not have DNS, IP or email SANs")
+	}
+	for _, name := range csr.Subject.Names {
+		if !name.Type.Equal(oidOrganization) {
+			return fmt.Errorf("CSR subject must be empty, %s not allowed", csr.Subject)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package istio
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	authv1 "k8s.io/api/authentication/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+// Client answering TokenReviews for the token with the user if the audiences match
+type tokenReviewClient struct {
+	client.Client
+	token     string
+	audiences []string
+	username  string
+	err       error
+}
+
+func (c *tokenReviewClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if c.err != nil {
+		return c.err
+	}
+	review := obj.(*authv1.TokenReview)
+	if review.Spec.Token != c.token {
+		review.Status.Error = "invalid bearer token"
+		return nil
+	}
+	for _, a := range c.audiences {
+		for _, b := range review.Spec.Audiences {
+			if a == b {
+				review.Status.Authenticated = true
+				review.Status.Audiences = []string{a}
+				review.Status.User.Username = c.username
+				return nil
+			}
+		}
+	}
+	review.Status.Error = "token audiences are invalid for the target audiences"
+	return nil
+}
+
+func TestAuthenticate(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		audiences []string
+		username  string
+		err       error
+		identity  string
+		code      codes.Code
+	}{
+		{"service account", "Bearer token", []string{"istio-ca"}, "system:serviceaccount:team-a:web", nil,
+			"spiffe://cluster.local/ns/team-a/sa/web", codes.OK},
+		{"no header", "", []string{"istio-ca"}, "system:serviceaccount:team-a:web", nil, "", codes.Unauthenticated},
+		{"not bearer", "Basic dXNlcjpwYXNz", []string{"istio-ca"}, "system:serviceaccount:team-a:web", nil, "", codes.Unauthenticated},
+		{"invalid token", "Bearer other", []string{"istio-ca"}, "system:serviceaccount:team-a:web", nil, "", codes.Unauthenticated},
+		{"other audience", "Bearer token", []string{"kubernetes"}, "system:serviceaccount:team-a:web", nil, "", codes.Unauthenticated},
+		{"not a service account", "Bearer token", []string{"istio-ca"}, "admin", nil, "", codes.PermissionDenied},
+		{"review failed", "Bearer token", []string{"istio-ca"}, "", errors.New("connection refused"), "", codes.Unavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Server{
+				Client:      &tokenReviewClient{token: "token", audiences: tt.audiences, username: tt.username, err: tt.err},
+				Log:         logr.Discard(),
+				TrustDomain: "cluster.local",
+				Audiences:   []string{"istio-ca"},
+			}
+			ctx := context.Background()
+			if tt.header != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.header))
+			}
+			identity, err := s.authenticate(ctx)
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("expected %s, got %v", tt.code, err)
+			}
+			if identity != tt.identity {
+				t.Errorf("expected identity %q, got %q", tt.identity, identity)
+			}
+		})
+	}
+}
+
+func TestCheckCSR(t *testing.T) {
+	identity := "spiffe://cluster.local/ns/team-a/sa/web"
+	uri := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := []struct {
+		name     string
+		template *x509.CertificateRequest
+		ok       bool
+	}{
+		{"SPIFFE ID", &x509.CertificateRequest{URIs: []*url.URL{uri(identity)}}, true},
+		{"organization", &x509.CertificateRequest{
+			Subject: pkix.Name{Organization: []string{"cluster.local"}},
+			URIs:    []*url.URL{uri(identity)}}, true},
+		{"no URI", &x509.CertificateRequest{}, false},
+		{"other service account", &x509.CertificateRequest{URIs: []*url.URL{uri("spiffe://cluster.local/ns/team-a/sa/default")}}, false},
+		{"other namespace", &x509.CertificateRequest{URIs: []*url.URL{uri("spiffe://cluster.local/ns/team-b/sa/web")}}, false},
+		{"other trust domain", &x509.CertificateRequest{URIs: []*url.URL{uri("spiffe://example.com/ns/team-a/sa/web")}}, false},
+		{"additional URI", &x509.CertificateRequest{URIs: []*url.URL{uri(identity), uri("spiffe://cluster.local/ns/team-b/sa/web")}}, false},
+		{"DNS name", &x509.CertificateRequest{URIs: []*url.URL{uri(identity)}, DNSNames: []string{"web.team-a.svc"}}, false},
+		{"IP address", &x509.CertificateRequest{URIs: []*url.URL{uri(identity)}, IPAddresses: []net.IP{net.ParseIP("10.0.0.1")}}, false},
+		{"email", &x509.CertificateRequest{URIs: []*url.URL{uri(identity)}, EmailAddresses: []string{"web@example.com"}}, false},
+		{"common name", &x509.CertificateRequest{
+			Subject: pkix.Name{CommonName: "web"},
+			URIs:    []*url.URL{uri(identity)}}, false},
+	}
+	key := testutil.NewKey(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			der, err := x509.CreateCertificateRequest(rand.Reader, tt.template, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			csr, err := x509.ParseCertificateRequest(der)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkCSR(csr, identity); (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+
+	t.Run("invalid signature", func(t *testing.T) {
+		der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{URIs: []*url.URL{uri(identity)}}, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr, err := x509.ParseCertificateRequest(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		csr.Signature[len(csr.Signature)-1] ^= 0xff
+		if err := checkCSR(csr, identity); err == nil {
+			t.Errorf("expected CSR with invalid signature rejected")
+		}
+	})
+}
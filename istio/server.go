@@ -0,0 +1,219 @@
+// Package istio implements the Istio CertificateService (the API of istiod's CA used
+// by istio-agent and istio-csr) backed by an ADCS issuer. Workloads authenticate with
+// their service account tokens and get certificates with SPIFFE IDs.
+//
+// Unlike the CertificateSigningRequest signer, which creates an AdcsRequest for each
+// request and leaves it to the controller, the server sends the requests to ADCS
+// directly. Mesh certificates are short-lived and requested often so no AdcsRequests
+// are created for them.
+package istio
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/go-logr/logr"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	securityapi "istio.io/api/security/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cmapi "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	// Interval of status checks of pending ADCS requests
+	pollInterval    = 2 * time.Second
+	shutdownTimeout = 10 * time.Second
+)
+
+// +kubebuilder:rbac:groups=authentication.k8s.io,resources=tokenreviews,verbs=create
+
+// Server is the Istio CA server. It implements manager.Runnable.
+type Server struct {
+	// Reads issuers and Secrets, creates TokenReviews
+	Client        client.Client
+	Log           logr.Logger
+	IssuerFactory issuers.IssuerFactory
+
+	// Address to listen on e.g. ':15012'
+	Addr string
+	// TLS certificate and key files of the server
+	CertFile string
+	KeyFile  string
+	// Issuer the certificates are requested from and its namespace
+	// (the cluster resource namespace for ClusterAdcsIssuers)
+	IssuerRef cmmeta.ObjectReference
+	Namespace string
+	// Trust domain of the SPIFFE IDs e.g. 'cluster.local'
+	TrustDomain string
+	// Audiences the service account tokens must be issued for. Any audience
+	// accepted by the API server if empty.
+	Audiences []string
+	// How long to wait for pending ADCS requests before the request is cancelled
+	Wait time.Duration
+}
+
+var _ securityapi.IstioCertificateServiceServer = &Server{}
+
+// Start implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	creds, err := credentials.NewServerTLSFromFile(s.CertFile, s.KeyFile)
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.Creds(creds))
+	securityapi.RegisterIstioCertificateServiceServer(server, s)
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, 1)
+	go func() {
+		s.Log.Info("Starting Istio CA server", "addr", s.Addr, "issuer", s.IssuerRef.Name, "trustDomain", s.TrustDomain)
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case <-ctx.Done():
+		server.GracefulStop()
+		return nil
+	case err := <-errs:
+		return err
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// The server runs in all replicas.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// CreateCertificate implements IstioCertificateServiceServer. The certificate chain of
+// the response starts with the certificate and ends with the root CA.
+func (s *Server) CreateCertificate(ctx context.Context, request *securityapi.IstioCertificateRequest) (*securityapi.IstioCertificateResponse, error) {
+	csrPEM := []byte(request.Csr)
+	validity := request.ValidityDuration
+
+	identity, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	log := s.Log.WithValues("identity", identity)
+	csr, err := pki.DecodeX509CertificateRequestBytes(csrPEM)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Cannot decode CSR: %s", err.Error())
+	}
+	if err := checkCSR(csr, identity); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	issuer, err := s.IssuerFactory.GetIssuer(ctx, s.IssuerRef, s.Namespace)
+	if err != nil {
+		log.Error(err, "Cannot get issuer", "issuer", s.IssuerRef)
+		return nil, status.Error(codes.Unavailable, "Issuer not available")
+	}
+	ar := &api.AdcsRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              identity,
+			CreationTimestamp: metav1.Now(),
+		},
+		Spec: api.AdcsRequestSpec{
+			CSRPEM:    csrPEM,
+			IssuerRef: s.IssuerRef,
+			// Mesh certificates authenticate both sides of mTLS connections
+			Usages: []cmapi.KeyUsage{cmapi.UsageDigitalSignature, cmapi.UsageKeyEncipherment, cmapi.UsageServerAuth, cmapi.UsageClientAuth},
+		},
+	}
+	if validity > 0 {
+		ar.Spec.Duration = &metav1.Duration{Duration: time.Duration(validity) * time.Second}
+	}
+
+	cert, ca, err := s.issue(ctx, issuer, ar)
+	if err != nil {
+		log.Info("Certificate not issued", "reason", err.Error(), "adcsRequestId", ar.Status.Id)
+		return nil, err
+	}
+	chain, err := certificateChain(cert, ca)
+	if err != nil {
+		log.Error(err, "Invalid certificate chain", "adcsRequestId", ar.Status.Id)
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	log.Info("Certificate issued", "serialNumber", ar.Status.SerialNumber, "adcsRequestId", ar.Status.Id)
+	return &securityapi.IstioCertificateResponse{CertChain: chain}, nil
+}
+
+// Send the request to ADCS and wait for the certificate. Requests still pending when
+// the wait (or the call's deadline) is over are denied in ADCS so that they aren't
+// issued when nobody waits for them anymore. The client sends a new request on retry.
+func (s *Server) issue(ctx context.Context, issuer *issuers.Issuer, ar *api.AdcsRequest) ([]byte, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.Wait)
+	defer cancel()
+	for {
+		cert, ca, err := issuer.Issue(ctx, ar)
+		var throttled *issuers.ThrottledError
+		var suspended *issuers.SuspendedError
+		switch {
+		case errors.As(err, &throttled):
+			err = status.Error(codes.Unavailable, throttled.Error())
+		case errors.As(err, &suspended):
+			err = status.Error(codes.Unavailable, suspended.Error())
+		case err != nil:
+			s.Log.Error(err, "ADCS request failed", "adcsRequestId", ar.Status.Id)
+			err = status.Error(codes.Unavailable, "ADCS request failed")
+		}
+
+		switch {
+		case err != nil && ar.Status.State != api.Pending:
+			return nil, nil, err
+		case ar.Status.State == api.Ready:
+			return cert, ca, nil
+		case ar.Status.State == api.Rejected:
+			return nil, nil, status.Errorf(codes.PermissionDenied, "ADCS request rejected: %s", ar.Status.Reason)
+		case ar.Status.State == api.Errored:
+			return nil, nil, status.Errorf(codes.Internal, "ADCS request failed: %s", ar.Status.Reason)
+		}
+
+		// Pending. Failed status checks are re-tried until the wait is over.
+		select {
+		case <-ctx.Done():
+			// The request may be already cancelled by the client
+			cancelCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			if err := issuer.Cancel(cancelCtx, ar); err != nil {
+				s.Log.Error(err, "Cannot cancel pending ADCS request", "adcsRequestId", ar.Status.Id)
+			}
+			return nil, nil, status.Errorf(codes.Unavailable, "ADCS request %s pending", ar.Status.Id)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Split the certificate and the CA certificates into PEM blocks. The root comes last.
+func certificateChain(cert, ca []byte) ([]string, error) {
+	x509Cert, err := pki.DecodeX509CertificateBytes(cert)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode issued certificate: %s", err.Error())
+	}
+	caCerts, err := pki.DecodeX509CertificateChainBytes(ca)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode CA certificates: %s", err.Error())
+	}
+	intermediates, roots := issuers.SplitChain(caCerts)
+	var chain []string
+	for _, c := range append(append([]*x509.Certificate{x509Cert}, intermediates...), roots...) {
+		chain = append(chain, string(issuers.EncodeCertificates([]*x509.Certificate{c})))
+	}
+	return chain, nil
+}
@@ -0,0 +1,60 @@
+package istio
+
+import (
+	"crypto/x509"
+	"testing"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	"github.com/nokia/adcs-issuer/internal/testutil"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+func TestCertificateChain(t *testing.T) {
+	root, rootKey := testutil.NewCA(t, "Root CA", nil, nil)
+	intermediate, intermediateKey := testutil.NewCA(t, "Issuing CA", root, rootKey)
+	leaf := testutil.NewCertificate(t, &x509.Certificate{}, testutil.NewKey(t).Public(), intermediate, intermediateKey)
+	encode := func(certs ...*x509.Certificate) []byte {
+		return issuers.EncodeCertificates(certs)
+	}
+
+	tests := []struct {
+		name  string
+		cert  []byte
+		ca    []byte
+		chain []*x509.Certificate
+	}{
+		{"root first", encode(leaf), encode(root, intermediate), []*x509.Certificate{leaf, intermediate, root}},
+		{"root last", encode(leaf), encode(intermediate, root), []*x509.Certificate{leaf, intermediate, root}},
+		{"root only", encode(intermediate), encode(root), []*x509.Certificate{intermediate, root}},
+		{"no root", encode(leaf), encode(intermediate), []*x509.Certificate{leaf, intermediate}},
+		{"invalid certificate", []byte("not PEM"), encode(root), nil},
+		{"invalid CA certificates", encode(leaf), []byte("not PEM"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain, err := certificateChain(tt.cert, tt.ca)
+			if tt.chain == nil {
+				if err == nil {
+					t.Errorf("expected error, got %d certificates", len(chain))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chain) != len(tt.chain) {
+				t.Fatalf("expected %d certificates, got %d", len(tt.chain), len(chain))
+			}
+			for i, c := range chain {
+				cert, err := pki.DecodeX509CertificateBytes([]byte(c))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !cert.Equal(tt.chain[i]) {
+					t.Errorf("certificate %d: expected %s, got %s", i, tt.chain[i].Subject, cert.Subject)
+				}
+			}
+		})
+	}
+}
@@ -17,21 +17,30 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
-	certmanager "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1alpha2"
+	certmanager "github.com/jetstack/cert-manager/pkg/apis/certmanager/v1"
+	"github.com/nokia/adcs-issuer/acme"
 	adcsv1 "github.com/nokia/adcs-issuer/api/v1"
 	batchv1 "github.com/nokia/adcs-issuer/api/v1"
 	"github.com/nokia/adcs-issuer/controllers"
+	"github.com/nokia/adcs-issuer/csi"
+	"github.com/nokia/adcs-issuer/est"
 	"github.com/nokia/adcs-issuer/healthcheck"
 	"github.com/nokia/adcs-issuer/issuers"
+	"github.com/nokia/adcs-issuer/istio"
+	"github.com/nokia/adcs-issuer/scep"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/klog"
 	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	// +kubebuilder:scaffold:imports
 )
@@ -65,12 +74,108 @@ func main() {
 	var enableLeaderElection bool
 	var clusterResourceNamespace string
 	var disableApprovedCheck bool
+	var orphanSweepInterval time.Duration
+	var deleteOrphans bool
+	var pollInterval time.Duration
+	var statusChecksPerSecond float64
+	var maxConcurrentRequests int
+	var disableCSRSigner bool
+	var acmeServer acme.Server
+	var acmeIssuerKind string
+	var acmeOrderSweepInterval time.Duration
+	var estServer est.Server
+	var estIssuerNamespaces string
+	var scepServer scep.Server
+	var scepIssuerNamespaces string
+	var istioServer istio.Server
+	var istioIssuerKind string
+	var istioAudiences string
+	var csiDriver csi.Driver
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&healthcheckAddr, "healthcheck-addr", ":8081", "The address the healthcheck endpoints binds to.")
 	flag.StringVar(&webhooksPort, "webhooks-port", strconv.Itoa(defaultWebhooksPort), "Port for webhooks requests.")
 	flag.BoolVar(&disableApprovedCheck, "disable-approved-check", false,
 		"Disables waiting for CertificateRequests to have an approved condition before signing.")
 
+	flag.DurationVar(&orphanSweepInterval, "orphan-sweep-interval", time.Hour,
+		"How often to look for AdcsRequests whose CertificateRequest doesn't exist anymore. Zero disables the check.")
+	flag.BoolVar(&deleteOrphans, "delete-orphans", false,
+		"Delete AdcsRequests whose CertificateRequest doesn't exist anymore.")
+
+	flag.DurationVar(&pollInterval, "status-check-poll-interval", 30*time.Second,
+		"How often to look for pending requests whose status should be checked in ADCS.")
+	flag.Float64Var(&statusChecksPerSecond, "status-checks-per-second", 1,
+		"Maximum number of status checks of pending requests per second for each issuer.")
+	flag.IntVar(&maxConcurrentRequests, "max-concurrent-requests", 1,
+		"Maximum number of AdcsRequests processed at the same time.")
+	flag.BoolVar(&disableCSRSigner, "disable-csr-signer", false,
+		"Disables signing of Kubernetes CertificateSigningRequests.")
+
+	flag.StringVar(&acmeServer.Addr, "acme-addr", "",
+		"The address the ACME server binds to e.g. ':8443'. The ACME server is disabled if not set.")
+	flag.StringVar(&acmeServer.CertFile, "acme-tls-cert-file", "",
+		"TLS certificate file of the ACME server. Plain HTTP is served if not set.")
+	flag.StringVar(&acmeServer.KeyFile, "acme-tls-key-file", "", "TLS private key file of the ACME server.")
+	flag.StringVar(&acmeServer.ExternalURL, "acme-external-url", "",
+		"URL the ACME server is reachable at e.g. 'https://acme.example.com'. Taken from requests if not set.")
+	flag.StringVar(&acmeServer.Namespace, "acme-namespace", "",
+		"Namespace of ACME accounts and orders. Defaults to the cluster resource namespace.")
+	flag.StringVar(&acmeIssuerKind, "acme-issuer-kind", "ClusterAdcsIssuer",
+		"Kind of the issuer of ACME orders (ClusterAdcsIssuer or AdcsIssuer in the ACME namespace).")
+	flag.StringVar(&acmeServer.IssuerRef.Name, "acme-issuer-name", "", "Name of the issuer of ACME orders.")
+	flag.StringVar(&acmeServer.DNSResolver, "acme-dns-resolver", "",
+		"DNS server (host:port) used to validate dns-01 challenges. The system resolver is used if not set.")
+	flag.IntVar(&acmeServer.MaxAccounts, "acme-max-accounts", 1000,
+		"Maximum number of ACME accounts. Zero means not limited.")
+	flag.IntVar(&acmeServer.MaxOrders, "acme-max-orders", 10000,
+		"Maximum number of ACME orders. Zero means not limited.")
+	flag.IntVar(&acmeServer.MaxOrdersPerAccount, "acme-max-orders-per-account", 100,
+		"Maximum number of ACME orders of a single account. Zero means not limited.")
+	flag.DurationVar(&acmeOrderSweepInterval, "acme-order-sweep-interval", 10*time.Minute,
+		"How often to remove expired ACME orders.")
+
+	flag.StringVar(&estServer.Addr, "est-addr", "",
+		"The address the EST server binds to e.g. ':8444'. The EST server is disabled if not set.")
+	flag.StringVar(&estServer.CertFile, "est-tls-cert-file", "", "TLS certificate file of the EST server.")
+	flag.StringVar(&estServer.KeyFile, "est-tls-key-file", "", "TLS private key file of the EST server.")
+	flag.DurationVar(&estServer.Wait, "est-wait", 10*time.Second,
+		"How long EST enrollments wait for the certificate before the client is asked to re-try.")
+	flag.StringVar(&estIssuerNamespaces, "est-issuer-namespaces", "",
+		"Comma separated namespaces whose AdcsIssuers can serve EST labels. Only ClusterAdcsIssuers are served if not set.")
+
+	flag.StringVar(&scepServer.Addr, "scep-addr", "",
+		"The address the SCEP server binds to e.g. ':8080'. The SCEP server is disabled if not set.")
+	flag.StringVar(&scepServer.CertFile, "scep-tls-cert-file", "",
+		"TLS certificate file of the SCEP server. Plain HTTP is served if not set.")
+	flag.StringVar(&scepServer.KeyFile, "scep-tls-key-file", "", "TLS private key file of the SCEP server.")
+	flag.DurationVar(&scepServer.Wait, "scep-wait", 10*time.Second,
+		"How long SCEP enrollments wait for the certificate before responding with PENDING.")
+	flag.StringVar(&scepIssuerNamespaces, "scep-issuer-namespaces", "",
+		"Comma separated namespaces whose AdcsIssuers can serve SCEP labels. Only ClusterAdcsIssuers are served if not set.")
+
+	flag.StringVar(&istioServer.Addr, "istio-addr", "",
+		"The address the Istio CA server binds to e.g. ':15012'. The Istio CA server is disabled if not set.")
+	flag.StringVar(&istioServer.CertFile, "istio-tls-cert-file", "", "TLS certificate file of the Istio CA server.")
+	flag.StringVar(&istioServer.KeyFile, "istio-tls-key-file", "", "TLS private key file of the Istio CA server.")
+	flag.StringVar(&istioIssuerKind, "istio-issuer-kind", "ClusterAdcsIssuer",
+		"Kind of the issuer of mesh certificates (ClusterAdcsIssuer or AdcsIssuer in the Istio namespace).")
+	flag.StringVar(&istioServer.IssuerRef.Name, "istio-issuer-name", "", "Name of the issuer of mesh certificates.")
+	flag.StringVar(&istioServer.Namespace, "istio-namespace", "",
+		"Namespace of the AdcsIssuer of mesh certificates. Defaults to the cluster resource namespace.")
+	flag.StringVar(&istioServer.TrustDomain, "istio-trust-domain", "cluster.local", "Trust domain of the mesh SPIFFE IDs.")
+	flag.StringVar(&istioAudiences, "istio-audiences", "istio-ca",
+		"Comma separated audiences the workloads' tokens must be issued for. Any audience accepted by the API server if empty.")
+	flag.DurationVar(&istioServer.Wait, "istio-wait", 10*time.Second,
+		"How long mesh certificate requests wait for pending ADCS requests before they are cancelled.")
+
+	flag.StringVar(&csiDriver.Endpoint, "csi-endpoint", "",
+		"Runs the binary as CSI node driver serving on the socket e.g. 'unix:///csi/csi.sock' instead of the controller manager.")
+	flag.StringVar(&csiDriver.NodeID, "csi-node-id", os.Getenv("NODE_NAME"), "Name of the node the CSI driver runs on.")
+	flag.StringVar(&csiDriver.DataRoot, "csi-data-root", "/var/lib/adcs-issuer/csi",
+		"Directory where the CSI driver keeps the volumes' keys and certificates.")
+	flag.DurationVar(&csiDriver.Wait, "csi-wait", 30*time.Second,
+		"How long mounting of CSI volume waits for the certificate before kubelet is asked to re-try.")
+
 	port, err := strconv.Atoi(webhooksPort)
 	if err != nil {
 		setupLog.Error(err, "invalid webhooks port. Using default.")
@@ -88,6 +193,11 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	if csiDriver.Endpoint != "" {
+		runCSIDriver(&csiDriver)
+		return
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -115,24 +225,142 @@ func main() {
 		os.Exit(1)
 	}
 
+	issuerFactory := issuers.IssuerFactory{
+		Client:                   mgr.GetClient(),
+		Log:                      ctrl.Log.WithName("factories").WithName("AdcsIssuer"),
+		ClusterResourceNamespace: clusterResourceNamespace,
+	}
+	poller := &controllers.PendingRequestPoller{
+		Client:          mgr.GetClient(),
+		Log:             ctrl.Log.WithName("pollers").WithName("AdcsRequest"),
+		IssuerFactory:   issuerFactory,
+		Interval:        pollInterval,
+		ChecksPerSecond: statusChecksPerSecond,
+	}
 	if err = (&controllers.AdcsRequestReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("AdcsRequest"),
-		IssuerFactory: issuers.IssuerFactory{
-			Client:                   mgr.GetClient(),
-			Log:                      ctrl.Log.WithName("factories").WithName("AdcsIssuer"),
-			ClusterResourceNamespace: clusterResourceNamespace,
-		},
+		Client:                       mgr.GetClient(),
+		Log:                          ctrl.Log.WithName("controllers").WithName("AdcsRequest"),
+		IssuerFactory:                issuerFactory,
 		Recorder:                     mgr.GetEventRecorderFor("adcs-requests-controller"),
 		CertificateRequestController: certificateRequestReconciler,
+		Poller:                       poller,
+		MaxConcurrentReconciles:      maxConcurrentRequests,
+		APIReader:                    mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AdcsRequest")
 		os.Exit(1)
 	}
+	if err = mgr.Add(poller); err != nil {
+		setupLog.Error(err, "unable to create poller", "poller", "AdcsRequest")
+		os.Exit(1)
+	}
+
+	if !disableCSRSigner {
+		if err = (&controllers.CertificateSigningRequestReconciler{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("controllers").WithName("CertificateSigningRequest"),
+			IssuerFactory: issuerFactory,
+			Recorder:      mgr.GetEventRecorderFor("adcs-csr-signer"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "CertificateSigningRequest")
+			os.Exit(1)
+		}
+	}
+
+	if acmeServer.Addr != "" {
+		if acmeServer.IssuerRef.Name == "" {
+			setupLog.Error(fmt.Errorf("--acme-issuer-name not set"), "unable to create ACME server")
+			os.Exit(1)
+		}
+		if acmeServer.Namespace == "" {
+			acmeServer.Namespace = clusterResourceNamespace
+		}
+		acmeServer.IssuerRef.Group = adcsv1.GroupVersion.Group
+		acmeServer.IssuerRef.Kind = acmeIssuerKind
+		acmeServer.Client = mgr.GetClient()
+		acmeServer.Reader = mgr.GetAPIReader()
+		acmeServer.Log = ctrl.Log.WithName("servers").WithName("ACME")
+		if err = mgr.Add(&acmeServer); err != nil {
+			setupLog.Error(err, "unable to create ACME server")
+			os.Exit(1)
+		}
+		if err = mgr.Add(&acme.OrderSweeper{Server: &acmeServer, Interval: acmeOrderSweepInterval}); err != nil {
+			setupLog.Error(err, "unable to create sweeper", "sweeper", "AcmeOrder")
+			os.Exit(1)
+		}
+	}
+
+	if estServer.Addr != "" {
+		if estServer.CertFile == "" || estServer.KeyFile == "" {
+			setupLog.Error(fmt.Errorf("--est-tls-cert-file and --est-tls-key-file must be set"), "unable to create EST server")
+			os.Exit(1)
+		}
+		estServer.Client = mgr.GetClient()
+		estServer.Reader = mgr.GetAPIReader()
+		estServer.Log = ctrl.Log.WithName("servers").WithName("EST")
+		estServer.IssuerFactory = issuerFactory
+		estServer.ClusterResourceNamespace = clusterResourceNamespace
+		estServer.IssuerNamespaces = splitList(estIssuerNamespaces)
+		if err = mgr.Add(&estServer); err != nil {
+			setupLog.Error(err, "unable to create EST server")
+			os.Exit(1)
+		}
+	}
+
+	if scepServer.Addr != "" {
+		scepServer.Client = mgr.GetClient()
+		scepServer.Reader = mgr.GetAPIReader()
+		scepServer.Log = ctrl.Log.WithName("servers").WithName("SCEP")
+		scepServer.IssuerFactory = issuerFactory
+		scepServer.ClusterResourceNamespace = clusterResourceNamespace
+		scepServer.IssuerNamespaces = splitList(scepIssuerNamespaces)
+		if err = mgr.Add(&scepServer); err != nil {
+			setupLog.Error(err, "unable to create SCEP server")
+			os.Exit(1)
+		}
+	}
+
+	if istioServer.Addr != "" {
+		if istioServer.IssuerRef.Name == "" {
+			setupLog.Error(fmt.Errorf("--istio-issuer-name not set"), "unable to create Istio CA server")
+			os.Exit(1)
+		}
+		if istioServer.CertFile == "" || istioServer.KeyFile == "" {
+			setupLog.Error(fmt.Errorf("--istio-tls-cert-file and --istio-tls-key-file must be set"), "unable to create Istio CA server")
+			os.Exit(1)
+		}
+		if istioServer.Namespace == "" {
+			istioServer.Namespace = clusterResourceNamespace
+		}
+		istioServer.IssuerRef.Group = adcsv1.GroupVersion.Group
+		istioServer.IssuerRef.Kind = istioIssuerKind
+		istioServer.Audiences = splitList(istioAudiences)
+		istioServer.Client = mgr.GetClient()
+		istioServer.Log = ctrl.Log.WithName("servers").WithName("Istio")
+		istioServer.IssuerFactory = issuerFactory
+		if err = mgr.Add(&istioServer); err != nil {
+			setupLog.Error(err, "unable to create Istio CA server")
+			os.Exit(1)
+		}
+	}
+
+	if orphanSweepInterval > 0 {
+		if err = mgr.Add(&controllers.AdcsRequestSweeper{
+			Client:        mgr.GetClient(),
+			Log:           ctrl.Log.WithName("sweepers").WithName("AdcsRequest"),
+			Recorder:      mgr.GetEventRecorderFor("adcs-requests-sweeper"),
+			Interval:      orphanSweepInterval,
+			DeleteOrphans: deleteOrphans,
+		}); err != nil {
+			setupLog.Error(err, "unable to create sweeper", "sweeper", "AdcsRequest")
+			os.Exit(1)
+		}
+	}
 
 	if err = (&controllers.AdcsIssuerReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("AdcsIssuer"),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("AdcsIssuer"),
+		Recorder: mgr.GetEventRecorderFor("adcs-issuers-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AdcsIssuer")
 		os.Exit(1)
@@ -144,8 +372,9 @@ func main() {
 	}
 
 	if err = (&controllers.ClusterAdcsIssuerReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ClusterAdcsIssuer"),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ClusterAdcsIssuer"),
+		Recorder: mgr.GetEventRecorderFor("adcs-issuers-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClusterAdcsIssuer")
 		os.Exit(1)
@@ -163,3 +392,35 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// Run the CSI node driver. It only creates AdcsRequests processed by the controller manager.
+func runCSIDriver(driver *csi.Driver) {
+	if driver.NodeID == "" {
+		setupLog.Error(fmt.Errorf("--csi-node-id not set"), "unable to create CSI driver")
+		os.Exit(1)
+	}
+	c, err := client.New(ctrl.GetConfigOrDie(), client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		os.Exit(1)
+	}
+	driver.Client = c
+	driver.Log = ctrl.Log.WithName("csi")
+	if err := driver.Start(ctrl.SetupSignalHandler()); err != nil {
+		setupLog.Error(err, "problem running CSI driver")
+		os.Exit(1)
+	}
+}
+
+// Values of a comma separated list flag without empty and repeated values
+func splitList(list string) []string {
+	var values []string
+	seen := map[string]bool{}
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" && !seen[value] {
+			values = append(values, value)
+			seen[value] = true
+		}
+	}
+	return values
+}
@@ -0,0 +1,132 @@
+package scep
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+)
+
+const challengePasswordKey = "password"
+
+var oidChallengePassword = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+
+// CertificationRequestInfo with the attributes kept raw
+// (x509.CertificateRequest parses only the extension request).
+type certificationRequestInfo struct {
+	Version    int
+	Subject    asn1.RawValue
+	PublicKey  asn1.RawValue
+	Attributes []csrAttribute `asn1:"tag:0"`
+}
+
+type csrAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue `asn1:"set"`
+}
+
+// Get the challengePassword attribute of the CSR (RFC 2985 section 5.4.1)
+func challengePassword(csr *x509.CertificateRequest) (string, error) {
+	var info certificationRequestInfo
+	if _, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &info); err != nil {
+		return "", fmt.Errorf("Cannot parse CSR attributes: %s", err.Error())
+	}
+	for _, attr := range info.Attributes {
+		if attr.Type.Equal(oidChallengePassword) {
+			// The attribute has a single DirectoryString value
+			var password string
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &password); err != nil {
+				return "", fmt.Errorf("Cannot parse challenge password: %s", err.Error())
+			}
+			return password, nil
+		}
+	}
+	return "", fmt.Errorf("CSR has no challenge password")
+}
+
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=delete
+
+// Check the challenge password of the CSR against the issuer's SCEP policy.
+// A matching one-time password is used up by deleting its Secret.
+func (s *Server) checkChallenge(ctx context.Context, label string, issuer *scepIssuer, csr *x509.CertificateRequest) error {
+	password, err := challengePassword(csr)
+	if err != nil {
+		return err
+	}
+	for _, name := range issuer.policy.ChallengePasswordSecretRefs {
+		secret := new(core.Secret)
+		if err := s.Client.Get(ctx, client.ObjectKey{Namespace: issuer.namespace, Name: name}, secret); err != nil {
+			s.Log.Error(err, "Cannot get SCEP challenge password Secret", "secret", name)
+			continue
+		}
+		if passwordMatches(secret, password) {
+			return nil
+		}
+	}
+	if !issuer.policy.OneTimeChallenges {
+		return fmt.Errorf("Invalid challenge password")
+	}
+
+	secrets := new(core.SecretList)
+	if err := s.Client.List(ctx, secrets, client.InNamespace(issuer.namespace),
+		client.MatchingLabels{api.SCEPChallengeLabel: label}); err != nil {
+		return fmt.Errorf("Cannot list one-time challenge passwords: %s", err.Error())
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !passwordMatches(secret, password) {
+			continue
+		}
+		// Only one of concurrent enrollments (e.g. on other replicas) deletes the Secret
+		err := s.Client.Delete(ctx, secret, client.Preconditions{UID: &secret.UID, ResourceVersion: &secret.ResourceVersion})
+		if apierrors.IsNotFound(err) || apierrors.IsConflict(err) {
+			return fmt.Errorf("Challenge password already used")
+		}
+		if err != nil {
+			return fmt.Errorf("Cannot use up challenge password Secret %s: %s", secret.Name, err.Error())
+		}
+		s.Log.Info("One-time SCEP challenge password used", "secret", secret.Name)
+		return nil
+	}
+	return fmt.Errorf("Invalid challenge password")
+}
+
+func passwordMatches(secret *core.Secret, password string) bool {
+	expected, ok := secret.Data[challengePasswordKey]
+	return ok && len(expected) > 0 && subtle.ConstantTimeCompare(expected, []byte(password)) == 1
+}
+
+// Load the RA certificate and key of the issuer's SCEP policy
+func (s *Server) raCredentials(ctx context.Context, issuer *scepIssuer) (*raCredentials, error) {
+	secret := new(core.Secret)
+	if err := s.Client.Get(ctx, client.ObjectKey{Namespace: issuer.namespace, Name: issuer.policy.RASecretRef}, secret); err != nil {
+		return nil, fmt.Errorf("Cannot get RA Secret %s: %s", issuer.policy.RASecretRef, err.Error())
+	}
+	cert, err := pki.DecodeX509CertificateBytes(secret.Data[core.TLSCertKey])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode RA certificate: %s", err.Error())
+	}
+	signer, err := pki.DecodePrivateKeyBytes(secret.Data[core.TLSPrivateKeyKey])
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode RA private key: %s", err.Error())
+	}
+	// Clients encrypt the requests to the RA certificate with RSA key transport
+	key, ok := signer.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("RA private key must be RSA")
+	}
+	if matches, err := pki.PublicKeyMatchesCertificate(key.Public(), cert); err != nil || !matches {
+		return nil, fmt.Errorf("RA private key doesn't match the certificate")
+	}
+	return &raCredentials{cert: cert, key: key}, nil
+}
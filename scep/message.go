@@ -0,0 +1,149 @@
+package scep
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+
+	cms "go.mozilla.org/pkcs7"
+)
+
+// Message types (RFC 8894 section 3.2.1.2)
+const (
+	messageTypeCertRep        = "3"
+	messageTypePKCSReq        = "19"
+	messageTypeGetCertInitial = "20"
+)
+
+// PKI statuses (RFC 8894 section 3.2.1.3)
+type pkiStatus string
+
+const (
+	statusSuccess pkiStatus = "0"
+	statusFailure pkiStatus = "2"
+	statusPending pkiStatus = "3"
+)
+
+// Failure reasons (RFC 8894 section 3.2.1.4)
+type failInfo string
+
+const (
+	failBadAlg          failInfo = "0"
+	failBadMessageCheck failInfo = "1"
+	failBadRequest      failInfo = "2"
+	failBadCertID       failInfo = "4"
+)
+
+// SCEP authenticated attributes (RFC 8894 section 3.2.1)
+var (
+	oidMessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidPKIStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidFailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidSenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidRecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidTransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+)
+
+const nonceSize = 16
+
+// Request of a SCEP client
+type pkiMessage struct {
+	messageType   string
+	transactionID string
+	senderNonce   []byte
+	// Certificate the message is signed with. Responses are encrypted to it.
+	signer *x509.Certificate
+	// Decrypted content of the pkcsPKIEnvelope
+	content []byte
+}
+
+// Registration authority certificate and key
+type raCredentials struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+// Verify the signature of the pkiMessage and decrypt its content with the RA key
+func parseMessage(der []byte, ra *raCredentials) (*pkiMessage, error) {
+	p7, err := cms.Parse(der)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse message: %s", err.Error())
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, fmt.Errorf("Invalid message signature: %s", err.Error())
+	}
+	msg := &pkiMessage{signer: p7.GetOnlySigner()}
+	if msg.signer == nil {
+		return nil, fmt.Errorf("Message must have exactly one signer")
+	}
+	if err := p7.UnmarshalSignedAttribute(oidMessageType, &msg.messageType); err != nil {
+		return nil, fmt.Errorf("Cannot get message type: %s", err.Error())
+	}
+	if err := p7.UnmarshalSignedAttribute(oidTransactionID, &msg.transactionID); err != nil || msg.transactionID == "" {
+		return nil, fmt.Errorf("Missing transaction ID")
+	}
+	if err := p7.UnmarshalSignedAttribute(oidSenderNonce, &msg.senderNonce); err != nil {
+		return nil, fmt.Errorf("Missing sender nonce")
+	}
+
+	envelope, err := cms.Parse(p7.Content)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot parse envelope: %s", err.Error())
+	}
+	if msg.content, err = envelope.Decrypt(ra.cert, ra.key); err != nil {
+		return nil, fmt.Errorf("Cannot decrypt envelope: %s", err.Error())
+	}
+	return msg, nil
+}
+
+// Create the CertRep message signed by the RA. The content (certs-only message
+// of successful responses) is encrypted to the certificate the request was signed with.
+func certRep(ra *raCredentials, req *pkiMessage, status pkiStatus, info failInfo, content []byte) ([]byte, error) {
+	var envelope []byte
+	if content != nil {
+		var err error
+		if envelope, err = encrypt(content, req.signer, responseEncryption); err != nil {
+			return nil, fmt.Errorf("Cannot encrypt response: %s", err.Error())
+		}
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	attributes := []cms.Attribute{
+		{Type: oidMessageType, Value: messageTypeCertRep},
+		{Type: oidPKIStatus, Value: string(status)},
+		{Type: oidTransactionID, Value: req.transactionID},
+		{Type: oidSenderNonce, Value: nonce},
+		{Type: oidRecipientNonce, Value: req.senderNonce},
+	}
+	if status == statusFailure {
+		attributes = append(attributes, cms.Attribute{Type: oidFailInfo, Value: string(info)})
+	}
+
+	sd, err := cms.NewSignedData(envelope)
+	if err != nil {
+		return nil, err
+	}
+	sd.SetDigestAlgorithm(cms.OIDDigestAlgorithmSHA256)
+	if err := sd.AddSigner(ra.cert, ra.key, cms.SignerInfoConfig{ExtraSignedAttributes: attributes}); err != nil {
+		return nil, fmt.Errorf("Cannot sign response: %s", err.Error())
+	}
+	return sd.Finish()
+}
+
+// The initial enrollment is signed with a self-signed certificate of the requested key
+func signedWithKeyOf(msg *pkiMessage, csr *x509.CertificateRequest) bool {
+	signerKey, err := x509.MarshalPKIXPublicKey(msg.signer.PublicKey)
+	if err != nil {
+		return false
+	}
+	csrKey, err := x509.MarshalPKIXPublicKey(csr.PublicKey)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(signerKey, csrKey)
+}
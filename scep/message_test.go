@@ -0,0 +1,222 @@
+package scep
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"testing"
+
+	cms "go.mozilla.org/pkcs7"
+
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+// Self-signed certificate as used by SCEP clients and the RA
+func newSelfSigned(t *testing.T, cn string, key *rsa.PrivateKey) *x509.Certificate {
+	return testutil.NewCertificate(t, &x509.Certificate{Subject: pkix.Name{CommonName: cn}}, key.Public(), nil, key)
+}
+
+// CSR with the challengePassword attribute (not supported by x509.CreateCertificateRequest)
+func newChallengeCSR(t *testing.T, cn string, key *rsa.PrivateKey, password string) []byte {
+	block, _ := pem.Decode(testutil.NewCSR(t, key, &x509.CertificateRequest{Subject: pkix.Name{CommonName: cn}}))
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info certificationRequestInfo
+	if _, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &info); err != nil {
+		t.Fatal(err)
+	}
+	if password != "" {
+		value, err := asn1.MarshalWithParams(password, "printable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		info.Attributes = append(info.Attributes, csrAttribute{
+			Type:   oidChallengePassword,
+			Values: asn1.RawValue{Tag: asn1.TagSet, IsCompound: true, Bytes: value},
+		})
+	}
+	tbs, err := asn1.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(tbs)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := asn1.Marshal(struct {
+		TBS       asn1.RawValue
+		Algorithm pkix.AlgorithmIdentifier
+		Signature asn1.BitString
+	}{
+		TBS:       asn1.RawValue{FullBytes: tbs},
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, Parameters: asn1.NullRawValue},
+		Signature: asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+// pkiMessage of a client: content encrypted to the RA and signed by the client
+func newTestMessage(t *testing.T, ra *raCredentials, signer *x509.Certificate, key *rsa.PrivateKey,
+	messageType, transactionID string, content []byte) []byte {
+	envelope, err := encrypt(content, ra.cert, responseEncryption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd, err := cms.NewSignedData(envelope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sd.SetDigestAlgorithm(cms.OIDDigestAlgorithmSHA256)
+	var attributes []cms.Attribute
+	if messageType != "" {
+		attributes = append(attributes, cms.Attribute{Type: oidMessageType, Value: messageType})
+	}
+	if transactionID != "" {
+		attributes = append(attributes, cms.Attribute{Type: oidTransactionID, Value: transactionID})
+	}
+	attributes = append(attributes, cms.Attribute{Type: oidSenderNonce, Value: []byte("0123456789abcdef")})
+	if err := sd.AddSigner(signer, key, cms.SignerInfoConfig{ExtraSignedAttributes: attributes}); err != nil {
+		t.Fatal(err)
+	}
+	der, err := sd.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func newTestRA(t *testing.T) *raCredentials {
+	key := testutil.NewRSAKey(t)
+	return &raCredentials{cert: newSelfSigned(t, "SCEP RA", key), key: key}
+}
+
+func TestParseMessage(t *testing.T) {
+	ra := newTestRA(t)
+	key := testutil.NewRSAKey(t)
+	signer := newSelfSigned(t, "device-1", key)
+	csr := newChallengeCSR(t, "device-1", key, "secret")
+
+	msg, err := parseMessage(newTestMessage(t, ra, signer, key, messageTypePKCSReq, "transaction-1", csr), ra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.messageType != messageTypePKCSReq || msg.transactionID != "transaction-1" {
+		t.Errorf("unexpected message type %s or transaction ID %s", msg.messageType, msg.transactionID)
+	}
+	if !bytes.Equal(msg.content, csr) {
+		t.Errorf("decrypted content doesn't match the CSR")
+	}
+	if !msg.signer.Equal(signer) {
+		t.Errorf("unexpected signer %s", msg.signer.Subject)
+	}
+	parsed, err := x509.ParseCertificateRequest(msg.content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !signedWithKeyOf(msg, parsed) {
+		t.Errorf("expected message signed with the key of the CSR")
+	}
+
+	otherRA := newTestRA(t)
+	tampered := newTestMessage(t, ra, signer, key, messageTypePKCSReq, "transaction-1", csr)
+	tampered[len(tampered)-1] ^= 0xff
+	tests := []struct {
+		name string
+		der  []byte
+		ra   *raCredentials
+	}{
+		{"not CMS", []byte("message"), ra},
+		{"invalid signature", tampered, ra},
+		{"no transaction ID", newTestMessage(t, ra, signer, key, messageTypePKCSReq, "", csr), ra},
+		{"no message type", newTestMessage(t, ra, signer, key, "", "transaction-1", csr), ra},
+		{"encrypted to another RA", newTestMessage(t, otherRA, signer, key, messageTypePKCSReq, "transaction-1", csr), ra},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseMessage(tt.der, tt.ra); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestCertRep(t *testing.T) {
+	ra := newTestRA(t)
+	key := testutil.NewRSAKey(t)
+	req := &pkiMessage{
+		messageType:   messageTypePKCSReq,
+		transactionID: "transaction-1",
+		senderNonce:   []byte("0123456789abcdef"),
+		signer:        newSelfSigned(t, "device-1", key),
+	}
+
+	der, err := certRep(ra, req, statusSuccess, "", []byte("certificates"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := cms.Parse(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p7.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if signer := p7.GetOnlySigner(); signer == nil || !signer.Equal(ra.cert) {
+		t.Errorf("expected response signed by the RA")
+	}
+	var messageType, status, transactionID string
+	var recipientNonce []byte
+	for oid, v := range map[*asn1.ObjectIdentifier]interface{}{
+		&oidMessageType:    &messageType,
+		&oidPKIStatus:      &status,
+		&oidTransactionID:  &transactionID,
+		&oidRecipientNonce: &recipientNonce,
+	} {
+		if err := p7.UnmarshalSignedAttribute(*oid, v); err != nil {
+			t.Fatalf("attribute %s: %v", oid, err)
+		}
+	}
+	if messageType != messageTypeCertRep || status != string(statusSuccess) || transactionID != req.transactionID ||
+		!bytes.Equal(recipientNonce, req.senderNonce) {
+		t.Errorf("unexpected attributes: %s, %s, %s, %x", messageType, status, transactionID, recipientNonce)
+	}
+
+	envelope, err := cms.Parse(p7.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := envelope.Decrypt(req.signer, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "certificates" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	der, err = certRep(ra, req, statusFailure, failBadRequest, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p7, err = cms.Parse(der); err != nil {
+		t.Fatal(err)
+	}
+	var info string
+	if err := p7.UnmarshalSignedAttribute(oidFailInfo, &info); err != nil || info != string(failBadRequest) {
+		t.Errorf("expected failInfo %s, got %s, %v", failBadRequest, info, err)
+	}
+	if len(p7.Content) != 0 {
+		t.Errorf("expected no content in failure")
+	}
+}
@@ -0,0 +1,382 @@
+// Package scep implements a SCEP (RFC 8894) server for legacy devices that can't use
+// certsrv or EST. SCEP labels are mapped to issuers by their 'scep' policy.
+// Enrollments create AdcsRequests which are sent to ADCS by the AdcsRequest controller.
+package scep
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	"github.com/jetstack/cert-manager/pkg/util/pki"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/issuers"
+)
+
+const (
+	basePath = "/scep"
+	// Path suffix appended by some clients
+	cgiSuffix = "/pkiclient.exe"
+	// Label used by requests without one
+	defaultLabel = api.DefaultLabel
+
+	pollInterval    = time.Second
+	maxRequestSize  = 64 * 1024
+	shutdownTimeout = 10 * time.Second
+)
+
+// Capabilities announced by GetCACaps (RFC 8894 section 3.5.2).
+// Renewal isn't supported as ADCS doesn't know the SCEP client certificates.
+var capabilities = []string{"AES", "POSTPKIOperation", "SHA-256", "SCEPStandard"}
+
+// Server is the SCEP server. It implements manager.Runnable.
+type Server struct {
+	// Reads issuers and Secrets, writes AdcsRequests
+	Client client.Client
+	// Reads AdcsRequests bypassing the cache
+	Reader        client.Reader
+	Log           logr.Logger
+	IssuerFactory issuers.IssuerFactory
+
+	// Address to listen on e.g. ':8080'
+	Addr string
+	// TLS certificate and key files of the server. SCEP messages are signed
+	// and encrypted so plain HTTP is served if not set.
+	CertFile string
+	KeyFile  string
+	// Namespace of ClusterAdcsIssuers' Secrets and AdcsRequests
+	ClusterResourceNamespace string
+	// Namespaces whose AdcsIssuers can serve SCEP labels other than the default one.
+	// Only ClusterAdcsIssuers are served if empty.
+	IssuerNamespaces []string
+	// How long to wait for the certificate before responding with PENDING
+	Wait time.Duration
+}
+
+// Issuer serving a SCEP label
+type scepIssuer struct {
+	ref cmmeta.ObjectReference
+	// Namespace of the Secrets and AdcsRequests
+	namespace string
+	policy    *api.SCEPPolicy
+}
+
+// Start implements manager.Runnable
+func (s *Server) Start(ctx context.Context) error {
+	server := &http.Server{Addr: s.Addr, Handler: s}
+	errs := make(chan error, 1)
+	go func() {
+		s.Log.Info("Starting SCEP server", "addr", s.Addr)
+		if s.CertFile != "" {
+			errs <- server.ListenAndServeTLS(s.CertFile, s.KeyFile)
+		} else {
+			errs <- server.ListenAndServe()
+		}
+	}()
+	select {
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	case err := <-errs:
+		return err
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable.
+// The server runs in all replicas.
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// Requests are sent to '/scep[/<label>][/pkiclient.exe]?operation=<operation>'
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(strings.TrimSuffix(r.URL.Path, cgiSuffix), "/")
+	label := defaultLabel
+	switch {
+	case path == basePath:
+	case strings.HasPrefix(path, basePath+"/") && !strings.Contains(strings.TrimPrefix(path, basePath+"/"), "/"):
+		label = strings.TrimPrefix(path, basePath+"/")
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	issuer, err := s.findIssuer(r.Context(), label)
+	if err != nil {
+		s.Log.Error(err, "Cannot find issuer", "label", label)
+		http.Error(w, "Cannot find issuer", http.StatusInternalServerError)
+		return
+	}
+	if issuer == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	operation := r.URL.Query().Get("operation")
+	switch {
+	case operation == "GetCACaps" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Join(capabilities, "\n")))
+	case operation == "GetCACert" && r.Method == http.MethodGet:
+		s.caCert(w, r, issuer)
+	case operation == "PKIOperation" && (r.Method == http.MethodGet || r.Method == http.MethodPost):
+		s.pkiOperation(w, r, label, issuer)
+	case operation == "GetCACaps" || operation == "GetCACert" || operation == "PKIOperation":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, fmt.Sprintf("Unsupported operation '%s'", operation), http.StatusBadRequest)
+	}
+}
+
+// Distribute the RA certificate followed by the CA certificates (RFC 8894 section 4.2.1.2)
+func (s *Server) caCert(w http.ResponseWriter, r *http.Request, issuer *scepIssuer) {
+	ra, err := s.raCredentials(r.Context(), issuer)
+	if err != nil {
+		s.Log.Error(err, "Cannot get RA certificate", "issuer", issuer.ref)
+		http.Error(w, "Cannot get RA certificate", http.StatusServiceUnavailable)
+		return
+	}
+	i, err := s.IssuerFactory.GetIssuer(r.Context(), issuer.ref, issuer.namespace)
+	if err != nil {
+		s.Log.Error(err, "Cannot get issuer", "issuer", issuer.ref)
+		http.Error(w, "Issuer not ready", http.StatusServiceUnavailable)
+		return
+	}
+	caCerts, err := i.CACertificates(r.Context())
+	if err != nil {
+		s.Log.Error(err, "Cannot get CA certificates", "issuer", issuer.ref)
+		http.Error(w, "Cannot get CA certificates", http.StatusServiceUnavailable)
+		return
+	}
+	der, err := issuers.EncodeCertificatesPKCS7(append([]*x509.Certificate{ra.cert}, caCerts...))
+	if err != nil {
+		s.Log.Error(err, "Cannot encode certificates")
+		http.Error(w, "Cannot encode certificates", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-x509-ca-ra-cert")
+	w.Write(der)
+}
+
+// Handle PKCSReq and GetCertInitial messages. Messages are sent base64 encoded
+// in the 'message' parameter of GET requests or as the body of POST requests.
+func (s *Server) pkiOperation(w http.ResponseWriter, r *http.Request, label string, issuer *scepIssuer) {
+	var der []byte
+	var err error
+	if r.Method == http.MethodGet {
+		der, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(r.URL.Query().Get("message")), ""))
+	} else {
+		der, err = ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	}
+	if err != nil {
+		http.Error(w, "Cannot read message", http.StatusBadRequest)
+		return
+	}
+
+	ra, err := s.raCredentials(r.Context(), issuer)
+	if err != nil {
+		s.Log.Error(err, "Cannot get RA credentials", "issuer", issuer.ref)
+		http.Error(w, "Cannot get RA credentials", http.StatusServiceUnavailable)
+		return
+	}
+	msg, err := parseMessage(der, ra)
+	if err != nil {
+		// There's nobody to send a signed failure to
+		s.Log.Info("Invalid SCEP message", "label", label, "reason", err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log := s.Log.WithValues("label", label, "transactionID", msg.transactionID)
+	var status pkiStatus
+	var info failInfo
+	var content []byte
+	switch msg.messageType {
+	case messageTypePKCSReq:
+		status, info, content = s.enroll(r.Context(), log, label, issuer, msg)
+	case messageTypeGetCertInitial:
+		status, info, content = s.certInitial(r.Context(), log, issuer, msg)
+	default:
+		log.Info("Unsupported SCEP message type", "messageType", msg.messageType)
+		status, info = statusFailure, failBadRequest
+	}
+
+	rep, err := certRep(ra, msg, status, info, content)
+	if err != nil {
+		log.Error(err, "Cannot create SCEP response")
+		http.Error(w, "Cannot create response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pki-message")
+	w.Write(rep)
+}
+
+// Create AdcsRequest for the CSR of PKCSReq (or find the one created by a previous attempt)
+// and wait for the certificate.
+func (s *Server) enroll(ctx context.Context, log logr.Logger, label string, issuer *scepIssuer, msg *pkiMessage) (pkiStatus, failInfo, []byte) {
+	csr, err := x509.ParseCertificateRequest(msg.content)
+	if err == nil {
+		err = csr.CheckSignature()
+	}
+	if err != nil {
+		log.Info("Invalid CSR", "reason", err.Error())
+		return statusFailure, failBadRequest, nil
+	}
+	if !signedWithKeyOf(msg, csr) {
+		log.Info("PKCSReq not signed with the requested key")
+		return statusFailure, failBadMessageCheck, nil
+	}
+	if _, ok := msg.signer.PublicKey.(*rsa.PublicKey); !ok {
+		// The issued certificate is encrypted to the requester's key
+		log.Info("PKCSReq requires RSA key")
+		return statusFailure, failBadAlg, nil
+	}
+
+	csrPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: msg.content})
+	key := requestKey(issuer, msg.transactionID)
+	log = log.WithValues("adcsrequest", key)
+	ar := new(api.AdcsRequest)
+	err = s.Reader.Get(ctx, key, ar)
+	if apierrors.IsNotFound(err) {
+		// Re-sent PKCSReq of a requested enrollment isn't checked again as
+		// its one-time challenge password was already used up.
+		if err := s.checkChallenge(ctx, label, issuer, csr); err != nil {
+			log.Info("SCEP client not authenticated", "subject", csr.Subject.String(), "reason", err.Error())
+			return statusFailure, failBadRequest, nil
+		}
+		ar = &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        key.Name,
+				Namespace:   key.Namespace,
+				Labels:      map[string]string{api.SCEPLabel: label},
+				Annotations: map[string]string{api.SCEPTransactionAnnotation: msg.transactionID},
+			},
+			Spec: api.AdcsRequestSpec{
+				CSRPEM:    csrPEM,
+				IssuerRef: issuer.ref,
+			},
+		}
+		err = s.Client.Create(ctx, ar)
+		if err == nil {
+			log.Info("SCEP enrollment requested", "subject", csr.Subject.String())
+		}
+	}
+	if err != nil {
+		log.Error(err, "Cannot get AdcsRequest")
+		return statusFailure, failBadRequest, nil
+	}
+	if ar.Annotations[api.SCEPTransactionAnnotation] != msg.transactionID || string(ar.Spec.CSRPEM) != string(csrPEM) {
+		// Re-sent PKCSReq must be the same (RFC 8894 section 3.3.1)
+		log.Info("PKCSReq doesn't match the pending request of the transaction")
+		return statusFailure, failBadRequest, nil
+	}
+	return s.waitForCertificate(ctx, log, ar)
+}
+
+// Poll for the certificate of a pending PKCSReq. The request's status is checked
+// on ADCS immediately by the AdcsRequest controller using the stored request ID.
+func (s *Server) certInitial(ctx context.Context, log logr.Logger, issuer *scepIssuer, msg *pkiMessage) (pkiStatus, failInfo, []byte) {
+	key := requestKey(issuer, msg.transactionID)
+	log = log.WithValues("adcsrequest", key)
+	ar := new(api.AdcsRequest)
+	if err := s.Reader.Get(ctx, key, ar); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "Cannot get AdcsRequest")
+		}
+		return statusFailure, failBadCertID, nil
+	}
+	csr, err := pki.DecodeX509CertificateRequestBytes(ar.Spec.CSRPEM)
+	if err != nil || ar.Annotations[api.SCEPTransactionAnnotation] != msg.transactionID || !signedWithKeyOf(msg, csr) {
+		log.Info("GetCertInitial doesn't match the request of the transaction")
+		return statusFailure, failBadCertID, nil
+	}
+	if _, ok := ar.Annotations[api.CheckNowAnnotation]; ar.Status.State == api.Pending && ar.Status.Id != "" && !ok {
+		patch := client.MergeFrom(ar.DeepCopy())
+		ar.Annotations[api.CheckNowAnnotation] = "true"
+		if err := s.Client.Patch(ctx, ar, patch); err != nil {
+			log.Error(err, "Cannot request status check", "annotation", api.CheckNowAnnotation)
+		}
+	}
+	return s.waitForCertificate(ctx, log, ar)
+}
+
+// Wait for the AdcsRequest controller and map the request's state to the SCEP status.
+// Completed requests are removed so that the transaction can be started again.
+func (s *Server) waitForCertificate(ctx context.Context, log logr.Logger, ar *api.AdcsRequest) (pkiStatus, failInfo, []byte) {
+	key := client.ObjectKey{Namespace: ar.Namespace, Name: ar.Name}
+	err := wait.PollImmediate(pollInterval, s.Wait, func() (bool, error) {
+		if err := s.Reader.Get(ctx, key, ar); err != nil {
+			return false, err
+		}
+		return ar.Status.State != api.Unknown && ar.Status.State != api.Pending, nil
+	})
+	if err != nil && err != wait.ErrWaitTimeout {
+		log.Error(err, "Cannot get AdcsRequest")
+		return statusPending, "", nil
+	}
+
+	var status pkiStatus
+	var info failInfo
+	var content []byte
+	switch ar.Status.State {
+	case api.Ready:
+		cert, err := pki.DecodeX509CertificateBytes(ar.Status.Certificate)
+		if err == nil {
+			content, err = issuers.EncodeCertificatesPKCS7([]*x509.Certificate{cert})
+		}
+		if err != nil {
+			log.Error(err, "Cannot encode issued certificate")
+			return statusFailure, failBadRequest, nil
+		}
+		log.Info("SCEP enrollment completed", "serialNumber", ar.Status.SerialNumber)
+		status = statusSuccess
+	case api.Rejected, api.Errored:
+		log.Info("SCEP enrollment failed", "state", ar.Status.State, "reason", ar.Status.Reason)
+		status, info = statusFailure, failBadRequest
+	default:
+		return statusPending, "", nil
+	}
+	if err := s.Client.Delete(context.Background(), ar); client.IgnoreNotFound(err) != nil {
+		log.Error(err, "Cannot delete AdcsRequest")
+	}
+	return status, info, content
+}
+
+// AdcsRequests are found by the transaction ID chosen by the client
+func requestKey(issuer *scepIssuer, transactionID string) client.ObjectKey {
+	sum := sha256.Sum256([]byte(transactionID))
+	return client.ObjectKey{Namespace: issuer.namespace, Name: "scep-" + hex.EncodeToString(sum[:10])}
+}
+
+// Find the issuer with the label in its SCEP policy
+func (s *Server) findIssuer(ctx context.Context, label string) (*scepIssuer, error) {
+	found, err := issuers.FindLabeledIssuer(ctx, s.Client, s.ClusterResourceNamespace, s.IssuerNamespaces, label,
+		func(i *issuers.LabeledIssuer) []string {
+			if i.SCEP == nil {
+				return nil
+			}
+			return i.SCEP.Labels
+		})
+	if found == nil || err != nil {
+		return nil, err
+	}
+	return &scepIssuer{ref: found.Ref, namespace: found.Namespace, policy: found.SCEP}, nil
+}
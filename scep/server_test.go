@@ -0,0 +1,203 @@
+package scep
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	cmmeta "github.com/jetstack/cert-manager/pkg/apis/meta/v1"
+	core "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	api "github.com/nokia/adcs-issuer/api/v1"
+	"github.com/nokia/adcs-issuer/internal/testutil"
+)
+
+const testNamespace = "adcs-issuer-system"
+
+func newTestServer(t *testing.T, objects ...client.Object) *Server {
+	scheme := runtime.NewScheme()
+	if err := api.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	return &Server{Client: c, Reader: c, Log: logr.Discard(), Wait: time.Millisecond}
+}
+
+func newTestIssuer(policy *api.SCEPPolicy) *scepIssuer {
+	return &scepIssuer{
+		ref:       cmmeta.ObjectReference{Group: api.GroupVersion.Group, Kind: "ClusterAdcsIssuer", Name: "adcs"},
+		namespace: testNamespace,
+		policy:    policy,
+	}
+}
+
+func newPasswordSecret(name, password string, labels map[string]string) *core.Secret {
+	return &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: testNamespace, Name: name, Labels: labels},
+		Data:       map[string][]byte{challengePasswordKey: []byte(password)},
+	}
+}
+
+func TestCheckChallenge(t *testing.T) {
+	key := testutil.NewRSAKey(t)
+	csr := func(password string) *x509.CertificateRequest {
+		parsed, err := x509.ParseCertificateRequest(newChallengeCSR(t, "device-1", key, password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	oneTime := map[string]string{api.SCEPChallengeLabel: "phones"}
+	s := newTestServer(t,
+		newPasswordSecret("static", "static-secret", nil),
+		newPasswordSecret("one-time-1", "one-time-secret", oneTime),
+		newPasswordSecret("other-label", "other-secret", map[string]string{api.SCEPChallengeLabel: "printers"}),
+	)
+	static := newTestIssuer(&api.SCEPPolicy{ChallengePasswordSecretRefs: []string{"missing", "static"}})
+	both := newTestIssuer(&api.SCEPPolicy{ChallengePasswordSecretRefs: []string{"static"}, OneTimeChallenges: true})
+
+	tests := []struct {
+		name     string
+		issuer   *scepIssuer
+		password string
+		ok       bool
+	}{
+		{"static", static, "static-secret", true},
+		{"static re-used", static, "static-secret", true},
+		{"wrong password", static, "wrong", false},
+		{"no password", static, "", false},
+		{"one-time not enabled", static, "one-time-secret", false},
+		{"one-time", both, "one-time-secret", true},
+		{"one-time re-used", both, "one-time-secret", false},
+		{"one-time of another label", both, "other-secret", false},
+		{"static with one-time enabled", both, "static-secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkChallenge(context.Background(), "phones", tt.issuer, csr(tt.password))
+			if (err == nil) != tt.ok {
+				t.Errorf("expected ok %t, got %v", tt.ok, err)
+			}
+		})
+	}
+
+	err := s.Client.Get(context.Background(), client.ObjectKey{Namespace: testNamespace, Name: "one-time-1"}, new(core.Secret))
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected used one-time password Secret deleted, got %v", err)
+	}
+}
+
+func TestEnroll(t *testing.T) {
+	ra := newTestRA(t)
+	key := testutil.NewRSAKey(t)
+	signer := newSelfSigned(t, "device-1", key)
+	s := newTestServer(t, newPasswordSecret("one-time-1", "one-time-secret", map[string]string{api.SCEPChallengeLabel: "phones"}))
+	issuer := newTestIssuer(&api.SCEPPolicy{OneTimeChallenges: true})
+	der := newTestMessage(t, ra, signer, key, messageTypePKCSReq, "transaction-1", newChallengeCSR(t, "device-1", key, "one-time-secret"))
+
+	// Re-sent PKCSReq finds the request without the used up password
+	for i := 0; i < 2; i++ {
+		msg, err := parseMessage(der, ra)
+		if err != nil {
+			t.Fatal(err)
+		}
+		status, info, _ := s.enroll(context.Background(), s.Log, "phones", issuer, msg)
+		if status != statusPending {
+			t.Fatalf("attempt %d: expected pending, got %s (%s)", i, status, info)
+		}
+	}
+	ar := new(api.AdcsRequest)
+	if err := s.Client.Get(context.Background(), requestKey(issuer, "transaction-1"), ar); err != nil {
+		t.Fatal(err)
+	}
+	if ar.Labels[api.SCEPLabel] != "phones" || ar.Spec.IssuerRef != issuer.ref {
+		t.Errorf("unexpected AdcsRequest %v", ar.ObjectMeta)
+	}
+
+	// Another transaction can't use the password again
+	other := newTestMessage(t, ra, signer, key, messageTypePKCSReq, "transaction-2", newChallengeCSR(t, "device-1", key, "one-time-secret"))
+	msg, err := parseMessage(other, ra)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, info, _ := s.enroll(context.Background(), s.Log, "phones", issuer, msg); status != statusFailure || info != failBadRequest {
+		t.Errorf("expected failure, got %s (%s)", status, info)
+	}
+}
+
+func TestCertInitial(t *testing.T) {
+	key := testutil.NewRSAKey(t)
+	signer := newSelfSigned(t, "device-1", key)
+	issuer := newTestIssuer(&api.SCEPPolicy{})
+	csr := newChallengeCSR(t, "device-1", key, "secret")
+	issued := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signer.Raw})
+
+	newRequest := func(state api.State) *api.AdcsRequest {
+		key := requestKey(issuer, "transaction-1")
+		return &api.AdcsRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   key.Namespace,
+				Name:        key.Name,
+				Annotations: map[string]string{api.SCEPTransactionAnnotation: "transaction-1"},
+			},
+			Spec:   api.AdcsRequestSpec{CSRPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})},
+			Status: api.AdcsRequestStatus{State: state, Id: "17", Certificate: issued},
+		}
+	}
+	message := func(transactionID string, signerKey bool) *pkiMessage {
+		msg := &pkiMessage{messageType: messageTypeGetCertInitial, transactionID: transactionID, signer: signer}
+		if !signerKey {
+			other := testutil.NewRSAKey(t)
+			msg.signer = newSelfSigned(t, "device-1", other)
+		}
+		return msg
+	}
+
+	t.Run("pending", func(t *testing.T) {
+		s := newTestServer(t, newRequest(api.Pending))
+		if status, info, _ := s.certInitial(context.Background(), s.Log, issuer, message("transaction-1", true)); status != statusPending {
+			t.Fatalf("expected pending, got %s (%s)", status, info)
+		}
+		ar := new(api.AdcsRequest)
+		if err := s.Client.Get(context.Background(), requestKey(issuer, "transaction-1"), ar); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ar.Annotations[api.CheckNowAnnotation]; !ok {
+			t.Errorf("expected %s annotation", api.CheckNowAnnotation)
+		}
+	})
+	t.Run("issued", func(t *testing.T) {
+		s := newTestServer(t, newRequest(api.Ready))
+		status, info, content := s.certInitial(context.Background(), s.Log, issuer, message("transaction-1", true))
+		if status != statusSuccess || len(content) == 0 {
+			t.Fatalf("expected success with certificate, got %s (%s)", status, info)
+		}
+		err := s.Client.Get(context.Background(), requestKey(issuer, "transaction-1"), new(api.AdcsRequest))
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("expected completed AdcsRequest deleted, got %v", err)
+		}
+	})
+	t.Run("unknown transaction", func(t *testing.T) {
+		s := newTestServer(t, newRequest(api.Pending))
+		if status, info, _ := s.certInitial(context.Background(), s.Log, issuer, message("transaction-2", true)); info != failBadCertID {
+			t.Errorf("expected badCertID, got %s (%s)", status, info)
+		}
+	})
+	t.Run("other key", func(t *testing.T) {
+		s := newTestServer(t, newRequest(api.Pending))
+		if status, info, _ := s.certInitial(context.Background(), s.Log, issuer, message("transaction-1", false)); info != failBadCertID {
+			t.Errorf("expected badCertID, got %s (%s)", status, info)
+		}
+	})
+}
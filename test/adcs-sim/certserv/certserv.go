@@ -5,6 +5,8 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
 	"encoding/pem"
 	"flag"
 	"fmt"
@@ -13,14 +15,18 @@ import (
 	"math/big"
 	mrand "math/rand"
 	"net/http"
+	"net/url"
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
 	"sync/atomic"
 	"text/template"
 	"time"
+	"unicode/utf16"
 
 	"github.com/jetstack/cert-manager/pkg/util/pki"
+	"go.mozilla.org/pkcs7"
 )
 
 type Certserv struct {
@@ -40,9 +46,29 @@ var (
 	tmplCertnewCer   = caWorkDir + "/templates/certnew.cer.tmpl"
 	tmplCertCaRc     = caWorkDir + "/templates/certcarc.asp.tmpl"
 	tmplCertFnsh     = caWorkDir + "/templates/certfnsh.asp.tmpl"
+	tmplCertIssued   = caWorkDir + "/templates/certissued.asp.tmpl"
 	tmplUnauthorized = caWorkDir + "/templates/unauth.tmpl"
 )
 
+const (
+	sidUrlPrefix = "tag:microsoft.com,2022-09-14:sid:"
+)
+
+var (
+	// szOID_ENROLLMENT_NAME_VALUE_PAIR
+	oidEnrollmentNameValuePair = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 13, 2, 1}
+	// szOID_ENROLLMENT_AGENT (Certificate Request Agent usage)
+	oidCertificateRequestAgent = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 20, 2, 1}
+	// szOID_RENEWAL_CERTIFICATE
+	oidRenewalCertificate = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 13, 1}
+)
+
+// EnrollmentNameValuePair ::= SEQUENCE { name BMPString, value BMPString }
+type enrollmentNameValuePair struct {
+	Name  asn1.RawValue
+	Value asn1.RawValue
+}
+
 type SimOrders struct {
 	reject       bool
 	delay        time.Duration
@@ -118,7 +144,7 @@ func (c *Certserv) HandleCertnewCer(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	fileInfo, _ := os.Lstat(csrFileName)
-	csr, err := decodeCertRequest(string(file))
+	csr, err := c.decodeCertRequest(string(file))
 	if err != nil {
 		msg := fmt.Sprintf("Cannot decode CSR %s.", reqId[0])
 		res := Resp{msg, "Error"}
@@ -136,6 +162,14 @@ func (c *Certserv) HandleCertnewCer(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if _, err := os.Stat(fmt.Sprintf("%s/%s.denied", caDir, reqId[0])); err == nil {
+		// Request denied with certdeny.asp
+		fmt.Printf("Certificate request %s denied.\n", reqId[0])
+		res := Resp{"Denied by CA administrator", "The request was denied by a certificate manager or CA administrator. 0x80094014 (-2146877420 CERTSRV_E_ADMIN_DENIED_REQUEST)"}
+		tmpl.Execute(w, res)
+		return
+	}
+
 	issueTime := fileInfo.ModTime().Add(orders.delay)
 	if issueTime.After(time.Now()) {
 		// Need to wait. Respond with 'pending'.
@@ -153,8 +187,8 @@ func (c *Certserv) HandleCertnewCer(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	// Generate the cert and send it back
-
-	certPem, err := c.CreateCertificatePem(csr)
+	attrib, _ := ioutil.ReadFile(fmt.Sprintf("%s/%s.attr", caDir, reqId[0]))
+	certPem, err := c.CreateCertificatePemWithAttributes(csr, parseCertAttrib(string(attrib)))
 	if err != nil {
 		// Error
 		res := Resp{"Cannot create certificate", "Error"}
@@ -219,7 +253,7 @@ func (c *Certserv) HandleCertfnshAsp(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	csr, err := decodeCertRequest(bodyCsr[0])
+	csr, err := c.decodeCertRequest(bodyCsr[0])
 	if err != nil {
 		m := "Cannot decode CSR"
 		fmt.Printf("%s: %s\n", m, err.Error())
@@ -243,6 +277,9 @@ func (c *Certserv) HandleCertfnshAsp(w http.ResponseWriter, req *http.Request) {
 		certId := atomic.AddUint64(&c.currentID, 1)
 		csrFileName := fmt.Sprintf("ca/%d.csr", certId)
 		err = ioutil.WriteFile(csrFileName, []byte(bodyCsr[0]), 0644)
+		if err == nil {
+			err = ioutil.WriteFile(fmt.Sprintf("ca/%d.attr", certId), []byte(req.PostForm.Get("CertAttrib")), 0644)
+		}
 		if err != nil {
 			m := "Cannot write CSR file"
 			fmt.Printf("%s: %s\n", m, err.Error())
@@ -259,19 +296,86 @@ func (c *Certserv) HandleCertfnshAsp(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// No delay nor rejection, so send the certificate immediately
-	certPem, err := c.CreateCertificatePem(csr)
+	certPem, err := c.CreateCertificatePemWithAttributes(csr, parseCertAttrib(req.PostForm.Get("CertAttrib")))
 	if err != nil {
 		m := "Cannot create certificate"
 		fmt.Printf("%s: %s\n", m, err.Error())
 		respondError(w, m)
 		return
 	}
-	fmt.Printf("Sending certificate:\n%s\n", certPem)
-	w.Header().Add("Content-Type", "application/pkix-cert")
-	fmt.Fprintf(w, "%s", certPem)
+	// Keep the issued certificate so it can be retrieved later with its request ID
+	certId := atomic.AddUint64(&c.currentID, 1)
+	err = ioutil.WriteFile(fmt.Sprintf("%s/%d.csr", caDir, certId), []byte(bodyCsr[0]), 0644)
+	if err == nil {
+		err = ioutil.WriteFile(fmt.Sprintf("%s/%d.pem", caDir, certId), certPem, 0644)
+	}
+	if err != nil {
+		m := "Cannot write certificate file"
+		fmt.Printf("%s: %s\n", m, err.Error())
+		respondError(w, m)
+		return
+	}
+	// Like ADCS, respond with the 'Certificate Issued' page linking to the certificate
+	fmt.Printf("Certificate %d issued:\n%s\n", certId, certPem)
+	tmpl, _ := template.ParseFiles(tmplCertIssued)
+	type Resp struct {
+		ReqID string
+	}
+	tmpl.Execute(w, Resp{fmt.Sprintf("%d", certId)})
+}
+
+// Deny pending request (simulates the certdeny.asp page calling ICertAdmin::DenyRequest).
+// Issued requests can't be denied.
+func (c *Certserv) HandleCertdenyAsp(w http.ResponseWriter, req *http.Request) {
+	fmt.Printf("HandleCertdenyAsp\n")
+	// Same version as config/certsrv/certdeny.asp
+	w.Header().Set("X-Certdeny-Version", "2")
+	if req.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if req.Header.Get("X-Certdeny-Request") != "1" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	err := req.ParseForm()
+	if err != nil {
+		respondError(w, "Cannot parse parameters")
+		return
+	}
+	reqId := req.PostForm.Get("ReqID")
+	if _, err := strconv.ParseUint(reqId, 10, 64); err != nil {
+		respondError(w, "Invalid ReqID")
+		return
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/%s.csr", caDir, reqId)); err != nil {
+		respondError(w, fmt.Sprintf("Request %s not found", reqId))
+		return
+	}
+	if _, err := os.Stat(fmt.Sprintf("%s/%s.pem", caDir, reqId)); err == nil {
+		respondError(w, fmt.Sprintf("Request %s already issued", reqId))
+		return
+	}
+	err = ioutil.WriteFile(fmt.Sprintf("%s/%s.denied", caDir, reqId), []byte{}, 0644)
+	if err != nil {
+		m := "Cannot write request file"
+		fmt.Printf("%s: %s\n", m, err.Error())
+		respondError(w, m)
+		return
+	}
+	fmt.Printf("Request %s denied\n", reqId)
+	fmt.Fprintf(w, "Request %s denied\n", reqId)
 }
 
 func (c *Certserv) CreateCertificatePem(csr *x509.CertificateRequest) ([]byte, error) {
+	return c.CreateCertificatePemWithAttributes(csr, nil)
+}
+
+// Create certificate using request attributes (CertAttrib).
+// The 'san' attribute is honored as if EDITF_ATTRIBUTESUBJECTALTNAME2 was set on the CA.
+// A strong mapping SID URL in 'san' is also added as the SID security extension.
+// The 'SubCA' template issues CA certificates with path length 0.
+func (c *Certserv) CreateCertificatePemWithAttributes(csr *x509.CertificateRequest, attribs map[string]string) ([]byte, error) {
 
 	keyUsages := x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
 	// create client certificate template
@@ -295,6 +399,22 @@ func (c *Certserv) CreateCertificatePem(csr *x509.CertificateRequest) ([]byte, e
 		URIs:           csr.URIs,
 	}
 
+	if err := applySanAttribute(certTemplate, attribs["san"]); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(attribs["certificatetemplate"], "SubCA") {
+		// Subordinate CA that can issue end-entity certificates only
+		certTemplate.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+		certTemplate.ExtKeyUsage = nil
+		certTemplate.BasicConstraintsValid = true
+		certTemplate.IsCA = true
+		certTemplate.MaxPathLenZero = true
+	}
+	if validity := validityPeriod(attribs["validityperiod"], attribs["validityperiodunits"]); validity > 0 {
+		// As if EDITF_ATTRIBUTEENDDATE was set on the CA
+		certTemplate.NotAfter = certTemplate.NotBefore.Add(validity)
+	}
+
 	derBytes, err := x509.CreateCertificate(rand.Reader, certTemplate, c.caCert, csr.PublicKey, c.caKey)
 	if err != nil {
 		return nil, fmt.Errorf("error creating x509 certificate: %s", err.Error())
@@ -332,7 +452,7 @@ func (c *Certserv) CreateCertificateChainPem(csr *x509.CertificateRequest) ([]by
 	return bytes, nil
 }
 
-func decodeCertRequest(data string) (*x509.CertificateRequest, error) {
+func (c *Certserv) decodeCertRequest(data string) (*x509.CertificateRequest, error) {
 	block, _ := pem.Decode([]byte(data))
 	var m string
 	if block == nil {
@@ -341,8 +461,20 @@ func decodeCertRequest(data string) (*x509.CertificateRequest, error) {
 		return nil, fmt.Errorf(e)
 	}
 
+	der := block.Bytes
+	if block.Type == "PKCS7" {
+		// CSR signed by an enrollment agent
+		content, err := c.verifySignedRequest(der)
+		if err != nil {
+			m = "Signed request error"
+			e := fmt.Sprintf("%s: %s\n", m, err.Error())
+			return nil, fmt.Errorf(e)
+		}
+		der = content
+	}
+
 	// parse the CSR
-	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	csr, err := x509.ParseCertificateRequest(der)
 	if err != nil {
 		m = "Cannot parse CSR"
 		e := fmt.Sprintf("%s: %s\n", m, err.Error())
@@ -357,6 +489,55 @@ func decodeCertRequest(data string) (*x509.CertificateRequest, error) {
 	return csr, nil
 }
 
+// Verify PKCS#7 signed request and return the CSR it contains. Renewals must be signed
+// by the renewed certificate. Requests with the requester name must be signed by
+// an enrollment agent certificate. Both must be issued by the simulator.
+func (c *Certserv) verifySignedRequest(der []byte) ([]byte, error) {
+	p7, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS#7: %s", err.Error())
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid signature: %s", err.Error())
+	}
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return nil, fmt.Errorf("single signer required")
+	}
+	if err := signer.CheckSignatureFrom(c.caCert); err != nil {
+		return nil, fmt.Errorf("signer %s not issued by the CA: %s", signer.Subject, err.Error())
+	}
+
+	var renewal asn1.RawValue
+	if err := p7.UnmarshalSignedAttribute(oidRenewalCertificate, &renewal); err == nil {
+		if !bytes.Equal(renewal.FullBytes, signer.Raw) {
+			return nil, fmt.Errorf("renewal not signed by the renewed certificate")
+		}
+		if now := time.Now(); now.Before(signer.NotBefore) || now.After(signer.NotAfter) {
+			return nil, fmt.Errorf("renewed certificate %s not valid", signer.SerialNumber)
+		}
+		fmt.Printf("Renewal of certificate %s (%s)\n", signer.SerialNumber, signer.Subject)
+		return p7.Content, nil
+	}
+
+	var pair enrollmentNameValuePair
+	if err := p7.UnmarshalSignedAttribute(oidEnrollmentNameValuePair, &pair); err != nil {
+		return nil, fmt.Errorf("requester name missing: %s", err.Error())
+	}
+	if !strings.EqualFold(decodeBMPString(pair.Name), "RequesterName") {
+		return nil, fmt.Errorf("unexpected name value pair %s", decodeBMPString(pair.Name))
+	}
+	agent := false
+	for _, usage := range signer.UnknownExtKeyUsage {
+		agent = agent || usage.Equal(oidCertificateRequestAgent)
+	}
+	if !agent {
+		return nil, fmt.Errorf("signer %s is not an enrollment agent", signer.Subject)
+	}
+	fmt.Printf("Request signed by enrollment agent %s on behalf of %s\n", signer.Subject, decodeBMPString(pair.Value))
+	return p7.Content, nil
+}
+
 func getSimOrders(names []string) *SimOrders {
 	orders := &SimOrders{false, 0, false}
 	exp := regexp.MustCompile(`^([a-z0-9\-]+)\.(([a-z0-9\-]+)\.)?sim$`)
@@ -382,6 +563,88 @@ func getSimOrders(names []string) *SimOrders {
 	return orders
 }
 
+// Parse CertAttrib 'name:value' lines. Names are returned in lower case.
+func parseCertAttrib(certAttrib string) map[string]string {
+	attribs := map[string]string{}
+	for _, line := range strings.Split(certAttrib, "\n") {
+		nv := strings.SplitN(strings.TrimSpace(line), ":", 2)
+		if len(nv) == 2 {
+			attribs[strings.ToLower(nv[0])] = nv[1]
+		}
+	}
+	fmt.Printf("Attributes: %v\n", attribs)
+	return attribs
+}
+
+func applySanAttribute(cert *x509.Certificate, san string) error {
+	if san == "" {
+		return nil
+	}
+	for _, name := range strings.Split(san, "&") {
+		tv := strings.SplitN(name, "=", 2)
+		if len(tv) != 2 {
+			continue
+		}
+		switch strings.ToLower(tv[0]) {
+		case "dns":
+			cert.DNSNames = append(cert.DNSNames, tv[1])
+		case "email":
+			cert.EmailAddresses = append(cert.EmailAddresses, tv[1])
+		case "url":
+			u, err := url.Parse(tv[1])
+			if err != nil {
+				return fmt.Errorf("Invalid URL in SAN attribute: %s", err.Error())
+			}
+			cert.URIs = append(cert.URIs, u)
+			if strings.HasPrefix(tv[1], sidUrlPrefix) {
+				ext, err := sidExtension(strings.TrimPrefix(tv[1], sidUrlPrefix))
+				if err != nil {
+					return err
+				}
+				cert.ExtraExtensions = append(cert.ExtraExtensions, ext)
+			}
+		}
+	}
+	return nil
+}
+
+func validityPeriod(period string, units string) time.Duration {
+	n, err := strconv.Atoi(units)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	switch strings.ToLower(period) {
+	case "hours":
+		return time.Duration(n) * time.Hour
+	case "days":
+		return time.Duration(n) * 24 * time.Hour
+	case "weeks":
+		return time.Duration(n) * 7 * 24 * time.Hour
+	case "months":
+		return time.Duration(n) * 30 * 24 * time.Hour
+	case "years":
+		return time.Duration(n) * 365 * 24 * time.Hour
+	}
+	return 0
+}
+
+// Build szOID_NTDS_CA_SECURITY_EXT extension with the SID
+func sidExtension(sid string) (pkix.Extension, error) {
+	type otherName struct {
+		TypeID asn1.ObjectIdentifier
+		Value  []byte `asn1:"explicit,tag:0"`
+	}
+	name, err := asn1.MarshalWithParams(otherName{asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 25, 2, 1}, []byte(sid)}, "tag:0")
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	value, err := asn1.Marshal([]asn1.RawValue{{FullBytes: name}})
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 25, 2}, Value: value}, nil
+}
+
 func respondError(w http.ResponseWriter, text string) {
 	w.WriteHeader(http.StatusBadRequest)
 	fmt.Fprintf(w, "%s\n", text)
@@ -429,3 +692,11 @@ func (c *Certserv) initRootCert() error {
 	fmt.Printf("Startign with id = %d\n", c.currentID)
 	return nil
 }
+
+func decodeBMPString(v asn1.RawValue) string {
+	var chars []uint16
+	for i := 0; i+1 < len(v.Bytes); i += 2 {
+		chars = append(chars, uint16(v.Bytes[i])<<8|uint16(v.Bytes[i+1]))
+	}
+	return string(utf16.Decode(chars))
+}
@@ -59,6 +59,7 @@ func main() {
 	http.HandleFunc("/certnew.p7b", certserv.HandleCertnewP7b)
 	http.HandleFunc("/certcarc.asp", certserv.HandleCertcarcAsp)
 	http.HandleFunc("/certfnsh.asp", certserv.HandleCertfnshAsp)
+	http.HandleFunc("/certdeny.asp", certserv.HandleCertdenyAsp)
 	log.Fatal(http.ListenAndServeTLS(fmt.Sprintf(":%d", *port), serverPem, serverKey, nil))
 }
 